@@ -0,0 +1,49 @@
+// Resolve the logged in user for REST API requests
+package middlewares
+
+import (
+	"strings"
+	"github.com/segoldin/JobWizard/job_wizard/data"
+	"github.com/segoldin/JobWizard/job_wizard/dbaccess"
+	"github.com/labstack/echo/v4"
+)
+
+// Key under which the current user's email is stored in the echo context
+const currentUserKey = "current_user"
+
+// Require a valid bearer token in the Authorization header
+// The email of the user who owns the token is saved in the context
+// so handlers can retrieve it with CurrentUser
+func RequireAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token := BearerToken(c)
+		if token == "" {
+			response := data.New_error_response(data.UnauthorizedError("Missing bearer token - please log in"))
+			return c.JSON(response.Status, response)
+		}
+		user_email, err := dbaccess.GetStore().GetSessionUser(token)
+		if err != nil {
+			response := data.New_error_response(err)
+			return c.JSON(response.Status, response)
+		}
+		c.Set(currentUserKey, user_email)
+		return next(c)
+	}
+}
+
+// Return the token from an "Authorization: Bearer <token>" header
+// or an empty string if there is none
+func BearerToken(c echo.Context) string {
+	header := c.Request().Header.Get(echo.HeaderAuthorization)
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Return the email of the user resolved by RequireAuth
+func CurrentUser(c echo.Context) string {
+	user_email, _ := c.Get(currentUserKey).(string)
+	return user_email
+}
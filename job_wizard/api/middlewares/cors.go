@@ -13,6 +13,6 @@ func InitCorsMiddleware(e *echo.Echo) {
 			"http://localhost:3000", "http://localhost:8080", "http://localhost:8888", "http://localhost:80"},
 		AllowCredentials: true,
 		AllowMethods:     []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete},
-		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept},
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
 	}))
 }
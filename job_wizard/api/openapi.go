@@ -0,0 +1,468 @@
+package api
+
+// This module builds the OpenAPI 3 description of the REST API
+// Each endpoint registered in ApplicationPrivateRoute has an entry in
+// apiDocs giving its parameters, request body and response. The schemas
+// for the data package types are generated from their json tags, so the
+// document stays in step with the structures the handlers actually use.
+// The document is served at /api/openapi.json and shown at /api/docs
+
+import (
+	_ "embed"
+	"net/http"
+	"reflect"
+	"strings"
+	"github.com/segoldin/JobWizard/job_wizard/data"
+	"github.com/labstack/echo/v4"
+)
+
+const apiVersion = "1.0.0"
+
+// Prefix of the group the routes are registered in
+const apiPrefix = "/api"
+
+//go:embed docs.html
+var docsPage string
+
+// A query parameter or form field for one endpoint
+type paramDoc struct {
+	name        string
+	kind        string // "string", "integer", "number" or "boolean", or "file" for an uploaded file
+	description string
+	required    bool
+	values      []string // allowed values, if limited
+}
+
+// A response that is an object of named string fields, for the
+// endpoints that answer with an echo.Map
+type stringFields []string
+
+// A response that is an object of named integer fields
+type countFields []string
+
+// A response that is an HTML page rather than JSON
+type htmlPage struct{}
+
+// A response that is a file download, in whatever type it was uploaded as
+type download struct{}
+
+// A response that is one page of a listing of the given type
+type listing struct {
+	item interface{}
+}
+
+// The documentation for one endpoint
+// body and response are zero values of the types involved, or nil
+// form lists the fields of a multipart/form-data body, which can be
+// sent instead of a JSON body if there is one
+type routeDoc struct {
+	method   string
+	path     string
+	summary  string
+	auth     bool
+	query    []paramDoc
+	body     interface{}
+	form     []paramDoc
+	response interface{}
+}
+
+// Query parameters for paging a listing sorted by one of sort_fields
+func pageParams(sort_fields []string) []paramDoc {
+	return []paramDoc{
+		{name: "limit", kind: "integer", description: "Maximum number of results, 1 to 500 (default 50)"},
+		{name: "offset", kind: "integer", description: "Number of results to skip"},
+		{name: "sort", kind: "string", description: "Field to sort by", values: sort_fields},
+		{name: "order", kind: "string", description: "Sort order", values: []string{"asc", "desc"}},
+	}
+}
+
+var jobIdParam = paramDoc{name: "job_id", kind: "string", description: "Job ID, such as 00003", required: true}
+var orgIdParam = paramDoc{name: "org_id", kind: "string", description: "Organization ID, such as 00001", required: true}
+var searchIdParam = paramDoc{name: "search_id", kind: "string", description: "Saved search ID, such as 00001", required: true}
+var webhookIdParam = paramDoc{name: "webhook_id", kind: "string", description: "Webhook ID, such as 00001", required: true}
+var applicantParam = paramDoc{name: "applicant", kind: "string", description: "Email of the applicant; the applicant can leave it out"}
+var resumeField = paramDoc{name: "resume", kind: "file", description: "PDF or DOCX file, at most 5 MB"}
+
+// Every endpoint provided, in the same order as ApplicationPrivateRoute
+var apiDocs = []routeDoc{
+	{method: http.MethodPost, path: "/register", summary: "Register a new user",
+		body: data.User_info{}, response: stringFields{"registered"}},
+	{method: http.MethodPost, path: "/login", summary: "Log in and receive a bearer token",
+		body: data.Credentials{}, response: stringFields{"email", "token", "expires"}},
+	{method: http.MethodPost, path: "/logout", summary: "Invalidate the bearer token used for this request", auth: true,
+		response: stringFields{"logged_out"}},
+	{method: http.MethodGet, path: "/user", summary: "Get the logged in user's profile", auth: true,
+		response: data.User_info{}},
+	{method: http.MethodPut, path: "/user", summary: "Change the logged in user's profile; empty or zero fields are left alone. A new password logs the user out", auth: true,
+		body: data.User_info{}, response: stringFields{"updated"}},
+	{method: http.MethodDelete, path: "/user", summary: "Delete the logged in user's account and applications; their organization jobs pass to an owner and their other jobs are closed. The only owner of an organization must make another member an owner first", auth: true,
+		response: stringFields{"deleted"}},
+	{method: http.MethodPut, path: "/user/resume", summary: "Upload the logged in user's default resume, sent with applications made without one", auth: true,
+		form: []paramDoc{{name: resumeField.name, kind: resumeField.kind, description: resumeField.description, required: true}},
+		response: stringFields{"updated", "resume"}},
+	{method: http.MethodGet, path: "/user/resume", summary: "Download the logged in user's default resume", auth: true,
+		response: download{}},
+	{method: http.MethodDelete, path: "/user/resume", summary: "Remove the logged in user's default resume; applications keep the resume they were sent with", auth: true,
+		response: stringFields{"removed_resume"}},
+	{method: http.MethodGet, path: "/user/notifications", summary: "The logged in user's notification preferences: the language of their emails and which kinds they are sent", auth: true,
+		response: data.Notification_preferences{}},
+	{method: http.MethodPut, path: "/user/notifications", summary: "Change the logged in user's notification preferences; only the fields given are changed. language is en or th", auth: true,
+		body: data.Notification_changes{}, response: data.Notification_preferences{}},
+	{method: http.MethodPost, path: "/job/create", summary: "Create a job owned by the logged in user; give org_id to post it for an organization the user belongs to", auth: true,
+		body: data.Job_info{}, response: stringFields{"created_job"}},
+	{method: http.MethodGet, path: "/search", summary: "Search for jobs", auth: true,
+		query: append([]paramDoc{
+			{name: "posted_from", kind: "string", description: "Only jobs posted on or after this date (YYYY-MM-DD, in the server's time zone) or RFC3339 time"},
+			{name: "posted_to", kind: "string", description: "Only jobs posted on or before this date (YYYY-MM-DD, the whole day) or RFC3339 time"},
+			{name: "posted", kind: "string", description: "Old name for posted_from"},
+			{name: "experience", kind: "integer", description: "Only jobs needing at most this many years of experience"},
+			{name: "education", kind: "integer", description: "Only jobs needing at most this education level, 0 to 4"},
+			{name: "salary", kind: "integer", description: "Only jobs paying at least this monthly salary"},
+			{name: "keyword", kind: "string", description: "Words to find in the title or description; \"phrases\" and prefix* allowed"},
+			{name: "include_archived", kind: "boolean", description: "Also return archived and expired jobs (default false)"},
+			{name: "org_id", kind: "string", description: "Only jobs offered by this organization"},
+			{name: "organization", kind: "string", description: "Only jobs offered by organizations whose name contains this"},
+			{name: "location", kind: "string", description: "Only jobs whose city or province contains this"},
+			{name: "work_arrangement", kind: "string", description: "Only jobs with this work arrangement", values: data.Work_arrangements[:]},
+			{name: "employment_type", kind: "string", description: "Only jobs with this employment type", values: data.Employment_types[:]},
+			{name: "latitude", kind: "number", description: "Latitude of the centre of a radius search"},
+			{name: "longitude", kind: "number", description: "Longitude of the centre of a radius search"},
+			{name: "radius_km", kind: "number", description: "Only jobs within this many km of latitude and longitude, at most 1000"},
+			{name: "skills", kind: "string", description: "Comma-separated skills; only jobs that list all of them"},
+		}, pageParams(data.Job_sort_fields)...),
+		response: listing{data.Job_summary{}}},
+	{method: http.MethodGet, path: "/search/detail", summary: "Get the details of a job", auth: true,
+		query: []paramDoc{jobIdParam}, response: data.Job_info{}},
+	{method: http.MethodGet, path: "/search/offered", summary: "List the jobs created by the logged in user", auth: true,
+		query: pageParams(data.Job_sort_fields), response: listing{data.Job_summary{}}},
+	{method: http.MethodGet, path: "/search/applied", summary: "List the logged in user's applications", auth: true,
+		query: pageParams(data.Application_sort_fields), response: listing{data.Application_summary{}}},
+	{method: http.MethodGet, path: "/search/candidates", summary: "List the applicants for a job created by the logged in user or offered by their organization", auth: true,
+		query: append([]paramDoc{jobIdParam,
+			{name: "qualified", kind: "boolean", description: "Only candidates who meet all the job's requirements (default false)"},
+		}, pageParams(data.Candidate_sort_fields)...),
+		response: listing{data.Candidate{}}},
+	{method: http.MethodPut, path: "/job/modify", summary: "Change a job created by the logged in user or offered by their organization; only the fields given are changed, and may be set to 0, \"\" or false. Set is_open to true to reopen a job that was closed without a hire", auth: true,
+		body: data.Job_changes{}, response: stringFields{"modified_job"}},
+	{method: http.MethodPost, path: "/job/archive", summary: "Archive a job created by the logged in user: it is closed and left out of searches", auth: true,
+		query: []paramDoc{jobIdParam}, response: stringFields{"archived_job"}},
+	{method: http.MethodDelete, path: "/job", summary: "Delete a job created by the logged in user; only possible if nobody has applied", auth: true,
+		query: []paramDoc{jobIdParam}, response: stringFields{"deleted_job"}},
+	{method: http.MethodPost, path: "/job/submit", summary: "Apply for a job, with a cover letter and resume if wanted; send a multipart form to upload the resume. Without one, the default resume is sent", auth: true,
+		body: data.Submission{},
+		form: []paramDoc{
+			{name: "job_id", kind: "string", description: "Job ID, such as 00003", required: true},
+			{name: "cover_letter", kind: "string", description: "Cover letter, at most 4000 characters"},
+			resumeField,
+		},
+		response: data.Submit_result{}},
+	{method: http.MethodDelete, path: "/job/submit", summary: "Withdraw an application for a job", auth: true,
+		query: []paramDoc{jobIdParam}, response: stringFields{"withdrawn_job"}},
+	{method: http.MethodGet, path: "/job/resume", summary: "Download the resume sent with an application for a job created by the logged in user or offered by their organization", auth: true,
+		query: []paramDoc{jobIdParam, {name: "email", kind: "string", description: "Email of the applicant", required: true}},
+		response: download{}},
+	{method: http.MethodPut, path: "/job/status", summary: "Move an application for the logged in user's job to a new status", auth: true,
+		body: data.Status_change{}, response: stringFields{"job_id", "email", "status"}},
+	{method: http.MethodPost, path: "/job/hire", summary: "Hire an applicant, which closes the job", auth: true,
+		body: data.Status_change{}, response: stringFields{"job_id", "email", "status"}},
+	{method: http.MethodPost, path: "/org/create", summary: "Create an organization owned by the logged in user", auth: true,
+		body: data.Organization{}, response: stringFields{"created_org"}},
+	{method: http.MethodGet, path: "/org", summary: "Get the profile and members of an organization", auth: true,
+		query: []paramDoc{orgIdParam}, response: data.Organization{}},
+	{method: http.MethodPut, path: "/org", summary: "Change an organization the logged in user owns; empty fields are left alone", auth: true,
+		body: data.Organization{}, response: stringFields{"updated_org"}},
+	{method: http.MethodGet, path: "/search/orgs", summary: "List organizations", auth: true,
+		query: append([]paramDoc{
+			{name: "keyword", kind: "string", description: "Part of the organization name"},
+			{name: "mine", kind: "boolean", description: "Only organizations the logged in user belongs to (default false)"},
+		}, pageParams(data.Organization_sort_fields)...),
+		response: listing{data.Organization{}}},
+	{method: http.MethodGet, path: "/skills", summary: "List the skills that jobs and users have given", auth: true,
+		query: append([]paramDoc{
+			{name: "keyword", kind: "string", description: "Part of the skill name"},
+		}, pageParams(data.Skill_sort_fields)...),
+		response: listing{data.Skill{}}},
+	{method: http.MethodPut, path: "/org/member", summary: "Add a member to an organization the logged in user owns, or change a member's role", auth: true,
+		body: data.Member_change{}, response: stringFields{"org_id", "email", "role"}},
+	{method: http.MethodDelete, path: "/org/member", summary: "Remove a member from an organization; owners can remove anybody, and members can leave", auth: true,
+		query: []paramDoc{orgIdParam, {name: "email", kind: "string", description: "Email of the member", required: true}},
+		response: stringFields{"org_id", "removed"}},
+	{method: http.MethodPost, path: "/saved-searches", summary: "Save a named search for the logged in user; the criteria are those of /search. Jobs posted later are its new matches", auth: true,
+		body: data.Saved_search{}, response: stringFields{"created_search"}},
+	{method: http.MethodGet, path: "/saved-searches", summary: "List the logged in user's saved searches, with the number of jobs posted since each was last run", auth: true,
+		query: pageParams(data.Saved_search_sort_fields), response: listing{data.Saved_search{}}},
+	{method: http.MethodDelete, path: "/saved-searches", summary: "Delete one of the logged in user's saved searches", auth: true,
+		query: []paramDoc{searchIdParam}, response: stringFields{"deleted_search"}},
+	{method: http.MethodGet, path: "/saved-searches/results", summary: "Run a saved search; its new matches are marked as seen", auth: true,
+		query: append([]paramDoc{searchIdParam}, pageParams(data.Job_sort_fields)...),
+		response: listing{data.Job_summary{}}},
+	{method: http.MethodGet, path: "/saved-searches/alerts", summary: "The logged in user's saved searches that have new matches, and the total number of new matches", auth: true,
+		response: data.Search_alerts{}},
+	{method: http.MethodPost, path: "/bookmarks", summary: "Bookmark a job for the logged in user; searches then flag it as bookmarked", auth: true,
+		query: []paramDoc{jobIdParam}, response: stringFields{"bookmarked"}},
+	{method: http.MethodDelete, path: "/bookmarks", summary: "Remove one of the logged in user's bookmarks", auth: true,
+		query: []paramDoc{jobIdParam}, response: stringFields{"unbookmarked"}},
+	{method: http.MethodGet, path: "/bookmarks", summary: "List the jobs the logged in user has bookmarked; filled jobs say when they were filled", auth: true,
+		query: pageParams(data.Bookmark_sort_fields), response: listing{data.Bookmark{}}},
+	{method: http.MethodPost, path: "/webhooks", summary: "Register a URL to be sent events on the logged in user's jobs, or on the jobs of an organization they own. Events: " +
+		strings.Join(data.Webhook_events[:], ", ") + ". Each event is POSTed to the URL as JSON with X-JobWizard-Event, X-JobWizard-Delivery and X-JobWizard-Signature headers; " +
+		"the signature is sha256= and the hex HMAC-SHA256 of the body, keyed with the secret, which is only returned here. " +
+		"A 2xx response counts as delivered; otherwise the delivery is retried with backoff, up to 8 attempts. " +
+		"Only public addresses are sent to", auth: true,
+		body: data.Webhook{}, response: data.Webhook{}},
+	{method: http.MethodGet, path: "/webhooks", summary: "List the logged in user's webhooks, without their secrets", auth: true,
+		query: pageParams(data.Webhook_sort_fields), response: listing{data.Webhook{}}},
+	{method: http.MethodDelete, path: "/webhooks", summary: "Delete one of the logged in user's webhooks and its deliveries", auth: true,
+		query: []paramDoc{webhookIdParam}, response: stringFields{"deleted_webhook"}},
+	{method: http.MethodGet, path: "/webhooks/deliveries", summary: "The delivery log of a webhook: each event sent, its status (pending, delivered or failed), attempts and last error", auth: true,
+		query: append([]paramDoc{webhookIdParam}, pageParams(data.Delivery_sort_fields)...),
+		response: listing{data.Webhook_delivery{}}},
+	{method: http.MethodPost, path: "/messages", summary: "Send a message in the thread of an application, as the applicant or the job's creator. " +
+		"The applicant can leave out applicant; the body is at most 2000 characters", auth: true,
+		body: data.Message{}, response: data.Message{}},
+	{method: http.MethodGet, path: "/messages", summary: "List the messages in the thread of an application, oldest first. Listing does not mark them read", auth: true,
+		query: append([]paramDoc{jobIdParam, applicantParam}, pageParams(data.Message_sort_fields)...),
+		response: listing{data.Message{}}},
+	{method: http.MethodPost, path: "/messages/read", summary: "Mark the messages sent to the logged in user in the thread of an application as read", auth: true,
+		query: []paramDoc{jobIdParam, applicantParam}, response: countFields{"marked_read"}},
+	{method: http.MethodGet, path: "/messages/unread", summary: "The number of messages sent to the logged in user that they have not read, in total and for each thread, newest first", auth: true,
+		response: data.Unread_counts{}},
+	{method: http.MethodGet, path: "/openapi.json", summary: "This OpenAPI document",
+		response: map[string]interface{}{}},
+	{method: http.MethodGet, path: "/docs", summary: "Browsable documentation generated from this document",
+		response: htmlPage{}},
+}
+
+/**************  Schema generation *************************/
+
+// Collects the component schemas while the document is built
+type schemaSet map[string]interface{}
+
+// Return the schema for a Go type. Named structs become components
+// and are returned as references
+func (schemas schemaSet) schemaFor(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemas.schemaFor(t.Elem())}
+	case reflect.Ptr:
+		schema := schemas.schemaFor(t.Elem())
+		schema["nullable"] = true
+		return schema
+	case reflect.Struct:
+		if _, found := schemas[t.Name()]; !found {
+			schemas[t.Name()] = nil // placeholder, in case the type refers to itself
+			schemas[t.Name()] = map[string]interface{}{
+				"type":       "object",
+				"properties": schemas.properties(t),
+			}
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	// interface{} - any value
+	return map[string]interface{}{}
+}
+
+// Return the properties of a struct, named by their json tags
+// The fields of embedded structs are included directly, as encoding/json does
+func (schemas schemaSet) properties(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			for name, schema := range schemas.properties(field.Type) {
+				properties[name] = schema
+			}
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemas.schemaFor(field.Type)
+	}
+	return properties
+}
+
+// Return the response schema for one of the response kinds in routeDoc
+func (schemas schemaSet) responseSchema(response interface{}) map[string]interface{} {
+	switch value := response.(type) {
+	case htmlPage:
+		return map[string]interface{}{"type": "string"}
+	case download:
+		return map[string]interface{}{"type": "string", "format": "binary"}
+	case stringFields:
+		properties := map[string]interface{}{}
+		for _, name := range value {
+			properties[name] = map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	case countFields:
+		properties := map[string]interface{}{}
+		for _, name := range value {
+			properties[name] = map[string]interface{}{"type": "integer"}
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	case listing:
+		// a Result_page whose results are the listed type
+		return map[string]interface{}{
+			"allOf": []interface{}{
+				schemas.schemaFor(reflect.TypeOf(data.Result_page{})),
+				map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"results": map[string]interface{}{
+							"type":  "array",
+							"items": schemas.schemaFor(reflect.TypeOf(value.item)),
+						},
+					},
+				},
+			},
+		}
+	}
+	return schemas.schemaFor(reflect.TypeOf(response))
+}
+
+// Build the OpenAPI operation object for one endpoint
+func (schemas schemaSet) operation(doc routeDoc) map[string]interface{} {
+	content_type := echo.MIMEApplicationJSON
+	if _, is_page := doc.response.(htmlPage); is_page {
+		content_type = echo.MIMETextHTML
+	}
+	if _, is_file := doc.response.(download); is_file {
+		content_type = echo.MIMEOctetStream
+	}
+	errorResponse := map[string]interface{}{
+		"description": "Error; the status depends on the error code",
+		"content": map[string]interface{}{
+			echo.MIMEApplicationJSON: map[string]interface{}{
+				"schema": schemas.schemaFor(reflect.TypeOf(data.Error_response{})),
+			},
+		},
+	}
+	operation := map[string]interface{}{
+		"summary": doc.summary,
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "OK",
+				"content": map[string]interface{}{
+					content_type: map[string]interface{}{"schema": schemas.responseSchema(doc.response)},
+				},
+			},
+			"default": errorResponse,
+		},
+	}
+	if doc.auth {
+		operation["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	}
+	if len(doc.query) > 0 {
+		var parameters []interface{}
+		for _, param := range doc.query {
+			schema := map[string]interface{}{"type": param.kind}
+			if len(param.values) > 0 {
+				schema["enum"] = param.values
+			}
+			parameters = append(parameters, map[string]interface{}{
+				"name":        param.name,
+				"in":          "query",
+				"description": param.description,
+				"required":    param.required,
+				"schema":      schema,
+			})
+		}
+		operation["parameters"] = parameters
+	}
+	body_content := map[string]interface{}{}
+	if doc.body != nil {
+		body_content[echo.MIMEApplicationJSON] = map[string]interface{}{
+			"schema": schemas.schemaFor(reflect.TypeOf(doc.body)),
+		}
+	}
+	if len(doc.form) > 0 {
+		body_content[echo.MIMEMultipartForm] = map[string]interface{}{"schema": formSchema(doc.form)}
+	}
+	if len(body_content) > 0 {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  body_content,
+		}
+	}
+	return operation
+}
+
+// Return the schema of a multipart form with the given fields
+func formSchema(fields []paramDoc) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for _, field := range fields {
+		schema := map[string]interface{}{"type": field.kind, "description": field.description}
+		if field.kind == "file" {
+			schema["type"] = "string"
+			schema["format"] = "binary"
+		}
+		properties[field.name] = schema
+		if field.required {
+			required = append(required, field.name)
+		}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+//**************** Exported Functions *******************************//
+
+// Build the OpenAPI document for all the endpoints in apiDocs
+func OpenAPISpec() map[string]interface{} {
+	schemas := schemaSet{}
+	paths := map[string]interface{}{}
+	for _, doc := range apiDocs {
+		path := apiPrefix + doc.path
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path].(map[string]interface{})[strings.ToLower(doc.method)] = schemas.operation(doc)
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "JobWizard API",
+			"version":     apiVersion,
+			"description": "Post, search and apply for jobs. Log in with /api/login and send the token as \"Authorization: Bearer <token>\".",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+/**************  Endpoint Implementations *************************/
+
+// Implementation for /openapi.json API endpoint
+func getOpenAPI(c echo.Context) error {
+	return c.JSON(http.StatusOK, OpenAPISpec())
+}
+
+// Implementation for /docs API endpoint
+// The page loads Redoc, which reads openapi.json
+func getDocs(c echo.Context) error {
+	return c.HTML(http.StatusOK, docsPage)
+}
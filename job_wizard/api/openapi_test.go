@@ -0,0 +1,77 @@
+package api
+
+// Tests for the OpenAPI document
+// Every registered route must be documented, and every documented
+// route must exist, so the document cannot drift from the server
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// fetch the document the way a client generator would
+func fetchSpec(t *testing.T) map[string]interface{} {
+	t.Helper()
+	rec := doRequest(http.MethodGet, "/api/openapi.json", nil, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("openapi.json returned %d", rec.Code)
+	}
+	var spec map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return spec
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	spec := fetchSpec(t)
+	paths := spec["paths"].(map[string]interface{})
+	routes := map[string]bool{}
+	for _, route := range testServer.Routes() {
+		method := strings.ToLower(route.Method)
+		routes[method+" "+route.Path] = true
+		operations, _ := paths[route.Path].(map[string]interface{})
+		if operations[method] == nil {
+			t.Errorf("route %s %s has no entry in the OpenAPI document", route.Method, route.Path)
+		}
+	}
+	for path, operations := range paths {
+		for method := range operations.(map[string]interface{}) {
+			if !routes[method+" "+path] {
+				t.Errorf("OpenAPI document describes %s %s, which is not a route", method, path)
+			}
+		}
+	}
+}
+
+func TestSpecReferencesResolve(t *testing.T) {
+	spec := fetchSpec(t)
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, name := range []string{"Job_info", "Job_summary", "Application_summary", "Candidate", "Result_page", "Error_response"} {
+		if schemas[name] == nil {
+			t.Errorf("missing schema for %s", name)
+		}
+	}
+	body, _ := json.Marshal(spec)
+	refs := regexp.MustCompile(`"#/components/schemas/(\w+)"`).FindAllStringSubmatch(string(body), -1)
+	for _, ref := range refs {
+		if schemas[ref[1]] == nil {
+			t.Errorf("reference to undefined schema %s", ref[1])
+		}
+	}
+	// embedded fields appear directly, as they do in the JSON
+	application := schemas["Application_summary"].(map[string]interface{})["properties"].(map[string]interface{})
+	if application["title"] == nil || application["history"] == nil {
+		t.Errorf("Application_summary should include the Job_summary fields, got %v", application)
+	}
+}
+
+func TestDocsPage(t *testing.T) {
+	rec := doRequest(http.MethodGet, "/api/docs", nil, "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "openapi.json") {
+		t.Errorf("docs page returned %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -11,27 +11,181 @@ package api
 // Created by Sally Goldin, 28 July 2025
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
     "github.com/segoldin/JobWizard/job_wizard/data"    
     "github.com/segoldin/JobWizard/job_wizard/dbaccess"
     "github.com/segoldin/JobWizard/job_wizard/helper"   	
+    "github.com/segoldin/JobWizard/job_wizard/api/middlewares"
 	"github.com/labstack/echo/v4"
 )
 
 // Endpoints provided
+// Each one must also be described in apiDocs in openapi.go
+// Everything except register, login and the documentation requires a bearer token;
+// the user is identified by the token, not by any request parameter
 func ApplicationPrivateRoute(_echo *echo.Group) {
+	auth := middlewares.RequireAuth
 	_echo.POST("/register", postRegisterUser)
-	_echo.POST("/job/create", postCreateJob)
-	_echo.GET("/search", getSearchJobs)
-	_echo.GET("/search/detail",getSearchJobDetail)
-	_echo.GET("/search/offered",getSearchJobsOffered)
-	_echo.GET("/search/applied",getSearchJobsApplied)
-	_echo.GET("/search/candidates",getSearchJobCandidates)				
-	_echo.PUT("/job/modify",putModifyJob)
-	_echo.POST("/job/submit", postSubmitJob)
+	_echo.POST("/login", postLogin)
+	_echo.POST("/logout", postLogout, auth)
+	_echo.GET("/user", getUserProfile, auth)
+	_echo.PUT("/user", putUserProfile, auth)
+	_echo.DELETE("/user", deleteUser, auth)
+	_echo.PUT("/user/resume", putUserResume, auth)
+	_echo.GET("/user/resume", getUserResume, auth)
+	_echo.DELETE("/user/resume", deleteUserResume, auth)
+	_echo.GET("/user/notifications", getNotificationPreferences, auth)
+	_echo.PUT("/user/notifications", putNotificationPreferences, auth)
+	_echo.POST("/job/create", postCreateJob, auth)
+	_echo.GET("/search", getSearchJobs, auth)
+	_echo.GET("/search/detail",getSearchJobDetail, auth)
+	_echo.GET("/search/offered",getSearchJobsOffered, auth)
+	_echo.GET("/search/applied",getSearchJobsApplied, auth)
+	_echo.GET("/search/candidates",getSearchJobCandidates, auth)				
+	_echo.PUT("/job/modify",putModifyJob, auth)
+	_echo.POST("/job/archive",postArchiveJob, auth)
+	_echo.DELETE("/job",deleteJob, auth)
+	_echo.POST("/job/submit", postSubmitJob, auth)
+	_echo.DELETE("/job/submit", deleteSubmitJob, auth)
+	_echo.GET("/job/resume", getApplicationResume, auth)
+	_echo.PUT("/job/status", putApplicationStatus, auth)
+	_echo.POST("/job/hire", postHireCandidate, auth)
+	_echo.POST("/org/create", postCreateOrganization, auth)
+	_echo.GET("/org", getOrganization, auth)
+	_echo.PUT("/org", putOrganization, auth)
+	_echo.GET("/search/orgs", getSearchOrganizations, auth)
+	_echo.PUT("/org/member", putOrganizationMember, auth)
+	_echo.DELETE("/org/member", deleteOrganizationMember, auth)
+	_echo.GET("/skills", getSkills, auth)
+	_echo.POST("/saved-searches", postSavedSearch, auth)
+	_echo.GET("/saved-searches", getSavedSearches, auth)
+	_echo.DELETE("/saved-searches", deleteSavedSearch, auth)
+	_echo.GET("/saved-searches/results", getSavedSearchResults, auth)
+	_echo.GET("/saved-searches/alerts", getSearchAlerts, auth)
+	_echo.POST("/bookmarks", postBookmark, auth)
+	_echo.DELETE("/bookmarks", deleteBookmark, auth)
+	_echo.GET("/bookmarks", getBookmarks, auth)
+	_echo.POST("/webhooks", postWebhook, auth)
+	_echo.GET("/webhooks", getWebhooks, auth)
+	_echo.DELETE("/webhooks", deleteWebhook, auth)
+	_echo.GET("/webhooks/deliveries", getWebhookDeliveries, auth)
+	_echo.POST("/messages", postMessage, auth)
+	_echo.GET("/messages", getMessages, auth)
+	_echo.POST("/messages/read", postMessagesRead, auth)
+	_echo.GET("/messages/unread", getUnreadMessages, auth)
+	_echo.GET("/openapi.json", getOpenAPI)
+	_echo.GET("/docs", getDocs)
+}
+
+/**************  Error handling *************************/
+
+// Send the JSON error response for err, with the HTTP status for its code
+// Errors that are not data.App_errors are reported as internal errors
+func errorResponse(c echo.Context, err error) error {
+	response := data.New_error_response(err)
+	return c.JSON(response.Status, response)
+}
+
+// Error handler for the echo server, so that errors raised by echo itself,
+// such as an unknown endpoint, have the same JSON shape as our own
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	var response data.Error_response
+	var http_err *echo.HTTPError
+	if errors.As(err, &http_err) {
+		response.Error = fmt.Sprintf("%v", http_err.Message)
+		response.Status = http_err.Code
+		response.Code = data.ErrorCodeForStatus(http_err.Code)
+	} else {
+		response = data.New_error_response(err)
+	}
+	c.JSON(response.Status, response)
+}
+
+/**************  Paging helpers *************************/
+
+// Read the limit, offset, sort and order query parameters for a listing
+// and validate them against the sort fields allowed for that listing
+func getPageRequest(c echo.Context, sort_fields []string) (page data.Page_request, bOk bool, err error) {
+	tmpstring := c.QueryParam("limit")
+	if len(tmpstring) > 0 {
+		page.Limit, err = strconv.Atoi(tmpstring)
+		if err != nil {
+			return page, false, data.ValidationError("limit", "Invalid limit format - must be integer")
+		}
+	}
+	tmpstring = c.QueryParam("offset")
+	if len(tmpstring) > 0 {
+		page.Offset, err = strconv.Atoi(tmpstring)
+		if err != nil {
+			return page, false, data.ValidationError("offset", "Invalid offset format - must be integer")
+		}
+	}
+	page.Sort = c.QueryParam("sort")
+	page.Order = c.QueryParam("order")
+	bOk, err = helper.ValidatePageRequest(&page, sort_fields)
+	return page, bOk, err
+}
+
+// Wrap one page of a listing with the total count and next page offset
+// The warning is included if nothing matched
+func pageResult(results interface{}, returned int, total int, page data.Page_request, warning string) data.Result_page {
+	result := data.New_result_page(results, returned, total, page)
+	if total == 0 {
+		result.Warning = warning
+	}
+	return result
+}
+
+/**************  File helpers *************************/
+
+// True if the request body is a multipart form, used to upload files
+func isMultipart(c echo.Context) bool {
+	return strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm)
+}
+
+// Read the resume uploaded in the named field of a multipart form
+// Returns nil if there is none. Files that are too big are refused
+// before being read; the rest of the checks are done by helper.ValidateResume
+func formResume(c echo.Context, field string) (resume *data.Document, err error) {
+	header, err := c.FormFile(field)
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, data.BadRequestError(err.Error())
+	}
+	if header.Size > data.MaxResumeBytes {
+		msg := fmt.Sprintf("Resume must be %d MB or less", data.MaxResumeBytes / (1024 * 1024))
+		return nil, data.ValidationError(field, msg)
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, data.MaxResumeBytes + 1))
+	if err != nil {
+		return nil, err
+	}
+	return &data.Document{File_name: header.Filename, Content_type: header.Header.Get(echo.HeaderContentType),
+		Content: content}, nil
+}
+
+// Send a stored file as a download, under its original name
+func sendDocument(c echo.Context, document data.Document) error {
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": document.File_name})
+	c.Response().Header().Set(echo.HeaderContentDisposition, disposition)
+	return c.Blob(http.StatusOK, document.Content_type, document.Content)
 }
 
 /**************  Endpoint Implementations *************************/
@@ -41,54 +195,76 @@ func getSearchJobs(c echo.Context) error {
 	var criteria data.Search_criteria
 	var err error
 	// copy parameters to struct used for validation
-	criteria.User_email = strings.ToLower(c.QueryParam("email"))
-   	criteria.Posted = c.QueryParam("posted")
+	criteria.User_email = middlewares.CurrentUser(c)
+   	// posted is the original name for posted_from
+   	criteria.Posted_from = c.QueryParam("posted_from")
+   	if criteria.Posted_from == "" {
+   		criteria.Posted_from = c.QueryParam("posted")
+   	}
+   	criteria.Posted_to = c.QueryParam("posted_to")
    	tmpstring := c.QueryParam("experience") 
     if len(tmpstring) > 0 {
     	criteria.Experience, err = strconv.Atoi(tmpstring)
     	if err != nil {
-    		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": "Invalid experience format - must be integer",
-			})	
+    		return errorResponse(c, data.ValidationError("experience", "Invalid experience format - must be integer"))	
     	}   
     }
    	tmpstring = c.QueryParam("education") 
     if len(tmpstring) > 0 {
     	criteria.Education, err = strconv.Atoi(tmpstring)
     	if err != nil {
-    		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": "Invalid education format - must be integer from 0 to 4",
-			})	
+    		return errorResponse(c, data.ValidationError("education", "Invalid education format - must be integer from 0 to 4"))	
     	}    	   
     }
    	tmpstring = c.QueryParam("salary") 
     if len(tmpstring) > 0 {
     	criteria.Salary, err = strconv.Atoi(tmpstring)
     	if err != nil {
-    		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": "Invalid salary format - must be integer less than one million",
-			})	
+    		return errorResponse(c, data.ValidationError("salary", "Invalid salary format - must be integer less than one million"))	
     	}    	   
     }
     criteria.Keyword = c.QueryParam("keyword")
-    bOk, msg := helper.ValidateSearchCriteria(&criteria)
+   	tmpstring = c.QueryParam("include_archived") 
+    if len(tmpstring) > 0 {
+    	criteria.Include_archived, err = strconv.ParseBool(tmpstring)
+    	if err != nil {
+    		return errorResponse(c, data.ValidationError("include_archived", "Invalid include_archived - must be true or false"))	
+    	}    	   
+    }
+    criteria.Org_id = c.QueryParam("org_id")
+    criteria.Organization = c.QueryParam("organization")
+    criteria.Location = c.QueryParam("location")
+    criteria.Work_arrangement = c.QueryParam("work_arrangement")
+    criteria.Employment_type = c.QueryParam("employment_type")
+    criteria.Skills = helper.SplitSkills(c.QueryParam("skills"))
+    coordinates := []struct {
+    	name  string
+    	value *float64
+    }{
+    	{"latitude", &criteria.Latitude}, {"longitude", &criteria.Longitude}, {"radius_km", &criteria.Radius_km},
+    }
+    for _, coordinate := range coordinates {
+    	tmpstring = c.QueryParam(coordinate.name)
+    	if len(tmpstring) > 0 {
+    		*coordinate.value, err = strconv.ParseFloat(tmpstring, 64)
+    		if err != nil {
+    			return errorResponse(c, data.ValidationError(coordinate.name, "Invalid " + coordinate.name + " format - must be a number"))
+    		}
+    	}
+    }
+    bOk, err := helper.ValidateSearchCriteria(&criteria)
 	if !bOk {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": msg,
-		})		
+		return errorResponse(c, err)		
 	}
-	jobs, err := dbaccess.SearchJobs(criteria.Posted,criteria.Experience,criteria.Education,criteria.Salary,criteria.Keyword)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": fmt.Sprintf("%v",err),
-		})
+	page, bOk, err := getPageRequest(c, data.Job_sort_fields)
+	if !bOk {
+		return errorResponse(c, err)
 	}
-	if len(jobs) == 0 {
-		return c.JSON(http.StatusOK, echo.Map{
-			"warning": "No matching jobs found",
-		})		
+	jobs, total, err := dbaccess.GetStore().SearchJobs(criteria,page)
+	if err != nil {
+		return errorResponse(c, err)
 	}
-	return c.JSON(http.StatusOK, jobs)
+	return c.JSON(http.StatusOK, pageResult(jobs, len(jobs), total, page, "No matching jobs found"))
 }
 
 // Implementation for /search/detail API endpoint
@@ -96,19 +272,15 @@ func getSearchJobDetail(c echo.Context) error {
 	var job data.Job_info
 	var err error
 	// copy parameters to struct used for validation
-	job.Creator = strings.ToLower(c.QueryParam("email"))
+	job.Creator = middlewares.CurrentUser(c)
    	job.Job_id = c.QueryParam("job_id")
-	bOk, msg := helper.ValidateDetailRequest(&job)
+	bOk, err := helper.ValidateDetailRequest(&job)
 	if !bOk {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": msg,
-		})
+		return errorResponse(c, err)
 	}
-	foundjob, err := dbaccess.GetJobDetail(job.Job_id)
+	foundjob, err := dbaccess.GetStore().GetJobDetail(job.Job_id)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": fmt.Sprintf("%v",err),
-		})
+		return errorResponse(c, err)
 	}
 	return c.JSON(http.StatusOK, foundjob)
 }
@@ -117,25 +289,20 @@ func getSearchJobDetail(c echo.Context) error {
 func getSearchJobsOffered(c echo.Context) error {
 	// copy parameters to struct used for validation
 	var job data.Job_info	
-	job.Creator = strings.ToLower(c.QueryParam("email"))
-	bOk, msg := helper.ValidateOfferedAppliedRequest(&job)
+	job.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateOfferedAppliedRequest(&job)
 	if !bOk {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": msg,
-		})
+		return errorResponse(c, err)
 	}
-	jobs, err := dbaccess.SearchOfferedJobs(job.Creator)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": fmt.Sprintf("%v",err),
-		})
+	page, bOk, err := getPageRequest(c, data.Job_sort_fields)
+	if !bOk {
+		return errorResponse(c, err)
 	}
-	if len(jobs) == 0 {
-		return c.JSON(http.StatusOK, echo.Map{
-			"warning": "No matching jobs found",
-		})		
+	jobs, total, err := dbaccess.GetStore().SearchOfferedJobs(job.Creator, page)
+	if err != nil {
+		return errorResponse(c, err)
 	}
-	return c.JSON(http.StatusOK, jobs)
+	return c.JSON(http.StatusOK, pageResult(jobs, len(jobs), total, page, "No matching jobs found"))
 }
 
 // Implementation for /search/applied API endpoint
@@ -145,78 +312,218 @@ func getSearchJobsOffered(c echo.Context) error {
 func getSearchJobsApplied(c echo.Context) error {
 	// copy parameters to struct used for validation
 	var job data.Job_info	
-	job.Creator = strings.ToLower(c.QueryParam("email"))
-	bOk, msg := helper.ValidateOfferedAppliedRequest(&job)
+	job.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateOfferedAppliedRequest(&job)
 	if !bOk {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": msg,
-		})
+		return errorResponse(c, err)
 	}
-	jobs, err := dbaccess.SearchAppliedJobs(job.Creator)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": fmt.Sprintf("%v",err),
-		})
+	page, bOk, err := getPageRequest(c, data.Application_sort_fields)
+	if !bOk {
+		return errorResponse(c, err)
 	}
-	if len(jobs) == 0 {
-		return c.JSON(http.StatusOK, echo.Map{
-			"warning": "No matching jobs found",
-		})		
+	applications, total, err := dbaccess.GetStore().SearchAppliedJobs(job.Creator, page)
+	if err != nil {
+		return errorResponse(c, err)
 	}
-	return c.JSON(http.StatusOK, jobs)
+	return c.JSON(http.StatusOK, pageResult(applications, len(applications), total, page, "No matching jobs found"))
 }
 
 // Implementation for /search/candidates API endpoint
-// Returns a list of users who have applied for a job
+// Returns a list of users who have applied for a job, or only those
+// who meet all its requirements if qualified is true
 func getSearchJobCandidates(c echo.Context) error {
 	// copy parameters to struct used for validation
 	var job data.Job_info
 	var err error
 	// copy parameters to struct used for validation
-	job.Creator = strings.ToLower(c.QueryParam("email"))
+	job.Creator = middlewares.CurrentUser(c)
    	job.Job_id = c.QueryParam("job_id")
-	bOk, msg := helper.ValidateDetailRequest(&job)
+	bOk, err := helper.ValidateDetailRequest(&job)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	qualified := false
+	tmpstring := c.QueryParam("qualified") 
+	if len(tmpstring) > 0 {
+		qualified, err = strconv.ParseBool(tmpstring)
+		if err != nil {
+			return errorResponse(c, data.ValidationError("qualified", "Invalid qualified - must be true or false"))	
+		}
+	}
+	page, bOk, err := getPageRequest(c, data.Candidate_sort_fields)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	candidates, total, err := dbaccess.GetStore().SearchCandidates(job.Creator,job.Job_id,qualified,page)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, pageResult(candidates, len(candidates), total, page, "No candidates found"))
+}
+
+// Implementation for /register API endpoint
+func postRegisterUser(c echo.Context) (err error) {
+	input := new(data.User_info)
+	if err := c.Bind(input); err != nil {
+		return errorResponse(c, data.BadRequestError(err.Error()))
+	}
+	input.Email = strings.ToLower(input.Email)
+	bOk, err := helper.ValidateUserInfo(input, true)
 	if !bOk {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": msg,
+		return errorResponse(c, err)		
+	}
+	err = dbaccess.GetStore().RegisterUser(input.Email,input.First,input.Last,input.Phone,*input.Education,input.Password,
+		input.Experience,input.Skills,input.Work_history)
+	if err != nil {
+		return errorResponse(c, err)				
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"registered" : input.Email,
 		})
+}
+
+// Implementation for /login API endpoint
+// Checks the email and password and returns a bearer token
+// which must be sent as "Authorization: Bearer <token>" on other requests
+func postLogin(c echo.Context) (err error) {
+	input := new(data.Credentials)
+	if err := c.Bind(input); err != nil {
+		return errorResponse(c, data.BadRequestError(err.Error()))
+	}
+	bOk, err := helper.ValidateCredentials(input)
+	if !bOk {
+		return errorResponse(c, err)		
+	}
+	bOk, err = dbaccess.GetStore().CheckPassword(input.Email, input.Password)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	if !bOk {
+		return errorResponse(c, data.UnauthorizedError("Invalid email or password"))
 	}
-	candidates,err := dbaccess.SearchCandidates(job.Creator,job.Job_id)
+	token, expires, err := dbaccess.GetStore().CreateSession(input.Email)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"error": fmt.Sprintf("%v",err),
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"email" : input.Email,
+			"token" : token,
+			"expires" : expires.In(data.DisplayZone()).Format(time.RFC3339),
 		})
+}
+
+// Implementation for /logout API endpoint
+// Invalidates the bearer token used for the request
+func postLogout(c echo.Context) (err error) {
+	err = dbaccess.GetStore().DeleteSession(middlewares.BearerToken(c))
+	if err != nil {
+		return errorResponse(c, err)
 	}
-	if len(candidates) == 0 {
-		return c.JSON(http.StatusOK, echo.Map{
-			"warning": "No candidates found",
-		})			
+	return c.JSON(http.StatusOK, echo.Map{
+			"logged_out" : middlewares.CurrentUser(c),
+		})
+}
+
+// Implementation for GET on the /user API endpoint
+// Returns the logged in user's profile
+func getUserProfile(c echo.Context) (err error) {
+	profile, err := dbaccess.GetStore().GetUserProfile(middlewares.CurrentUser(c))
+	if err != nil {
+		return errorResponse(c, err)
 	}
-	return c.JSON(http.StatusOK, candidates)	
+	return c.JSON(http.StatusOK, profile)
 }
 
-// Implementation for /register API endpoint
-func postRegisterUser(c echo.Context) (err error) {
+// Implementation for PUT on the /user API endpoint
+// Changes only the fields given. Changing the password logs the
+// user out everywhere, including this token
+func putUserProfile(c echo.Context) (err error) {
 	input := new(data.User_info)
 	if err := c.Bind(input); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": err.Error(),
+		return errorResponse(c, data.BadRequestError(err.Error()))
+	}
+	input.Email = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateUserInfo(input, false)
+	if !bOk {
+		return errorResponse(c, err)		
+	}
+	err = dbaccess.GetStore().UpdateUserProfile(input.Email,input.First,input.Last,input.Phone,input.Education,input.Password,
+		input.Experience,input.Skills,input.Work_history)
+	if err != nil {
+		return errorResponse(c, err)				
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"updated" : input.Email,
+		})
+}
+
+// Implementation for DELETE on the /user API endpoint
+// Deletes the logged in user's account; see DeleteUser in dbaccess/user.go
+func deleteUser(c echo.Context) (err error) {
+	user_email := middlewares.CurrentUser(c)
+	err = dbaccess.GetStore().DeleteUser(user_email)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"deleted" : user_email,
 		})
+}
+
+// Implementation for PUT on the /user/resume API endpoint
+// Uploads the logged in user's default resume, in the resume field of a
+// multipart form, replacing any earlier one
+func putUserResume(c echo.Context) (err error) {
+	if !isMultipart(c) {
+		return errorResponse(c, data.BadRequestError("Resume must be uploaded as multipart/form-data"))
 	}
-	bOk, msg := helper.ValidateUserInfo(input)
+	resume, err := formResume(c, "resume")
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	if resume == nil {
+		return errorResponse(c, data.ValidationError("resume", "Resume file is missing"))
+	}
+	bOk, err := helper.ValidateResume(resume)
 	if !bOk {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": msg,
-		})		
+		return errorResponse(c, err)
 	}
-	err = dbaccess.RegisterUser(input.Email,input.First,input.Last,input.Phone,input.Education)
+	user_email := middlewares.CurrentUser(c)
+	err = dbaccess.GetStore().SetUserResume(user_email, resume)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": err.Error(),
-		})				
+		return errorResponse(c, err)
+	}
+	profile, err := dbaccess.GetStore().GetUserProfile(user_email)
+	if err != nil {
+		return errorResponse(c, err)
 	}
 	return c.JSON(http.StatusOK, echo.Map{
-			"registered" : input.Email,
+			"updated" : user_email,
+			"resume" : profile.Resume,
+		})
+}
+
+// Implementation for GET on the /user/resume API endpoint
+// Downloads the logged in user's default resume
+func getUserResume(c echo.Context) (err error) {
+	resume, err := dbaccess.GetStore().GetUserResume(middlewares.CurrentUser(c))
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return sendDocument(c, resume)
+}
+
+// Implementation for DELETE on the /user/resume API endpoint
+// Removes the logged in user's default resume. Applications that
+// were sent with it keep it
+func deleteUserResume(c echo.Context) (err error) {
+	user_email := middlewares.CurrentUser(c)
+	err = dbaccess.GetStore().SetUserResume(user_email, nil)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"removed_resume" : user_email,
 		})
 }
 
@@ -224,21 +531,16 @@ func postRegisterUser(c echo.Context) (err error) {
 func postCreateJob(c echo.Context) (err error) {
 	input := new(data.Job_info)
 	if err := c.Bind(input); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": err.Error(),
-		})
+		return errorResponse(c, data.BadRequestError(err.Error()))
 	}
-	bOk, msg := helper.ValidateJobInfo(input, true)
+	input.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateJobInfo(input)
 	if !bOk {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": msg,
-		})		
+		return errorResponse(c, err)		
 	}
-	job_id, err := dbaccess.CreateJob(input.Creator,input.Title,input.Description,input.Min_education, input.Min_experience, input.Salary) 
+	job_id, err := dbaccess.GetStore().CreateJob(*input) 
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": err.Error(),
-		})				
+		return errorResponse(c, err)				
 	}
 	return c.JSON(http.StatusOK, echo.Map{
 			"created_job" : job_id,
@@ -246,51 +548,661 @@ func postCreateJob(c echo.Context) (err error) {
 }
 
 // Implementation for /job/modify API endpoint
+// Only the fields present in the request body are changed, so any of
+// them can be set to 0, "" or false
 func putModifyJob(c echo.Context) (err error) {
-	input := new(data.Job_info)
+	input := new(data.Job_changes)
 	if err := c.Bind(input); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": err.Error(),
-		})
+		return errorResponse(c, data.BadRequestError(err.Error()))
 	}
-	bOk, msg := helper.ValidateJobInfo(input, false)
+	input.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateJobChanges(input)
 	if !bOk {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": msg,
-		})		
+		return errorResponse(c, err)		
 	}
-	job_id, err := dbaccess.ModifyJob(input.Creator,input.Job_id,input.Title,input.Description,input.Min_education, input.Min_experience, input.Salary, input.Is_open) 
+	job_id, err := dbaccess.GetStore().ModifyJob(*input) 
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": err.Error(),
-		})				
+		return errorResponse(c, err)				
 	}
 	return c.JSON(http.StatusOK, echo.Map{
 			"modified_job" : job_id,
 		})
 }
 
+// Implementation for /job/archive API endpoint
+// Archives the logged in user's job given by the job_id query parameter
+func postArchiveJob(c echo.Context) (err error) {
+	var job data.Job_info
+	job.Creator = middlewares.CurrentUser(c)
+	job.Job_id = c.QueryParam("job_id")
+	bOk, err := helper.ValidateJobOwnerRequest(&job)
+	if !bOk {
+		return errorResponse(c, err)		
+	}
+	err = dbaccess.GetStore().ArchiveJob(job.Creator, job.Job_id)
+	if err != nil {
+		return errorResponse(c, err)				
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"archived_job" : job.Job_id,
+		})
+}
+
+// Implementation for DELETE on the /job API endpoint
+// Deletes the logged in user's job given by the job_id query parameter,
+// if nobody has applied for it
+func deleteJob(c echo.Context) (err error) {
+	var job data.Job_info
+	job.Creator = middlewares.CurrentUser(c)
+	job.Job_id = c.QueryParam("job_id")
+	bOk, err := helper.ValidateJobOwnerRequest(&job)
+	if !bOk {
+		return errorResponse(c, err)		
+	}
+	err = dbaccess.GetStore().DeleteJob(job.Creator, job.Job_id)
+	if err != nil {
+		return errorResponse(c, err)				
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"deleted_job" : job.Job_id,
+		})
+}
+
 // Implementation for /job/submit API endpoint
+// The body is JSON, or a multipart form to upload a resume with the
+// application. Without one, the user's default resume is sent, if any
 func postSubmitJob(c echo.Context) (err error) {
 	input := new(data.Submission)
+	if isMultipart(c) {
+		input.Job_id = c.FormValue("job_id")
+		input.Cover_letter = c.FormValue("cover_letter")
+		input.Resume, err = formResume(c, "resume")
+		if err != nil {
+			return errorResponse(c, err)
+		}
+	} else if err := c.Bind(input); err != nil {
+		return errorResponse(c, data.BadRequestError(err.Error()))
+	}
+	input.Email = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateJobSubmission(input)
+	if !bOk {
+		return errorResponse(c, err)		
+	}
+	job_id, unmet, err := dbaccess.GetStore().SubmitJobApplication(input.Email,input.Job_id,input.Cover_letter,input.Resume) 
+	if err != nil {
+		return errorResponse(c, err)				
+	}
+	result := data.Submit_result{Applied_for_job: job_id, Unmet_requirements: unmet}
+	if len(unmet) > 0 {
+		// the application was made, but with a warning
+		result.Warning = data.UnmetWarning
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// Implementation for DELETE on the /job/submit API endpoint
+// Withdraws the logged in user's application for the job given by
+// the job_id query parameter
+func deleteSubmitJob(c echo.Context) (err error) {
+	var input data.Submission
+	input.Email = middlewares.CurrentUser(c)
+	input.Job_id = c.QueryParam("job_id")
+	bOk, err := helper.ValidateJobSubmission(&input)
+	if !bOk {
+		return errorResponse(c, err)		
+	}
+	job_id, err := dbaccess.GetStore().WithdrawApplication(input.Email,input.Job_id) 
+	if err != nil {
+		return errorResponse(c, err)				
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"withdrawn_job" : job_id,
+		})
+}
+
+// Implementation for /job/resume API endpoint
+// Lets the job creator, or a member of the job's organization, download
+// the resume sent with an application
+func getApplicationResume(c echo.Context) (err error) {
+	var input data.Status_change
+	input.Creator = middlewares.CurrentUser(c)
+	input.Job_id = c.QueryParam("job_id")
+	input.Applicant = c.QueryParam("email")
+	bOk, err := helper.ValidateApplicationRequest(&input)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	resume, err := dbaccess.GetStore().GetApplicationResume(input.Creator, input.Job_id, input.Applicant)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return sendDocument(c, resume)
+}
+
+// Implementation for /job/status API endpoint
+// Lets the job creator move an application to a new status
+func putApplicationStatus(c echo.Context) (err error) {
+	input := new(data.Status_change)
+	if err := c.Bind(input); err != nil {
+		return errorResponse(c, data.BadRequestError(err.Error()))
+	}
+	return changeApplicationStatus(c, input)
+}
+
+// Implementation for /job/hire API endpoint
+// Same as /job/status with status "hired"; also closes the job
+func postHireCandidate(c echo.Context) (err error) {
+	input := new(data.Status_change)
+	if err := c.Bind(input); err != nil {
+		return errorResponse(c, data.BadRequestError(err.Error()))
+	}
+	input.Status = data.StatusHired
+	return changeApplicationStatus(c, input)
+}
+
+// Common code for the status change endpoints
+// The creator is always the logged in user
+func changeApplicationStatus(c echo.Context, input *data.Status_change) (err error) {
+	input.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateStatusChange(input)
+	if !bOk {
+		return errorResponse(c, err)		
+	}
+	err = dbaccess.GetStore().UpdateApplicationStatus(input.Creator, input.Job_id, input.Applicant, input.Status)
+	if err != nil {
+		return errorResponse(c, err)				
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"job_id" : input.Job_id,
+			"email" : input.Applicant,
+			"status" : input.Status,
+		})
+}
+
+// Implementation for /org/create API endpoint
+// The logged in user becomes the owner of the new organization
+func postCreateOrganization(c echo.Context) (err error) {
+	input := new(data.Organization)
+	if err := c.Bind(input); err != nil {
+		return errorResponse(c, data.BadRequestError(err.Error()))
+	}
+	input.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateOrganization(input, true)
+	if !bOk {
+		return errorResponse(c, err)		
+	}
+	org_id, err := dbaccess.GetStore().CreateOrganization(*input)
+	if err != nil {
+		return errorResponse(c, err)				
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"created_org" : org_id,
+		})
+}
+
+// Implementation for GET on the /org API endpoint
+// Returns the profile and members of the organization given by org_id
+func getOrganization(c echo.Context) (err error) {
+	var org data.Organization
+	org.Creator = middlewares.CurrentUser(c)
+	org.Org_id = c.QueryParam("org_id")
+	bOk, err := helper.ValidateOrgRequest(&org)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	found, err := dbaccess.GetStore().GetOrganization(org.Org_id)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, found)
+}
+
+// Implementation for PUT on the /org API endpoint
+// An owner can change the fields given; empty fields are left alone
+func putOrganization(c echo.Context) (err error) {
+	input := new(data.Organization)
+	if err := c.Bind(input); err != nil {
+		return errorResponse(c, data.BadRequestError(err.Error()))
+	}
+	input.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateOrganization(input, false)
+	if !bOk {
+		return errorResponse(c, err)		
+	}
+	err = dbaccess.GetStore().UpdateOrganization(*input)
+	if err != nil {
+		return errorResponse(c, err)				
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"updated_org" : input.Org_id,
+		})
+}
+
+// Implementation for /search/orgs API endpoint
+// Lists organizations whose names contain the keyword, or only
+// the logged in user's organizations if mine is true
+func getSearchOrganizations(c echo.Context) (err error) {
+	var job data.Job_info	
+	job.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateOfferedAppliedRequest(&job)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	mine := false
+	tmpstring := c.QueryParam("mine") 
+	if len(tmpstring) > 0 {
+		mine, err = strconv.ParseBool(tmpstring)
+		if err != nil {
+			return errorResponse(c, data.ValidationError("mine", "Invalid mine - must be true or false"))	
+		}
+	}
+	member_email := ""
+	if mine {
+		member_email = job.Creator
+	}
+	page, bOk, err := getPageRequest(c, data.Organization_sort_fields)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	orgs, total, err := dbaccess.GetStore().SearchOrganizations(c.QueryParam("keyword"), member_email, page)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, pageResult(orgs, len(orgs), total, page, "No matching organizations found"))
+}
+
+// Implementation for /skills API endpoint
+// Lists the known skills whose names contain the keyword
+func getSkills(c echo.Context) (err error) {
+	var job data.Job_info
+	job.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateOfferedAppliedRequest(&job)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	page, bOk, err := getPageRequest(c, data.Skill_sort_fields)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	skills, total, err := dbaccess.GetStore().ListSkills(c.QueryParam("keyword"), page)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, pageResult(skills, len(skills), total, page, "No matching skills found"))
+}
+
+// Implementation for PUT on the /org/member API endpoint
+// An owner adds a member to the organization or changes a member's role
+func putOrganizationMember(c echo.Context) (err error) {
+	input := new(data.Member_change)
+	if err := c.Bind(input); err != nil {
+		return errorResponse(c, data.BadRequestError(err.Error()))
+	}
+	input.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateMemberChange(input, false)
+	if !bOk {
+		return errorResponse(c, err)		
+	}
+	err = dbaccess.GetStore().SetOrganizationMember(*input)
+	if err != nil {
+		return errorResponse(c, err)				
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"org_id" : input.Org_id,
+			"email" : input.Member,
+			"role" : input.Role,
+		})
+}
+
+// Implementation for DELETE on the /org/member API endpoint
+// Removes the member given by the org_id and email query parameters
+// Owners can remove anybody; other members can only remove themselves
+func deleteOrganizationMember(c echo.Context) (err error) {
+	var input data.Member_change
+	input.Creator = middlewares.CurrentUser(c)
+	input.Org_id = c.QueryParam("org_id")
+	input.Member = c.QueryParam("email")
+	bOk, err := helper.ValidateMemberChange(&input, true)
+	if !bOk {
+		return errorResponse(c, err)		
+	}
+	err = dbaccess.GetStore().RemoveOrganizationMember(input)
+	if err != nil {
+		return errorResponse(c, err)				
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"org_id" : input.Org_id,
+			"removed" : input.Member,
+		})
+}
+
+// Implementation for POST on the /saved-searches API endpoint
+// Saves the name and criteria in the body for the logged in user
+func postSavedSearch(c echo.Context) (err error) {
+	input := new(data.Saved_search)
+	if err := c.Bind(input); err != nil {
+		return errorResponse(c, data.BadRequestError(err.Error()))
+	}
+	input.User_email = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateSavedSearch(input)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	search_id, err := dbaccess.GetStore().CreateSavedSearch(*input)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"created_search" : search_id,
+		})
+}
+
+// Implementation for GET on the /saved-searches API endpoint
+// Lists the logged in user's saved searches with their new matches
+func getSavedSearches(c echo.Context) (err error) {
+	var job data.Job_info
+	job.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateOfferedAppliedRequest(&job)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	page, bOk, err := getPageRequest(c, data.Saved_search_sort_fields)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	searches, total, err := dbaccess.GetStore().ListSavedSearches(job.Creator, page)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, pageResult(searches, len(searches), total, page, "No saved searches found"))
+}
+
+// Implementation for DELETE on the /saved-searches API endpoint
+// Deletes the saved search given by the search_id query parameter
+func deleteSavedSearch(c echo.Context) (err error) {
+	var input data.Saved_search
+	input.User_email = middlewares.CurrentUser(c)
+	input.Search_id = c.QueryParam("search_id")
+	bOk, err := helper.ValidateSavedSearchRequest(&input)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	err = dbaccess.GetStore().DeleteSavedSearch(input.User_email, input.Search_id)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"deleted_search" : input.Search_id,
+		})
+}
+
+// Implementation for /saved-searches/results API endpoint
+// Runs a saved search, which marks its matches as seen
+func getSavedSearchResults(c echo.Context) (err error) {
+	var input data.Saved_search
+	input.User_email = middlewares.CurrentUser(c)
+	input.Search_id = c.QueryParam("search_id")
+	bOk, err := helper.ValidateSavedSearchRequest(&input)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	page, bOk, err := getPageRequest(c, data.Job_sort_fields)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	jobs, total, err := dbaccess.GetStore().RunSavedSearch(input.User_email, input.Search_id, page)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, pageResult(jobs, len(jobs), total, page, "No matching jobs found"))
+}
+
+// Implementation for /saved-searches/alerts API endpoint
+// Returns the saved searches with new matches, for a notification badge to poll
+func getSearchAlerts(c echo.Context) (err error) {
+	var job data.Job_info
+	job.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateOfferedAppliedRequest(&job)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	alerts, err := dbaccess.GetStore().GetSearchAlerts(job.Creator)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, alerts)
+}
+
+// Implementation for POST on the /bookmarks API endpoint
+// Bookmarks the job given by the job_id query parameter
+func postBookmark(c echo.Context) (err error) {
+	var job data.Job_info
+	job.Creator = middlewares.CurrentUser(c)
+	job.Job_id = c.QueryParam("job_id")
+	bOk, err := helper.ValidateDetailRequest(&job)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	err = dbaccess.GetStore().AddBookmark(job.Creator, job.Job_id)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"bookmarked" : job.Job_id,
+		})
+}
+
+// Implementation for DELETE on the /bookmarks API endpoint
+// Removes the bookmark of the job given by the job_id query parameter
+func deleteBookmark(c echo.Context) (err error) {
+	var job data.Job_info
+	job.Creator = middlewares.CurrentUser(c)
+	job.Job_id = c.QueryParam("job_id")
+	bOk, err := helper.ValidateDetailRequest(&job)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	err = dbaccess.GetStore().RemoveBookmark(job.Creator, job.Job_id)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"unbookmarked" : job.Job_id,
+		})
+}
+
+// Implementation for GET on the /bookmarks API endpoint
+func getBookmarks(c echo.Context) (err error) {
+	var job data.Job_info
+	job.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateOfferedAppliedRequest(&job)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	page, bOk, err := getPageRequest(c, data.Bookmark_sort_fields)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	bookmarks, total, err := dbaccess.GetStore().ListBookmarks(job.Creator, page)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, pageResult(bookmarks, len(bookmarks), total, page, "No bookmarked jobs"))
+}
+
+// Implementation for POST on the /webhooks API endpoint
+// Returns the new webhook with the secret that signs its deliveries
+func postWebhook(c echo.Context) (err error) {
+	input := new(data.Webhook)
 	if err := c.Bind(input); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": err.Error(),
+		return errorResponse(c, data.BadRequestError(err.Error()))
+	}
+	input.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateWebhook(input)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	created, err := dbaccess.GetStore().CreateWebhook(*input)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, created)
+}
+
+// Implementation for GET on the /webhooks API endpoint
+func getWebhooks(c echo.Context) (err error) {
+	var job data.Job_info
+	job.Creator = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateOfferedAppliedRequest(&job)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	page, bOk, err := getPageRequest(c, data.Webhook_sort_fields)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	hooks, total, err := dbaccess.GetStore().ListWebhooks(job.Creator, page)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, pageResult(hooks, len(hooks), total, page, "No webhooks found"))
+}
+
+// Implementation for DELETE on the /webhooks API endpoint
+// Deletes the webhook given by the webhook_id query parameter
+func deleteWebhook(c echo.Context) (err error) {
+	var input data.Webhook
+	input.Creator = middlewares.CurrentUser(c)
+	input.Webhook_id = c.QueryParam("webhook_id")
+	bOk, err := helper.ValidateWebhookRequest(&input)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	err = dbaccess.GetStore().DeleteWebhook(input.Creator, input.Webhook_id)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+			"deleted_webhook" : input.Webhook_id,
 		})
+}
+
+// Implementation for /webhooks/deliveries API endpoint
+// Lists the deliveries of the webhook given by the webhook_id query parameter
+func getWebhookDeliveries(c echo.Context) (err error) {
+	var input data.Webhook
+	input.Creator = middlewares.CurrentUser(c)
+	input.Webhook_id = c.QueryParam("webhook_id")
+	bOk, err := helper.ValidateWebhookRequest(&input)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	page, bOk, err := getPageRequest(c, data.Delivery_sort_fields)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	deliveries, total, err := dbaccess.GetStore().ListWebhookDeliveries(input.Creator, input.Webhook_id, page)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, pageResult(deliveries, len(deliveries), total, page, "No deliveries found"))
+}
+
+// Implementation for GET on the /user/notifications API endpoint
+func getNotificationPreferences(c echo.Context) (err error) {
+	prefs, err := dbaccess.GetStore().GetNotificationPreferences(middlewares.CurrentUser(c))
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, prefs)
+}
+
+// Implementation for PUT on the /user/notifications API endpoint
+// Changes only the preferences given, and returns them all
+func putNotificationPreferences(c echo.Context) (err error) {
+	input := new(data.Notification_changes)
+	if err := c.Bind(input); err != nil {
+		return errorResponse(c, data.BadRequestError(err.Error()))
 	}
-	bOk, msg := helper.ValidateJobSubmission(input)
+	input.User_email = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateNotificationChanges(input)
 	if !bOk {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": msg,
-		})		
+		return errorResponse(c, err)
 	}
-	job_id, err := dbaccess.SubmitJobApplication(input.Email,input.Job_id) 
+	prefs, err := dbaccess.GetStore().SetNotificationPreferences(*input)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": err.Error(),
-		})				
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, prefs)
+}
+
+// Implementation for POST on the /messages API endpoint
+// Sends a message to the thread of the application given by job_id and
+// applicant. The applicant leaves out applicant for their own application
+func postMessage(c echo.Context) (err error) {
+	input := new(data.Message)
+	if err := c.Bind(input); err != nil {
+		return errorResponse(c, data.BadRequestError(err.Error()))
+	}
+	input.Sender = middlewares.CurrentUser(c)
+	bOk, err := helper.ValidateMessage(input)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	sent, err := dbaccess.GetStore().SendMessage(*input)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, sent)
+}
+
+// Implementation for GET on the /messages API endpoint
+// Lists the thread given by the job_id and applicant query parameters
+func getMessages(c echo.Context) (err error) {
+	var input data.Message
+	input.Sender = middlewares.CurrentUser(c)
+	input.Job_id = c.QueryParam("job_id")
+	input.Applicant = c.QueryParam("applicant")
+	bOk, err := helper.ValidateThreadRequest(&input)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	page, bOk, err := getPageRequest(c, data.Message_sort_fields)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	messages, total, err := dbaccess.GetStore().ListMessages(input.Sender, input.Job_id, input.Applicant, page)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, pageResult(messages, len(messages), total, page, "No messages found"))
+}
+
+// Implementation for POST on the /messages/read API endpoint
+// Marks the messages sent to the user in the thread as read
+func postMessagesRead(c echo.Context) (err error) {
+	var input data.Message
+	input.Sender = middlewares.CurrentUser(c)
+	input.Job_id = c.QueryParam("job_id")
+	input.Applicant = c.QueryParam("applicant")
+	bOk, err := helper.ValidateThreadRequest(&input)
+	if !bOk {
+		return errorResponse(c, err)
+	}
+	marked, err := dbaccess.GetStore().MarkMessagesRead(input.Sender, input.Job_id, input.Applicant)
+	if err != nil {
+		return errorResponse(c, err)
 	}
 	return c.JSON(http.StatusOK, echo.Map{
-			"applied_for_job" : job_id,
+			"marked_read" : marked,
 		})
 }
+
+// Implementation for GET on the /messages/unread API endpoint
+func getUnreadMessages(c echo.Context) (err error) {
+	counts, err := dbaccess.GetStore().GetUnreadCounts(middlewares.CurrentUser(c))
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, counts)
+}
@@ -0,0 +1,892 @@
+package api
+
+// Regression tests for the REST handlers
+// Values containing quotes, LIKE wildcards and SQL injection payloads
+// must be stored and searched as plain data
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"github.com/segoldin/JobWizard/job_wizard/data"
+	"github.com/segoldin/JobWizard/job_wizard/dbaccess"
+	"github.com/labstack/echo/v4"
+)
+
+var testServer *echo.Echo
+
+// Use an empty in-memory store, so no database file is needed,
+// then register the routes on a test server
+func TestMain(m *testing.M) {
+	dbaccess.SetStore(dbaccess.NewMemoryStore())
+	dbaccess.SetBlobStore(dbaccess.NewMemoryBlobStore())
+	testServer = echo.New()
+	testServer.HTTPErrorHandler = HTTPErrorHandler
+	ApplicationPrivateRoute(testServer.Group("/api"))
+	os.Exit(m.Run())
+}
+
+// Send a request with an optional JSON body to the test server
+// If token is not empty it is sent as a bearer token
+func doRequest(method string, target string, body interface{}, token string) *httptest.ResponseRecorder {
+	var payload string
+	if body != nil {
+		bytes, _ := json.Marshal(body)
+		payload = string(bytes)
+	}
+	req := httptest.NewRequest(method, target, strings.NewReader(payload))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	testServer.ServeHTTP(rec, req)
+	return rec
+}
+
+// Send a multipart form to the test server, with the given fields and
+// a file in the resume field if file_name is not empty
+func doUpload(method string, target string, fields map[string]string, file_name string, content []byte,
+	token string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	if file_name != "" {
+		file, _ := form.CreateFormFile("resume", file_name)
+		file.Write(content)
+	}
+	form.Close()
+	req := httptest.NewRequest(method, target, &body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	testServer.ServeHTTP(rec, req)
+	return rec
+}
+
+// Register a user and log in, returning the bearer token
+func registerAndLogin(t *testing.T, email string, password string) string {
+	t.Helper()
+	rec := doRequest(http.MethodPost, "/api/register", echo.Map{
+		"email": email, "first": "Rest", "last": "Owner", "phone": "0812345678", "education": 3, "password": password}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("register returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/login", data.Credentials{Email: email, Password: password}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login returned %d: %s", rec.Code, rec.Body.String())
+	}
+	var session map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &session)
+	if session["token"] == "" {
+		t.Fatalf("login returned no token: %s", rec.Body.String())
+	}
+	return session["token"]
+}
+
+func TestLoginLogout(t *testing.T) {
+	token := registerAndLogin(t, "session.user@example.com", "open sesame")
+	rec := doRequest(http.MethodGet, "/api/search/offered", nil, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("request without token should return 401, got %d", rec.Code)
+	}
+	rec = doRequest(http.MethodGet, "/api/search/offered", nil, "not-a-real-token")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("request with unknown token should return 401, got %d", rec.Code)
+	}
+	rec = doRequest(http.MethodGet, "/api/search/offered", nil, token)
+	if rec.Code != http.StatusOK {
+		t.Errorf("request with token should succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/login", data.Credentials{
+		Email: "session.user@example.com", Password: "wrong password"}, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("login with wrong password should return 401, got %d", rec.Code)
+	}
+	rec = doRequest(http.MethodPost, "/api/logout", nil, token)
+	if rec.Code != http.StatusOK {
+		t.Errorf("logout returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/search/offered", nil, token)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("token should be invalid after logout, got %d", rec.Code)
+	}
+}
+
+func TestIdentityComesFromToken(t *testing.T) {
+	owner := registerAndLogin(t, "real.owner@example.com", "password one")
+	other := registerAndLogin(t, "impostor@example.com", "password two")
+	rec := doRequest(http.MethodPost, "/api/job/create", data.Job_info{
+		Creator: "impostor@example.com", Title: "Owned Job", Description: "Created by the token holder"}, owner)
+	var created map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	job_id := created["created_job"]
+	query := url.Values{"email": {"impostor@example.com"}, "job_id": {job_id}}
+	rec = doRequest(http.MethodGet, "/api/search/detail?"+query.Encode(), nil, other)
+	var detail data.Job_info
+	json.Unmarshal(rec.Body.Bytes(), &detail)
+	if detail.Creator != "real.owner@example.com" {
+		t.Errorf("creator in body should be ignored, job belongs to %q", detail.Creator)
+	}
+	// the impostor cannot act as the owner by passing parameters
+	rec = doRequest(http.MethodPut, "/api/job/modify", echo.Map{
+		"creator": "real.owner@example.com", "job_id": job_id, "title": "Stolen"}, other)
+	if rec.Code == http.StatusOK {
+		t.Errorf("modify by another user should fail")
+	}
+	query = url.Values{"email": {"real.owner@example.com"}, "job_id": {job_id}}
+	rec = doRequest(http.MethodGet, "/api/search/candidates?"+query.Encode(), nil, other)
+	if rec.Code == http.StatusOK {
+		t.Errorf("candidates for another user's job should fail")
+	}
+}
+
+func TestHandlersWithQuotesAndPayloads(t *testing.T) {
+	token := registerAndLogin(t, "rest.owner@example.com", "it's \"quoted\"")
+	rec := doRequest(http.MethodPost, "/api/job/create", data.Job_info{
+		Title: "Director's Assistant", Description: "It's 100% \"remote\"", Min_education: 1}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create returned %d: %s", rec.Code, rec.Body.String())
+	}
+	var created map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	job_id := created["created_job"]
+
+	doRequest(http.MethodPost, "/api/job/create", data.Job_info{
+		Title: "snake_case Wrangler", Description: "Decoy"}, token)
+
+	searches := map[string]int{
+		"Director's":  1,
+		"snake_case":  1,
+		"%":           0,
+		"' OR 1=1 --": 0,
+		"' UNION SELECT 1,user_email,1,created FROM user --": 0,
+	}
+	for keyword, expected := range searches {
+		query := url.Values{"keyword": {keyword}}
+		rec = doRequest(http.MethodGet, "/api/search?"+query.Encode(), nil, token)
+		if rec.Code != http.StatusOK {
+			t.Errorf("search for %q returned %d", keyword, rec.Code)
+			continue
+		}
+		var result struct{ Total int; Results []data.Job_summary }
+		json.Unmarshal(rec.Body.Bytes(), &result)
+		if len(result.Results) != expected || result.Total != expected {
+			t.Errorf("search for %q: expected %d results, got %s", keyword, expected, rec.Body.String())
+		}
+	}
+
+	rec = doRequest(http.MethodPut, "/api/job/modify", echo.Map{
+		"job_id": job_id, "title": "O'Brien's Deputy"}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("modify returned %d: %s", rec.Code, rec.Body.String())
+	}
+	query := url.Values{"job_id": {job_id}}
+	rec = doRequest(http.MethodGet, "/api/search/detail?"+query.Encode(), nil, token)
+	var detail data.Job_info
+	json.Unmarshal(rec.Body.Bytes(), &detail)
+	if detail.Title != "O'Brien's Deputy" || detail.Description != "It's 100% \"remote\"" {
+		t.Errorf("detail returned unexpected values: %s", rec.Body.String())
+	}
+
+	// an injected login email must not match any user
+	rec = doRequest(http.MethodPost, "/api/login", data.Credentials{
+		Email: "' OR '1'='1", Password: "' OR '1'='1"}, "")
+	if rec.Code == http.StatusOK {
+		t.Errorf("login with injected email should fail, got %s", rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/search", nil, "' OR '1'='1")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("injected token should be rejected, got %d", rec.Code)
+	}
+}
+
+func TestErrorStatusCodes(t *testing.T) {
+	owner := registerAndLogin(t, "status.owner@example.com", "password one")
+	other := registerAndLogin(t, "status.other@example.com", "password two")
+	rec := doRequest(http.MethodPost, "/api/job/create", data.Job_info{
+		Title: "Status Code Tester", Description: "Checks error responses"}, owner)
+	var created map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	job_id := created["created_job"]
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   interface{}
+		token  string
+		status int
+		code   string
+		field  string
+	}{
+		{"missing token", http.MethodGet, "/api/search", nil, "", http.StatusUnauthorized, data.CodeUnauthorized, ""},
+		{"bad body", http.MethodPost, "/api/job/create", "not an object", owner, http.StatusBadRequest, data.CodeBadRequest, ""},
+		{"invalid field", http.MethodPost, "/api/job/create", data.Job_info{Title: "No description"}, owner,
+			http.StatusUnprocessableEntity, data.CodeValidation, "description"},
+		{"non-integer query", http.MethodGet, "/api/search?salary=lots", nil, owner,
+			http.StatusUnprocessableEntity, data.CodeValidation, "salary"},
+		{"bad sort", http.MethodGet, "/api/search?sort=colour", nil, owner,
+			http.StatusUnprocessableEntity, data.CodeValidation, "sort"},
+		{"unknown job", http.MethodGet, "/api/search/detail?job_id=99999", nil, owner, http.StatusNotFound, data.CodeNotFound, ""},
+		{"not the creator", http.MethodPut, "/api/job/modify", echo.Map{"job_id": job_id, "title": "Mine now"}, other,
+			http.StatusForbidden, data.CodeForbidden, ""},
+		{"apply to own job", http.MethodPost, "/api/job/submit", data.Submission{Job_id: job_id}, owner,
+			http.StatusForbidden, data.CodeForbidden, ""},
+		{"duplicate email", http.MethodPost, "/api/register", data.User_info{Email: "status.owner@example.com",
+			First: "Dup", Last: "User", Phone: "0812345678", Password: "password three"}, "", http.StatusConflict, data.CodeConflict, ""},
+		{"unknown endpoint", http.MethodGet, "/api/nowhere", nil, owner, http.StatusNotFound, data.CodeNotFound, ""},
+	}
+	for _, test := range tests {
+		rec := doRequest(test.method, test.target, test.body, test.token)
+		var response data.Error_response
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		if rec.Code != test.status || response.Status != test.status || response.Code != test.code ||
+			response.Field != test.field || response.Error == "" {
+			t.Errorf("%s: expected %d %s %q, got %d %s", test.name, test.status, test.code, test.field,
+				rec.Code, rec.Body.String())
+		}
+	}
+
+	// applying twice is a conflict
+	doRequest(http.MethodPost, "/api/job/submit", data.Submission{Job_id: job_id}, other)
+	rec = doRequest(http.MethodPost, "/api/job/submit", data.Submission{Job_id: job_id}, other)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("duplicate application should return 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// The text of an internal error, such as one from the database driver,
+// is not sent to the client
+func TestInternalErrorHidden(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := testServer.NewContext(httptest.NewRequest(http.MethodGet, "/api/search", nil), rec)
+	errorResponse(c, errors.New("no such column: user.password_hash in SELECT password_hash FROM user"))
+	var response data.Error_response
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if rec.Code != http.StatusInternalServerError || response.Code != data.CodeInternal || response.Error != "Internal error" {
+		t.Errorf("expected a plain internal error, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestUserProfileEndpoints(t *testing.T) {
+	token := registerAndLogin(t, "profile.rest@example.com", "password one")
+	rec := doRequest(http.MethodPut, "/api/user", data.User_info{
+		Email: "someone.else@example.com", Phone: "0899999999"}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update profile returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/user", nil, token)
+	var profile data.User_info
+	json.Unmarshal(rec.Body.Bytes(), &profile)
+	if profile.Email != "profile.rest@example.com" || profile.Phone != "0899999999" || profile.First != "Rest" {
+		t.Errorf("unexpected profile %s", rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "password") {
+		t.Errorf("profile must not include the password: %s", rec.Body.String())
+	}
+	// 0 is a change, and leaving education out is not
+	rec = doRequest(http.MethodPut, "/api/user", echo.Map{"education": 0}, token)
+	doRequest(http.MethodPut, "/api/user", echo.Map{"first": "Restless"}, token)
+	rec = doRequest(http.MethodGet, "/api/user", nil, token)
+	if !strings.Contains(rec.Body.String(), `"education":0`) {
+		t.Errorf("expected education 0, got %s", rec.Body.String())
+	}
+	rec = doRequest(http.MethodPut, "/api/user", data.User_info{Phone: "12345"}, token)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"field":"phone"`) {
+		t.Errorf("invalid phone should be a validation error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodDelete, "/api/user", nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/user", nil, token)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("token of a deleted user should be rejected, got %d", rec.Code)
+	}
+}
+
+func TestArchiveAndDeleteEndpoints(t *testing.T) {
+	token := registerAndLogin(t, "archive.rest@example.com", "password one")
+	rec := doRequest(http.MethodPost, "/api/job/create", data.Job_info{
+		Title: "Seasonal Picker", Description: "Harvest help", Expires_on: "2000-01-01"}, token)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"field":"expires_on"`) {
+		t.Errorf("a past expiry date should be a validation error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/job/create", data.Job_info{
+		Title: "Seasonal Picker", Description: "Harvest help", Expires_on: "2999-12-31"}, token)
+	var created map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	job_id := created["created_job"]
+	query := url.Values{"job_id": {job_id}}
+	rec = doRequest(http.MethodGet, "/api/search/detail?"+query.Encode(), nil, token)
+	var detail data.Job_info
+	json.Unmarshal(rec.Body.Bytes(), &detail)
+	if !strings.HasPrefix(detail.Expires_on, "3000-01-01T00:00:00") {
+		t.Errorf("the job should expire at the end of the day given, got %q", detail.Expires_on)
+	}
+	rec = doRequest(http.MethodPost, "/api/job/archive?"+query.Encode(), nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("archive returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/search?keyword=Seasonal", nil, token)
+	if strings.Contains(rec.Body.String(), job_id) {
+		t.Errorf("an archived job should not be found: %s", rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/search?keyword=Seasonal&include_archived=true", nil, token)
+	if !strings.Contains(rec.Body.String(), job_id) {
+		t.Errorf("include_archived should find the archived job: %s", rec.Body.String())
+	}
+	rec = doRequest(http.MethodDelete, "/api/job?"+query.Encode(), nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/search/detail?"+query.Encode(), nil, token)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("a deleted job should not be found, got %d", rec.Code)
+	}
+}
+
+func TestModifyJobToZero(t *testing.T) {
+	token := registerAndLogin(t, "patch.owner@example.com", "password one")
+	rec := doRequest(http.MethodPost, "/api/job/create", data.Job_info{
+		Title: "Patch Tester", Description: "Checks partial updates", Min_education: 3, Min_experience: 2, Salary: 40000}, token)
+	var created map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	job_id := created["created_job"]
+	query := url.Values{"job_id": {job_id}}
+
+	changes := []struct {
+		body   echo.Map
+		status int
+	}{
+		{echo.Map{"job_id": job_id, "salary": 0, "min_education": 0}, http.StatusOK},
+		{echo.Map{"job_id": job_id, "is_open": false}, http.StatusOK},
+		{echo.Map{"job_id": job_id, "is_open": false}, http.StatusConflict},
+		{echo.Map{"job_id": job_id, "is_open": true}, http.StatusOK},
+		{echo.Map{"job_id": job_id, "title": ""}, http.StatusUnprocessableEntity},
+		{echo.Map{"job_id": job_id}, http.StatusUnprocessableEntity},
+	}
+	for _, change := range changes {
+		rec = doRequest(http.MethodPut, "/api/job/modify", change.body, token)
+		if rec.Code != change.status {
+			t.Errorf("modify with %v: expected %d, got %d: %s", change.body, change.status, rec.Code, rec.Body.String())
+		}
+	}
+	rec = doRequest(http.MethodGet, "/api/search/detail?"+query.Encode(), nil, token)
+	var detail data.Job_info
+	json.Unmarshal(rec.Body.Bytes(), &detail)
+	if detail.Salary != 0 || detail.Min_education != 0 || detail.Min_experience != 2 || !detail.Is_open ||
+		detail.Title != "Patch Tester" {
+		t.Errorf("only the fields given should change: %s", rec.Body.String())
+	}
+}
+
+func TestOrganizationEndpoints(t *testing.T) {
+	owner := registerAndLogin(t, "org.rest.owner@example.com", "password one")
+	recruiter := registerAndLogin(t, "org.rest.recruiter@example.com", "password two")
+	rec := doRequest(http.MethodPost, "/api/org/create", data.Organization{
+		Name: "Rest Widgets", Website: "javascript:alert(1)"}, owner)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"field":"website"`) {
+		t.Errorf("a website that is not http or https should be a validation error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/org/create", data.Organization{
+		Name: "Rest Widgets", Website: "https://rest.example.com", Location: "Bangkok"}, owner)
+	var created map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	org_id := created["created_org"]
+	if org_id == "" {
+		t.Fatalf("create returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPut, "/api/org/member", data.Member_change{
+		Org_id: org_id, Member: "org.rest.recruiter@example.com", Role: "recruiter"}, owner)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("adding a member returned %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// the recruiter posts a job for the organization, and the owner can change it
+	rec = doRequest(http.MethodPost, "/api/job/create", data.Job_info{
+		Title: "Widget Maker", Description: "Makes widgets", Org_id: org_id}, recruiter)
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	job_id := created["created_job"]
+	rec = doRequest(http.MethodPut, "/api/job/modify", echo.Map{"job_id": job_id, "salary": 25000}, owner)
+	if rec.Code != http.StatusOK {
+		t.Errorf("the owner should modify the recruiter's job, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/search?"+url.Values{"organization": {"rest widg"}}.Encode(), nil, owner)
+	if !strings.Contains(rec.Body.String(), job_id) || !strings.Contains(rec.Body.String(), `"organization":"Rest Widgets"`) {
+		t.Errorf("searching by organization should find the job: %s", rec.Body.String())
+	}
+
+	rec = doRequest(http.MethodGet, "/api/org?"+url.Values{"org_id": {org_id}}.Encode(), nil, recruiter)
+	var org data.Organization
+	json.Unmarshal(rec.Body.Bytes(), &org)
+	if rec.Code != http.StatusOK || len(org.Members) != 2 || org.Location != "Bangkok" {
+		t.Errorf("unexpected organization %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPut, "/api/org", echo.Map{"org_id": org_id, "location": "Phuket"}, recruiter)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("a recruiter should not change the organization, got %d", rec.Code)
+	}
+	rec = doRequest(http.MethodGet, "/api/search/orgs?mine=true", nil, recruiter)
+	if !strings.Contains(rec.Body.String(), `"total":1`) {
+		t.Errorf("the recruiter should belong to one organization: %s", rec.Body.String())
+	}
+	query := url.Values{"org_id": {org_id}, "email": {"org.rest.recruiter@example.com"}}
+	rec = doRequest(http.MethodDelete, "/api/org/member?"+query.Encode(), nil, recruiter)
+	if rec.Code != http.StatusOK {
+		t.Errorf("a member should be able to leave, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestLocationSearch(t *testing.T) {
+	token := registerAndLogin(t, "where.owner@example.com", "password one")
+	rec := doRequest(http.MethodPost, "/api/job/create", data.Job_info{Title: "Whereabouts Tester", Description: "Works near Silom",
+		Province: "Bangkok", City: "Bang Rak", Latitude: 13.7286, Longitude: 100.5340,
+		Work_arrangement: "Hybrid", Employment_type: "part-time"}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	searches := []struct {
+		query  url.Values
+		status int
+		total  int
+		field  string
+	}{
+		{url.Values{"location": {"bang rak"}, "work_arrangement": {"hybrid"}}, http.StatusOK, 1, ""},
+		{url.Values{"latitude": {"13.74"}, "longitude": {"100.53"}, "radius_km": {"5"}}, http.StatusOK, 1, ""},
+		{url.Values{"latitude": {"18.79"}, "longitude": {"98.98"}, "radius_km": {"50"}}, http.StatusOK, 0, ""},
+		{url.Values{"employment_type": {"full-time"}}, http.StatusOK, 0, ""},
+		{url.Values{"work_arrangement": {"sometimes"}}, http.StatusUnprocessableEntity, 0, "work_arrangement"},
+		{url.Values{"radius_km": {"5"}}, http.StatusUnprocessableEntity, 0, "latitude"},
+		{url.Values{"latitude": {"13.74"}, "longitude": {"100.53"}}, http.StatusUnprocessableEntity, 0, "radius_km"},
+		{url.Values{"latitude": {"NaN"}, "longitude": {"100.53"}, "radius_km": {"5"}}, http.StatusUnprocessableEntity, 0, "latitude"},
+		{url.Values{"latitude": {"north"}}, http.StatusUnprocessableEntity, 0, "latitude"},
+	}
+	for _, search := range searches {
+		search.query.Set("keyword", "Whereabouts")
+		rec = doRequest(http.MethodGet, "/api/search?"+search.query.Encode(), nil, token)
+		var page struct {
+			Total   int
+			Results []data.Job_summary
+		}
+		var response data.Error_response
+		json.Unmarshal(rec.Body.Bytes(), &page)
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		if rec.Code != search.status || page.Total != search.total || response.Field != search.field {
+			t.Errorf("search %v: expected %d with %d results, got %d: %s", search.query, search.status, search.total,
+				rec.Code, rec.Body.String())
+		}
+		if search.total > 0 && (page.Results[0].City != "Bang Rak" || page.Results[0].Work_arrangement != data.WorkHybrid) {
+			t.Errorf("summaries should include the location, got %s", rec.Body.String())
+		}
+	}
+}
+
+func TestSkills(t *testing.T) {
+	token := registerAndLogin(t, "skills.owner@example.com", "password one")
+	rec := doRequest(http.MethodPost, "/api/job/create", data.Job_info{Title: "Skillful Tester", Description: "Tests with skill",
+		Skills: []data.Job_skill{{Name: " Apitest  Go ", Required: true}, {Name: "apitest sql"}}}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create failed: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPut, "/api/user", map[string]interface{}{
+		"skills": []data.User_skill{{Name: "apitest go", Proficiency: 4}}}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update profile failed: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPut, "/api/user", map[string]interface{}{
+		"skills": []data.User_skill{{Name: "apitest go", Proficiency: 6}}}, token)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("a proficiency of 6 should be rejected, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(http.MethodGet, "/api/search?skills=apitest+go,+apitest+sql&sort=match", nil, token)
+	var page struct {
+		Total   int
+		Results []data.Job_summary
+	}
+	json.Unmarshal(rec.Body.Bytes(), &page)
+	// (2*4 + 1*0) / (5 * 3) = 53%
+	if rec.Code != http.StatusOK || page.Total != 1 || page.Results[0].Match_score == nil || *page.Results[0].Match_score != 53 {
+		t.Errorf("expected the job with a score of 53, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/search?skills=apitest+go,apitest+go", nil, token)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("a repeated skill should be rejected, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/skills?keyword=apitest", nil, token)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"total":2`) {
+		t.Errorf("expected the two skills, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestUnmetRequirements(t *testing.T) {
+	owner := registerAndLogin(t, "unmet.owner@example.com", "password one")
+	rec := doRequest(http.MethodPost, "/api/register", map[string]interface{}{
+		"email": "unmet.applicant@example.com", "first": "Una", "last": "Met", "phone": "0812345678", "education": 3,
+		"password": "password two", "experience": 2,
+		"work_history": []data.Work_entry{{Title: "Trainee", Employer: "Acme", Start_date: "2023-01-09"}}}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("register failed: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/login", data.Credentials{Email: "unmet.applicant@example.com", Password: "password two"}, "")
+	var session map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &session)
+	applicant := session["token"]
+
+	rec = doRequest(http.MethodGet, "/api/user", nil, applicant)
+	var profile data.User_info
+	json.Unmarshal(rec.Body.Bytes(), &profile)
+	if profile.Experience == nil || *profile.Experience != 2 || len(profile.Work_history) != 1 {
+		t.Errorf("the profile should have the experience and work history, got %s", rec.Body.String())
+	}
+	rec = doRequest(http.MethodPut, "/api/user", map[string]interface{}{
+		"work_history": []data.Work_entry{{Title: "Trainee", Employer: "Acme", Start_date: "2023-01-09", End_date: "2022-12-31"}}}, applicant)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "work_history") {
+		t.Errorf("an end before the start should be rejected, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(http.MethodPost, "/api/job/create", data.Job_info{Title: "Unmet Expectations", Description: "Needs five years",
+		Min_education: 2, Min_experience: 5}, owner)
+	var created map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	rec = doRequest(http.MethodPost, "/api/job/submit", data.Submission{Job_id: created["created_job"]}, applicant)
+	var result data.Submit_result
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if rec.Code != http.StatusOK || result.Warning == "" || len(result.Unmet_requirements) != 1 ||
+		result.Unmet_requirements[0].Requirement != data.RequirementExperience || result.Unmet_requirements[0].Actual != 2 {
+		t.Errorf("expected the application with unmet experience, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/search/candidates?qualified=true&job_id="+created["created_job"], nil, owner)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"total":0`) {
+		t.Errorf("no candidate should be qualified, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/search/candidates?qualified=maybe&job_id="+created["created_job"], nil, owner)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("qualified must be a boolean, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestResumeAttachments(t *testing.T) {
+	owner := registerAndLogin(t, "attach.owner@example.com", "password one")
+	applicant := registerAndLogin(t, "attach.applicant@example.com", "password two")
+	other := registerAndLogin(t, "attach.other@example.com", "password three")
+	var job_ids []string
+	for _, title := range []string{"Attached Writer", "Attached Editor"} {
+		rec := doRequest(http.MethodPost, "/api/job/create", data.Job_info{Title: title, Description: "Words"}, owner)
+		var created map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &created)
+		job_ids = append(job_ids, created["created_job"])
+	}
+	pdf := []byte("%PDF-1.7 uploaded resume")
+
+	// only PDF and DOCX files, whose content matches the name
+	rec := doUpload(http.MethodPost, "/api/job/submit", map[string]string{"job_id": job_ids[0]}, "cv.txt", []byte("plain text"), applicant)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "PDF or DOCX") {
+		t.Errorf("a text file should be refused, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doUpload(http.MethodPost, "/api/job/submit", map[string]string{"job_id": job_ids[0]}, "cv.docx", pdf, applicant)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("a PDF named .docx should be refused, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doUpload(http.MethodPost, "/api/job/submit", map[string]string{"job_id": job_ids[0]}, "big.pdf",
+		append([]byte("%PDF-"), make([]byte, data.MaxResumeBytes)...), applicant)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "5 MB") {
+		t.Errorf("a file over 5 MB should be refused, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/job/submit", data.Submission{Job_id: job_ids[0], Cover_letter: strings.Repeat("x", 4001)}, applicant)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "cover_letter") {
+		t.Errorf("a long cover letter should be refused, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doUpload(http.MethodPost, "/api/job/submit", map[string]string{"job_id": job_ids[0], "cover_letter": "Please hire me"},
+		"C:\\Users\\me\\cv.pdf", pdf, applicant)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("submit with a resume failed: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/search/candidates?job_id="+job_ids[0], nil, owner)
+	var candidates struct {
+		Results []data.Candidate `json:"results"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &candidates)
+	if len(candidates.Results) != 1 || candidates.Results[0].Cover_letter != "Please hire me" || candidates.Results[0].Resume == nil ||
+		candidates.Results[0].Resume.File_name != "cv.pdf" || candidates.Results[0].Resume.Size != len(pdf) {
+		t.Errorf("the candidate should have the cover letter and resume, got %s", rec.Body.String())
+	}
+
+	// only the job creator can download it
+	query := "/api/job/resume?job_id=" + job_ids[0] + "&email=attach.applicant@example.com"
+	rec = doRequest(http.MethodGet, query, nil, owner)
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), pdf) || rec.Header().Get(echo.HeaderContentType) != data.ContentTypePdf ||
+		!strings.Contains(rec.Header().Get(echo.HeaderContentDisposition), `filename=cv.pdf`) {
+		t.Errorf("expected the resume as a download, got %d %v: %s", rec.Code, rec.Header(), rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, query, nil, other)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("another user should not get the resume, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// the default resume is sent with applications made without one
+	rec = doUpload(http.MethodPut, "/api/user/resume", nil, "default.pdf", []byte("%PDF-default"), applicant)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "default.pdf") {
+		t.Fatalf("upload of the default resume failed: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/user", nil, applicant)
+	if !strings.Contains(rec.Body.String(), `"file_name":"default.pdf"`) {
+		t.Errorf("the profile should describe the default resume, got %s", rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/user/resume", nil, applicant)
+	if rec.Code != http.StatusOK || rec.Body.String() != "%PDF-default" {
+		t.Errorf("expected the default resume, got %d: %s", rec.Code, rec.Body.String())
+	}
+	doRequest(http.MethodPost, "/api/job/submit", data.Submission{Job_id: job_ids[1]}, applicant)
+	rec = doRequest(http.MethodDelete, "/api/user/resume", nil, applicant)
+	if rec.Code != http.StatusOK {
+		t.Errorf("removing the default resume failed: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/job/resume?job_id="+job_ids[1]+"&email=attach.applicant@example.com", nil, owner)
+	if rec.Code != http.StatusOK || rec.Body.String() != "%PDF-default" {
+		t.Errorf("the application should keep the default resume, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/user/resume", nil, applicant)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("the default resume should be gone, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSavedSearchEndpoints(t *testing.T) {
+	seeker := registerAndLogin(t, "saved.rest.seeker@example.com", "password one")
+	other := registerAndLogin(t, "saved.rest.other@example.com", "password two")
+	rec := doRequest(http.MethodPost, "/api/saved-searches", echo.Map{"name": " ", "criteria": echo.Map{"keyword": "plumber"}}, seeker)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"field":"name"`) {
+		t.Errorf("a search without a name should be a validation error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/saved-searches", echo.Map{"name": "Plumbing",
+		"criteria": echo.Map{"keyword": "plumber", "salary": 15000, "posted_from": "2020-01-01"}}, seeker)
+	var created map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	search_id := created["created_search"]
+	if search_id == "" {
+		t.Fatalf("saving a search returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/saved-searches", echo.Map{"name": "Plumbing"}, seeker)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("a second search with the same name should conflict, got %d", rec.Code)
+	}
+
+	doRequest(http.MethodPost, "/api/job/create", data.Job_info{Title: "Plumber", Description: "Fixes pipes", Salary: 18000}, other)
+	doRequest(http.MethodPost, "/api/job/create", data.Job_info{Title: "Apprentice Plumber", Description: "Learns to fix pipes", Salary: 9000}, other)
+	rec = doRequest(http.MethodGet, "/api/saved-searches/alerts", nil, seeker)
+	var alerts data.Search_alerts
+	json.Unmarshal(rec.Body.Bytes(), &alerts)
+	if rec.Code != http.StatusOK || alerts.Total_new != 1 || len(alerts.Alerts) != 1 || alerts.Alerts[0].Search_id != search_id {
+		t.Errorf("expected one new match, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/saved-searches", nil, seeker)
+	if !strings.Contains(rec.Body.String(), `"new_matches":1`) || !strings.Contains(rec.Body.String(), `"keyword":"plumber"`) {
+		t.Errorf("the listing should show the criteria and new matches: %s", rec.Body.String())
+	}
+
+	query := url.Values{"search_id": {search_id}}
+	rec = doRequest(http.MethodGet, "/api/saved-searches/results?"+query.Encode(), nil, other)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("another user should not run the search, got %d", rec.Code)
+	}
+	rec = doRequest(http.MethodGet, "/api/saved-searches/results?"+query.Encode(), nil, seeker)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"total":1`) {
+		t.Errorf("running the search should find the job, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/saved-searches/alerts", nil, seeker)
+	if !strings.Contains(rec.Body.String(), `"total_new":0`) || !strings.Contains(rec.Body.String(), `"alerts":[]`) {
+		t.Errorf("running the search should clear its alert: %s", rec.Body.String())
+	}
+	rec = doRequest(http.MethodDelete, "/api/saved-searches?"+query.Encode(), nil, seeker)
+	if rec.Code != http.StatusOK {
+		t.Errorf("deleting the search returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodDelete, "/api/saved-searches?search_id=abc", nil, seeker)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("an invalid search_id should be a validation error, got %d", rec.Code)
+	}
+}
+
+func TestBookmarkEndpoints(t *testing.T) {
+	seeker := registerAndLogin(t, "bookmark.rest.seeker@example.com", "password one")
+	boss := registerAndLogin(t, "bookmark.rest.boss@example.com", "password two")
+	rec := doRequest(http.MethodPost, "/api/job/create", data.Job_info{Title: "Glassblower", Description: "Blows glass", Salary: 16000}, boss)
+	var created map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	job_id := created["created_job"]
+	if job_id == "" {
+		t.Fatalf("creating the job returned %d: %s", rec.Code, rec.Body.String())
+	}
+
+	query := url.Values{"job_id": {job_id}}
+	rec = doRequest(http.MethodPost, "/api/bookmarks?"+query.Encode(), nil, seeker)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"bookmarked":"`+job_id+`"`) {
+		t.Fatalf("bookmarking the job returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/bookmarks?"+query.Encode(), nil, seeker)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("a second bookmark should conflict, got %d", rec.Code)
+	}
+	rec = doRequest(http.MethodPost, "/api/bookmarks?job_id=abc", nil, seeker)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("an invalid job_id should be a validation error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/search?keyword=Glassblower", nil, seeker)
+	if !strings.Contains(rec.Body.String(), `"bookmarked":true`) {
+		t.Errorf("the search should flag the bookmarked job: %s", rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/search?keyword=Glassblower", nil, boss)
+	if !strings.Contains(rec.Body.String(), `"bookmarked":false`) {
+		t.Errorf("the job is not bookmarked by its creator: %s", rec.Body.String())
+	}
+
+	closed := false
+	doRequest(http.MethodPut, "/api/job/modify", data.Job_changes{Job_id: job_id, Is_open: &closed}, boss)
+	rec = doRequest(http.MethodGet, "/api/bookmarks", nil, seeker)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"total":1`) || !strings.Contains(rec.Body.String(), `"filled":"`) {
+		t.Errorf("the listing should show the filled job, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodDelete, "/api/bookmarks?"+query.Encode(), nil, seeker)
+	if rec.Code != http.StatusOK {
+		t.Errorf("removing the bookmark returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodDelete, "/api/bookmarks?"+query.Encode(), nil, seeker)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("removing it again should not be found, got %d", rec.Code)
+	}
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	boss := registerAndLogin(t, "hook.rest.boss@example.com", "password one")
+	other := registerAndLogin(t, "hook.rest.other@example.com", "password two")
+	rec := doRequest(http.MethodPost, "/api/webhooks", echo.Map{"url": "http://localhost:9999/hook", "events": []string{"job.deleted"}}, boss)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"field":"events"`) {
+		t.Errorf("an unknown event should be a validation error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/webhooks", echo.Map{"url": "ftp://localhost/hook", "events": []string{"job.created"}}, boss)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"field":"url"`) {
+		t.Errorf("a URL that is not http should be a validation error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/webhooks", echo.Map{"url": "http://localhost:9999/hook",
+		"events": []string{"job.created", " application.submitted"}}, boss)
+	var created data.Webhook
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusOK || created.Webhook_id == "" || created.Secret == "" || len(created.Events) != 2 {
+		t.Fatalf("registering the webhook returned %d: %s", rec.Code, rec.Body.String())
+	}
+
+	doRequest(http.MethodPost, "/api/job/create", data.Job_info{Title: "Cartographer", Description: "Draws maps", Salary: 19000}, boss)
+	rec = doRequest(http.MethodGet, "/api/webhooks", nil, boss)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"total":1`) || strings.Contains(rec.Body.String(), created.Secret) {
+		t.Errorf("the listing should show the webhook without its secret, got %d: %s", rec.Code, rec.Body.String())
+	}
+	query := url.Values{"webhook_id": {created.Webhook_id}}
+	rec = doRequest(http.MethodGet, "/api/webhooks/deliveries?"+query.Encode(), nil, boss)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"event":"job.created"`) || !strings.Contains(rec.Body.String(), `"status":"pending"`) {
+		t.Errorf("expected a pending delivery for the new job, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/webhooks/deliveries?"+query.Encode(), nil, other)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("another user should not see the deliveries, got %d", rec.Code)
+	}
+	rec = doRequest(http.MethodDelete, "/api/webhooks?"+query.Encode(), nil, boss)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"deleted_webhook":"`+created.Webhook_id+`"`) {
+		t.Errorf("deleting the webhook returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodDelete, "/api/webhooks?webhook_id=abc", nil, boss)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("an invalid webhook_id should be a validation error, got %d", rec.Code)
+	}
+}
+
+func TestNotificationEndpoints(t *testing.T) {
+	token := registerAndLogin(t, "notify.rest@example.com", "password one")
+	rec := doRequest(http.MethodGet, "/api/user/notifications", nil, token)
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"language":"en","application_received":true,"hired":true,"rejected":true}`+"\n" {
+		t.Errorf("expected the default preferences, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPut, "/api/user/notifications", echo.Map{"language": "fr"}, token)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"field":"language"`) {
+		t.Errorf("an unknown language should be a validation error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPut, "/api/user/notifications", echo.Map{}, token)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("a change with nothing in it should be a validation error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPut, "/api/user/notifications", echo.Map{"language": "th", "application_received": false}, token)
+	var prefs data.Notification_preferences
+	json.Unmarshal(rec.Body.Bytes(), &prefs)
+	if rec.Code != http.StatusOK || prefs.Language != data.LanguageThai || prefs.Application_received || !prefs.Hired {
+		t.Errorf("changing the preferences returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/user/notifications", nil, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("the preferences should need a login, got %d", rec.Code)
+	}
+}
+
+func TestMessageEndpoints(t *testing.T) {
+	seeker := registerAndLogin(t, "message.rest.seeker@example.com", "password one")
+	boss := registerAndLogin(t, "message.rest.boss@example.com", "password two")
+	outsider := registerAndLogin(t, "message.rest.other@example.com", "password three")
+	rec := doRequest(http.MethodPost, "/api/job/create", data.Job_info{Title: "Cartographer", Description: "Draws maps", Salary: 21000}, boss)
+	var created map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	job_id := created["created_job"]
+	doRequest(http.MethodPost, "/api/job/submit", data.Submission{Job_id: job_id}, seeker)
+
+	rec = doRequest(http.MethodPost, "/api/messages", echo.Map{"job_id": job_id, "applicant": "message.rest.seeker@example.com", "body": "When can you start?"}, boss)
+	var sent data.Message
+	json.Unmarshal(rec.Body.Bytes(), &sent)
+	if rec.Code != http.StatusOK || sent.Sender != "message.rest.boss@example.com" || sent.Message_id == "" {
+		t.Fatalf("sending a message returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/messages", echo.Map{"job_id": job_id, "body": "Next week"}, seeker)
+	if rec.Code != http.StatusOK {
+		t.Errorf("the applicant should reply without naming themselves, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/messages", echo.Map{"job_id": job_id, "body": "  "}, seeker)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"field":"body"`) {
+		t.Errorf("a blank message should be a validation error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/messages", echo.Map{"job_id": job_id, "body": strings.Repeat("x", data.MaxMessageLength+1)}, seeker)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("a long message should be a validation error, got %d", rec.Code)
+	}
+
+	query := url.Values{"job_id": {job_id}, "applicant": {"message.rest.seeker@example.com"}}
+	rec = doRequest(http.MethodGet, "/api/messages?"+query.Encode(), nil, outsider)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("another user should not read the thread, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/messages?"+query.Encode(), nil, boss)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"total":2`) || !strings.Contains(rec.Body.String(), "Next week") {
+		t.Errorf("listing the thread returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/messages/unread", nil, seeker)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"total":1`) || !strings.Contains(rec.Body.String(), "Cartographer") {
+		t.Errorf("expected one unread message, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodPost, "/api/messages/read?job_id="+job_id, nil, seeker)
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"marked_read":1}`+"\n" {
+		t.Errorf("marking the thread read returned %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(http.MethodGet, "/api/messages/unread", nil, seeker)
+	if rec.Body.String() != `{"total":0,"threads":[]}`+"\n" {
+		t.Errorf("expected nothing unread, got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,129 @@
+package data
+// Error types shared by dbaccess, helper, the REST API and the command line
+// Every error a user can cause is an App_error with a machine-readable code,
+// which decides the HTTP status. Anything else is an internal error, whose
+// text is logged but never sent, since it can show SQL and table names
+
+import (
+    "errors"
+    "log"
+    "net/http"
+)
+
+// Error codes
+const (
+    CodeBadRequest   = "bad_request"    // request could not be read at all
+    CodeUnauthorized = "unauthorized"   // not logged in, or wrong password
+    CodeForbidden    = "forbidden"      // logged in but not allowed to do this
+    CodeNotFound     = "not_found"      // the job, user or application does not exist
+    CodeConflict     = "conflict"       // not possible in the current state, such as a duplicate
+    CodeValidation   = "validation"     // an argument has an invalid value
+    CodeInternal     = "internal"       // database or other unexpected failure
+)
+
+// The message sent for every internal error
+const internalMessage = "Internal error"
+
+// HTTP status for each error code
+var codeStatus = map[string]int{
+    CodeBadRequest:   http.StatusBadRequest,
+    CodeUnauthorized: http.StatusUnauthorized,
+    CodeForbidden:    http.StatusForbidden,
+    CodeNotFound:     http.StatusNotFound,
+    CodeConflict:     http.StatusConflict,
+    CodeValidation:   http.StatusUnprocessableEntity,
+    CodeInternal:     http.StatusInternalServerError,
+}
+
+// An error with a code. Field names the argument at fault, if any
+type App_error struct {
+    Code            string
+    Field           string
+    Message         string
+}
+
+func (e *App_error) Error() string {
+    return e.Message
+}
+
+// The single JSON shape for errors from both the REST API and the command line
+type Error_response struct {
+    Error           string   `json:"error"`            // message for people
+    Code            string   `json:"code"`             // one of the Code constants
+    Field           string   `json:"field,omitempty"`
+    Status          int      `json:"status"`           // HTTP status
+}
+
+func BadRequestError(message string) error {
+    return &App_error{Code: CodeBadRequest, Message: message}
+}
+
+func UnauthorizedError(message string) error {
+    return &App_error{Code: CodeUnauthorized, Message: message}
+}
+
+func ForbiddenError(message string) error {
+    return &App_error{Code: CodeForbidden, Message: message}
+}
+
+func NotFoundError(message string) error {
+    return &App_error{Code: CodeNotFound, Message: message}
+}
+
+func ConflictError(message string) error {
+    return &App_error{Code: CodeConflict, Message: message}
+}
+
+// The field is the JSON name of the argument, such as "job_id"
+func ValidationError(field string, message string) error {
+    return &App_error{Code: CodeValidation, Field: field, Message: message}
+}
+
+// Return the code of an error, CodeInternal if it is not an App_error
+func ErrorCode(err error) string {
+    var app_err *App_error
+    if errors.As(err, &app_err) {
+        return app_err.Code
+    }
+    return CodeInternal
+}
+
+// Return the HTTP status for an error
+func ErrorStatus(err error) int {
+    status, found := codeStatus[ErrorCode(err)]
+    if !found {
+        return http.StatusInternalServerError
+    }
+    return status
+}
+
+// Return the error code that corresponds to an HTTP status
+// Used for errors that come with a status but no code
+func ErrorCodeForStatus(status int) string {
+    for code, code_status := range codeStatus {
+        if code_status == status {
+            return code
+        }
+    }
+    if status >= 400 && status < 500 {
+        return CodeBadRequest
+    }
+    return CodeInternal
+}
+
+// Build the JSON error response for an error
+// An internal error is logged, and the response only says that it happened
+func New_error_response(err error) (response Error_response) {
+    response.Error = err.Error()
+    response.Code = ErrorCode(err)
+    if response.Code == CodeInternal {
+        log.Printf("Internal error: %v", err)
+        response.Error = internalMessage
+    }
+    response.Status = ErrorStatus(err)
+    var app_err *App_error
+    if errors.As(err, &app_err) {
+        response.Field = app_err.Field
+    }
+    return response
+}
@@ -0,0 +1,60 @@
+package data
+// Time stamps shared by dbaccess, helper, the REST API and the command line
+// Every store keeps time stamps in UTC as ISO-8601 text, for instance
+// 2025-06-27T06:41:00Z, which sorts correctly as a string. They are shown
+// to users in RFC3339 in the display time zone, which is set from
+// JOBWIZARD_TIMEZONE (an IANA name such as Asia/Bangkok) and is the
+// server's local time zone if that is not set
+
+import (
+    "fmt"
+    "time"
+    _ "time/tzdata"   // so zone names work where the OS has no zone database
+)
+
+// Format of the time stamps in every store
+const StoredTimeFormat = "2006-01-02T15:04:05Z"
+
+// Format of a date given by the user
+const DateFormat = "2006-01-02"
+
+var displayZone = time.Local
+
+// Set the display time zone from an IANA name. An empty name means local time
+func SetDisplayZone(name string) (err error) {
+    if name == "" {
+        displayZone = time.Local
+        return nil
+    }
+    zone, err := time.LoadLocation(name)
+    if err != nil {
+        return fmt.Errorf("Unknown time zone %s in JOBWIZARD_TIMEZONE", name)
+    }
+    displayZone = zone
+    return nil
+}
+
+// Return the time zone used to show time stamps and to interpret dates
+func DisplayZone() *time.Location {
+    return displayZone
+}
+
+// Format a time for storage
+func StoredTime(t time.Time) string {
+    return t.UTC().Format(StoredTimeFormat)
+}
+
+// Return the current time, formatted for storage
+func StoredNow() string {
+    return StoredTime(time.Now())
+}
+
+// Convert a stored time stamp to RFC3339 in the display time zone
+// Anything that is not a stored time stamp is returned unchanged
+func DisplayTime(stored string) string {
+    t, err := time.Parse(StoredTimeFormat, stored)
+    if err != nil {
+        return stored
+    }
+    return t.In(displayZone).Format(time.RFC3339)
+}
@@ -1,5 +1,7 @@
 package data
 // Structure types used for passing information around
+// The dates returned, such as Date_posted, are RFC3339 times in the
+// display time zone; see timestamps.go
 // Created by Sally Goldin 23 June 2025
 
 // Used for registering a new user
@@ -8,7 +10,18 @@ type User_info struct {
     First           string   `json:"first"`
     Last            string   `json:"last"`   
     Phone           string   `json:"phone"`
-    Education       int      `json:"education"`
+    Education       *int     `json:"education"`    // 0 to 4; when changing a profile, null leaves it alone
+    Experience      *int     `json:"experience"`   // years of work experience; when changing a profile, null leaves it alone
+    Password        string   `json:"password,omitempty"`
+    Skills          []User_skill `json:"skills"`   // when changing a profile, null leaves them alone
+    Work_history    []Work_entry `json:"work_history"`   // most recent first; null leaves it alone
+    Resume          *Document `json:"resume,omitempty"`  // the default resume for applications, if any
+}
+
+// Used to log in to the REST API
+type Credentials struct {
+    Email           string   `json:"email"`
+    Password        string   `json:"password"`
 }
 
 // Used both for input and output - to create jobs as well as to return job detail
@@ -22,30 +35,156 @@ type Job_info struct {
     Salary          int       `json:"salary"` 
     Is_open         bool      `json:"is_open"`
     Date_posted     string    `json:"date_posted"`
+    Expires_on      string    `json:"expires_on,omitempty"`  // YYYY-MM-DD or RFC3339 when creating
+    Is_archived     bool      `json:"is_archived"`
+    Org_id          string    `json:"org_id,omitempty"`      // organization offering the job, if any
+    Organization    string    `json:"organization,omitempty"` // its name, returned with the detail
+    Province        string    `json:"province"`
+    City            string    `json:"city"`
+    Latitude        float64   `json:"latitude,omitempty"`    // 0,0 means the position is not given
+    Longitude       float64   `json:"longitude,omitempty"`
+    Work_arrangement string   `json:"work_arrangement"`      // one of Work_arrangements, or "" if not stated
+    Employment_type string    `json:"employment_type"`       // one of Employment_types, or "" if not stated
+    Skills          []Job_skill `json:"skills"`                // required skills first, then by name
+}
+
+// Used to modify a job. Only the fields given are changed, so that
+// any of them can be set to any valid value, including 0, "" or false
+// An empty Expires_on removes the expiry date
+type Job_changes struct {
+    Creator         string    `json:"creator"`
+    Job_id          string    `json:"job_id"`
+    Title           *string   `json:"title,omitempty"`
+    Description     *string   `json:"description,omitempty"`
+    Min_education   *int      `json:"min_education,omitempty"`
+    Min_experience  *int      `json:"min_experience,omitempty"`
+    Salary          *int      `json:"salary,omitempty"`
+    Is_open         *bool     `json:"is_open,omitempty"`
+    Expires_on      *string   `json:"expires_on,omitempty"`
+    Province        *string   `json:"province,omitempty"`
+    City            *string   `json:"city,omitempty"`
+    Latitude        *float64  `json:"latitude,omitempty"`    // latitude and longitude go together
+    Longitude       *float64  `json:"longitude,omitempty"`
+    Work_arrangement *string  `json:"work_arrangement,omitempty"`
+    Employment_type *string   `json:"employment_type,omitempty"`
+    Skills          *[]Job_skill `json:"skills,omitempty"`     // replaces all the job's skills
 }
 
 // Used to return information from a job search
+// For a keyword search, Title_highlight and Snippet have the matching
+// words wrapped in <mark></mark>
 type Job_summary struct {
     Job_id          string    `json:"job_id"`
     Title           string    `json:"title"`
     Is_open         bool      `json:"is_open"`
     Date_posted     string    `json:"date_posted"`    
+    Expires_on      string    `json:"expires_on,omitempty"`
+    Is_archived     bool      `json:"is_archived,omitempty"`
+    Org_id          string    `json:"org_id,omitempty"`
+    Organization    string    `json:"organization,omitempty"`
+    Province        string    `json:"province,omitempty"`
+    City            string    `json:"city,omitempty"`
+    Work_arrangement string   `json:"work_arrangement,omitempty"`
+    Employment_type string    `json:"employment_type,omitempty"`
+    Match_score     *int      `json:"match_score,omitempty"`  // see Job_skill; null if the job lists no skills
+    Bookmarked      *bool     `json:"bookmarked,omitempty"`   // only in searches made for a user
+    Title_highlight string    `json:"title_highlight,omitempty"`
+    Snippet         string    `json:"snippet,omitempty"`
+}
+
+// Used to return the jobs a user has applied for, with the
+// state of each application and every status it has passed through
+type Application_summary struct {
+    Job_summary
+    Applied_date    string          `json:"applied_date"`
+    Status          string          `json:"status"`
+    Status_date     string          `json:"status_date"`
+    History         []Status_entry  `json:"history"`
 }
 
-// Used to search for jobs
+// One step in the status history of an application
+type Status_entry struct {
+    Status          string    `json:"status"`
+    Changed         string    `json:"changed"`   // RFC3339 time
+}
+
+// Used to search for jobs. Saved searches keep the criteria as JSON,
+// without the user, who is given with each search
 type Search_criteria struct {
-    User_email       string         
-    Posted           string 
-    Experience       int    
-    Education        int
-    Salary           int
-    Keyword          string
+    User_email       string   `json:"-"`
+    Posted_from      string   `json:"posted_from,omitempty"`  // YYYY-MM-DD or RFC3339; see ValidateSearchCriteria
+    Posted_to        string   `json:"posted_to,omitempty"`
+    Experience       int      `json:"experience,omitempty"`
+    Education        int      `json:"education,omitempty"`
+    Salary           int      `json:"salary,omitempty"`
+    Keyword          string   `json:"keyword,omitempty"`
+    Include_archived bool     `json:"include_archived,omitempty"`  // also return archived and expired jobs
+    Org_id           string   `json:"org_id,omitempty"`        // only jobs offered by this organization
+    Organization     string   `json:"organization,omitempty"`  // only jobs offered by organizations whose name contains this
+    Location         string   `json:"location,omitempty"`      // only jobs whose city or province contains this
+    Work_arrangement string   `json:"work_arrangement,omitempty"`
+    Employment_type  string   `json:"employment_type,omitempty"`
+    Latitude         float64  `json:"latitude,omitempty"`      // with Radius_km, only jobs within that distance of this point
+    Longitude        float64  `json:"longitude,omitempty"`
+    Radius_km        float64  `json:"radius_km,omitempty"`
+    Skills           []string `json:"skills,omitempty"`        // only jobs that list all of these skills
+    After_job_id     int      `json:"-"`                       // only jobs with a higher ID; used for new matches
+}
+
+// Used to request one page of a listing, sorted by one of the
+// sort fields for that listing. A zero Limit means no limit
+type Page_request struct {
+    Limit            int
+    Offset           int
+    Sort             string
+    Order            string   // "asc" or "desc"
+}
+
+// Fields that listings can be sorted by
+var Job_sort_fields = []string{"relevance", "posted", "title", "salary", "job_id", "match"}
+var Application_sort_fields = []string{"applied", "posted", "title", "status", "job_id"}
+var Candidate_sort_fields = []string{"applied", "name", "status", "match"}
+var Organization_sort_fields = []string{"name", "created", "org_id"}
+var Saved_search_sort_fields = []string{"name", "created"}
+var Bookmark_sort_fields = []string{"bookmarked", "posted", "title", "salary", "job_id"}
+var Webhook_sort_fields = []string{"created", "url"}
+var Delivery_sort_fields = []string{"created", "status"}
+var Message_sort_fields = []string{"sent"}
+
+// Envelope for returning one page of a listing
+// Next_offset is null when there are no more results
+type Result_page struct {
+    Total           int          `json:"total"`
+    Offset          int          `json:"offset"`
+    Limit           int          `json:"limit"`
+    Next_offset     *int         `json:"next_offset"`
+    Results         interface{}  `json:"results"`
+    Warning         string       `json:"warning,omitempty"`
+}
+
+// Wrap a page of results, where returned is the number of entries
+// in results and total is the number matching without paging
+func New_result_page(results interface{}, returned int, total int, page Page_request) (result Result_page) {
+    result.Total = total
+    result.Offset = page.Offset
+    result.Limit = page.Limit
+    result.Results = results
+    if returned == 0 {
+        result.Results = []interface{}{}
+    }
+    next := page.Offset + returned
+    if returned > 0 && next < total {
+        result.Next_offset = &next
+    }
+    return result
 }
 
 // Used to apply for a job
 type Submission struct {
     Email           string   `json:"email"`
     Job_id          string   `json:"job_id"`   
+    Cover_letter    string   `json:"cover_letter"`
+    Resume          *Document `json:"-"`  // uploaded separately; nil to use the default resume
 }
 
 // Used for returning information about an applicant
@@ -54,4 +193,375 @@ type Candidate struct {
     Name            string   `json:"name"`  // concatenated first and last name
     Phone           string   `json:"phone"`
     Applied_date    string   `json:"applied_date"`
-}
\ No newline at end of file
+    Status          string   `json:"status"`
+    Status_date     string   `json:"status_date"`
+    Match_score     *int     `json:"match_score,omitempty"`  // null if the job lists no skills
+    Education       int      `json:"education"`
+    Experience      int      `json:"experience"`
+    Qualified       bool     `json:"qualified"`  // true if the candidate meets all the job's requirements
+    Cover_letter    string   `json:"cover_letter,omitempty"`
+    Resume          *Document `json:"resume,omitempty"`  // null if no resume was sent
+}
+
+// Used by a job creator to move an application to a new status
+type Status_change struct {
+    Creator         string   `json:"creator"`
+    Job_id          string   `json:"job_id"`
+    Applicant       string   `json:"email"`   // email of the applicant
+    Status          string   `json:"status"`
+}
+
+// Application statuses, in the order an application normally moves through them
+// Hired, rejected and withdrawn are final
+const (
+    StatusSubmitted    = "submitted"
+    StatusReviewed     = "reviewed"
+    StatusShortlisted  = "shortlisted"
+    StatusInterviewing = "interviewing"
+    StatusOffered      = "offered"
+    StatusHired        = "hired"
+    StatusRejected     = "rejected"
+    StatusWithdrawn    = "withdrawn"
+)
+
+var Application_statuses = [...]string{StatusSubmitted, StatusReviewed, StatusShortlisted,
+    StatusInterviewing, StatusOffered, StatusHired, StatusRejected, StatusWithdrawn}
+
+// Return true if no further changes are allowed once an application has this status
+func IsFinalStatus(status string) bool {
+    return status == StatusHired || status == StatusRejected || status == StatusWithdrawn
+}
+
+// Used both for input and output - to create or change an employer
+// organization as well as to return its profile
+// Creator is the user making the request; it is not returned
+type Organization struct {
+    Org_id          string        `json:"org_id"`
+    Creator         string        `json:"creator,omitempty"`
+    Name            string        `json:"name"`
+    Description     string        `json:"description"`
+    Website         string        `json:"website"`
+    Logo_url        string        `json:"logo_url"`
+    Location        string        `json:"location"`
+    Created         string        `json:"created"`
+    Members         []Org_member  `json:"members,omitempty"`  // only with the detail
+}
+
+// A user who belongs to an organization
+type Org_member struct {
+    Email           string   `json:"email"`
+    Name            string   `json:"name"`   // concatenated first and last name
+    Role            string   `json:"role"`
+    Added           string   `json:"added"`
+}
+
+// Used by an organization owner to add a member, change a member's role
+// or remove a member. Members can also remove themselves
+type Member_change struct {
+    Creator         string   `json:"creator"`   // email of the user making the change
+    Org_id          string   `json:"org_id"`
+    Member          string   `json:"email"`     // email of the member
+    Role            string   `json:"role"`
+}
+
+// Roles in an organization. Every member can post and manage the
+// organization's jobs; only owners can change the organization or its members
+const (
+    RoleOwner     = "owner"
+    RoleRecruiter = "recruiter"
+)
+
+var Org_roles = [...]string{RoleOwner, RoleRecruiter}
+
+// Where the work is done
+const (
+    WorkOnsite = "onsite"
+    WorkHybrid = "hybrid"
+    WorkRemote = "remote"
+)
+
+var Work_arrangements = [...]string{WorkOnsite, WorkHybrid, WorkRemote}
+
+// Kinds of employment a job offers
+const (
+    EmploymentFullTime   = "full-time"
+    EmploymentPartTime   = "part-time"
+    EmploymentContract   = "contract"
+    EmploymentInternship = "internship"
+)
+
+var Employment_types = [...]string{EmploymentFullTime, EmploymentPartTime, EmploymentContract, EmploymentInternship}
+
+// A skill that a job asks for. Required skills count twice as much
+// as nice-to-have ones in the match score
+// The match score of a user for a job is from 0 to 100: each of the job's
+// skills counts in proportion to the user's proficiency in it out of
+// MaxProficiency, or not at all if the user does not have it
+type Job_skill struct {
+    Name            string   `json:"name"`
+    Required        bool     `json:"required"`
+}
+
+// A skill that a user has, with their proficiency from
+// MinProficiency (beginner) to MaxProficiency (expert)
+type User_skill struct {
+    Name            string   `json:"name"`
+    Proficiency     int      `json:"proficiency"`
+}
+
+// An entry in the list of skills known to JobWizard
+// Skills are added to the list when a job or user first names them
+type Skill struct {
+    Name            string   `json:"name"`
+}
+
+const (
+    MinProficiency = 1
+    MaxProficiency = 5
+)
+
+var Skill_sort_fields = []string{"name"}
+
+// A job that a user has held. Dates are YYYY-MM-DD, and End_date
+// is "" if the user still has the job
+type Work_entry struct {
+    Title           string   `json:"title"`
+    Employer        string   `json:"employer"`
+    Start_date      string   `json:"start_date"`
+    End_date        string   `json:"end_date"`
+}
+
+// A requirement of a job that an applicant does not meet, returned
+// when they apply. For a missing required skill, Skill names it,
+// Required is MinProficiency and Actual is 0
+type Unmet_requirement struct {
+    Requirement     string   `json:"requirement"`   // one of the Requirement constants
+    Required        int      `json:"required"`
+    Actual          int      `json:"actual"`
+    Skill           string   `json:"skill,omitempty"`
+}
+
+// Returned by the REST API when a user applies for a job
+// The warning and unmet requirements are left out if the user meets them all
+type Submit_result struct {
+    Applied_for_job    string   `json:"applied_for_job"`
+    Warning            string   `json:"warning,omitempty"`
+    Unmet_requirements []Unmet_requirement `json:"unmet_requirements,omitempty"`
+}
+
+// Warning given with unmet requirements
+const UnmetWarning = "Applied but user does not meet all the job requirements"
+
+const (
+    RequirementEducation  = "min_education"
+    RequirementExperience = "min_experience"
+    RequirementSkill      = "skill"
+)
+
+// A file uploaded by a user, such as a resume. Listings and profiles
+// return only the description; the content is downloaded separately
+type Document struct {
+    File_name       string   `json:"file_name"`
+    Content_type    string   `json:"content_type"`
+    Size            int      `json:"size"`   // in bytes
+    Uploaded        string   `json:"uploaded"`
+    Content         []byte   `json:"-"`
+}
+
+// Content types of the resumes that can be uploaded
+const (
+    ContentTypePdf  = "application/pdf"
+    ContentTypeDocx = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+)
+
+const (
+    MaxResumeBytes  = 5 * 1024 * 1024
+    MaxCoverLetter  = 4000   // characters
+)
+
+// A named job search that a user keeps so they can run it again
+// New_matches is the number of matching jobs posted since the search
+// was last run. User_email is the owner; it is not returned
+type Saved_search struct {
+    Search_id       string           `json:"search_id"`
+    User_email      string           `json:"-"`
+    Name            string           `json:"name"`
+    Criteria        Search_criteria  `json:"criteria"`
+    Created         string           `json:"created"`
+    Last_viewed     string           `json:"last_viewed"`
+    New_matches     int              `json:"new_matches"`
+}
+
+// The saved searches of a user that have new matches, and the
+// total number of new matches, for a notification badge
+type Search_alerts struct {
+    Total_new       int              `json:"total_new"`
+    Alerts          []Saved_search   `json:"alerts"`
+}
+
+const (
+    MaxSavedSearches = 20   // for one user
+    MaxSearchName    = 64   // characters
+)
+
+// A job that a user has bookmarked. Filled is when the job was filled,
+// if that happened after it was bookmarked; it is cleared if the job
+// is reopened
+type Bookmark struct {
+    Job_summary
+    Bookmarked_on   string    `json:"bookmarked_on"`
+    Filled          string    `json:"filled,omitempty"`
+}
+
+// Events that a webhook can be told about
+const (
+    EventJobCreated           = "job.created"
+    EventJobModified          = "job.modified"
+    EventJobFilled            = "job.filled"     // closed, or someone was hired
+    EventApplicationSubmitted = "application.submitted"
+    EventApplicationStatus    = "application.status_changed"
+)
+
+var Webhook_events = [...]string{EventJobCreated, EventJobModified, EventJobFilled,
+    EventApplicationSubmitted, EventApplicationStatus}
+
+// Used both for input and output - to register a URL that is sent the
+// events on the jobs a user created, or, if Org_id is given, on the jobs
+// of an organization they own. The Secret signs every delivery and is
+// only returned when the webhook is created
+type Webhook struct {
+    Webhook_id      string     `json:"webhook_id"`
+    Creator         string     `json:"-"`
+    Org_id          string     `json:"org_id,omitempty"`
+    Url             string     `json:"url"`
+    Events          []string   `json:"events"`
+    Secret          string     `json:"secret,omitempty"`
+    Created         string     `json:"created"`
+}
+
+// The JSON body posted to a webhook. Applicant and Status are only
+// set for application events
+type Webhook_event struct {
+    Event           string    `json:"event"`
+    Occurred        string    `json:"occurred"`
+    Job_id          string    `json:"job_id"`
+    Title           string    `json:"title"`
+    Org_id          string    `json:"org_id,omitempty"`
+    Applicant       string    `json:"applicant,omitempty"`
+    Status          string    `json:"status,omitempty"`
+}
+
+// One event queued for a webhook, with the outcome of its latest attempt
+// Url and Secret are only set for the deliveries waiting to be sent
+type Webhook_delivery struct {
+    Delivery_id     string    `json:"delivery_id"`
+    Webhook_id      string    `json:"webhook_id"`
+    Event           string    `json:"event"`
+    Payload         string    `json:"payload"`         // the Webhook_event as JSON
+    Status          string    `json:"status"`
+    Attempts        int       `json:"attempts"`
+    Next_attempt    string    `json:"next_attempt,omitempty"`   // only while pending
+    Response_code   int       `json:"response_code,omitempty"`
+    Last_error      string    `json:"last_error,omitempty"`
+    Created         string    `json:"created"`
+    Delivered       string    `json:"delivered,omitempty"`
+    Url             string    `json:"-"`
+    Secret          string    `json:"-"`
+}
+
+// Delivery statuses. A pending delivery is retried, waiting twice as
+// long after each failed attempt, until it has failed MaxDeliveryAttempts times
+const (
+    DeliveryPending   = "pending"
+    DeliveryDelivered = "delivered"
+    DeliveryFailed    = "failed"
+)
+
+const (
+    MaxWebhooks         = 10     // for one user
+    MaxDeliveryAttempts = 8
+)
+
+// Kinds of email notification
+const (
+    NotifyApplicationReceived = "application_received"   // to the job's creator
+    NotifyHired               = "hired"                  // to the applicant
+    NotifyRejected            = "rejected"               // to the applicant
+)
+
+var Notification_kinds = [...]string{NotifyApplicationReceived, NotifyHired, NotifyRejected}
+
+// Languages that notifications can be written in
+const (
+    LanguageEnglish = "en"
+    LanguageThai    = "th"
+)
+
+var Languages = [...]string{LanguageEnglish, LanguageThai}
+
+// Output - a user's notification preferences. Until a user changes them,
+// every kind is sent, in English
+type Notification_preferences struct {
+    Language             string    `json:"language"`
+    Application_received bool      `json:"application_received"`
+    Hired                bool      `json:"hired"`
+    Rejected             bool      `json:"rejected"`
+}
+
+// Input - changes to a user's notification preferences
+// Only the fields that are given are changed
+type Notification_changes struct {
+    User_email           string    `json:"-"`
+    Language             *string   `json:"language,omitempty"`
+    Application_received *bool     `json:"application_received,omitempty"`
+    Hired                *bool     `json:"hired,omitempty"`
+    Rejected             *bool     `json:"rejected,omitempty"`
+}
+
+// An email waiting in the outbox. The subject and body are written from
+// the Kind and the details when it is sent, in the recipient's Language
+// Applicant and Applicant_name are only set for application_received
+type Notification struct {
+    Notification_id string
+    Recipient       string     // email address
+    Name            string     // first name of the recipient
+    Kind            string
+    Language        string
+    Job_id          string
+    Title           string
+    Applicant       string
+    Applicant_name  string
+    Attempts        int
+    Created         string
+}
+
+// Used both for input and output - a message in the thread of a job
+// application, which only the applicant and the job's creator can read
+// Read is when the other of the two read it, or "" if they have not
+type Message struct {
+    Message_id      string    `json:"message_id"`
+    Job_id          string    `json:"job_id"`
+    Applicant       string    `json:"applicant"`
+    Sender          string    `json:"sender"`
+    Body            string    `json:"body"`
+    Sent            string    `json:"sent"`
+    Read            string    `json:"read,omitempty"`
+}
+
+// Output - a thread with messages the user has not read yet
+type Unread_thread struct {
+    Job_id          string    `json:"job_id"`
+    Title           string    `json:"title"`
+    Applicant       string    `json:"applicant"`
+    Unread          int       `json:"unread"`
+    Latest          string    `json:"latest"`     // when the newest unread message was sent
+}
+
+// Output - the number of unread messages of a user, in total and by
+// thread, the thread with the newest message first
+type Unread_counts struct {
+    Total           int             `json:"total"`
+    Threads         []Unread_thread `json:"threads"`
+}
+
+const MaxMessageLength = 2000   // characters
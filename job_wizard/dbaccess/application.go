@@ -0,0 +1,231 @@
+package dbaccess
+// This module holds the database functions for moving job applications
+// through their lifecycle, from submitted to hired, rejected or withdrawn
+
+import (
+    "database/sql"
+    "fmt"
+    "strconv"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+//**************** Private Functions *******************************//
+
+// Add a row to the status history for an application, and queue the
+// webhook event and email for it. Called inside the transaction that
+// changes the status
+func recordStatus(tx *sql.Tx, job_id int, user_email string, status string, changed_by string, nowstring string) (err error) {
+    sqlcmd := "INSERT INTO application_status (job_id, user_email, status, changed_by, change_time) VALUES (?,?,?,?,?)"
+    _, err = tx.Exec(sqlcmd, job_id, user_email, status, changed_by, nowstring)
+    if err != nil {
+        return err
+    }
+    event := data.EventApplicationStatus
+    if status == data.StatusSubmitted {
+        event = data.EventApplicationSubmitted
+    }
+    err = queueWebhookEvent(tx, event, job_id, user_email, status)
+    if err != nil {
+        return err
+    }
+    return queueNotification(tx, job_id, user_email, status)
+}
+
+//******** Exported Functions *****************************//
+
+// Function for a job creator to move an application to a new status
+// The job must have been created by creator_email, or be offered by an
+// organization that user belongs to, and the application
+// must not already have a final status (hired, rejected, withdrawn)
+// Hiring a candidate also closes the job and records the hired person,
+// all in the same transaction
+func (store *sqlStore) UpdateApplicationStatus(creator_email string, job_id string, applicant_email string, status string) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    row := tx.QueryRow("SELECT created_by, organization_id, is_open FROM job WHERE id=?", idval)
+    var created_by string
+    var org_idval int
+    var open_flag bool
+    err = row.Scan(&created_by, &org_idval, &open_flag)
+    if err != nil {
+        tx.Rollback()
+        return data.NotFoundError("No matching job found")
+    }
+    err = checkJobManager(tx, created_by, org_idval, creator_email)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    var current string
+    row = tx.QueryRow("SELECT status FROM job_application WHERE job_id=? AND user_email=?", idval, applicant_email)
+    err = row.Scan(&current)
+    if err != nil {
+        tx.Rollback()
+        return data.NotFoundError("No application from this user for this job")
+    }
+    if data.IsFinalStatus(current) {
+        tx.Rollback()
+        return data.ConflictError("Application has already been " + current)
+    }
+    if current == status {
+        tx.Rollback()
+        return data.ConflictError("Application status is already " + status)
+    }
+    if status == data.StatusHired {
+        if !open_flag {
+            tx.Rollback()
+            return data.ConflictError("Job has already been filled")
+        }
+        _, err = tx.Exec("UPDATE job SET is_open=?, hired_person=? WHERE id=?", false, applicant_email, idval)
+        if err == nil {
+            err = flagFilledBookmarks(tx, idval, true)
+        }
+        if err != nil {
+            tx.Rollback()
+            return err
+        }
+    }
+    nowstring := data.StoredNow()
+    sqlcmd := "UPDATE job_application SET status=?, status_time=? WHERE job_id=? AND user_email=?"
+    _, err = tx.Exec(sqlcmd, status, nowstring, idval, applicant_email)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    err = recordStatus(tx, idval, applicant_email, status, creator_email, nowstring)
+    if err == nil && status == data.StatusHired {
+        err = queueWebhookEvent(tx, data.EventJobFilled, idval, "", "")
+    }
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    return tx.Commit()
+}
+
+// Function for an applicant to take back an application
+// The application is kept, with status withdrawn, so the job creator
+// can still see it. Hired or rejected applications cannot be withdrawn
+func (store *sqlStore) WithdrawApplication(user_email string, job_id string) (withdrawn_job_id string, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return "", err
+    }
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    tx, err := db.Begin()
+    if err != nil {
+        return "", err
+    }
+    var current string
+    row := tx.QueryRow("SELECT status FROM job_application WHERE job_id=? AND user_email=?", idval, user_email)
+    err = row.Scan(&current)
+    if err != nil {
+        tx.Rollback()
+        return "", data.NotFoundError("No application from this user for this job")
+    }
+    if data.IsFinalStatus(current) {
+        tx.Rollback()
+        return "", data.ConflictError("Application has already been " + current)
+    }
+    nowstring := data.StoredNow()
+    sqlcmd := "UPDATE job_application SET status=?, status_time=? WHERE job_id=? AND user_email=?"
+    _, err = tx.Exec(sqlcmd, data.StatusWithdrawn, nowstring, idval, user_email)
+    if err != nil {
+        tx.Rollback()
+        return "", err
+    }
+    err = recordStatus(tx, idval, user_email, data.StatusWithdrawn, user_email, nowstring)
+    if err != nil {
+        tx.Rollback()
+        return "", err
+    }
+    err = tx.Commit()
+    if err != nil {
+        return "", err
+    }
+    return job_id, nil
+}
+
+// Function to search for jobs applied to by a particular user
+// Each entry includes the apply date, the current status of the
+// application and its status history, oldest first
+// Returns one page of entries, by default most recent application first,
+// plus the total number of applications
+func (store *sqlStore) SearchAppliedJobs(user_email string, page data.Page_request) (applications []data.Application_summary, total int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return applications, 0, err
+    }
+    // collect the history first, keyed by job id
+    history := make(map[int][]data.Status_entry)
+    sqlcmd := "SELECT job_id, status, change_time FROM application_status WHERE user_email=? ORDER BY id"
+    rows, err := db.Query(sqlcmd, user_email)
+    if err != nil {
+        return applications, 0, err
+    }
+    var idval int
+    var entry data.Status_entry
+    for rows.Next() {
+        err = rows.Scan(&idval, &entry.Status, &entry.Changed)
+        if err != nil {
+            rows.Close()
+            return applications, 0, err
+        }
+        entry.Changed = data.DisplayTime(entry.Changed)
+        history[idval] = append(history[idval], entry)
+    }
+    rows.Close()
+
+    fromclause := "FROM job j, job_application ja WHERE j.id=ja.job_id AND ja.user_email=?"
+    row := db.QueryRow("SELECT COUNT(*) " + fromclause, user_email)
+    err = row.Scan(&total)
+    if err != nil {
+        return applications, 0, err
+    }
+    clause, page_args := pageClause(page, applicationSortColumns, "applied", "desc", "j.id")
+    sqlcmd = "SELECT j.id, j.title, j.is_open, j.created, j.expires_on, j.is_archived, j.organization_id, " + orgNameColumn +
+        ", j.province, j.city, j.work_arrangement, j.employment_type, ja.apply_time, ja.status, ja.status_time " + fromclause + clause
+    rows, err = db.Query(sqlcmd, append([]interface{}{user_email}, page_args...)...)
+    if err != nil {
+        return applications, 0, err
+    }
+    defer rows.Close()
+    var posted string
+    var expires string
+    var org_idval int
+    var apply_time string
+    var status_time sql.NullString
+    for rows.Next() {
+        var application data.Application_summary
+        err = rows.Scan(&idval, &application.Title, &application.Is_open, &posted, &expires, &application.Is_archived,
+                        &org_idval, &application.Organization, &application.Province, &application.City,
+                        &application.Work_arrangement, &application.Employment_type,
+                        &apply_time, &application.Status, &status_time)
+        if err != nil {
+            return applications, 0, err
+        }
+        application.Job_id = fmt.Sprintf("%05d", idval)
+        application.Date_posted = data.DisplayTime(posted)
+        application.Expires_on = data.DisplayTime(expires)
+        application.Org_id = orgIdString(org_idval)
+        application.Applied_date = data.DisplayTime(apply_time)
+        // applications made before statuses existed have no status time or history
+        application.Status_date = application.Applied_date
+        if status_time.Valid {
+            application.Status_date = data.DisplayTime(status_time.String)
+        }
+        application.History = history[idval]
+        if application.History == nil {
+            application.History = []data.Status_entry{{Status: application.Status, Changed: data.DisplayTime(apply_time)}}
+        }
+        applications = append(applications, application)
+    }
+    return applications, total, nil
+}
@@ -0,0 +1,114 @@
+package dbaccess
+// Tests for moving applications through their lifecycle
+
+import (
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// find the candidate with a given email, or fail the test
+func findCandidate(t *testing.T, creator string, job_id string, email string) data.Candidate {
+    t.Helper()
+    candidates, _, err := testStore.SearchCandidates(creator, job_id, false, data.Page_request{})
+    if err != nil {
+        t.Fatalf("SearchCandidates failed: %v", err)
+    }
+    for _, candidate := range candidates {
+        if candidate.Email == email {
+            return candidate
+        }
+    }
+    t.Fatalf("candidate %s not found", email)
+    return data.Candidate{}
+}
+
+func TestApplicationLifecycle(t *testing.T) {
+    mustRegister(t, "lifecycle.boss@example.com")
+    mustRegister(t, "first.applicant@example.com")
+    mustRegister(t, "second.applicant@example.com")
+    job_id, _ := testStore.CreateJob(data.Job_info{Creator: "lifecycle.boss@example.com", Title: "Lifecycle Job", Description: "Testing"})
+    testStore.SubmitJobApplication("first.applicant@example.com", job_id, "", nil)
+    testStore.SubmitJobApplication("second.applicant@example.com", job_id, "", nil)
+
+    candidate := findCandidate(t, "lifecycle.boss@example.com", job_id, "first.applicant@example.com")
+    if candidate.Status != data.StatusSubmitted {
+        t.Errorf("new application should be submitted, got %q", candidate.Status)
+    }
+    err := testStore.UpdateApplicationStatus("first.applicant@example.com", job_id, "second.applicant@example.com", data.StatusRejected)
+    if err == nil {
+        t.Errorf("only the creator may change a status")
+    }
+    for _, status := range []string{data.StatusReviewed, data.StatusShortlisted, data.StatusInterviewing, data.StatusOffered} {
+        err = testStore.UpdateApplicationStatus("lifecycle.boss@example.com", job_id, "first.applicant@example.com", status)
+        if err != nil {
+            t.Fatalf("move to %s failed: %v", status, err)
+        }
+    }
+    err = testStore.UpdateApplicationStatus("lifecycle.boss@example.com", job_id, "second.applicant@example.com", data.StatusRejected)
+    if err != nil {
+        t.Fatalf("reject failed: %v", err)
+    }
+    err = testStore.UpdateApplicationStatus("lifecycle.boss@example.com", job_id, "second.applicant@example.com", data.StatusReviewed)
+    if err == nil {
+        t.Errorf("a rejected application must not change status")
+    }
+    err = testStore.UpdateApplicationStatus("lifecycle.boss@example.com", job_id, "first.applicant@example.com", data.StatusHired)
+    if err != nil {
+        t.Fatalf("hire failed: %v", err)
+    }
+    if found, _ := testStore.GetJobDetail(job_id); found.Is_open {
+        t.Errorf("hire should close the job")
+    }
+    if _, is_sql := testStore.(*sqlStore); is_sql {
+        var hired_person string
+        db.QueryRow("SELECT hired_person FROM job WHERE id=?", job_id).Scan(&hired_person)
+        if hired_person != "first.applicant@example.com" {
+            t.Errorf("hire should record the person, got %q", hired_person)
+        }
+    }
+    applied, _, _ := testStore.SearchAppliedJobs("first.applicant@example.com", data.Page_request{})
+    if len(applied) != 1 || applied[0].Status != data.StatusHired {
+        t.Fatalf("applied listing should show hired status, got %v", applied)
+    }
+    if len(applied[0].History) != 6 {
+        t.Errorf("expected 6 history entries, got %+v", applied[0].History)
+    }
+}
+
+func TestWithdrawApplication(t *testing.T) {
+    mustRegister(t, "withdraw.boss@example.com")
+    mustRegister(t, "withdraw.applicant@example.com")
+    job_id, _ := testStore.CreateJob(data.Job_info{Creator: "withdraw.boss@example.com", Title: "Withdraw Job", Description: "Testing"})
+    if _, err := testStore.WithdrawApplication("withdraw.applicant@example.com", job_id); err == nil {
+        t.Errorf("withdrawing without applying should fail")
+    }
+    testStore.SubmitJobApplication("withdraw.applicant@example.com", job_id, "", nil)
+    testStore.UpdateApplicationStatus("withdraw.boss@example.com", job_id, "withdraw.applicant@example.com", data.StatusReviewed)
+    if _, err := testStore.WithdrawApplication("withdraw.applicant@example.com", job_id); err != nil {
+        t.Fatalf("withdraw failed: %v", err)
+    }
+    if _, err := testStore.WithdrawApplication("withdraw.applicant@example.com", job_id); err == nil {
+        t.Errorf("withdrawing twice should fail")
+    }
+    err := testStore.UpdateApplicationStatus("withdraw.boss@example.com", job_id, "withdraw.applicant@example.com", data.StatusOffered)
+    if err == nil {
+        t.Errorf("creator must not change a withdrawn application")
+    }
+    applied, _, err := testStore.SearchAppliedJobs("withdraw.applicant@example.com", data.Page_request{})
+    if err != nil || len(applied) != 1 {
+        t.Fatalf("expected one application, got %v (%v)", applied, err)
+    }
+    application := applied[0]
+    if application.Status != data.StatusWithdrawn || application.Applied_date == "" {
+        t.Errorf("unexpected application summary %+v", application)
+    }
+    expected := []string{data.StatusSubmitted, data.StatusReviewed, data.StatusWithdrawn}
+    if len(application.History) != len(expected) {
+        t.Fatalf("expected history %v, got %+v", expected, application.History)
+    }
+    for i, status := range expected {
+        if application.History[i].Status != status {
+            t.Errorf("history entry %d: expected %s, got %s", i, status, application.History[i].Status)
+        }
+    }
+}
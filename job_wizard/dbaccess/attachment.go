@@ -0,0 +1,192 @@
+package dbaccess
+// This module holds the database functions for the resumes users send
+// with their applications, or keep on their profile as the default for
+// applications that come without one. The resume table describes each
+// file and the blob store keeps its content (see blob.go)
+// A resume is shared by the profile and the applications that used it,
+// and is deleted once none of them refer to it any longer
+
+import (
+    "strconv"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+//**************** Private Functions *******************************//
+
+// Store the content of a new resume in the blob store, before the
+// transaction that records it. Returns the key it is kept under
+func putResume(resume *data.Document) (blob_key string, err error) {
+    blob_key, err = newBlobKey()
+    if err != nil {
+        return "", err
+    }
+    err = GetBlobStore().Put(blob_key, resume.Content)
+    if err != nil {
+        return "", err
+    }
+    return blob_key, nil
+}
+
+// Record a resume whose content was stored by putResume
+// Returns the id of the new resume row
+func insertResume(conn sqlConn, user_email string, resume *data.Document, blob_key string) (resume_id int, err error) {
+    sqlcmd := "INSERT INTO resume (user_email, file_name, content_type, size_bytes, blob_key, created) values (?,?,?,?,?,?)"
+    _, err = conn.Exec(sqlcmd, user_email, resume.File_name, resume.Content_type, len(resume.Content), blob_key, data.StoredNow())
+    if err != nil {
+        return 0, err
+    }
+    err = conn.QueryRow("SELECT id FROM resume WHERE blob_key=?", blob_key).Scan(&resume_id)
+    return resume_id, err
+}
+
+// Delete a resume that neither a profile nor an application refers to
+// any longer. Returns the key of its content, to be deleted once the
+// transaction has been committed, or "" if the resume is still in use
+func dropUnusedResume(conn sqlConn, resume_id int) (blob_key string, err error) {
+    if resume_id == 0 {
+        return "", nil
+    }
+    var uses int
+    sqlcmd := "SELECT (SELECT COUNT(*) FROM user WHERE resume_id=?) + (SELECT COUNT(*) FROM job_application WHERE resume_id=?)"
+    err = conn.QueryRow(sqlcmd, resume_id, resume_id).Scan(&uses)
+    if err != nil || uses > 0 {
+        return "", err
+    }
+    err = conn.QueryRow("SELECT blob_key FROM resume WHERE id=?", resume_id).Scan(&blob_key)
+    if err != nil {
+        return "", err
+    }
+    _, err = conn.Exec("DELETE FROM resume WHERE id=?", resume_id)
+    if err != nil {
+        return "", err
+    }
+    return blob_key, nil
+}
+
+// Return the description of a resume, and its content if with_content is true
+func loadResume(conn sqlConn, resume_id int, with_content bool) (resume data.Document, err error) {
+    sqlcmd := "SELECT file_name, content_type, size_bytes, created, blob_key FROM resume WHERE id=?"
+    var created string
+    var blob_key string
+    err = conn.QueryRow(sqlcmd, resume_id).Scan(&resume.File_name, &resume.Content_type, &resume.Size, &created, &blob_key)
+    if err != nil {
+        return resume, data.NotFoundError("No resume found")
+    }
+    resume.Uploaded = data.DisplayTime(created)
+    if with_content {
+        resume.Content, err = GetBlobStore().Get(blob_key)
+    }
+    return resume, err
+}
+
+// Replace the default resume of a user in a transaction
+// Returns the key of the content of the old resume if it is no longer used
+func setUserResume(user_email string, resume *data.Document, blob_key string) (old_key string, err error) {
+    tx, err := db.Begin()
+    if err != nil {
+        return "", err
+    }
+    var old_id int
+    err = tx.QueryRow("SELECT coalesce(resume_id, 0) FROM user WHERE user_email=?", user_email).Scan(&old_id)
+    if err != nil {
+        tx.Rollback()
+        return "", data.NotFoundError("Unknown user")
+    }
+    if resume == nil && old_id == 0 {
+        tx.Rollback()
+        return "", data.NotFoundError("User has no resume")
+    }
+    resume_id := 0
+    if resume != nil {
+        resume_id, err = insertResume(tx, user_email, resume, blob_key)
+        if err != nil {
+            tx.Rollback()
+            return "", err
+        }
+    }
+    _, err = tx.Exec("UPDATE user SET resume_id=? WHERE user_email=?", resume_id, user_email)
+    if err != nil {
+        tx.Rollback()
+        return "", err
+    }
+    old_key, err = dropUnusedResume(tx, old_id)
+    if err != nil {
+        tx.Rollback()
+        return "", err
+    }
+    return old_key, tx.Commit()
+}
+
+//******** Exported Functions *****************************//
+
+// Function to set the default resume of a user, which is sent with
+// applications that come without one. A nil resume removes the default
+// The old default is deleted unless an application used it
+func (store *sqlStore) SetUserResume(user_email string, resume *data.Document) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    blob_key := ""
+    if resume != nil {
+        blob_key, err = putResume(resume)
+        if err != nil {
+            return err
+        }
+    }
+    old_key, err := setUserResume(user_email, resume, blob_key)
+    if err != nil {
+        deleteBlobs([]string{blob_key})
+        return err
+    }
+    deleteBlobs([]string{old_key})
+    return nil
+}
+
+// Function to get the default resume of a user, with its content
+func (store *sqlStore) GetUserResume(user_email string) (resume data.Document, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return resume, err
+    }
+    var resume_id int
+    err = db.QueryRow("SELECT coalesce(resume_id, 0) FROM user WHERE user_email=?", user_email).Scan(&resume_id)
+    if err != nil {
+        return resume, data.NotFoundError("Unknown user")
+    }
+    if resume_id == 0 {
+        return resume, data.NotFoundError("User has no resume")
+    }
+    return loadResume(db, resume_id, true)
+}
+
+// Function to get the resume sent with an application, with its content
+// Like the list of candidates, only the job creator or a member of the
+// job's organization can see it
+func (store *sqlStore) GetApplicationResume(creator_email string, job_id string, applicant_email string) (resume data.Document, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return resume, err
+    }
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    var created_by string
+    var org_idval int
+    err = db.QueryRow("SELECT created_by, organization_id FROM job WHERE id=?", idval).Scan(&created_by, &org_idval)
+    if err != nil {
+        return resume, data.NotFoundError("No matching job found")
+    }
+    err = checkJobManager(db, created_by, org_idval, creator_email)
+    if err != nil {
+        return resume, err
+    }
+    var resume_id int
+    sqlcmd := "SELECT coalesce(resume_id, 0) FROM job_application WHERE job_id=? AND user_email=?"
+    err = db.QueryRow(sqlcmd, idval, applicant_email).Scan(&resume_id)
+    if err != nil {
+        return resume, data.NotFoundError("No application from this user for this job")
+    }
+    if resume_id == 0 {
+        return resume, data.NotFoundError("Application has no resume")
+    }
+    return loadResume(db, resume_id, true)
+}
@@ -0,0 +1,143 @@
+package dbaccess
+// Tests for resumes and cover letters sent with applications,
+// default resumes and the blob stores
+
+import (
+    "bytes"
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Wraps a blob store to keep track of which files are still stored
+type trackingBlobs struct {
+    BlobStore
+    stored map[string]bool
+}
+
+func (blobs *trackingBlobs) Put(key string, content []byte) error {
+    blobs.stored[key] = true
+    return blobs.BlobStore.Put(key, content)
+}
+
+func (blobs *trackingBlobs) Delete(key string) error {
+    delete(blobs.stored, key)
+    return blobs.BlobStore.Delete(key)
+}
+
+func testResume(name string, content string) *data.Document {
+    return &data.Document{File_name: name, Content_type: data.ContentTypePdf, Content: []byte("%PDF-" + content)}
+}
+
+func TestBlobStores(t *testing.T) {
+    for _, blobs := range []BlobStore{NewDiskBlobStore(t.TempDir()), NewMemoryBlobStore()} {
+        if err := blobs.Put("abc123", []byte("content")); err != nil {
+            t.Fatalf("Put failed: %v", err)
+        }
+        content, err := blobs.Get("abc123")
+        if err != nil || string(content) != "content" {
+            t.Errorf("expected the content back, got %q (%v)", content, err)
+        }
+        blobs.Delete("abc123")
+        if _, err = blobs.Get("abc123"); data.ErrorCode(err) != data.CodeNotFound {
+            t.Errorf("a deleted file should not be found, got %v", err)
+        }
+        if err = blobs.Delete("abc123"); err != nil {
+            t.Errorf("deleting a missing file should not fail, got %v", err)
+        }
+    }
+}
+
+func TestResumes(t *testing.T) {
+    saved := GetBlobStore()
+    blobs := &trackingBlobs{saved, map[string]bool{}}
+    SetBlobStore(blobs)
+    defer SetBlobStore(saved)
+
+    mustRegister(t, "resume.boss@example.com")
+    mustRegister(t, "resume.applicant@example.com")
+    mustRegister(t, "resume.other@example.com")
+    first_id, _ := testStore.CreateJob(data.Job_info{Creator: "resume.boss@example.com", Title: "Resume Reader", Description: "Reading"})
+    second_id, _ := testStore.CreateJob(data.Job_info{Creator: "resume.boss@example.com", Title: "Resume Writer", Description: "Writing"})
+    third_id, _ := testStore.CreateJob(data.Job_info{Creator: "resume.boss@example.com", Title: "Resume Editor", Description: "Editing"})
+
+    if _, err := testStore.GetUserResume("resume.applicant@example.com"); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("a new user should have no resume, got %v", err)
+    }
+    if err := testStore.SetUserResume("resume.applicant@example.com", testResume("default.pdf", "default")); err != nil {
+        t.Fatalf("SetUserResume failed: %v", err)
+    }
+    profile, _ := testStore.GetUserProfile("resume.applicant@example.com")
+    if profile.Resume == nil || profile.Resume.File_name != "default.pdf" || profile.Resume.Size != 12 ||
+       profile.Resume.Uploaded == "" || profile.Resume.Content != nil {
+        t.Errorf("the profile should describe the resume, got %+v", profile.Resume)
+    }
+
+    // an uploaded resume goes with one application, the default with the others
+    _, _, err := testStore.SubmitJobApplication("resume.applicant@example.com", first_id, "Dear hiring manager", testResume("cv.pdf", "uploaded"))
+    if err != nil {
+        t.Fatalf("SubmitJobApplication failed: %v", err)
+    }
+    testStore.SubmitJobApplication("resume.applicant@example.com", second_id, "", nil)
+    testStore.SubmitJobApplication("resume.other@example.com", first_id, "", nil)
+    if len(blobs.stored) != 2 {
+        t.Errorf("expected the default and the uploaded resume, got %v", blobs.stored)
+    }
+    candidates, _, err := testStore.SearchCandidates("resume.boss@example.com", first_id, false, data.Page_request{})
+    if err != nil || len(candidates) != 2 {
+        t.Fatalf("expected two candidates, got %v (%v)", candidates, err)
+    }
+    if candidates[0].Cover_letter != "Dear hiring manager" || candidates[0].Resume == nil || candidates[0].Resume.File_name != "cv.pdf" ||
+       candidates[0].Resume.Content_type != data.ContentTypePdf || candidates[1].Resume != nil {
+        t.Errorf("expected the cover letter and resume of the first candidate only, got %+v", candidates)
+    }
+    resume, err := testStore.GetApplicationResume("resume.boss@example.com", first_id, "resume.applicant@example.com")
+    if err != nil || !bytes.Equal(resume.Content, []byte("%PDF-uploaded")) {
+        t.Errorf("expected the uploaded resume, got %q (%v)", resume.Content, err)
+    }
+    if _, err = testStore.GetApplicationResume("resume.other@example.com", first_id, "resume.applicant@example.com"); data.ErrorCode(err) != data.CodeForbidden {
+        t.Errorf("only the job creator should get the resume, got %v", err)
+    }
+    if _, err = testStore.GetApplicationResume("resume.boss@example.com", first_id, "resume.other@example.com"); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("an application without a resume should have none to get, got %v", err)
+    }
+
+    // a failed application does not keep its resume
+    _, _, err = testStore.SubmitJobApplication("resume.applicant@example.com", first_id, "", testResume("again.pdf", "again"))
+    if data.ErrorCode(err) != data.CodeConflict || len(blobs.stored) != 2 {
+        t.Errorf("expected a duplicate application and no new file, got %v, %v", err, blobs.stored)
+    }
+
+    // replacing the default keeps it for the application that used it
+    testStore.SetUserResume("resume.applicant@example.com", testResume("newer.pdf", "newer"))
+    resume, err = testStore.GetApplicationResume("resume.boss@example.com", second_id, "resume.applicant@example.com")
+    if err != nil || resume.File_name != "default.pdf" || !bytes.Equal(resume.Content, []byte("%PDF-default")) {
+        t.Errorf("the application should keep the old default, got %+v (%v)", resume, err)
+    }
+    resume, _ = testStore.GetUserResume("resume.applicant@example.com")
+    if resume.File_name != "newer.pdf" || len(blobs.stored) != 3 {
+        t.Errorf("expected the newer default and three files, got %+v, %v", resume, blobs.stored)
+    }
+    // a default that no application used is deleted with its file
+    testStore.SetUserResume("resume.applicant@example.com", testResume("newest.pdf", "newest"))
+    if len(blobs.stored) != 3 {
+        t.Errorf("the unused default should be deleted, got %v", blobs.stored)
+    }
+    testStore.SubmitJobApplication("resume.applicant@example.com", third_id, "", nil)
+    if err = testStore.SetUserResume("resume.applicant@example.com", nil); err != nil {
+        t.Errorf("removing the default failed: %v", err)
+    }
+    if _, err = testStore.GetUserResume("resume.applicant@example.com"); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("the default should be removed, got %v", err)
+    }
+    if err = testStore.SetUserResume("resume.applicant@example.com", nil); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("removing a missing default should fail, got %v", err)
+    }
+
+    // deleting the user deletes all of their files
+    if err = testStore.DeleteUser("resume.applicant@example.com"); err != nil {
+        t.Fatalf("DeleteUser failed: %v", err)
+    }
+    if len(blobs.stored) != 0 {
+        t.Errorf("the user's files should be deleted, got %v", blobs.stored)
+    }
+}
@@ -0,0 +1,122 @@
+package dbaccess
+// This module holds the database functions for authenticating users
+// of the REST API: password checking and bearer token sessions
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "database/sql"
+    "encoding/hex"
+    "time"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+    "golang.org/x/crypto/bcrypt"
+)
+
+// How long a login token remains valid
+const sessionLifetime = 24 * time.Hour
+
+//**************** Private Functions *******************************//
+
+// Hash a password for storage in the user table
+func hashPassword(password string) (hash string, err error) {
+    bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return "", err
+    }
+    return string(bytes), nil
+}
+
+// Create a random token to identify a session
+func newToken() (token string, err error) {
+    bytes := make([]byte, 32)
+    _, err = rand.Read(bytes)
+    if err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(bytes), nil
+}
+
+// Return the form of a token kept in the session table. Only the hash
+// is stored, so a copy of the database does not give anyone a session
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+//******** Exported Functions *****************************//
+
+// Check an email and password against the user table
+// Returns true if the user exists and the password matches
+// Users registered before passwords were introduced have no
+// password hash and can never log in
+func (store *sqlStore) CheckPassword(user_email string, password string) (bOk bool, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return false, err
+    }
+    var hash string
+    row := db.QueryRow("SELECT password_hash FROM user WHERE user_email=?", user_email)
+    err = row.Scan(&hash)
+    if err == sql.ErrNoRows || (err == nil && hash == "") {
+        return false, nil
+    }
+    if err != nil {
+        return false, err
+    }
+    err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+    return err == nil, nil
+}
+
+// Start a new session for a user who has been authenticated
+// Returns the bearer token and the time when it expires
+func (store *sqlStore) CreateSession(user_email string) (token string, expires time.Time, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return "", expires, err
+    }
+    token, err = newToken()
+    if err != nil {
+        return "", expires, err
+    }
+    now := time.Now()
+    expires = now.Add(sessionLifetime)
+    sqlcmd := "INSERT INTO session (token, user_email, created, expires) VALUES (?,?,?,?)"
+    _, err = db.Exec(sqlcmd, hashToken(token), user_email, data.StoredTime(now), expires.Unix())
+    if err != nil {
+        return "", expires, err
+    }
+    return token, expires, nil
+}
+
+// Find the user who owns a session token
+// Returns an error if the token is unknown or has expired
+func (store *sqlStore) GetSessionUser(token string) (user_email string, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return "", err
+    }
+    var expires int64
+    row := db.QueryRow("SELECT user_email, expires FROM session WHERE token=?", hashToken(token))
+    err = row.Scan(&user_email, &expires)
+    if err == sql.ErrNoRows {
+        return "", data.UnauthorizedError("Invalid session token")
+    }
+    if err != nil {
+        return "", err
+    }
+    if time.Now().Unix() > expires {
+        db.Exec("DELETE FROM session WHERE token=?", hashToken(token))
+        return "", data.UnauthorizedError("Session has expired - please log in again")
+    }
+    return user_email, nil
+}
+
+// End a session by removing its token
+func (store *sqlStore) DeleteSession(token string) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    _, err = db.Exec("DELETE FROM session WHERE token=?", hashToken(token))
+    return err
+}
@@ -0,0 +1,141 @@
+package dbaccess
+// This module defines BlobStore, which keeps the contents of uploaded
+// files such as resumes. The database only records their names, types
+// and sizes, and the key each file is kept under in the blob store
+// There are two implementations: diskBlobStore, which keeps each file
+// in a directory on local disk, and memoryBlobStore for tests and demo
+// mode. Another store, for instance a cloud bucket, can be used by
+// passing it to SetBlobStore
+
+import (
+    "errors"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sync"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Directory for uploaded files if JOBWIZARD_FILES_DIR is not set
+const defaultFilesDir = "files"
+
+// Keeps the contents of files under keys made by newBlobKey
+// Get returns a data.NotFoundError for a key that is not there
+type BlobStore interface {
+    Put(key string, content []byte) (err error)
+    Get(key string) (content []byte, err error)
+    Delete(key string) (err error)
+}
+
+// Keeps each file in dir, named by its key
+type diskBlobStore struct {
+    dir string
+}
+
+// Keeps the files in a map
+type memoryBlobStore struct {
+    mutex sync.Mutex
+    blobs map[string][]byte
+}
+
+// The blob store used by GetBlobStore, or nil to use the directory
+// named by JOBWIZARD_FILES_DIR
+var activeBlobStore BlobStore
+
+//**************** Private Functions *******************************//
+
+// Create a random key for a new file. The keys are hex, so they are
+// safe to use as file names
+func newBlobKey() (key string, err error) {
+    return newToken()
+}
+
+func (blobs *diskBlobStore) Put(key string, content []byte) (err error) {
+    err = os.MkdirAll(blobs.dir, 0700)
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(filepath.Join(blobs.dir, key), content, 0600)
+}
+
+func (blobs *diskBlobStore) Get(key string) (content []byte, err error) {
+    content, err = os.ReadFile(filepath.Join(blobs.dir, key))
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil, data.NotFoundError("File is missing")
+    }
+    return content, err
+}
+
+func (blobs *diskBlobStore) Delete(key string) (err error) {
+    err = os.Remove(filepath.Join(blobs.dir, key))
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil
+    }
+    return err
+}
+
+func (blobs *memoryBlobStore) Put(key string, content []byte) (err error) {
+    blobs.mutex.Lock()
+    defer blobs.mutex.Unlock()
+    blobs.blobs[key] = append([]byte{}, content...)
+    return nil
+}
+
+func (blobs *memoryBlobStore) Get(key string) (content []byte, err error) {
+    blobs.mutex.Lock()
+    defer blobs.mutex.Unlock()
+    content, found := blobs.blobs[key]
+    if !found {
+        return nil, data.NotFoundError("File is missing")
+    }
+    return append([]byte{}, content...), nil
+}
+
+func (blobs *memoryBlobStore) Delete(key string) (err error) {
+    blobs.mutex.Lock()
+    defer blobs.mutex.Unlock()
+    delete(blobs.blobs, key)
+    return nil
+}
+
+// Delete files that are no longer referenced, once the change that
+// dropped them has been committed. A file that cannot be deleted is
+// only wasted space, so errors are ignored. Empty keys are skipped
+func deleteBlobs(keys []string) {
+    for _, key := range keys {
+        if key != "" {
+            GetBlobStore().Delete(key)
+        }
+    }
+}
+
+//******** Exported Functions *****************************//
+
+// Return a blob store that keeps files in a directory, which is
+// created when the first file is stored
+func NewDiskBlobStore(dir string) BlobStore {
+    return &diskBlobStore{dir: dir}
+}
+
+// Return a blob store that keeps files in memory
+func NewMemoryBlobStore() BlobStore {
+    return &memoryBlobStore{blobs: map[string][]byte{}}
+}
+
+// Replace the blob store used by the whole program
+func SetBlobStore(blobs BlobStore) {
+    activeBlobStore = blobs
+}
+
+// Return the blob store in use. Unless SetBlobStore has been called,
+// files are kept in JOBWIZARD_FILES_DIR, or in "files" if that is not set
+func GetBlobStore() BlobStore {
+    if activeBlobStore == nil {
+        dir := os.Getenv("JOBWIZARD_FILES_DIR")
+        if dir == "" {
+            dir = defaultFilesDir
+        }
+        activeBlobStore = NewDiskBlobStore(dir)
+    }
+    return activeBlobStore
+}
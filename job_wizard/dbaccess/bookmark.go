@@ -0,0 +1,111 @@
+package dbaccess
+// This module holds the database functions for bookmarks, which let
+// users keep a list of jobs without applying for them
+// Searches made for a user say which jobs they have bookmarked, and
+// the bookmarks of a job are flagged when the job is filled
+
+import (
+    "fmt"
+    "strconv"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Selects whether the user given by the placeholder has bookmarked job j
+const bookmarkedColumn = "EXISTS(SELECT 1 FROM bookmark b WHERE b.job_id = j.id AND b.user_email = ?)"
+
+//**************** Private Functions *******************************//
+
+// Flag the bookmarks of a job when it is filled, or clear the flag
+// when it is reopened
+func flagFilledBookmarks(conn execer, job_idval int, filled bool) (err error) {
+    if filled {
+        _, err = conn.Exec("UPDATE bookmark SET filled=? WHERE job_id=? AND filled=''", data.StoredNow(), job_idval)
+    } else {
+        _, err = conn.Exec("UPDATE bookmark SET filled='' WHERE job_id=?", job_idval)
+    }
+    return err
+}
+
+//******** Exported Functions *****************************//
+
+// Function for a user to bookmark a job
+func (store *sqlStore) AddBookmark(user_email string, job_id string) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    var found int
+    err = db.QueryRow("SELECT id FROM job WHERE id=?", idval).Scan(&found)
+    if err != nil {
+        return data.NotFoundError("No matching job found")
+    }
+    sqlcmd := "INSERT INTO bookmark (user_email, job_id, created, filled) values (?,?,?,'')"
+    _, err = db.Exec(sqlcmd, user_email, idval, data.StoredNow())
+    if err != nil && isUniqueViolation(err) {
+        return data.ConflictError("Job is already bookmarked")
+    }
+    return err
+}
+
+// Function for a user to remove a bookmark
+func (store *sqlStore) RemoveBookmark(user_email string, job_id string) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    result, err := db.Exec("DELETE FROM bookmark WHERE user_email=? AND job_id=?", user_email, idval)
+    if err != nil {
+        return err
+    }
+    if count, _ := result.RowsAffected(); count == 0 {
+        return data.NotFoundError("Job is not bookmarked")
+    }
+    return nil
+}
+
+// Function to list the jobs a user has bookmarked, by default the most
+// recently bookmarked first. Returns one page plus the total number
+func (store *sqlStore) ListBookmarks(user_email string, page data.Page_request) (bookmarks []data.Bookmark, total int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return bookmarks, 0, err
+    }
+    fromclause := "FROM bookmark b JOIN job j ON j.id=b.job_id WHERE b.user_email=?"
+    err = db.QueryRow("SELECT COUNT(*) " + fromclause, user_email).Scan(&total)
+    if err != nil {
+        return bookmarks, 0, err
+    }
+    clause, page_args := pageClause(page, bookmarkSortColumns, "bookmarked", "desc", "b.id")
+    sqlcmd := "SELECT j.id, j.title, j.is_open, j.created, j.expires_on, j.is_archived, j.organization_id, " + orgNameColumn +
+        ", j.province, j.city, j.work_arrangement, j.employment_type, b.created, b.filled " + fromclause + clause
+    rows, err := db.Query(sqlcmd, append([]interface{}{user_email}, page_args...)...)
+    if err != nil {
+        return bookmarks, 0, err
+    }
+    defer rows.Close()
+    var idval int
+    var posted string
+    var expires string
+    var org_idval int
+    var created string
+    var filled string
+    for rows.Next() {
+        var bookmark data.Bookmark
+        err = rows.Scan(&idval, &bookmark.Title, &bookmark.Is_open, &posted, &expires, &bookmark.Is_archived,
+                        &org_idval, &bookmark.Organization, &bookmark.Province, &bookmark.City,
+                        &bookmark.Work_arrangement, &bookmark.Employment_type, &created, &filled)
+        if err != nil {
+            return bookmarks, 0, err
+        }
+        bookmark.Job_id = fmt.Sprintf("%05d", idval)
+        bookmark.Date_posted = data.DisplayTime(posted)
+        bookmark.Expires_on = data.DisplayTime(expires)
+        bookmark.Org_id = orgIdString(org_idval)
+        bookmark.Bookmarked_on = data.DisplayTime(created)
+        bookmark.Filled = data.DisplayTime(filled)
+        bookmarks = append(bookmarks, bookmark)
+    }
+    return bookmarks, total, nil
+}
@@ -0,0 +1,110 @@
+package dbaccess
+// Tests for bookmarks, and how they follow the jobs they point to
+
+import (
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+func TestBookmarks(t *testing.T) {
+    mustRegister(t, "bookmark.seeker@example.com")
+    mustRegister(t, "bookmark.boss@example.com")
+    first_id, _ := testStore.CreateJob(data.Job_info{Creator: "bookmark.boss@example.com", Title: "Lighthouse Keeper", Description: "Keeps the light on", Salary: 22000})
+    second_id, _ := testStore.CreateJob(data.Job_info{Creator: "bookmark.boss@example.com", Title: "Lighthouse Painter", Description: "Paints stripes", Salary: 12000})
+
+    if err := testStore.AddBookmark("bookmark.seeker@example.com", first_id); err != nil {
+        t.Fatalf("AddBookmark failed: %v", err)
+    }
+    if err := testStore.AddBookmark("bookmark.seeker@example.com", first_id); data.ErrorCode(err) != data.CodeConflict {
+        t.Errorf("a second bookmark of the same job should conflict, got %v", err)
+    }
+    if err := testStore.AddBookmark("bookmark.seeker@example.com", "99999"); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("bookmarking a missing job should not be found, got %v", err)
+    }
+    if err := testStore.AddBookmark("bookmark.seeker@example.com", second_id); err != nil {
+        t.Fatalf("AddBookmark failed: %v", err)
+    }
+
+    // searches for a user flag the bookmarked jobs; others leave the flag out
+    summaries, _, err := testStore.SearchJobs(data.Search_criteria{Keyword: "Lighthouse", User_email: "bookmark.seeker@example.com"}, data.Page_request{})
+    if err != nil || len(summaries) != 2 {
+        t.Fatalf("expected both jobs, got %+v (%v)", summaries, err)
+    }
+    for _, summary := range summaries {
+        if summary.Bookmarked == nil || !*summary.Bookmarked {
+            t.Errorf("job %s should be flagged as bookmarked", summary.Job_id)
+        }
+    }
+    summaries, _, _ = testStore.SearchJobs(data.Search_criteria{Keyword: "Lighthouse", User_email: "bookmark.boss@example.com"}, data.Page_request{})
+    for _, summary := range summaries {
+        if summary.Bookmarked == nil || *summary.Bookmarked {
+            t.Errorf("job %s should not be bookmarked by the boss", summary.Job_id)
+        }
+    }
+    summaries, _, _ = testStore.SearchJobs(data.Search_criteria{Keyword: "Lighthouse"}, data.Page_request{})
+    for _, summary := range summaries {
+        if summary.Bookmarked != nil {
+            t.Errorf("a search without a user should not flag bookmarks")
+        }
+    }
+
+    // closing a job flags its bookmarks; reopening it clears the flag
+    closed := false
+    if _, err = testStore.ModifyJob(data.Job_changes{Creator: "bookmark.boss@example.com", Job_id: first_id, Is_open: &closed}); err != nil {
+        t.Fatalf("ModifyJob failed: %v", err)
+    }
+    bookmarks, total, err := testStore.ListBookmarks("bookmark.seeker@example.com", data.Page_request{Sort: "job_id", Order: "asc"})
+    if err != nil || total != 2 || len(bookmarks) != 2 || bookmarks[0].Job_id != first_id {
+        t.Fatalf("expected both bookmarks in order of job, got %+v, %d (%v)", bookmarks, total, err)
+    }
+    if bookmarks[0].Filled == "" || bookmarks[0].Is_open || bookmarks[1].Filled != "" || bookmarks[0].Bookmarked_on == "" {
+        t.Errorf("only the closed job should be flagged as filled, got %+v", bookmarks)
+    }
+    opened := true
+    testStore.ModifyJob(data.Job_changes{Creator: "bookmark.boss@example.com", Job_id: first_id, Is_open: &opened})
+    bookmarks, _, _ = testStore.ListBookmarks("bookmark.seeker@example.com", data.Page_request{Sort: "job_id", Order: "asc"})
+    if len(bookmarks) != 2 || bookmarks[0].Filled != "" {
+        t.Errorf("reopening the job should clear the flag, got %+v", bookmarks)
+    }
+
+    if err = testStore.RemoveBookmark("bookmark.seeker@example.com", second_id); err != nil {
+        t.Errorf("RemoveBookmark failed: %v", err)
+    }
+    if err = testStore.RemoveBookmark("bookmark.seeker@example.com", second_id); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("removing a missing bookmark should not be found, got %v", err)
+    }
+
+    // deleting the job or the user deletes the bookmarks
+    if err = testStore.DeleteJob("bookmark.boss@example.com", first_id); err != nil {
+        t.Fatalf("DeleteJob failed: %v", err)
+    }
+    if _, total, _ = testStore.ListBookmarks("bookmark.seeker@example.com", data.Page_request{}); total != 0 {
+        t.Errorf("deleting the job should delete its bookmarks, got %d", total)
+    }
+    testStore.AddBookmark("bookmark.seeker@example.com", second_id)
+    testStore.DeleteUser("bookmark.seeker@example.com")
+    mustRegister(t, "bookmark.seeker@example.com")
+    if _, total, _ = testStore.ListBookmarks("bookmark.seeker@example.com", data.Page_request{}); total != 0 {
+        t.Errorf("a deleted user's bookmarks should be deleted, got %d", total)
+    }
+}
+
+func TestHireFlagsBookmarks(t *testing.T) {
+    mustRegister(t, "hired.bookmarker@example.com")
+    mustRegister(t, "hired.applicant@example.com")
+    mustRegister(t, "hired.boss@example.com")
+    job_id, _ := testStore.CreateJob(data.Job_info{Creator: "hired.boss@example.com", Title: "Ferry Pilot", Description: "Crosses the bay", Salary: 26000})
+    testStore.AddBookmark("hired.bookmarker@example.com", job_id)
+    if _, _, err := testStore.SubmitJobApplication("hired.applicant@example.com", job_id, "", nil); err != nil {
+        t.Fatalf("SubmitJobApplication failed: %v", err)
+    }
+    for _, status := range []string{data.StatusReviewed, data.StatusShortlisted, data.StatusInterviewing, data.StatusOffered, data.StatusHired} {
+        if err := testStore.UpdateApplicationStatus("hired.boss@example.com", job_id, "hired.applicant@example.com", status); err != nil {
+            t.Fatalf("move to %s failed: %v", status, err)
+        }
+    }
+    bookmarks, _, err := testStore.ListBookmarks("hired.bookmarker@example.com", data.Page_request{})
+    if err != nil || len(bookmarks) != 1 || bookmarks[0].Filled == "" {
+        t.Errorf("hiring should flag the bookmark as filled, got %+v (%v)", bookmarks, err)
+    }
+}
@@ -7,50 +7,102 @@ import (
     "database/sql"
     "fmt"
     "os"
-    "sort"
     "strconv"
     "strings"
+    "sync"
     "time"
     "github.com/segoldin/JobWizard/job_wizard/data"      
     "github.com/joho/godotenv"     
      _ "github.com/mattn/go-sqlite3"
 )
 
-const timeFormatString = "2006-01-02 15:04 +700"
-const formatWithoutZone = "2006-01-02 15:04"
-
 // uppercase alphabet leaving out I and O
 var (
     db *sql.DB
     dbname = ""
+    dbdriver = ""   // driverSqlite or driverPostgres
+)
+
+// The database is opened only once, however many goroutines ask for it
+// first. connectErr is the error from doing so, if any
+var (
+    connectOnce sync.Once
+    connectErr error
 )
 //**************** Private Functions *******************************//
 
 // Connect to the database if not already done
 // Use module global var for the db connection
-// Return the db connection and error
-func connectDb(dbname string) (dbconn *sql.DB, err error) {
+// The schema is brought up to date unless JOBWIZARD_AUTO_MIGRATE is false
+// Return the error, if the database could not be opened
+func connectDb(dbname string) (err error) {
+    return firstConnect(dbname, autoMigrate())
+}
+
+// Open the database into db the first time it is needed, and bring the
+// schema up to date if migrate is true. Later calls return the first error
+func firstConnect(dbname string, migrate bool) (err error) {
+    connectOnce.Do(func() {
+        var dbconn *sql.DB
+        dbconn, connectErr = openDb(dbname)
+        if connectErr == nil && migrate {
+            _, connectErr = migrateDb(dbconn, -1)
+            if connectErr != nil {
+                dbconn.Close()
+            }
+        }
+        if connectErr == nil {
+            db = dbconn
+        }
+    })
+    return connectErr
+}
+
+// Close the database, so that the next use opens it again
+// Only for when nothing else is using it, such as between tests
+func closeDb() {
     if db != nil {
-        return db,nil
+        db.Close()
+        db = nil
     }
+    connectOnce = sync.Once{}
+    connectErr = nil
+    resetFullText()
+}
+
+// Open the database without checking its schema
+// If no name is given, it comes from JOBWIZARD_DB_NAME, and the driver
+// from JOBWIZARD_DB_DRIVER: sqlite3 (the default) or postgres
+// For PostgreSQL the name is a connection string; see postgres.go
+func openDb(dbname string) (dbconn *sql.DB, err error) {
+    godotenv.Load(".env_jobwizard")
     if dbname == "" {
-        godotenv.Load(".env_jobwizard")
         dbname = os.Getenv("JOBWIZARD_DB_NAME")
     }    
-    dbconn, err = sql.Open("sqlite3", dbname)
+    driver := dbdriver
+    if driver == "" {
+        driver = os.Getenv("JOBWIZARD_DB_DRIVER")
+    }
+    switch driver {
+    case "", driverSqlite:
+        dbconn, err = sql.Open(driverSqlite, dbname)
+    case driverPostgres:
+        dbconn, err = openPostgres(dbname)
+    default:
+        return nil, fmt.Errorf("Unknown JOBWIZARD_DB_DRIVER %s - must be %s or %s", driver, driverSqlite, driverPostgres)
+    }
     if err != nil {
         msg := fmt.Sprintf("Error opening the database - %v\n",err)
         return nil,fmt.Errorf(msg)
     }
-    //fmt.Printf("In connectDb - dbname is %s\n",dbname)
     return dbconn,nil
 }
 
 
 //******** Exported Functions *****************************//
 
-func CheckConnection() bool {
-    _,err := connectDb(dbname)
+func (store *sqlStore) CheckConnection() bool {
+    err := connectDb(dbname)
     if err != nil {
         return false
     } else {
@@ -58,13 +110,13 @@ func CheckConnection() bool {
     }    
 }
 
-func IsRegisteredUser(user_email string) (bRegistered bool, err error) {
-    db, err = connectDb(dbname)
+func (store *sqlStore) IsRegisteredUser(user_email string) (bRegistered bool, err error) {
+    err = connectDb(dbname)
     if err != nil {
         return false, err
     }     
-    sqlcmd := fmt.Sprintf("SELECT id FROM user WHERE user_email='%s'",user_email)
-    row := db.QueryRow(sqlcmd)
+    sqlcmd := "SELECT id FROM user WHERE user_email=?"
+    row := db.QueryRow(sqlcmd, user_email)
     var id int
     err = row.Scan(&id)
     if err != nil {
@@ -77,15 +129,17 @@ func IsRegisteredUser(user_email string) (bRegistered bool, err error) {
 }
 
 // Function to create a new user, implementing the Register use case
+// The password is stored only as a bcrypt hash. Experience, skills and
+// work history are optional, and are stored in the same transaction
 // If user email already exists, will return an error
-func RegisterUser(user_email string, first_name string, last_name string, phone string, education int) (err error) {
-    db,err = connectDb(dbname)
+func (store *sqlStore) RegisterUser(user_email string, first_name string, last_name string, phone string, education int, password string,
+                                    experience *int, skills []data.User_skill, work_history []data.Work_entry) (err error) {
+    err = connectDb(dbname)
     if err != nil {
         return err
     } 
-    sqlcmd := fmt.Sprintf("SELECT id from user where user_email='%s'",
-            user_email)
-    rows,err := db.Query(sqlcmd)
+    sqlcmd := "SELECT id from user where user_email=?"
+    rows,err := db.Query(sqlcmd, user_email)
     if err != nil {
         return err
     }
@@ -104,45 +158,84 @@ func RegisterUser(user_email string, first_name string, last_name string, phone
     }
     rows.Close() // need to explicitly close before insert/update or DB will be locked
     if rowcount > 0 {
-        return fmt.Errorf("Email is not unique; user not created")
+        return data.ConflictError("Email is not unique; user not created")
+    }
+    password_hash, err := hashPassword(password)
+    if err != nil {
+        return err
     }
     now := time.Now()
-    nowstring := now.Format(timeFormatString) 
-    sqlcmd = fmt.Sprintf("INSERT INTO user (user_email, first_name, last_name, phone, max_education, created) values ('%s','%s','%s','%s',%d,'%s')",
-                                user_email, first_name, last_name, phone, education, nowstring)
-    _,err = db.Exec(sqlcmd)
+    nowstring := data.StoredTime(now) 
+    years := 0
+    if experience != nil {
+        years = *experience
+    }
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    sqlcmd = "INSERT INTO user (user_email, first_name, last_name, phone, max_education, password_hash, years_experience, created) values (?,?,?,?,?,?,?,?)"
+    _,err = tx.Exec(sqlcmd, user_email, first_name, last_name, phone, education, password_hash, years, nowstring)
+    if err == nil && len(skills) > 0 {
+        err = saveUserSkills(tx, user_email, skills)
+    }
+    if err == nil && len(work_history) > 0 {
+        err = saveWorkHistory(tx, user_email, work_history)
+    }
     if err != nil {
+        tx.Rollback()
         return err
     }
-    return nil
+    return tx.Commit()
 }
 
 // Function to create a new job, implementing the Create Job use case
+// job.Expires_on is a stored time stamp after which the job closes, or "" for never
+// If job.Org_id is given the job belongs to that organization, and the
+// creator must be one of its members
 // Returns the ID (autoincrement) of the job, transformed into a string with leading zeros
-func CreateJob(creator_email string, title string, desc string, education int, experience int, salary int) (job_id string, err error) {
-    db,err = connectDb(dbname)
+func (store *sqlStore) CreateJob(job data.Job_info) (job_id string, err error) {
+    err = connectDb(dbname)
     if err != nil {
           return "", err
     }
+    org_idval := 0
+    if job.Org_id != "" {
+        org_idval, _ = strconv.Atoi(job.Org_id)  // already validated the format
+        _, err = checkOrgMember(db, org_idval, job.Creator)
+        if err != nil {
+            return "", err
+        }
+    }
+    // set up the full-text index, if any, before locking the db
+    fullTextEnabled()
     // do this in a transaction in case somebody else is also creating a job
     tx, err := db.Begin()
     if err != nil {
         return "", err
     }    
     now := time.Now()
-    nowstring := now.Format(timeFormatString)     
-    sqlcmd := 
-      fmt.Sprintf("INSERT INTO job (created_by, title, description, min_education, min_years_experience, salary, created) values ('%s','%s','%s', %d, %d, %d,'%s')",
-       creator_email, title, desc, education, experience, salary, nowstring) 
-    _,err = tx.Exec(sqlcmd)
+    nowstring := data.StoredTime(now)     
+    sqlcmd := "INSERT INTO job (created_by, title, description, min_education, min_years_experience, salary, created, expires_on, organization_id,"
+    sqlcmd += " province, city, latitude, longitude, work_arrangement, employment_type) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+    id, err := insertReturningId(tx, sqlcmd, job.Creator, job.Title, job.Description, job.Min_education, job.Min_experience,
+                                 job.Salary, nowstring, job.Expires_on, org_idval, job.Province, job.City, job.Latitude,
+                                 job.Longitude, job.Work_arrangement, job.Employment_type)
     if err != nil {
         tx.Rollback()
-        return "",err
+        return "", err
     }
-    sqlcmd = "SELECT MAX(id) FROM job"
-    var id int
-    row := tx.QueryRow(sqlcmd)
-    err = row.Scan(&id)  
+    err = saveJobSkills(tx, id, job.Skills)
+    if err != nil {
+        tx.Rollback()
+        return "", err
+    }
+    err = indexJob(tx, id)
+    if err != nil {
+        tx.Rollback()
+        return "", err
+    }
+    err = queueWebhookEvent(tx, data.EventJobCreated, id, "", "")
     if err != nil {
         tx.Rollback()
         return "", err
@@ -156,357 +249,621 @@ func CreateJob(creator_email string, title string, desc string, education int, e
 }
 
 // Function to search for jobs based on criteria, implementing the Search Jobs use case
-// Returns an array of job summary structures in posted date order (descending) or error
-func SearchJobs(posted_criterion string, min_experience int, min_education int, salary int, keyword string) (summaries []data.Job_summary, err error) {
-    var added_where = false   
-    sqlcmd := "SELECT id,title,is_open,created FROM job "
-    if posted_criterion != "" {
-        if !added_where {
-            sqlcmd += " where "
-            added_where = true
-        }
-        clause := fmt.Sprintf(" created >= '%s' ",posted_criterion)
-        sqlcmd += clause
-    }
-   if min_experience != 0 {
-        if !added_where {
-            sqlcmd += " where "
-            added_where = true
-        } else {
-            sqlcmd += " and "
-        }
-        clause := fmt.Sprintf(" min_years_experience <= %d ",min_experience)
-        sqlcmd += clause
+// The keyword is matched against the title and description; see search.go
+// Posted_from and Posted_to are stored time stamps (see data.StoredTimeFormat)
+// limiting the posted time, from inclusive and to exclusive, or empty
+// Organization matches part of the name of the organization offering the job
+// and Location part of its city or province; see location.go for the radius
+// Jobs must list all of the Skills given. Each summary has the match score
+// of the user searching, who can sort the results by it
+// Archived and expired jobs are left out unless Include_archived is true
+// Returns one page of job summary structures, by default in posted date order (descending),
+// or by relevance for a keyword search, plus the total number of matching jobs, or error
+// All user-supplied values are passed as query parameters, never pasted into the SQL
+func (store *sqlStore) SearchJobs(criteria data.Search_criteria, page data.Page_request) (summaries []data.Job_summary, total int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return summaries, 0, err
+    } 
+    var clauses []string
+    var args []interface{}
+    keyword := criteria.Keyword
+    terms := parseKeyword(keyword)
+    use_fts := len(terms) > 0 && fullTextEnabled()
+    fromclause := "FROM job j "
+    if use_fts {
+        fromclause += "JOIN job_fts ON job_fts.rowid = j.id "
+        clauses = append(clauses, "job_fts MATCH ?")
+        args = append(args, ftsQuery(terms))
+    } else if len(terms) > 0 {
+        clauses, args = likeClauses(terms)
+    } else if keyword != "" {
+        // nothing but punctuation - look for it literally in the title
+        // escape LIKE wildcards so % and _ in the keyword match literally
+        clauses = append(clauses, "j.title like ? escape '\\'")
+        args = append(args, "%" + escapeLike(keyword) + "%")
+    }
+    if criteria.Posted_from != "" {
+        clauses = append(clauses, "j.created >= ?")
+        args = append(args, criteria.Posted_from)
+    }
+    if criteria.Posted_to != "" {
+        clauses = append(clauses, "j.created < ?")
+        args = append(args, criteria.Posted_to)
+    }
+    if criteria.Experience != 0 {
+        clauses = append(clauses, "j.min_years_experience <= ?")
+        args = append(args, criteria.Experience)
     }    
-    if min_education != 0 {
-        if !added_where {
-            sqlcmd += " where "
-            added_where = true
-        } else {
-            sqlcmd += " and "
-        }
-        clause := fmt.Sprintf(" min_education <= %d ",min_education)
-        sqlcmd += clause
-    }
-    if salary != 0 {
-        if !added_where {
-            sqlcmd += " where "
-            added_where = true
-        } else {
-            sqlcmd += " and "
-        }
-        clause := fmt.Sprintf(" salary >= %d ",salary)
-        sqlcmd += clause
-    }
-    if keyword != "" {
-        if !added_where {
-            sqlcmd += " where "
-            added_where = true
-        } else {
-            sqlcmd += " and "
-        }
-        clause := fmt.Sprintf(" title like '%%%s%%' ",keyword)
-        sqlcmd += clause
-    }
-    sqlcmd += " order by created desc"   
-    summaries, err = doSearchOperation(sqlcmd)
-    return summaries, err
+    if criteria.Education != 0 {
+        clauses = append(clauses, "j.min_education <= ?")
+        args = append(args, criteria.Education)
+    }
+    if criteria.Salary != 0 {
+        clauses = append(clauses, "j.salary >= ?")
+        args = append(args, criteria.Salary)
+    }
+    if criteria.After_job_id != 0 {
+        clauses = append(clauses, "j.id > ?")
+        args = append(args, criteria.After_job_id)
+    }
+    if criteria.Org_id != "" {
+        org_idval, _ := strconv.Atoi(criteria.Org_id)  // already validated the format
+        clauses = append(clauses, "j.organization_id = ?")
+        args = append(args, org_idval)
+    }
+    if criteria.Organization != "" {
+        clauses = append(clauses, "j.organization_id IN (SELECT id FROM organization WHERE name like ? escape '\\')")
+        args = append(args, "%" + escapeLike(criteria.Organization) + "%")
+    }
+    for _, skill := range criteria.Skills {
+        clauses = append(clauses, "j.id IN (SELECT js.job_id FROM job_skill js, skill s WHERE js.skill_id = s.id AND s.name = ?)")
+        args = append(args, skill)
+    }
+    location_clauses, location_args := locationClauses(criteria)
+    clauses = append(clauses, location_clauses...)
+    args = append(args, location_args...)
+    if !criteria.Include_archived {
+        clauses = append(clauses, "j.is_archived = ?", "(j.expires_on = '' or j.expires_on > ?)")
+        args = append(args, false, data.StoredNow())
+    }
+    if len(clauses) > 0 {
+        fromclause += " where " + strings.Join(clauses, " and ")
+    }
+    summaries, total, err = doSearchOperation(fromclause, args, page, terms, use_fts, criteria.User_email)
+    return summaries, total, err
 }
 
-
-// Function to search for jobs offered by a particular user
-func SearchOfferedJobs(user_email string) (summaries []data.Job_summary, err error) {
-    sqlcmd := fmt.Sprintf("SELECT id,title,is_open,created FROM job where created_by='%s'",user_email)
-    sqlcmd += " order by created desc"
-    summaries, err = doSearchOperation(sqlcmd)
-    return summaries, err
+// Escape the characters that have special meaning in a LIKE pattern
+// so that they match literally. Used together with "escape '\'"
+func escapeLike(value string) string {
+    replacer := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+    return replacer.Replace(value)
 }
 
-// Function to search for jobs applied to by a particular user
-func SearchAppliedJobs(user_email string) (summaries []data.Job_summary, err error) {
-    sqlcmd := "SELECT j.id,j.title,j.is_open,j.created FROM job j, job_application ja "
-    sqlcmd += " where j.id=ja.job_id and "
-    sqlcmd += fmt.Sprintf("ja.user_email='%s'",user_email)
-    sqlcmd += " order by created desc"
-    summaries, err = doSearchOperation(sqlcmd)
-    return summaries, err
+
+// Function to search for jobs offered by a particular user
+// Returns one page of summaries plus the total number of jobs offered
+func (store *sqlStore) SearchOfferedJobs(user_email string, page data.Page_request) (summaries []data.Job_summary, total int, err error) {
+    fromclause := "FROM job j where created_by=?"
+    summaries, total, err = doSearchOperation(fromclause, []interface{}{user_email}, page, nil, false, "")
+    return summaries, total, err
 }
 
 // This function is a factorization that handles searching for jobs 
 // and returning summaries
-// It is called by three different tasks, which use different criteria/queries
+// It is called by two different tasks, which use different criteria/queries
 // but otherwise handle the return information the same way
-func doSearchOperation(sqlcmd string) (summaries []data.Job_summary, err error) {    
-    db,err = connectDb(dbname)
+// The fromclause selects from job with alias j; the args are the values
+// for its '?' placeholders. Returns one page and the total count
+// If there are search terms, the summaries include highlights, which come
+// from the job_fts table when use_fts is true and are built here otherwise
+// If user_email is given, the summaries have that user's match score
+// and say whether the user has bookmarked each job
+func doSearchOperation(fromclause string, args []interface{}, page data.Page_request,
+                       terms []searchTerm, use_fts bool, user_email string) (summaries []data.Job_summary, total int, err error) {    
+    err = connectDb(dbname)
     if err != nil {
-        return summaries, err
+        return summaries, 0, err
     } 
-    rows,err := db.Query(sqlcmd)
+    row := db.QueryRow("SELECT COUNT(*) " + fromclause, args...)
+    err = row.Scan(&total)
+    if err != nil {
+        return summaries, 0, err
+    }
+    sort_columns := jobSortColumns
+    default_sort := "posted"
+    selectcols := "SELECT j.id,j.title,j.is_open,j.created,j.expires_on,j.is_archived,j.organization_id," + orgNameColumn +
+        ",j.province,j.city,j.work_arrangement,j.employment_type"
+    var select_args []interface{}
+    if user_email != "" {
+        selectcols += "," + fmt.Sprintf(matchScoreColumn, data.MaxProficiency, "?") + "," + bookmarkedColumn
+        select_args = append(select_args, user_email, user_email)
+    } else {
+        selectcols += ",-1,0"
+    }
+    if use_fts {
+        sort_columns = ftsJobSortColumns
+        default_sort = "relevance"
+        selectcols += fmt.Sprintf(", highlight(job_fts, 0, '%s', '%s'), snippet(job_fts, 1, '%s', '%s', '%s', %d)",
+            highlightStart, highlightEnd, highlightStart, highlightEnd, snippetEllipsis, snippetWords)
+    } else if len(terms) > 0 {
+        selectcols += ", j.title, j.description"
+    }
+    if user_email != "" {
+        sort_columns = withMatchSort(sort_columns)
+    }
+    clause, page_args := pageClause(page, sort_columns, default_sort, "desc", "j.id")
+    sqlcmd := selectcols + " " + fromclause + clause
+    rows,err := db.Query(sqlcmd, append(append(select_args, args...), page_args...)...)
     if err != nil {
-        return summaries, err
+        return summaries, 0, err
     }
     defer rows.Close()
     var idval int
     var title string
     var is_open bool
     var posted string
+    var expires string
+    var is_archived bool
+    var org_idval int
+    var organization string
+    var province string
+    var city string
+    var arrangement string
+    var employment string
+    var score int
+    var bookmarked bool
+    var highlighted string
+    var snippet string
     for rows.Next() {
-        err = rows.Scan(&idval,&title,&is_open,&posted)
+        if len(terms) > 0 {
+            err = rows.Scan(&idval,&title,&is_open,&posted,&expires,&is_archived,&org_idval,&organization,
+                            &province,&city,&arrangement,&employment,&score,&bookmarked,&highlighted,&snippet)
+        } else {
+            err = rows.Scan(&idval,&title,&is_open,&posted,&expires,&is_archived,&org_idval,&organization,
+                            &province,&city,&arrangement,&employment,&score,&bookmarked)
+        }
         if err != nil {
             rows.Close()
-            return summaries, err
+            return summaries, 0, err
         }
         var job data.Job_summary
         job.Job_id = fmt.Sprintf("%05d",idval)
         job.Title = title
         job.Is_open = is_open
-        job.Date_posted = posted[0:10] 
+        job.Date_posted = data.DisplayTime(posted) 
+        job.Expires_on = data.DisplayTime(expires)
+        job.Is_archived = is_archived
+        job.Org_id = orgIdString(org_idval)
+        job.Organization = organization
+        job.Province = province
+        job.City = city
+        job.Work_arrangement = arrangement
+        job.Employment_type = employment
+        job.Match_score = scoreValue(score)
+        if user_email != "" {
+            job.Bookmarked = &bookmarked
+        }
+        if use_fts {
+            job.Title_highlight = highlighted
+            job.Snippet = snippet
+        } else if len(terms) > 0 {
+            // highlighted holds the title and snippet the description
+            job.Title_highlight = highlightText(highlighted, terms)
+            job.Snippet = makeSnippet(snippet, terms)
+        }
         summaries = append(summaries,job)
     }
-    // sort by job_id
-    sort.SliceStable(summaries, func(i, j int) bool {
-        return summaries[i].Job_id < summaries[j].Job_id
-    })
-    return summaries, nil
+    return summaries, total, nil
 }
 
 // Function to get all the detail for a particular job, implementing the Show Job Detail use case
 // Returns a filled in job structure if the job id is found
-func GetJobDetail(job_id string) (foundjob data.Job_info, err error) {
-    db,err = connectDb(dbname)
+func (store *sqlStore) GetJobDetail(job_id string) (foundjob data.Job_info, err error) {
+    err = connectDb(dbname)
     if err != nil {
         return foundjob, err
     }     
     id, _ := strconv.Atoi(job_id)  // we already validated this 
-    sqlcmd := "SELECT created_by, title, description, min_education, min_years_experience, salary, is_open, created"
-    whereclause := fmt.Sprintf(" from job where id = %d", id);
-    sqlcmd = sqlcmd + whereclause
-    row := db.QueryRow(sqlcmd)
+    var org_idval int
+    sqlcmd := "SELECT created_by, title, description, min_education, min_years_experience, salary, is_open, created,"
+    sqlcmd += " expires_on, is_archived, organization_id, " + orgNameColumn + ","
+    sqlcmd += " province, city, latitude, longitude, work_arrangement, employment_type from job j where id = ?"
+    row := db.QueryRow(sqlcmd, id)
     err = row.Scan(&foundjob.Creator,&foundjob.Title,&foundjob.Description,
-         &foundjob.Min_education,&foundjob.Min_experience,&foundjob.Salary,&foundjob.Is_open,&foundjob.Date_posted)
+         &foundjob.Min_education,&foundjob.Min_experience,&foundjob.Salary,&foundjob.Is_open,&foundjob.Date_posted,
+         &foundjob.Expires_on,&foundjob.Is_archived,&org_idval,&foundjob.Organization,
+         &foundjob.Province,&foundjob.City,&foundjob.Latitude,&foundjob.Longitude,
+         &foundjob.Work_arrangement,&foundjob.Employment_type)
     if err != nil {
         if err == sql.ErrNoRows {
-            return foundjob, fmt.Errorf("No matching job found")
+            return foundjob, data.NotFoundError("No matching job found")
         } else {
             return foundjob, err
         }
     }
+    foundjob.Skills, err = loadJobSkills(db, id)
+    if err != nil {
+        return foundjob, err
+    }
     foundjob.Job_id = fmt.Sprintf("%05d",id)
-    foundjob.Date_posted = foundjob.Date_posted[0:10]
+    foundjob.Org_id = orgIdString(org_idval)
+    foundjob.Date_posted = data.DisplayTime(foundjob.Date_posted)
+    foundjob.Expires_on = data.DisplayTime(foundjob.Expires_on)
     return foundjob, nil 
 }
 
-// Function to modify an existing job. Only changes values with non-null values
-// Null value for string arguments indicated by ""
-// Null value for integers indicated by 0
-// For boolean, only modify if is_open is false (default when created is true)
+// Function to modify an existing job, by its creator or any member of the
+// organization offering it, changing only the fields given
+// in changes, which may be set to 0, "" or false. A filled job can be
+// reopened, but not if someone was hired for it or it has expired. Expires_on, if given, is a stored
+// time stamp, or "" to remove the expiry. Archived jobs cannot be changed
 // Returns the ID (autoincrement) of the job, transformed into a string with leading zeros
-func ModifyJob(creator_email string, job_id string, title string, desc string, education int, experience int, salary int, is_open bool) (return_job_id string, err error) {
-    db,err = connectDb(dbname)
+func (store *sqlStore) ModifyJob(changes data.Job_changes) (return_job_id string, err error) {
+    err = connectDb(dbname)
     if err != nil {
           return "", err
     }
-    return_job_id = job_id
-    // First, check that this job exists and that it was created by this user
-    // Also get the is_open flag. We don't allow a job to be marked as filled twice
-    // BUT it *can* be reopened
-    idval, _ := strconv.Atoi(job_id) 
-    sqlcmd := fmt.Sprintf("select created_by, is_open from job where id=%d", idval)
-    row := db.QueryRow(sqlcmd)
+    return_job_id = changes.Job_id
+    // First, check that this job exists and that this user created it
+    // or belongs to its organization
+    // Also get the is_open flag and expiry, to check opening and closing
+    idval, _ := strconv.Atoi(changes.Job_id) 
+    sqlcmd := "select created_by, organization_id, is_open, is_archived, expires_on, coalesce(hired_person, '') from job where id=?"
+    row := db.QueryRow(sqlcmd, idval)
     var created_by string
+    var org_idval int
     var open_flag bool
-    err = row.Scan(&created_by, &open_flag)  
+    var is_archived bool
+    var expires string
+    var hired_person string
+    err = row.Scan(&created_by, &org_idval, &open_flag, &is_archived, &expires, &hired_person)  
     if err != nil {
-        return "00000", fmt.Errorf("No matching job found")
+        return "00000", data.NotFoundError("No matching job found")
     }
-    if !strings.EqualFold(creator_email, created_by) {
-        return "00000", fmt.Errorf("Specified user did not create this job")
+    err = checkJobManager(db, created_by, org_idval, changes.Creator)
+    if err != nil {
+        return "00000", err
     }
-    if (open_flag == false) && (is_open == false) {
-        return "00000", fmt.Errorf("Job has already been filled")
+    if is_archived {
+        return "00000", data.ConflictError("Job has been archived")
     }
-    sqlcmd = constructUpdateCommand(idval, title, desc, education, experience, salary, is_open)
-    _, err = db.Exec(sqlcmd)
+    err = checkJobChanges(changes, open_flag, expires, hired_person)
     if err != nil {
         return "00000", err
-    } else {
-        return return_job_id, nil
     }
-}
-
-// construct an SQL command to update only the columns with non-null values
-// return the command
-func constructUpdateCommand(idval int, title string, desc string, education int, experience int, salary int, is_open bool) (sqlcmd string) {
-    values := ""
-    firstColumn := true
-    if title != "" {
-        if !firstColumn {
-            values += ", "
-        }
-        valstring := fmt.Sprintf("title='%s'",title)
-        values += valstring
-        firstColumn = false
+    // set up the full-text index, if any, before locking the db
+    fullTextEnabled()
+    tx, err := db.Begin()
+    if err != nil {
+        return "00000", err
     }
-    if desc != "" {
-        if !firstColumn {
-            values += ", "
-        }
-        valstring := fmt.Sprintf("description='%s'",desc)
-        values += valstring
-        firstColumn = false
+    sqlcmd, args := constructUpdateCommand(idval, changes)
+    reopening := changes.Is_open != nil && *changes.Is_open && !open_flag
+    if reopening {
+        // in case someone was hired since the check above
+        sqlcmd += " AND coalesce(hired_person, '') = ''"
     }
-    if education != 0 {
-        if !firstColumn {
-            values += ", "
+    if sqlcmd != "" {
+        result, err := tx.Exec(sqlcmd, args...)
+        if err != nil {
+            tx.Rollback()
+            return "00000", err
+        }
+        if count, _ := result.RowsAffected(); reopening && count == 0 {
+            tx.Rollback()
+            return "00000", data.ConflictError("Someone has been hired for this job - it cannot be reopened")
         }
-        valstring := fmt.Sprintf("min_education=%d",education)
-        values += valstring
-        firstColumn = false
     }
-    if experience != 0 {
-        if !firstColumn {
-            values += ", "
+    if changes.Skills != nil {
+        err = saveJobSkills(tx, idval, *changes.Skills)
+        if err != nil {
+            tx.Rollback()
+            return "00000", err
         }
-        valstring := fmt.Sprintf("min_years_experience=%d",experience)
-        values += valstring
-        firstColumn = false
     }
-    if salary != 0 {
-        if !firstColumn {
-            values += ", "
+    if changes.Is_open != nil && *changes.Is_open != open_flag {
+        err = flagFilledBookmarks(tx, idval, !*changes.Is_open)
+        if err != nil {
+            tx.Rollback()
+            return "00000", err
         }
-        valstring := fmt.Sprintf("salary=%d",salary)
-        values += valstring
-        firstColumn = false
     }
-    if is_open == false {
-        if !firstColumn {
-            values += ", "
+    if changes.Title != nil || changes.Description != nil {
+        err = indexJob(tx, idval)
+        if err != nil {
+            tx.Rollback()
+            return "00000", err
         }
-        values += "is_open='f'"
-        firstColumn = false        
     }
-    sqlcmd = fmt.Sprintf("UPDATE job set %s WHERE id = %d",values,idval)
+    err = queueWebhookEvent(tx, data.EventJobModified, idval, "", "")
+    if err == nil && changes.Is_open != nil && open_flag && !*changes.Is_open {
+        err = queueWebhookEvent(tx, data.EventJobFilled, idval, "", "")
+    }
+    if err != nil {
+        tx.Rollback()
+        return "00000", err
+    }
+    err = tx.Commit()
+    if err != nil {
+        return "00000", err
+    }
+    return return_job_id, nil
+}
+
+// construct an SQL command to update only the columns given in changes
+// return the command and the values for its '?' placeholders, or ""
+// if only the skills change
+func constructUpdateCommand(idval int, changes data.Job_changes) (sqlcmd string, args []interface{}) {
+    var columns []string
+    if changes.Title != nil {
+        columns = append(columns, "title=?")
+        args = append(args, *changes.Title)
+    }
+    if changes.Description != nil {
+        columns = append(columns, "description=?")
+        args = append(args, *changes.Description)
+    }
+    if changes.Min_education != nil {
+        columns = append(columns, "min_education=?")
+        args = append(args, *changes.Min_education)
+    }
+    if changes.Min_experience != nil {
+        columns = append(columns, "min_years_experience=?")
+        args = append(args, *changes.Min_experience)
+    }
+    if changes.Salary != nil {
+        columns = append(columns, "salary=?")
+        args = append(args, *changes.Salary)
+    }
+    if changes.Is_open != nil {
+        columns = append(columns, "is_open=?")
+        args = append(args, *changes.Is_open)
+    }
+    if changes.Expires_on != nil {
+        columns = append(columns, "expires_on=?")
+        args = append(args, *changes.Expires_on)
+    }
+    if changes.Province != nil {
+        columns = append(columns, "province=?")
+        args = append(args, *changes.Province)
+    }
+    if changes.City != nil {
+        columns = append(columns, "city=?")
+        args = append(args, *changes.City)
+    }
+    if changes.Latitude != nil {
+        columns = append(columns, "latitude=?")
+        args = append(args, *changes.Latitude)
+    }
+    if changes.Longitude != nil {
+        columns = append(columns, "longitude=?")
+        args = append(args, *changes.Longitude)
+    }
+    if changes.Work_arrangement != nil {
+        columns = append(columns, "work_arrangement=?")
+        args = append(args, *changes.Work_arrangement)
+    }
+    if changes.Employment_type != nil {
+        columns = append(columns, "employment_type=?")
+        args = append(args, *changes.Employment_type)
+    }
+    if len(columns) == 0 {
+        return "", nil
+    }
+    sqlcmd = fmt.Sprintf("UPDATE job set %s WHERE id = ?", strings.Join(columns, ", "))
+    args = append(args, idval)
     //fmt.Println(sqlcmd)
-    return sqlcmd    
+    return sqlcmd, args    
 }
 
 // Function to apply for a job
-// Returns the ID of the job, transformed into a string with leading zeros or
-// an empty string and an error or warning
-// Checks for job already filled
-// Warns if experience or education is below requirements
-func SubmitJobApplication(user_email string, job_id string) (applied_job_id string, err error) {
-    db,err = connectDb(dbname)
+// Returns the ID of the job, transformed into a string with leading zeros,
+// and the job's requirements that the user does not meet, if any; or
+// an empty string and an error
+// Checks for job already filled, archived or expired
+// Unmet education, experience or required skills do not stop the application
+// The resume is sent with the application; if it is nil, the user's default
+// resume is sent instead, if they have one
+func (store *sqlStore) SubmitJobApplication(user_email string, job_id string, cover_letter string,
+                                            resume *data.Document) (applied_job_id string, unmet []data.Unmet_requirement, err error) {
+    err = connectDb(dbname)
     if err != nil {
-          return "", err
+          return "", nil, err
+    }
+    blob_key := ""
+    if resume != nil {
+        blob_key, err = putResume(resume)
+        if err != nil {
+            return "", nil, err
+        }
+    }
+    applied_job_id, unmet, err = submitJobApplication(user_email, job_id, cover_letter, resume, blob_key)
+    if err != nil {
+        deleteBlobs([]string{blob_key})
     }
+    return applied_job_id, unmet, err
+}
+
+// Record an application in a transaction, with the resume whose content
+// is kept under blob_key, or the user's default resume if resume is nil
+func submitJobApplication(user_email string, job_id string, cover_letter string, resume *data.Document,
+                          blob_key string) (applied_job_id string, unmet []data.Unmet_requirement, err error) {
     idval, _ := strconv.Atoi(job_id)  // already validated the format
     // start by getting the user information
     // do this in a transaction so nobody else can apply
     var education int
-    sqlcmd := fmt.Sprintf("SELECT max_education FROM user WHERE user_email='%s'",user_email)
-    row := db.QueryRow(sqlcmd)
-    err = row.Scan(&education)
+    var experience int
+    sqlcmd := "SELECT max_education, coalesce(years_experience, 0) FROM user WHERE user_email=?"
+    row := db.QueryRow(sqlcmd, user_email)
+    err = row.Scan(&education, &experience)
     if err != nil {
-        return "", fmt.Errorf("Unknown user")
+        return "", nil, data.NotFoundError("Unknown user")
     }
  
     // do this in a transaction in case somebody else is also applying for a job
     tx, err := db.Begin()
     if err != nil {
-        return "", err
+        return "", nil, err
     }    
     // now get the job information
-    sqlcmd = fmt.Sprintf("SELECT created_by, min_education, is_open FROM job WHERE id=%d",idval)
-    row = tx.QueryRow(sqlcmd)
+    sqlcmd = "SELECT created_by, min_education, coalesce(min_years_experience, 0), is_open, is_archived, expires_on FROM job WHERE id=?"
+    row = tx.QueryRow(sqlcmd, idval)
     var creator string
     var min_education int
+    var min_experience int
     var open_flag bool
-    err = row.Scan(&creator, &min_education, &open_flag)
+    var is_archived bool
+    var expires string
+    err = row.Scan(&creator, &min_education, &min_experience, &open_flag, &is_archived, &expires)
     if err != nil {
         tx.Rollback()
-        return "", fmt.Errorf("No matching job found")
+        return "", nil, data.NotFoundError("No matching job found")
+    }
+    if is_archived {
+        tx.Rollback()
+        return "", nil, data.ConflictError("Job has been archived")
+    }
+    if isExpired(expires) {
+        tx.Rollback()
+        return "", nil, data.ConflictError("Job has expired")
     }
     if open_flag == false {
         tx.Rollback()
-        return "", fmt.Errorf("Job has already been filled")        
+        return "", nil, data.ConflictError("Job has already been filled")        
     }
     if user_email == creator {
-        return "", fmt.Errorf("Creator cannot submit an application for their own job")
+        tx.Rollback()
+        return "", nil, data.ForbiddenError("Creator cannot submit an application for their own job")
+    }
+    var resume_id int
+    if resume != nil {
+        resume_id, err = insertResume(tx, user_email, resume, blob_key)
+    } else {
+        err = tx.QueryRow("SELECT coalesce(resume_id, 0) FROM user WHERE user_email=?", user_email).Scan(&resume_id)
+    }
+    if err != nil {
+        tx.Rollback()
+        return "", nil, err
     }
     now := time.Now()
-    nowstring := now.Format(timeFormatString)     
-    sqlcmd = 
-      fmt.Sprintf("INSERT INTO job_application (job_id, user_email,apply_time) VALUES (%d,'%s','%s')",
-       idval, user_email, nowstring) 
-    _,err = tx.Exec(sqlcmd)
+    nowstring := data.StoredTime(now)     
+    sqlcmd = "INSERT INTO job_application (job_id, user_email, apply_time, status, status_time, resume_id, cover_letter) VALUES (?,?,?,?,?,?,?)"
+    _,err = tx.Exec(sqlcmd, idval, user_email, nowstring, data.StatusSubmitted, nowstring, resume_id, cover_letter)
     if err != nil {
         tx.Rollback()
-        testErr := fmt.Sprintf("%v",err)
-        if strings.Contains(testErr,"UNIQUE") {
-            return "", fmt.Errorf("Attempt to create duplicate job application")
+        if isUniqueViolation(err) {
+            return "", nil, data.ConflictError("Attempt to create duplicate job application")
         } else {
-            return "",err
+            return "", nil, err
         }
     }
-    tx.Commit()
-    applied_job_id = job_id
-    if min_education > education {
-        return applied_job_id,fmt.Errorf("Applied but user education is less than job requires")
-    } else {
-        return applied_job_id, nil
+    err = recordStatus(tx, idval, user_email, data.StatusSubmitted, user_email, nowstring)
+    if err != nil {
+        tx.Rollback()
+        return "", nil, err
+    }
+    unmet, err = loadUnmetRequirements(tx, user_email, education, experience, idval, min_education, min_experience)
+    if err != nil {
+        tx.Rollback()
+        return "", nil, err
+    }
+    err = tx.Commit()
+    if err != nil {
+        return "", nil, err
     }
+    return job_id, unmet, nil
 }
 
 // Search for anyone who has applied for a specific job 
-// This must be a job created by the 'creator' email
-// Returns a list of Candidate structures or an error
-func SearchCandidates(creator_email string, job_id string) (candidates []data.Candidate, err error) {
-    db,err = connectDb(dbname)
+// This must be a job created by the 'creator' email, or offered by
+// an organization that user belongs to
+// Returns one page of Candidate structures, by default in order of application,
+// plus the total number of candidates, or an error
+// Each candidate has their match score for the job, which they can be sorted by,
+// and whether they meet all its requirements. If qualified_only is true,
+// only candidates who meet them are returned. The cover letter is included,
+// but only the description of the resume; see GetApplicationResume
+func (store *sqlStore) SearchCandidates(creator_email string, job_id string, qualified_only bool,
+                                        page data.Page_request) (candidates []data.Candidate, total int, err error) {
+    err = connectDb(dbname)
     if err != nil {
-          return candidates, err
+          return candidates, 0, err
     }
-    // First, check that this job exists and that it was created by this user
+    // First, check that this job exists and that this user created it
+    // or belongs to its organization
     idval, _ := strconv.Atoi(job_id) 
-    sqlcmd := fmt.Sprintf("select created_by, is_open from job where id=%d", idval)
-    row := db.QueryRow(sqlcmd)
+    sqlcmd := "select created_by, organization_id from job where id=?"
+    row := db.QueryRow(sqlcmd, idval)
     var created_by string
-    var open_flag bool
-    err = row.Scan(&created_by, &open_flag)  
+    var org_idval int
+    err = row.Scan(&created_by, &org_idval)  
+    if err != nil {
+        return candidates, 0, data.NotFoundError("No matching job found")
+    }
+    err = checkJobManager(db, created_by, org_idval, creator_email)
     if err != nil {
-        return candidates, fmt.Errorf("No matching job found")
+        return candidates, 0, err
     }
-    if !strings.EqualFold(creator_email, created_by) {
-        return candidates, fmt.Errorf("Specified user did not create this job")
+    // okay... let's join the applicants and user table, and the job for the match score
+    fromclause := "FROM job_application a JOIN job j ON j.id=a.job_id JOIN user u ON a.user_email=u.user_email" +
+        " LEFT JOIN resume r ON r.id=a.resume_id WHERE a.job_id=?"
+    if qualified_only {
+        fromclause += " AND " + qualifiedCondition
     }
-    // okay... let's join the applicants and user table
-    formatString := "SELECT a.user_email, a.apply_time, u.first_name, u.last_name, u.phone " +
-       "FROM job_application a, user u where a.user_email=u.user_email AND " +
-       "a.job_id=%d order by a.apply_time" 
-    sqlcmd = fmt.Sprintf(formatString,idval)
-    rows,err := db.Query(sqlcmd)
+    row = db.QueryRow("SELECT COUNT(*) " + fromclause, idval)
+    err = row.Scan(&total)
+    if err != nil {
+        return candidates, 0, err
+    }
+    clause, page_args := pageClause(page, candidateSortColumns, "applied", "asc", "a.id")
+    sqlcmd = "SELECT a.user_email, a.apply_time, a.status, a.status_time, u.first_name, u.last_name, u.phone, " +
+       "coalesce(u.max_education, 0), coalesce(u.years_experience, 0), " + qualifiedCondition + " AS qualified, " +
+       "coalesce(a.cover_letter, ''), coalesce(r.id, 0), coalesce(r.file_name, ''), coalesce(r.content_type, ''), " +
+       "coalesce(r.size_bytes, 0), coalesce(r.created, ''), " +
+       fmt.Sprintf(matchScoreColumn, data.MaxProficiency, "a.user_email") + " " + fromclause + clause
+    rows,err := db.Query(sqlcmd, append([]interface{}{idval}, page_args...)...)
     if err != nil {
-        return candidates, err
+        return candidates, 0, err
     }
     defer rows.Close()
     var email string
     var applied_time string
+    var status string
+    var status_time sql.NullString
     var first string
     var last string
     var phone string
+    var score int
+    var resume_id int
+    var resume data.Document
     for rows.Next() {
-        err = rows.Scan(&email, &applied_time, &first, &last, &phone)
+        var applicant data.Candidate
+        err = rows.Scan(&email, &applied_time, &status, &status_time, &first, &last, &phone,
+                        &applicant.Education, &applicant.Experience, &applicant.Qualified, &applicant.Cover_letter,
+                        &resume_id, &resume.File_name, &resume.Content_type, &resume.Size, &resume.Uploaded, &score)
         if err != nil {
             rows.Close()
-            return candidates, err
+            return candidates, 0, err
         }
-        var applicant data.Candidate
         applicant.Email = email
         applicant.Name = first + " " + last
         applicant.Phone = phone
-        applicant.Applied_date = applied_time[0:10]
+        applicant.Applied_date = data.DisplayTime(applied_time)
+        applicant.Status = status
+        applicant.Match_score = scoreValue(score)
+        // applications made before statuses existed have no status time
+        applicant.Status_date = applicant.Applied_date
+        if status_time.Valid {
+            applicant.Status_date = data.DisplayTime(status_time.String)
+        }
+        if resume_id != 0 {
+            description := resume
+            description.Uploaded = data.DisplayTime(resume.Uploaded)
+            applicant.Resume = &description
+        }
         candidates = append(candidates,applicant)
     }
-    return candidates, nil    
+    return candidates, total, nil    
 }
@@ -0,0 +1,165 @@
+package dbaccess
+// This module holds the sample users and jobs loaded into the
+// in-memory store in demo mode. They are the same as database/users.csv
+// and database/jobs.csv, and every user's password is demoPassword
+// Every job except the CEO posting belongs to the sample organization,
+// and the jobs and users also have locations, skills and experience,
+// which the CSV files do not
+
+import (
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Password for all the demo users
+const demoPassword = "jobwizard"
+
+var demoUsers = []data.User_info{
+    {Email: "sally@cmkl.ac.th", First: "Sally", Last: "Goldin", Phone: "0879990088", Education: demoEducation(4), Experience: demoYears(25),
+     Skills: []data.User_skill{{Name: "go", Proficiency: 5}, {Name: "sql", Proficiency: 4}, {Name: "teaching", Proficiency: 5}},
+     Work_history: []data.Work_entry{{Title: "Professor", Employer: "CMKL University", Start_date: "2019-01-07"}}},
+    {Email: "joe@cmkl.ac.th", First: "Joe", Last: "Jenkins", Phone: "0329871233", Education: demoEducation(1), Experience: demoYears(6),
+     Skills: []data.User_skill{{Name: "recruiting", Proficiency: 4}}},
+    {Email: "mark@cmkl.ac.th", First: "Mark", Last: "Masters", Phone: "0770992324", Education: demoEducation(3), Experience: demoYears(10),
+     Skills: []data.User_skill{{Name: "management", Proficiency: 4}, {Name: "react", Proficiency: 2}}},
+    {Email: "jim@gmail.com", First: "James", Last: "Jamison", Phone: "0654329809", Education: demoEducation(2), Experience: demoYears(3),
+     Skills: []data.User_skill{{Name: "javascript", Proficiency: 4}, {Name: "react", Proficiency: 3}, {Name: "figma", Proficiency: 2}},
+     Work_history: []data.Work_entry{
+         {Title: "Web Developer", Employer: "Siam Web Studio", Start_date: "2022-06-01", End_date: "2024-05-31"},
+         {Title: "Intern", Employer: "Siam Web Studio", Start_date: "2021-06-01", End_date: "2021-08-31"}}},
+    {Email: "lisa@outlook.com", First: "Lisa", Last: "Roberts", Phone: "0567876666", Education: demoEducation(1), Experience: demoYears(1)},
+}
+
+// The sample organization, owned by its creator, and its other members
+var demoOrg = data.Organization{Creator: "sally@cmkl.ac.th", Name: "CMKL University",
+    Description: "Graduate university in Bangkok focused on AI, computing and engineering",
+    Website: "https://www.cmkl.ac.th", Location: "Bangkok"}
+
+var demoMembers = []data.Member_change{
+    {Creator: "sally@cmkl.ac.th", Org_id: "00001", Member: "joe@cmkl.ac.th", Role: data.RoleRecruiter},
+    {Creator: "sally@cmkl.ac.th", Org_id: "00001", Member: "mark@cmkl.ac.th", Role: data.RoleRecruiter},
+}
+
+var demoJobs = []data.Job_info{
+    {Creator: "sally@cmkl.ac.th", Org_id: "00001", Title: "Front End Developer",
+     Description: "Design and build attractive and highly usable UIs using React/JS",
+     Province: "Bangkok", City: "Pathum Wan", Latitude: 13.7388, Longitude: 100.5290,
+     Work_arrangement: data.WorkHybrid, Employment_type: data.EmploymentFullTime,
+     Skills: []data.Job_skill{{Name: "javascript", Required: true}, {Name: "react", Required: true}, {Name: "figma"}}},
+    {Creator: "sally@cmkl.ac.th", Org_id: "00001", Title: "Back End Developer",
+     Description: "Microservices; REST APIs; Go language; Database design and implementation",
+     Min_education: 2, Min_experience: 2, Salary: 40000,
+     Province: "Bangkok", City: "Pathum Wan", Latitude: 13.7388, Longitude: 100.5290,
+     Work_arrangement: data.WorkRemote, Employment_type: data.EmploymentFullTime,
+     Skills: []data.Job_skill{{Name: "go", Required: true}, {Name: "sql", Required: true}, {Name: "docker"}}},
+    {Creator: "joe@cmkl.ac.th", Org_id: "00001", Title: "HR Director",
+     Description: "Manage onboarding - evaluation - staff retention - staff benefits for small university",
+     Min_education: 3, Min_experience: 5, Salary: 95000,
+     Province: "Bangkok", City: "Pathum Wan", Latitude: 13.7388, Longitude: 100.5290,
+     Work_arrangement: data.WorkOnsite, Employment_type: data.EmploymentFullTime,
+     Skills: []data.Job_skill{{Name: "recruiting", Required: true}, {Name: "management"}}},
+    {Creator: "mark@cmkl.ac.th", Org_id: "00001", Title: "Executive Secretary",
+     Description: "Handle day to day management tasks for university president",
+     Min_education: 1, Min_experience: 3, Salary: 35000,
+     Province: "Bangkok", City: "Pathum Wan", Latitude: 13.7388, Longitude: 100.5290,
+     Work_arrangement: data.WorkOnsite, Employment_type: data.EmploymentFullTime},
+    {Creator: "joe@cmkl.ac.th", Org_id: "00001", Title: "Student Relations Officer",
+     Description: "Assist students with planning study; gather feedback and complaints; interface with curriculum committee",
+     Min_education: 2, Min_experience: 3, Salary: 42000,
+     Province: "Bangkok", City: "Pathum Wan", Latitude: 13.7388, Longitude: 100.5290,
+     Work_arrangement: data.WorkOnsite, Employment_type: data.EmploymentFullTime},
+    {Creator: "sally@cmkl.ac.th", Org_id: "00001", Title: "Professor",
+     Description: "Teaching and research to support the university",
+     Min_education: 4, Min_experience: 5, Salary: 95000,
+     Province: "Bangkok", City: "Pathum Wan", Latitude: 13.7388, Longitude: 100.5290,
+     Work_arrangement: data.WorkOnsite, Employment_type: data.EmploymentFullTime},
+    {Creator: "joe@cmkl.ac.th", Org_id: "00001", Title: "Software Project Leader",
+     Description: "Allocate tasks to software development team; monitor progress; train new developers; report to managment",
+     Min_education: 3, Min_experience: 4, Salary: 50000,
+     Province: "Bangkok", City: "Pathum Wan", Latitude: 13.7388, Longitude: 100.5290,
+     Work_arrangement: data.WorkOnsite, Employment_type: data.EmploymentFullTime,
+     Skills: []data.Job_skill{{Name: "management", Required: true}, {Name: "go"}, {Name: "javascript"}}},
+    {Creator: "joe@cmkl.ac.th", Org_id: "00001", Title: "Graphics Professional",
+     Description: "Create graphics content including imagery, videos, slide decks; acquire photos at university events",
+     Min_education: 3, Min_experience: 2, Salary: 32600,
+     Province: "Bangkok", City: "Pathum Wan", Latitude: 13.7388, Longitude: 100.5290,
+     Work_arrangement: data.WorkHybrid, Employment_type: data.EmploymentContract},
+    {Creator: "joe@cmkl.ac.th", Org_id: "00001", Title: "Janitor",
+     Description: "Cleaning and maintenance",
+     Min_education: 1, Salary: 12500,
+     Province: "Bangkok", City: "Pathum Wan", Latitude: 13.7388, Longitude: 100.5290,
+     Work_arrangement: data.WorkOnsite, Employment_type: data.EmploymentFullTime},
+    {Creator: "joe@cmkl.ac.th", Org_id: "00001", Title: "Driver",
+     Description: "Part time - Drive university van on schedule rounds; occasionally chauffer university president",
+     Min_education: 1, Min_experience: 3, Salary: 18500,
+     Province: "Bangkok", City: "Pathum Wan", Latitude: 13.7388, Longitude: 100.5290,
+     Work_arrangement: data.WorkOnsite, Employment_type: data.EmploymentPartTime},
+    {Creator: "mark@cmkl.ac.th", Title: "CEO",
+     Description: "Top executive for promising tech start-up; compensation includes stock options",
+     Min_education: 4, Min_experience: 4, Salary: 60000,
+     Province: "Chiang Mai", City: "Mueang Chiang Mai", Latitude: 18.7883, Longitude: 98.9853,
+     Work_arrangement: data.WorkOnsite, Employment_type: data.EmploymentFullTime},
+    {Creator: "sally@cmkl.ac.th", Org_id: "00001", Title: "UX Designer",
+     Description: "Design user interfaces for in-house software; guide developers in implementation; handle usability tests",
+     Min_education: 3, Min_experience: 3, Salary: 38000,
+     Province: "Bangkok", City: "Pathum Wan", Latitude: 13.7388, Longitude: 100.5290,
+     Work_arrangement: data.WorkOnsite, Employment_type: data.EmploymentFullTime,
+     Skills: []data.Job_skill{{Name: "figma", Required: true}, {Name: "react"}}},
+}
+
+// Jobs in the sample data that have been filled, and who was hired
+var demoHires = []data.Status_change{
+    {Creator: "joe@cmkl.ac.th", Job_id: "00009", Applicant: "lisa@outlook.com", Status: data.StatusHired},
+    {Creator: "sally@cmkl.ac.th", Job_id: "00012", Applicant: "jim@gmail.com", Status: data.StatusHired},
+}
+
+//**************** Private Functions *******************************//
+
+// Return a number of years of experience for a demo user
+func demoYears(years int) *int {
+    return &years
+}
+
+// Return a pointer to an education level, for the demo users
+func demoEducation(level int) *int {
+    return &level
+}
+
+//******** Exported Functions *****************************//
+
+// Fill an empty store with the sample users, organization and jobs
+// The organization must get the ID 00001 and the jobs 00001 to 00012,
+// so the store must be new
+func LoadDemoData(store Store) (err error) {
+    for _, user := range demoUsers {
+        err = store.RegisterUser(user.Email, user.First, user.Last, user.Phone, *user.Education, demoPassword,
+                                 user.Experience, user.Skills, user.Work_history)
+        if err != nil {
+            return err
+        }
+    }
+    _, err = store.CreateOrganization(demoOrg)
+    if err != nil {
+        return err
+    }
+    for _, member := range demoMembers {
+        err = store.SetOrganizationMember(member)
+        if err != nil {
+            return err
+        }
+    }
+    for _, job := range demoJobs {
+        _, err = store.CreateJob(job)
+        if err != nil {
+            return err
+        }
+    }
+    for _, hire := range demoHires {
+        // requirements the applicant does not meet do not matter here
+        store.SubmitJobApplication(hire.Applicant, hire.Job_id, "", nil)
+        err = store.UpdateApplicationStatus(hire.Creator, hire.Job_id, hire.Applicant, hire.Status)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
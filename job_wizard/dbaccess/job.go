@@ -0,0 +1,175 @@
+package dbaccess
+// This module holds the database functions for removing jobs: archiving
+// or deleting a job, and closing jobs whose expiry time has passed
+
+import (
+    "database/sql"
+    "strconv"
+    "strings"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Anything with a QueryRow method - either the db or a transaction
+type queryer interface {
+    QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+//**************** Private Functions *******************************//
+
+// Return true if a stored expiry time stamp has passed
+// An empty time stamp means the job never expires
+func isExpired(expires string) bool {
+    return expires != "" && expires <= data.StoredNow()
+}
+
+// Check that a user may manage a job: the user created it, or belongs
+// to the organization offering it
+func checkJobManager(conn queryer, created_by string, org_idval int, user_email string) (err error) {
+    if strings.EqualFold(user_email, created_by) {
+        return nil
+    }
+    if org_idval != 0 {
+        var role string
+        row := conn.QueryRow("SELECT role FROM organization_member WHERE organization_id=? AND user_email=?", org_idval, user_email)
+        if row.Scan(&role) == nil {
+            return nil
+        }
+    }
+    return data.ForbiddenError("Specified user did not create this job and is not in its organization")
+}
+
+// Check that a job exists and can be managed by this user
+// Returns whether it is archived and how many applications it has
+func checkJobOwner(conn queryer, idval int, creator_email string) (is_archived bool, applications int, err error) {
+    var created_by string
+    var org_idval int
+    row := conn.QueryRow("SELECT created_by, organization_id, is_archived FROM job WHERE id=?", idval)
+    err = row.Scan(&created_by, &org_idval, &is_archived)
+    if err != nil {
+        return false, 0, data.NotFoundError("No matching job found")
+    }
+    err = checkJobManager(conn, created_by, org_idval, creator_email)
+    if err != nil {
+        return false, 0, err
+    }
+    row = conn.QueryRow("SELECT COUNT(*) FROM job_application WHERE job_id=?", idval)
+    err = row.Scan(&applications)
+    if err != nil {
+        return false, 0, err
+    }
+    return is_archived, applications, nil
+}
+
+// Check whether changes can be made to a job that is open or not and
+// expires at a stored time stamp. There must be something to change,
+// a filled job cannot be closed again, and a job can only be reopened
+// if nobody was hired for it and it will not have expired by then
+func checkJobChanges(changes data.Job_changes, open_flag bool, expires string, hired_person string) error {
+    if changes.Title == nil && changes.Description == nil && changes.Min_education == nil &&
+       changes.Min_experience == nil && changes.Salary == nil && changes.Is_open == nil && changes.Expires_on == nil &&
+       changes.Province == nil && changes.City == nil && changes.Latitude == nil && changes.Longitude == nil &&
+       changes.Work_arrangement == nil && changes.Employment_type == nil && changes.Skills == nil {
+        return data.ValidationError("", "Nothing to change")
+    }
+    if changes.Is_open == nil || open_flag {
+        return nil
+    }
+    if !*changes.Is_open {
+        return data.ConflictError("Job has already been filled")
+    }
+    if hired_person != "" {
+        return data.ConflictError("Someone has been hired for this job - it cannot be reopened")
+    }
+    if changes.Expires_on != nil {
+        expires = *changes.Expires_on
+    }
+    if isExpired(expires) {
+        return data.ConflictError("Job has expired - give a later expires_on to reopen it")
+    }
+    return nil
+}
+
+//******** Exported Functions *****************************//
+
+// Function to archive a job, by its creator or a member of its organization
+// The job is closed and left out of searches,
+// but its applicants can still see it and its details
+func (store *sqlStore) ArchiveJob(creator_email string, job_id string) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    is_archived, _, err := checkJobOwner(db, idval, creator_email)
+    if err != nil {
+        return err
+    }
+    if is_archived {
+        return data.ConflictError("Job has already been archived")
+    }
+    _, err = db.Exec("UPDATE job SET is_archived=?, is_open=? WHERE id=?", true, false, idval)
+    return err
+}
+
+// Function to delete a job completely
+// Only a job nobody has applied for can be deleted, so that applicants
+// never lose track of an application. Other jobs must be archived
+func (store *sqlStore) DeleteJob(creator_email string, job_id string) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    // set up the full-text index, if any, before locking the db
+    fullTextEnabled()
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    _, applications, err := checkJobOwner(tx, idval, creator_email)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    if applications > 0 {
+        tx.Rollback()
+        return data.ConflictError("Job has applications and cannot be deleted - archive it instead")
+    }
+    _, err = tx.Exec("DELETE FROM job WHERE id=?", idval)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    _, err = tx.Exec("DELETE FROM job_skill WHERE job_id=?", idval)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    _, err = tx.Exec("DELETE FROM bookmark WHERE job_id=?", idval)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    err = unindexJob(tx, idval)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    return tx.Commit()
+}
+
+// Function to close every open job whose expiry time has passed
+// Called regularly by the server. Returns the number of jobs closed
+func (store *sqlStore) CloseExpiredJobs() (closed int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return 0, err
+    }
+    sqlcmd := "UPDATE job SET is_open=? WHERE is_open=? AND expires_on <> '' AND expires_on <= ?"
+    result, err := db.Exec(sqlcmd, false, true, data.StoredNow())
+    if err != nil {
+        return 0, err
+    }
+    count, err := result.RowsAffected()
+    return int(count), err
+}
@@ -0,0 +1,178 @@
+package dbaccess
+// Tests for archiving, deleting and expiring jobs
+
+import (
+    "testing"
+    "time"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// return true if a keyword search finds the job
+func searchFinds(t *testing.T, keyword string, job_id string, include_archived bool) bool {
+    t.Helper()
+    summaries, _, err := testStore.SearchJobs(data.Search_criteria{Keyword: keyword, Include_archived: include_archived}, data.Page_request{})
+    if err != nil {
+        t.Fatalf("SearchJobs failed: %v", err)
+    }
+    for _, summary := range summaries {
+        if summary.Job_id == job_id {
+            return true
+        }
+    }
+    return false
+}
+
+func TestArchiveJob(t *testing.T) {
+    mustRegister(t, "archive.boss@example.com")
+    mustRegister(t, "archive.applicant@example.com")
+    job_id, _ := testStore.CreateJob(data.Job_info{Creator: "archive.boss@example.com", Title: "Archivist", Description: "Old records"})
+    testStore.SubmitJobApplication("archive.applicant@example.com", job_id, "", nil)
+
+    if err := testStore.ArchiveJob("archive.applicant@example.com", job_id); data.ErrorCode(err) != data.CodeForbidden {
+        t.Errorf("only the creator may archive a job, got %v", err)
+    }
+    if err := testStore.ArchiveJob("archive.boss@example.com", job_id); err != nil {
+        t.Fatalf("ArchiveJob failed: %v", err)
+    }
+    if err := testStore.ArchiveJob("archive.boss@example.com", job_id); data.ErrorCode(err) != data.CodeConflict {
+        t.Errorf("archiving twice should be a conflict, got %v", err)
+    }
+    found, _ := testStore.GetJobDetail(job_id)
+    if found.Is_open || !found.Is_archived {
+        t.Errorf("an archived job should be closed and marked archived, got %+v", found)
+    }
+    if searchFinds(t, "Archivist", job_id, false) {
+        t.Errorf("an archived job should not be found by default")
+    }
+    if !searchFinds(t, "Archivist", job_id, true) {
+        t.Errorf("an archived job should be found with include_archived")
+    }
+    applied, _, _ := testStore.SearchAppliedJobs("archive.applicant@example.com", data.Page_request{})
+    if len(applied) != 1 || !applied[0].Is_archived {
+        t.Errorf("the applicant should still see the archived job, got %v", applied)
+    }
+    new_title := "New Title"
+    changes := data.Job_changes{Creator: "archive.boss@example.com", Job_id: job_id, Title: &new_title}
+    if _, err := testStore.ModifyJob(changes); data.ErrorCode(err) != data.CodeConflict {
+        t.Errorf("an archived job should not be modified, got %v", err)
+    }
+}
+
+func TestDeleteJob(t *testing.T) {
+    mustRegister(t, "delete.boss@example.com")
+    mustRegister(t, "delete.applicant@example.com")
+    unwanted_id, _ := testStore.CreateJob(data.Job_info{Creator: "delete.boss@example.com", Title: "Unwanted Posting", Description: "Posted by mistake"})
+    applied_id, _ := testStore.CreateJob(data.Job_info{Creator: "delete.boss@example.com", Title: "Wanted Posting", Description: "Somebody applied"})
+    testStore.SubmitJobApplication("delete.applicant@example.com", applied_id, "", nil)
+
+    if err := testStore.DeleteJob("delete.applicant@example.com", unwanted_id); data.ErrorCode(err) != data.CodeForbidden {
+        t.Errorf("only the creator may delete a job, got %v", err)
+    }
+    if err := testStore.DeleteJob("delete.boss@example.com", applied_id); data.ErrorCode(err) != data.CodeConflict {
+        t.Errorf("a job with applications should not be deleted, got %v", err)
+    }
+    if err := testStore.DeleteJob("delete.boss@example.com", unwanted_id); err != nil {
+        t.Fatalf("DeleteJob failed: %v", err)
+    }
+    if _, err := testStore.GetJobDetail(unwanted_id); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("a deleted job should not be found, got %v", err)
+    }
+    if searchFinds(t, "Unwanted", unwanted_id, true) {
+        t.Errorf("a deleted job should not be found by a search")
+    }
+    if err := testStore.DeleteJob("delete.boss@example.com", unwanted_id); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("deleting twice should be not found, got %v", err)
+    }
+}
+
+func TestExpiredJobs(t *testing.T) {
+    mustRegister(t, "expiry.boss@example.com")
+    mustRegister(t, "expiry.applicant@example.com")
+    expired := data.StoredTime(time.Now().Add(-time.Hour))
+    later := data.StoredTime(time.Now().Add(24 * time.Hour))
+    expired_id, _ := testStore.CreateJob(data.Job_info{Creator: "expiry.boss@example.com", Title: "Lapsed Vacancy", Description: "Too late", Expires_on: expired})
+    current_id, _ := testStore.CreateJob(data.Job_info{Creator: "expiry.boss@example.com", Title: "Current Vacancy", Description: "Still time", Expires_on: later})
+
+    if searchFinds(t, "Vacancy", expired_id, false) || !searchFinds(t, "Vacancy", current_id, false) {
+        t.Errorf("only the job that has not expired should be found by default")
+    }
+    if !searchFinds(t, "Vacancy", expired_id, true) {
+        t.Errorf("an expired job should be found with include_archived")
+    }
+    if _, _, err := testStore.SubmitJobApplication("expiry.applicant@example.com", expired_id, "", nil); data.ErrorCode(err) != data.CodeConflict {
+        t.Errorf("applying for an expired job should be a conflict, got %v", err)
+    }
+    if _, err := testStore.CloseExpiredJobs(); err != nil {
+        t.Fatalf("CloseExpiredJobs failed: %v", err)
+    }
+    found, _ := testStore.GetJobDetail(expired_id)
+    if found.Is_open {
+        t.Errorf("the expired job should have been closed")
+    }
+    if _, err := time.Parse(time.RFC3339, found.Expires_on); err != nil {
+        t.Errorf("expires_on should be an RFC3339 time, got %q", found.Expires_on)
+    }
+    found, _ = testStore.GetJobDetail(current_id)
+    if !found.Is_open {
+        t.Errorf("a job that has not expired should stay open")
+    }
+    if closed, _ := testStore.CloseExpiredJobs(); closed != 0 {
+        t.Errorf("a second sweep should close nothing, closed %d", closed)
+    }
+}
+
+func TestReopenJob(t *testing.T) {
+    mustRegister(t, "reopen.boss@example.com")
+    job_id, _ := testStore.CreateJob(data.Job_info{Creator: "reopen.boss@example.com", Title: "Reopened Role", Description: "Filled then reopened", Min_education: 2, Min_experience: 1, Salary: 25000})
+    open := true
+    closed := false
+    expired := data.StoredTime(time.Now().Add(-time.Hour))
+    no_expiry := ""
+    changes := []struct {
+        changes data.Job_changes
+        code    string
+    }{
+        {data.Job_changes{Is_open: &closed}, ""},
+        {data.Job_changes{Is_open: &closed}, data.CodeConflict},
+        {data.Job_changes{Is_open: &open, Expires_on: &expired}, data.CodeConflict},
+        {data.Job_changes{}, data.CodeValidation},
+        {data.Job_changes{Is_open: &open, Expires_on: &no_expiry}, ""},
+        {data.Job_changes{Is_open: &open}, ""},
+    }
+    for i, change := range changes {
+        change.changes.Creator = "reopen.boss@example.com"
+        change.changes.Job_id = job_id
+        _, err := testStore.ModifyJob(change.changes)
+        if (err == nil) != (change.code == "") || (err != nil && data.ErrorCode(err) != change.code) {
+            t.Errorf("change %d: expected %q, got %v", i, change.code, err)
+        }
+    }
+    found, _ := testStore.GetJobDetail(job_id)
+    if !found.Is_open || found.Expires_on != "" {
+        t.Errorf("the job should be open with no expiry, got %+v", found)
+    }
+}
+
+func TestReopenHiredJob(t *testing.T) {
+    mustRegister(t, "rehire.boss@example.com")
+    mustRegister(t, "rehire.hired@example.com")
+    mustRegister(t, "rehire.second@example.com")
+    job_id, _ := testStore.CreateJob(data.Job_info{Creator: "rehire.boss@example.com", Title: "Hired Role", Description: "Testing"})
+    testStore.SubmitJobApplication("rehire.hired@example.com", job_id, "", nil)
+    testStore.SubmitJobApplication("rehire.second@example.com", job_id, "", nil)
+    if err := testStore.UpdateApplicationStatus("rehire.boss@example.com", job_id, "rehire.hired@example.com", data.StatusHired); err != nil {
+        t.Fatalf("hire failed: %v", err)
+    }
+    open := true
+    _, err := testStore.ModifyJob(data.Job_changes{Creator: "rehire.boss@example.com", Job_id: job_id, Is_open: &open})
+    if data.ErrorCode(err) != data.CodeConflict {
+        t.Errorf("reopening a job with a hire should conflict, got %v", err)
+    }
+    if found, _ := testStore.GetJobDetail(job_id); found.Is_open {
+        t.Errorf("the job should still be closed")
+    }
+    err = testStore.UpdateApplicationStatus("rehire.boss@example.com", job_id, "rehire.second@example.com", data.StatusHired)
+    if data.ErrorCode(err) != data.CodeConflict {
+        t.Errorf("a second hire should conflict, got %v", err)
+    }
+}
@@ -0,0 +1,82 @@
+package dbaccess
+// This module holds the location filters for job searches, shared by
+// the SQL and memory stores so that both find the same jobs
+// Distances use the equirectangular approximation, which needs only
+// arithmetic, so the same formula can go into SQLite and PostgreSQL
+// queries. Within a few hundred km it is off by well under one percent
+
+import (
+    "math"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Length of one degree of latitude, in km
+const kmPerDegree = 111.2
+
+//**************** Private Functions *******************************//
+
+// Return the km per degree of latitude and of longitude near a latitude
+func degreeScale(latitude float64) (lat_scale float64, lon_scale float64) {
+    return kmPerDegree, kmPerDegree * math.Cos(latitude * math.Pi / 180)
+}
+
+// Return true if a job has a position. 0,0 means none was given
+func hasPosition(latitude float64, longitude float64) bool {
+    return latitude != 0 || longitude != 0
+}
+
+// Return true if a job is within criteria.Radius_km of the search point
+// Jobs without a position are never within the radius
+func withinRadius(latitude float64, longitude float64, criteria data.Search_criteria) bool {
+    if !hasPosition(latitude, longitude) {
+        return false
+    }
+    lat_scale, lon_scale := degreeScale(criteria.Latitude)
+    dy := (latitude - criteria.Latitude) * lat_scale
+    dx := (longitude - criteria.Longitude) * lon_scale
+    return dx*dx + dy*dy <= criteria.Radius_km * criteria.Radius_km
+}
+
+// Return the where clauses and their arguments for the location,
+// work arrangement, employment type and radius criteria, for job alias j
+func locationClauses(criteria data.Search_criteria) (clauses []string, args []interface{}) {
+    if criteria.Location != "" {
+        pattern := "%" + escapeLike(criteria.Location) + "%"
+        clauses = append(clauses, "(j.city like ? escape '\\' or j.province like ? escape '\\')")
+        args = append(args, pattern, pattern)
+    }
+    if criteria.Work_arrangement != "" {
+        clauses = append(clauses, "j.work_arrangement = ?")
+        args = append(args, criteria.Work_arrangement)
+    }
+    if criteria.Employment_type != "" {
+        clauses = append(clauses, "j.employment_type = ?")
+        args = append(args, criteria.Employment_type)
+    }
+    if criteria.Radius_km > 0 {
+        lat_scale, lon_scale := degreeScale(criteria.Latitude)
+        clauses = append(clauses, "(j.latitude <> 0 or j.longitude <> 0)",
+            "((j.latitude - ?) * ?) * ((j.latitude - ?) * ?) + ((j.longitude - ?) * ?) * ((j.longitude - ?) * ?) <= ?")
+        args = append(args, criteria.Latitude, lat_scale, criteria.Latitude, lat_scale,
+            criteria.Longitude, lon_scale, criteria.Longitude, lon_scale, criteria.Radius_km * criteria.Radius_km)
+    }
+    return clauses, args
+}
+
+// Return true if a job matches the location, work arrangement,
+// employment type and radius criteria, as locationClauses does in SQL
+func matchesLocation(job data.Job_info, criteria data.Search_criteria) bool {
+    if criteria.Location != "" && !containsFold(job.City, criteria.Location) && !containsFold(job.Province, criteria.Location) {
+        return false
+    }
+    if criteria.Work_arrangement != "" && job.Work_arrangement != criteria.Work_arrangement {
+        return false
+    }
+    if criteria.Employment_type != "" && job.Employment_type != criteria.Employment_type {
+        return false
+    }
+    if criteria.Radius_km > 0 && !withinRadius(job.Latitude, job.Longitude, criteria) {
+        return false
+    }
+    return true
+}
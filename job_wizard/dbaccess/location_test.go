@@ -0,0 +1,92 @@
+package dbaccess
+// Tests for job locations, work arrangements, employment types and radius searches
+
+import (
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// return the IDs of the jobs a search finds, in a set
+func searchIds(t *testing.T, criteria data.Search_criteria) map[string]bool {
+    t.Helper()
+    summaries, _, err := testStore.SearchJobs(criteria, data.Page_request{})
+    if err != nil {
+        t.Fatalf("SearchJobs failed: %v", err)
+    }
+    ids := make(map[string]bool)
+    for _, summary := range summaries {
+        ids[summary.Job_id] = true
+    }
+    return ids
+}
+
+func TestJobLocation(t *testing.T) {
+    mustRegister(t, "location.boss@example.com")
+    silom_id, _ := testStore.CreateJob(data.Job_info{Creator: "location.boss@example.com", Title: "Locator Silom", Description: "Bangkok office",
+        Province: "Bangkok", City: "Bang Rak", Latitude: 13.7286, Longitude: 100.5340,
+        Work_arrangement: data.WorkOnsite, Employment_type: data.EmploymentFullTime})
+    nonthaburi_id, _ := testStore.CreateJob(data.Job_info{Creator: "location.boss@example.com", Title: "Locator Nonthaburi", Description: "Just outside Bangkok",
+        Province: "Nonthaburi", City: "Pak Kret", Latitude: 13.9130, Longitude: 100.4983,
+        Work_arrangement: data.WorkHybrid, Employment_type: data.EmploymentPartTime})
+    chiangmai_id, _ := testStore.CreateJob(data.Job_info{Creator: "location.boss@example.com", Title: "Locator Chiang Mai", Description: "Northern office",
+        Province: "Chiang Mai", City: "Mueang Chiang Mai", Latitude: 18.7883, Longitude: 98.9853,
+        Work_arrangement: data.WorkOnsite, Employment_type: data.EmploymentContract})
+    remote_id, _ := testStore.CreateJob(data.Job_info{Creator: "location.boss@example.com", Title: "Locator Anywhere", Description: "No office",
+        Work_arrangement: data.WorkRemote, Employment_type: data.EmploymentInternship})
+
+    found, err := testStore.GetJobDetail(nonthaburi_id)
+    if err != nil {
+        t.Fatalf("GetJobDetail failed: %v", err)
+    }
+    if found.Province != "Nonthaburi" || found.City != "Pak Kret" || found.Latitude != 13.9130 ||
+       found.Longitude != 100.4983 || found.Work_arrangement != data.WorkHybrid || found.Employment_type != data.EmploymentPartTime {
+        t.Errorf("the location should be returned with the detail, got %+v", found)
+    }
+
+    searches := []struct {
+        criteria data.Search_criteria
+        expected []string
+    }{
+        {data.Search_criteria{Location: "bangkok"}, []string{silom_id}},
+        {data.Search_criteria{Location: "chiang"}, []string{chiangmai_id}},
+        {data.Search_criteria{Location: "%"}, nil},
+        {data.Search_criteria{Work_arrangement: data.WorkRemote}, []string{remote_id}},
+        {data.Search_criteria{Employment_type: data.EmploymentContract}, []string{chiangmai_id}},
+        // Nonthaburi is about 21 km from Silom, Chiang Mai nearly 600 km
+        {data.Search_criteria{Latitude: 13.7286, Longitude: 100.5340, Radius_km: 10}, []string{silom_id}},
+        {data.Search_criteria{Latitude: 13.7286, Longitude: 100.5340, Radius_km: 30}, []string{silom_id, nonthaburi_id}},
+        {data.Search_criteria{Latitude: 13.7286, Longitude: 100.5340, Radius_km: 30, Work_arrangement: data.WorkHybrid}, []string{nonthaburi_id}},
+        {data.Search_criteria{Latitude: 13.7286, Longitude: 100.5340, Radius_km: 700}, []string{silom_id, nonthaburi_id, chiangmai_id}},
+    }
+    for i, search := range searches {
+        search.criteria.Keyword = "Locator"
+        ids := searchIds(t, search.criteria)
+        if len(ids) != len(search.expected) {
+            t.Errorf("search %d: expected %v, got %v", i, search.expected, ids)
+            continue
+        }
+        for _, id := range search.expected {
+            if !ids[id] {
+                t.Errorf("search %d: expected %v, got %v", i, search.expected, ids)
+            }
+        }
+    }
+
+    // moving the job to Chiang Mai takes it out of a Bangkok radius search
+    latitude := 18.7953
+    longitude := 98.9620
+    province := "Chiang Mai"
+    arrangement := ""
+    changes := data.Job_changes{Creator: "location.boss@example.com", Job_id: nonthaburi_id,
+        Province: &province, Latitude: &latitude, Longitude: &longitude, Work_arrangement: &arrangement}
+    if _, err = testStore.ModifyJob(changes); err != nil {
+        t.Fatalf("ModifyJob failed: %v", err)
+    }
+    if searchIds(t, data.Search_criteria{Keyword: "Locator", Latitude: 13.7286, Longitude: 100.5340, Radius_km: 30})[nonthaburi_id] {
+        t.Errorf("the moved job should not be near Bangkok")
+    }
+    found, _ = testStore.GetJobDetail(nonthaburi_id)
+    if found.Province != "Chiang Mai" || found.City != "Pak Kret" || found.Work_arrangement != "" {
+        t.Errorf("only the fields given should have changed, got %+v", found)
+    }
+}
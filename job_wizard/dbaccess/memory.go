@@ -0,0 +1,2009 @@
+package dbaccess
+// This module holds memoryStore, an implementation of Store that keeps
+// everything in maps in memory. It needs no database file, so it is used
+// by tests and by demo mode, and it behaves the same way as the SQLite
+// store: the same errors, the same filtering, sorting and paging
+// Nothing is saved when the program exits
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+    "golang.org/x/crypto/bcrypt"
+)
+
+type memoryUser struct {
+    profile       data.User_info   // Password is always empty and Education, Experience and Resume nil
+    education     int
+    experience    int
+    password_hash string
+    resume        *memoryResume    // the default resume, or nil
+}
+
+type memoryJob struct {
+    id            int
+    info          data.Job_info    // Job_id and Date_posted are filled in and Expires_on
+                                   // converted from a stored time stamp when returned
+    hired_person  string
+    created       string
+    org_id        int              // 0 if the job belongs to no organization
+}
+
+type memoryOrg struct {
+    id            int
+    info          data.Organization   // Org_id, Creator and Members are never set here
+    created       string
+}
+
+type memoryMember struct {
+    id            int
+    org_id        int
+    user_email    string
+    role          string
+    added         string
+}
+
+type memoryApplication struct {
+    id            int
+    job_id        int
+    user_email    string
+    apply_time    string
+    status        string
+    status_time   string
+    cover_letter  string
+    resume        *memoryResume    // nil if no resume was sent
+}
+
+// A resume shared by a user's profile and the applications that used it
+type memoryResume struct {
+    user_email    string
+    description   data.Document    // without the content, which is in the blob store
+    blob_key      string
+}
+
+type memoryBookmark struct {
+    id            int
+    user_email    string
+    job_id        int
+    created       string
+    filled        string           // when the job was filled, or ""
+}
+
+type memorySavedSearch struct {
+    id            int
+    info          data.Saved_search   // Search_id and New_matches are filled in when returned
+    seen_job_id   int                 // the last job when the search was run
+}
+
+type memoryWebhook struct {
+    id            int
+    info          data.Webhook     // Webhook_id and Secret are filled in when returned
+    org_id        int              // 0 for the jobs of the creator
+    secret        string
+}
+
+type memoryDelivery struct {
+    id            int
+    webhook_id    int
+    info          data.Webhook_delivery   // the ids, Url and Secret are filled in when returned
+}
+
+type memoryNotification struct {
+    id            int
+    info          data.Notification   // Notification_id is filled in when returned
+    status        string
+    next_attempt  string
+    last_error    string
+    sent          string
+}
+
+type memoryMessage struct {
+    id            int
+    job_id        int
+    info          data.Message     // Message_id is filled in and the times converted when returned
+}
+
+type memoryStatus struct {
+    job_id        int
+    user_email    string
+    status        string
+    changed_by    string
+    change_time   string
+}
+
+type memorySession struct {
+    user_email    string
+    expires       int64
+}
+
+// One row of a listing before it is sorted, with its values stored
+// under the same column names used in the SQL, so the sort column maps
+// in paging.go work for both stores
+type memoryRow map[string]interface{}
+
+// The in-memory implementation. One mutex guards everything, since
+// the REST server calls the store from many goroutines
+type memoryStore struct {
+    mutex         sync.Mutex
+    users         map[string]*memoryUser
+    jobs          map[int]*memoryJob
+    applications  []*memoryApplication   // in the order they were made
+    history       []memoryStatus
+    sessions      map[string]memorySession  // by the hash of the token
+    orgs          map[int]*memoryOrg
+    members       []*memoryMember        // in the order they joined
+    skills        map[string]int         // the id of each known skill, by name
+    bookmarks     []*memoryBookmark      // in the order they were made
+    saved_searches map[int]*memorySavedSearch
+    webhooks      map[int]*memoryWebhook
+    deliveries    []*memoryDelivery      // in the order they were queued
+    preferences   map[string]data.Notification_preferences   // only for users who changed them
+    outbox        []*memoryNotification  // in the order they were queued
+    messages      []*memoryMessage       // in the order they were sent
+    last_job_id   int
+    last_application_id int
+    last_org_id   int
+    last_member_id int
+    last_search_id int
+    last_bookmark_id int
+    last_webhook_id int
+    last_delivery_id int
+    last_notification_id int
+    last_message_id int
+}
+
+//**************** Private Functions *******************************//
+
+// Compare two values from a memoryRow, the way SQLite orders them
+func compareValues(a interface{}, b interface{}) int {
+    switch first := a.(type) {
+    case int:
+        second := b.(int)
+        if first < second {
+            return -1
+        } else if first > second {
+            return 1
+        }
+        return 0
+    case bool:
+        second := b.(bool)
+        if first == second {
+            return 0
+        } else if second {
+            return -1
+        }
+        return 1
+    }
+    return strings.Compare(a.(string), b.(string))
+}
+
+// Sort rows and return one page of them, exactly as pageClause
+// would have the database do it
+func pageRows(rows []memoryRow, page data.Page_request, columns map[string][]string, default_sort string,
+              default_order string, tiebreak string) []memoryRow {
+    sort_columns, found := columns[page.Sort]
+    if !found {
+        sort_columns = columns[default_sort]
+    }
+    order := strings.ToLower(page.Order)
+    if order != "asc" && order != "desc" {
+        order = default_order
+    }
+    sort_columns = append(append([]string{}, sort_columns...), tiebreak)
+    sort.SliceStable(rows, func(i, j int) bool {
+        for _, column := range sort_columns {
+            result := compareValues(rows[i][column], rows[j][column])
+            if result != 0 {
+                if order == "desc" {
+                    return result > 0
+                }
+                return result < 0
+            }
+        }
+        return false
+    })
+    if page.Offset >= len(rows) {
+        return nil
+    }
+    rows = rows[page.Offset:]
+    if page.Limit > 0 && page.Limit < len(rows) {
+        rows = rows[:page.Limit]
+    }
+    return rows
+}
+
+// Return true if text contains value, ignoring case, like LIKE '%value%'
+func containsFold(text string, value string) bool {
+    return strings.Contains(strings.ToLower(text), strings.ToLower(value))
+}
+
+// Return the row for a job in a listing, with the columns in jobSortColumns
+func jobRow(job *memoryJob) memoryRow {
+    return memoryRow{"j.id": job.id, "j.created": job.created, "j.title": job.info.Title,
+                     "j.salary": job.info.Salary, "job": job}
+}
+
+// Turn sorted job rows into summaries, highlighting the terms if any
+// and adding the match score and bookmark flag if the rows have them
+// Must hold the mutex
+func (store *memoryStore) jobSummaries(rows []memoryRow, terms []searchTerm) (summaries []data.Job_summary) {
+    for _, row := range rows {
+        job := row["job"].(*memoryJob)
+        var summary data.Job_summary
+        summary.Job_id = fmt.Sprintf("%05d", job.id)
+        summary.Title = job.info.Title
+        summary.Is_open = job.info.Is_open
+        summary.Date_posted = data.DisplayTime(job.created)
+        summary.Expires_on = data.DisplayTime(job.info.Expires_on)
+        summary.Is_archived = job.info.Is_archived
+        summary.Org_id = orgIdString(job.org_id)
+        summary.Organization = store.orgName(job.org_id)
+        summary.Province = job.info.Province
+        summary.City = job.info.City
+        summary.Work_arrangement = job.info.Work_arrangement
+        summary.Employment_type = job.info.Employment_type
+        if score, found := row["match_score"]; found {
+            summary.Match_score = scoreValue(score.(int))
+        }
+        if bookmarked, found := row["bookmarked"]; found {
+            flag := bookmarked.(bool)
+            summary.Bookmarked = &flag
+        }
+        if len(terms) > 0 {
+            summary.Title_highlight = highlightText(job.info.Title, terms)
+            summary.Snippet = makeSnippet(job.info.Description, terms)
+        }
+        summaries = append(summaries, summary)
+    }
+    return summaries
+}
+
+// Add a skill to the list of known skills, if it is new. Must hold the mutex
+func (store *memoryStore) addSkill(name string) {
+    if _, found := store.skills[name]; !found {
+        store.skills[name] = len(store.skills) + 1
+    }
+}
+
+// Return a user's proficiency in each of their skills. Must hold the mutex
+func (store *memoryStore) proficiencies(user_email string) map[string]int {
+    proficiencies := make(map[string]int)
+    if user, found := store.users[user_email]; found {
+        for _, skill := range user.profile.Skills {
+            proficiencies[skill.Name] = skill.Proficiency
+        }
+    }
+    return proficiencies
+}
+
+// Store a copy of a job's skills, sorted the way they are returned,
+// and add them to the known skills. Must hold the mutex
+func (store *memoryStore) setJobSkills(job *memoryJob, skills []data.Job_skill) {
+    job.info.Skills = append([]data.Job_skill{}, skills...)
+    sortJobSkills(job.info.Skills)
+    for _, skill := range skills {
+        store.addSkill(skill.Name)
+    }
+}
+
+// Return the name of an organization, or "" for none. Must hold the mutex
+func (store *memoryStore) orgName(org_id int) string {
+    if org, found := store.orgs[org_id]; found {
+        return org.info.Name
+    }
+    return ""
+}
+
+// Find a member of an organization. Must hold the mutex
+func (store *memoryStore) findMember(org_id int, user_email string) *memoryMember {
+    for _, member := range store.members {
+        if member.org_id == org_id && member.user_email == user_email {
+            return member
+        }
+    }
+    return nil
+}
+
+// Check that an organization exists and that the user is a member, the
+// same way as checkOrgMember. Must hold the mutex
+func (store *memoryStore) checkOrgMember(org_id int, user_email string) (role string, err error) {
+    if _, found := store.orgs[org_id]; !found {
+        return "", data.NotFoundError("No matching organization found")
+    }
+    member := store.findMember(org_id, user_email)
+    if member == nil {
+        return "", data.ForbiddenError("Specified user is not a member of this organization")
+    }
+    return member.role, nil
+}
+
+// Check that an organization exists and that the user is one of its owners
+// Must hold the mutex
+func (store *memoryStore) checkOrgOwner(org_id int, user_email string) (err error) {
+    role, err := store.checkOrgMember(org_id, user_email)
+    if err != nil {
+        return err
+    }
+    if role != data.RoleOwner {
+        return data.ForbiddenError("Only an owner can change this organization")
+    }
+    return nil
+}
+
+// Check that an organization has more than one owner. Must hold the mutex
+func (store *memoryStore) checkOtherOwners(org_id int) (err error) {
+    owners := 0
+    for _, member := range store.members {
+        if member.org_id == org_id && member.role == data.RoleOwner {
+            owners++
+        }
+    }
+    if owners <= 1 {
+        return data.ConflictError("An organization must have at least one owner")
+    }
+    return nil
+}
+
+// Check that a user may manage a job, the same way as checkJobManager
+// Must hold the mutex
+func (store *memoryStore) checkJobManager(job *memoryJob, user_email string) (err error) {
+    if strings.EqualFold(user_email, job.info.Creator) {
+        return nil
+    }
+    if job.org_id != 0 && store.findMember(job.org_id, user_email) != nil {
+        return nil
+    }
+    return data.ForbiddenError("Specified user did not create this job and is not in its organization")
+}
+
+// Find the application from a user for a job. Must hold the mutex
+func (store *memoryStore) findApplication(job_id int, user_email string) *memoryApplication {
+    for _, application := range store.applications {
+        if application.job_id == job_id && application.user_email == user_email {
+            return application
+        }
+    }
+    return nil
+}
+
+// Change the status of an application and add it to the history
+// Must hold the mutex
+func (store *memoryStore) setStatus(application *memoryApplication, status string, changed_by string) {
+    nowstring := data.StoredNow()
+    application.status = status
+    application.status_time = nowstring
+    store.history = append(store.history, memoryStatus{application.job_id, application.user_email,
+                                                      status, changed_by, nowstring})
+    event := data.EventApplicationStatus
+    if status == data.StatusSubmitted {
+        event = data.EventApplicationSubmitted
+    }
+    store.queueWebhookEvent(event, store.jobs[application.job_id], application.user_email, status)
+    store.queueNotification(store.jobs[application.job_id], application.user_email, status)
+}
+
+// Check that a user may use the thread of an application, the same way
+// as checkThread. Returns the job. Must hold the mutex
+func (store *memoryStore) checkThread(job_id int, applicant string, user_email string) (job *memoryJob, err error) {
+    job, found := store.jobs[job_id]
+    if !found {
+        return nil, data.NotFoundError("No matching job found")
+    }
+    if user_email != applicant && user_email != job.info.Creator {
+        return nil, data.ForbiddenError("Only the applicant and the job's creator can use this thread")
+    }
+    if store.findApplication(job_id, applicant) == nil {
+        return nil, data.NotFoundError("No application from this user for this job")
+    }
+    return job, nil
+}
+
+// Return a user's notification preferences, the same way as
+// notificationPreferences. Must hold the mutex
+func (store *memoryStore) notificationPreferences(user_email string) data.Notification_preferences {
+    prefs, found := store.preferences[user_email]
+    if !found {
+        return defaultPreferences()
+    }
+    return prefs
+}
+
+// Queue the email, if any, for an application that has just moved to
+// status, the same way as queueNotification. Must hold the mutex
+func (store *memoryStore) queueNotification(job *memoryJob, applicant string, status string) {
+    kind := notificationKind(status)
+    if kind == "" {
+        return
+    }
+    recipient := applicant
+    if kind == data.NotifyApplicationReceived {
+        recipient = job.info.Creator
+    }
+    user, found := store.users[recipient]
+    if !found {
+        return
+    }
+    prefs := store.notificationPreferences(recipient)
+    if !wantsNotification(prefs, kind) {
+        return
+    }
+    nowstring := data.StoredNow()
+    info := data.Notification{Recipient: recipient, Name: user.profile.First, Kind: kind, Language: prefs.Language,
+                              Job_id: fmt.Sprintf("%05d", job.id), Title: job.info.Title, Created: nowstring}
+    if kind == data.NotifyApplicationReceived {
+        profile := store.users[applicant].profile
+        info.Applicant = applicant
+        info.Applicant_name = strings.TrimSpace(profile.First + " " + profile.Last)
+    }
+    store.last_notification_id++
+    store.outbox = append(store.outbox, &memoryNotification{id: store.last_notification_id, info: info,
+                                                            status: data.DeliveryPending, next_attempt: nowstring})
+}
+
+// Queue an event on a job for every webhook that wants it, the same way
+// as queueWebhookEvent. Must hold the mutex
+func (store *memoryStore) queueWebhookEvent(event string, job *memoryJob, applicant string, status string) {
+    var webhook_ids []int
+    for id, hook := range store.webhooks {
+        wanted := hook.org_id == 0 && hook.info.Creator == job.info.Creator
+        if hook.org_id != 0 && hook.org_id == job.org_id {
+            member := store.findMember(hook.org_id, hook.info.Creator)
+            wanted = member != nil && member.role == data.RoleOwner
+        }
+        if wanted && hasEvent(hook.info.Events, event) {
+            webhook_ids = append(webhook_ids, id)
+        }
+    }
+    if len(webhook_ids) == 0 {
+        return
+    }
+    sort.Ints(webhook_ids)
+    payload, _ := webhookPayload(event, job.id, job.info.Title, job.org_id, applicant, status)
+    nowstring := data.StoredNow()
+    for _, webhook_id := range webhook_ids {
+        store.last_delivery_id++
+        store.deliveries = append(store.deliveries, &memoryDelivery{id: store.last_delivery_id, webhook_id: webhook_id,
+            info: data.Webhook_delivery{Event: event, Payload: payload, Status: data.DeliveryPending,
+                                        Next_attempt: nowstring, Created: nowstring}})
+    }
+}
+
+// Remove a webhook and its deliveries. Must hold the mutex
+func (store *memoryStore) dropWebhook(webhook_id int) {
+    delete(store.webhooks, webhook_id)
+    var deliveries []*memoryDelivery
+    for _, delivery := range store.deliveries {
+        if delivery.webhook_id != webhook_id {
+            deliveries = append(deliveries, delivery)
+        }
+    }
+    store.deliveries = deliveries
+}
+
+// Describe a resume whose content was stored by putResume
+func newMemoryResume(user_email string, resume *data.Document, blob_key string) *memoryResume {
+    description := *resume
+    description.Content = nil
+    description.Size = len(resume.Content)
+    description.Uploaded = data.StoredNow()
+    return &memoryResume{user_email: user_email, description: description, blob_key: blob_key}
+}
+
+// Return the description of a resume, with the upload time as displayed
+func (resume *memoryResume) document() *data.Document {
+    description := resume.description
+    description.Uploaded = data.DisplayTime(resume.description.Uploaded)
+    return &description
+}
+
+// Return a resume with its content
+func (resume *memoryResume) load() (document data.Document, err error) {
+    document = *resume.document()
+    document.Content, err = GetBlobStore().Get(resume.blob_key)
+    return document, err
+}
+
+// Find a user's bookmark of a job, or nil if there is none
+// Must hold the mutex
+func (store *memoryStore) findBookmark(user_email string, job_id int) *memoryBookmark {
+    for _, bookmark := range store.bookmarks {
+        if bookmark.user_email == user_email && bookmark.job_id == job_id {
+            return bookmark
+        }
+    }
+    return nil
+}
+
+// Flag the bookmarks of a job when it is filled, or clear the flag
+// when it is reopened. Must hold the mutex
+func (store *memoryStore) flagFilledBookmarks(job_id int, filled bool) {
+    nowstring := data.StoredNow()
+    for _, bookmark := range store.bookmarks {
+        if bookmark.job_id != job_id {
+            continue
+        }
+        if !filled {
+            bookmark.filled = ""
+        } else if bookmark.filled == "" {
+            bookmark.filled = nowstring
+        }
+    }
+}
+
+// Remove the bookmarks for which drop returns true. Must hold the mutex
+func (store *memoryStore) dropBookmarks(drop func(bookmark *memoryBookmark) bool) {
+    var bookmarks []*memoryBookmark
+    for _, bookmark := range store.bookmarks {
+        if !drop(bookmark) {
+            bookmarks = append(bookmarks, bookmark)
+        }
+    }
+    store.bookmarks = bookmarks
+}
+
+// True if a profile or an application still refers to a resume
+// Must hold the mutex
+func (store *memoryStore) resumeInUse(resume *memoryResume) bool {
+    if user, found := store.users[resume.user_email]; found && user.resume == resume {
+        return true
+    }
+    for _, application := range store.applications {
+        if application.resume == resume {
+            return true
+        }
+    }
+    return false
+}
+
+//******** Exported Functions *****************************//
+
+// Return a new, empty store that keeps everything in memory
+func NewMemoryStore() Store {
+    return &memoryStore{
+        users:    map[string]*memoryUser{},
+        jobs:     map[int]*memoryJob{},
+        sessions: map[string]memorySession{},
+        orgs:     map[int]*memoryOrg{},
+        skills:   map[string]int{},
+        saved_searches: map[int]*memorySavedSearch{},
+        webhooks: map[int]*memoryWebhook{},
+        preferences: map[string]data.Notification_preferences{},
+    }
+}
+
+func (store *memoryStore) CheckConnection() bool {
+    return true
+}
+
+func (store *memoryStore) IsRegisteredUser(user_email string) (bRegistered bool, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    _, bRegistered = store.users[user_email]
+    return bRegistered, nil
+}
+
+func (store *memoryStore) RegisterUser(user_email string, first_name string, last_name string, phone string, education int, password string,
+                                       experience *int, skills []data.User_skill, work_history []data.Work_entry) (err error) {
+    // hash first; bcrypt is deliberately slow
+    password_hash, err := hashPassword(password)
+    if err != nil {
+        return err
+    }
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    if _, found := store.users[user_email]; found {
+        return data.ConflictError("Email is not unique; user not created")
+    }
+    store.users[user_email] = &memoryUser{
+        profile: data.User_info{Email: user_email, First: first_name, Last: last_name,
+                                Phone: phone},
+        education: education,
+        password_hash: password_hash,
+    }
+    store.setUserDetails(store.users[user_email], experience, skills, work_history)
+    return nil
+}
+
+func (store *memoryStore) GetUserProfile(user_email string) (profile data.User_info, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    user, found := store.users[user_email]
+    if !found {
+        return profile, data.NotFoundError("Unknown user")
+    }
+    profile = user.profile
+    education := user.education
+    profile.Education = &education
+    experience := user.experience
+    profile.Experience = &experience
+    profile.Skills = append([]data.User_skill{}, user.profile.Skills...)
+    profile.Work_history = append([]data.Work_entry{}, user.profile.Work_history...)
+    if user.resume != nil {
+        profile.Resume = user.resume.document()
+    }
+    return profile, nil
+}
+
+func (store *memoryStore) UpdateUserProfile(user_email string, first_name string, last_name string, phone string, education *int,
+                                            password string, experience *int, skills []data.User_skill,
+                                            work_history []data.Work_entry) (err error) {
+    if first_name == "" && last_name == "" && phone == "" && education == nil && password == "" &&
+       experience == nil && skills == nil && work_history == nil {
+        return data.ValidationError("", "Nothing to change")
+    }
+    password_hash := ""
+    if password != "" {
+        password_hash, err = hashPassword(password)
+        if err != nil {
+            return err
+        }
+    }
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    user, found := store.users[user_email]
+    if !found {
+        return data.NotFoundError("Unknown user")
+    }
+    if first_name != "" {
+        user.profile.First = first_name
+    }
+    if last_name != "" {
+        user.profile.Last = last_name
+    }
+    if phone != "" {
+        user.profile.Phone = phone
+    }
+    if education != nil {
+        user.education = *education
+    }
+    store.setUserDetails(user, experience, skills, work_history)
+    if password_hash != "" {
+        user.password_hash = password_hash
+        for token, session := range store.sessions {
+            if session.user_email == user_email {
+                delete(store.sessions, token)
+            }
+        }
+    }
+    return nil
+}
+
+// Set the experience, skills and work history of a user, leaving alone
+// those that are nil. Must hold the mutex
+func (store *memoryStore) setUserDetails(user *memoryUser, experience *int, skills []data.User_skill,
+                                         work_history []data.Work_entry) {
+    if experience != nil {
+        user.experience = *experience
+    }
+    if work_history != nil {
+        user.profile.Work_history = append([]data.Work_entry{}, work_history...)
+        sortWorkHistory(user.profile.Work_history)
+    }
+    if skills != nil {
+        user.profile.Skills = append([]data.User_skill{}, skills...)
+        sort.SliceStable(user.profile.Skills, func(i, j int) bool {
+            return user.profile.Skills[i].Name < user.profile.Skills[j].Name
+        })
+        for _, skill := range skills {
+            store.addSkill(skill.Name)
+        }
+    }
+}
+
+func (store *memoryStore) DeleteUser(user_email string) (err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    user, found := store.users[user_email]
+    if !found {
+        return data.NotFoundError("Unknown user")
+    }
+    for _, member := range store.members {
+        if member.user_email == user_email && member.role == data.RoleOwner && store.checkOtherOwners(member.org_id) != nil {
+            return data.ConflictError(fmt.Sprintf("You are the only owner of organization %s - transfer ownership first", orgIdString(member.org_id)))
+        }
+    }
+    delete(store.users, user_email)
+    for token, session := range store.sessions {
+        if session.user_email == user_email {
+            delete(store.sessions, token)
+        }
+    }
+    var history []memoryStatus
+    for _, entry := range store.history {
+        if entry.user_email != user_email {
+            history = append(history, entry)
+        }
+    }
+    store.history = history
+    var applications []*memoryApplication
+    var blob_keys []string
+    for _, application := range store.applications {
+        if application.user_email != user_email {
+            applications = append(applications, application)
+        } else if application.resume != nil {
+            blob_keys = append(blob_keys, application.resume.blob_key)
+        }
+    }
+    store.applications = applications
+    if user.resume != nil {
+        blob_keys = append(blob_keys, user.resume.blob_key)
+    }
+    deleteBlobs(blob_keys)
+    var members []*memoryMember
+    for _, member := range store.members {
+        if member.user_email != user_email {
+            members = append(members, member)
+        }
+    }
+    store.members = members
+    for id, search := range store.saved_searches {
+        if search.info.User_email == user_email {
+            delete(store.saved_searches, id)
+        }
+    }
+    store.dropBookmarks(func(bookmark *memoryBookmark) bool {
+        return bookmark.user_email == user_email
+    })
+    for id, hook := range store.webhooks {
+        if hook.info.Creator == user_email {
+            store.dropWebhook(id)
+        }
+    }
+    delete(store.preferences, user_email)
+    var outbox []*memoryNotification
+    for _, notification := range store.outbox {
+        if notification.info.Recipient != user_email {
+            outbox = append(outbox, notification)
+        }
+    }
+    store.outbox = outbox
+    var messages []*memoryMessage
+    for _, message := range store.messages {
+        if message.info.Applicant != user_email && message.info.Sender != user_email {
+            messages = append(messages, message)
+        }
+    }
+    store.messages = messages
+    // organization jobs go to the owner who joined first; the rest are closed
+    for _, job := range store.jobs {
+        if job.info.Creator != user_email {
+            continue
+        }
+        job.info.Creator = ""
+        for _, member := range store.members {
+            if job.org_id != 0 && member.org_id == job.org_id && member.role == data.RoleOwner {
+                job.info.Creator = member.user_email
+                break
+            }
+        }
+        if job.info.Creator == "" {
+            job.info.Is_open = false
+        }
+    }
+    return nil
+}
+
+func (store *memoryStore) CheckPassword(user_email string, password string) (bOk bool, err error) {
+    store.mutex.Lock()
+    user, found := store.users[user_email]
+    store.mutex.Unlock()
+    if !found || user.password_hash == "" {
+        return false, nil
+    }
+    err = bcrypt.CompareHashAndPassword([]byte(user.password_hash), []byte(password))
+    return err == nil, nil
+}
+
+func (store *memoryStore) CreateSession(user_email string) (token string, expires time.Time, err error) {
+    token, err = newToken()
+    if err != nil {
+        return "", expires, err
+    }
+    expires = time.Now().Add(sessionLifetime)
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    store.sessions[hashToken(token)] = memorySession{user_email, expires.Unix()}
+    return token, expires, nil
+}
+
+func (store *memoryStore) GetSessionUser(token string) (user_email string, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    session, found := store.sessions[hashToken(token)]
+    if !found {
+        return "", data.UnauthorizedError("Invalid session token")
+    }
+    if time.Now().Unix() > session.expires {
+        delete(store.sessions, hashToken(token))
+        return "", data.UnauthorizedError("Session has expired - please log in again")
+    }
+    return session.user_email, nil
+}
+
+func (store *memoryStore) DeleteSession(token string) (err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    delete(store.sessions, hashToken(token))
+    return nil
+}
+
+func (store *memoryStore) CreateOrganization(org data.Organization) (org_id string, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    for _, existing := range store.orgs {
+        if existing.info.Name == org.Name {
+            return "", data.ConflictError("Organization name is not unique; organization not created")
+        }
+    }
+    nowstring := data.StoredNow()
+    store.last_org_id++
+    store.last_member_id++
+    store.members = append(store.members, &memoryMember{id: store.last_member_id, org_id: store.last_org_id,
+                                                        user_email: org.Creator, role: data.RoleOwner, added: nowstring})
+    org.Org_id = ""
+    org.Creator = ""
+    org.Members = nil
+    store.orgs[store.last_org_id] = &memoryOrg{id: store.last_org_id, info: org, created: nowstring}
+    return orgIdString(store.last_org_id), nil
+}
+
+func (store *memoryStore) GetOrganization(org_id string) (org data.Organization, err error) {
+    idval, _ := strconv.Atoi(org_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    found, ok := store.orgs[idval]
+    if !ok {
+        return org, data.NotFoundError("No matching organization found")
+    }
+    org = found.info
+    org.Org_id = orgIdString(idval)
+    org.Created = data.DisplayTime(found.created)
+    for _, member := range store.members {
+        user, ok := store.users[member.user_email]
+        if member.org_id != idval || !ok {
+            continue
+        }
+        org.Members = append(org.Members, data.Org_member{Email: member.user_email, Role: member.role,
+            Name: user.profile.First + " " + user.profile.Last, Added: data.DisplayTime(member.added)})
+    }
+    return org, nil
+}
+
+func (store *memoryStore) UpdateOrganization(org data.Organization) (err error) {
+    if org.Name == "" && org.Description == "" && org.Website == "" && org.Logo_url == "" && org.Location == "" {
+        return data.ValidationError("", "Nothing to change")
+    }
+    idval, _ := strconv.Atoi(org.Org_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    err = store.checkOrgOwner(idval, org.Creator)
+    if err != nil {
+        return err
+    }
+    for id, existing := range store.orgs {
+        if id != idval && org.Name != "" && existing.info.Name == org.Name {
+            return data.ConflictError("Organization name is not unique; organization not changed")
+        }
+    }
+    info := &store.orgs[idval].info
+    if org.Name != "" {
+        info.Name = org.Name
+    }
+    if org.Description != "" {
+        info.Description = org.Description
+    }
+    if org.Website != "" {
+        info.Website = org.Website
+    }
+    if org.Logo_url != "" {
+        info.Logo_url = org.Logo_url
+    }
+    if org.Location != "" {
+        info.Location = org.Location
+    }
+    return nil
+}
+
+func (store *memoryStore) SearchOrganizations(keyword string, member_email string, page data.Page_request) (orgs []data.Organization, total int, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    var rows []memoryRow
+    for _, org := range store.orgs {
+        if keyword != "" && !containsFold(org.info.Name, keyword) {
+            continue
+        }
+        if member_email != "" && store.findMember(org.id, member_email) == nil {
+            continue
+        }
+        rows = append(rows, memoryRow{"o.id": org.id, "o.name": org.info.Name, "o.created": org.created, "org": org})
+    }
+    for _, row := range pageRows(rows, page, organizationSortColumns, "name", "asc", "o.id") {
+        org := row["org"].(*memoryOrg)
+        summary := org.info
+        summary.Org_id = orgIdString(org.id)
+        summary.Created = data.DisplayTime(org.created)
+        orgs = append(orgs, summary)
+    }
+    return orgs, len(rows), nil
+}
+
+func (store *memoryStore) SetOrganizationMember(change data.Member_change) (err error) {
+    idval, _ := strconv.Atoi(change.Org_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    err = store.checkOrgOwner(idval, change.Creator)
+    if err != nil {
+        return err
+    }
+    member := store.findMember(idval, change.Member)
+    if member == nil {
+        store.last_member_id++
+        store.members = append(store.members, &memoryMember{id: store.last_member_id, org_id: idval,
+                                                            user_email: change.Member, role: change.Role, added: data.StoredNow()})
+        return nil
+    }
+    if member.role == change.Role {
+        return data.ConflictError("Member is already " + member.role)
+    }
+    if member.role == data.RoleOwner {
+        err = store.checkOtherOwners(idval)
+        if err != nil {
+            return err
+        }
+    }
+    member.role = change.Role
+    return nil
+}
+
+func (store *memoryStore) RemoveOrganizationMember(change data.Member_change) (err error) {
+    idval, _ := strconv.Atoi(change.Org_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    if change.Member == change.Creator {
+        _, err = store.checkOrgMember(idval, change.Creator)
+    } else {
+        err = store.checkOrgOwner(idval, change.Creator)
+    }
+    if err != nil {
+        return err
+    }
+    member := store.findMember(idval, change.Member)
+    if member == nil {
+        return data.NotFoundError("No such member of this organization")
+    }
+    if member.role == data.RoleOwner {
+        err = store.checkOtherOwners(idval)
+        if err != nil {
+            return err
+        }
+    }
+    var members []*memoryMember
+    for _, other := range store.members {
+        if other != member {
+            members = append(members, other)
+        }
+    }
+    store.members = members
+    return nil
+}
+
+func (store *memoryStore) CreateJob(job data.Job_info) (job_id string, err error) {
+    org_id := 0
+    if job.Org_id != "" {
+        org_id, _ = strconv.Atoi(job.Org_id)  // already validated the format
+    }
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    if org_id != 0 {
+        _, err = store.checkOrgMember(org_id, job.Creator)
+        if err != nil {
+            return "", err
+        }
+    }
+    store.last_job_id++
+    job_entry := &memoryJob{
+        id: store.last_job_id,
+        info: data.Job_info{Creator: job.Creator, Title: job.Title, Description: job.Description,
+                            Min_education: job.Min_education, Min_experience: job.Min_experience,
+                            Salary: job.Salary, Is_open: true, Expires_on: job.Expires_on,
+                            Province: job.Province, City: job.City, Latitude: job.Latitude, Longitude: job.Longitude,
+                            Work_arrangement: job.Work_arrangement, Employment_type: job.Employment_type},
+        created: data.StoredNow(),
+        org_id: org_id,
+    }
+    store.setJobSkills(job_entry, job.Skills)
+    store.jobs[store.last_job_id] = job_entry
+    store.queueWebhookEvent(data.EventJobCreated, job_entry, "", "")
+    return fmt.Sprintf("%05d", store.last_job_id), nil
+}
+
+// Keywords are matched the same way as SQLite without FTS5: the raw text
+// of every term must appear in the title or the description
+func (store *memoryStore) SearchJobs(criteria data.Search_criteria, page data.Page_request) (summaries []data.Job_summary, total int, err error) {
+    keyword := criteria.Keyword
+    terms := parseKeyword(keyword)
+    org_id, _ := strconv.Atoi(criteria.Org_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    proficiencies := store.proficiencies(criteria.User_email)
+    var rows []memoryRow
+    for _, job := range store.jobs {
+        matches := true
+        for _, term := range terms {
+            if !containsFold(job.info.Title, term.raw) && !containsFold(job.info.Description, term.raw) {
+                matches = false
+            }
+        }
+        if len(terms) == 0 && keyword != "" && !containsFold(job.info.Title, keyword) {
+            matches = false
+        }
+        if criteria.Posted_from != "" && job.created < criteria.Posted_from {
+            matches = false
+        }
+        if criteria.Posted_to != "" && job.created >= criteria.Posted_to {
+            matches = false
+        }
+        if criteria.Experience != 0 && job.info.Min_experience > criteria.Experience {
+            matches = false
+        }
+        if criteria.Education != 0 && job.info.Min_education > criteria.Education {
+            matches = false
+        }
+        if criteria.Salary != 0 && job.info.Salary < criteria.Salary {
+            matches = false
+        }
+        if criteria.After_job_id != 0 && job.id <= criteria.After_job_id {
+            matches = false
+        }
+        if criteria.Org_id != "" && job.org_id != org_id {
+            matches = false
+        }
+        if criteria.Organization != "" && (job.org_id == 0 || !containsFold(store.orgName(job.org_id), criteria.Organization)) {
+            matches = false
+        }
+        if !matchesLocation(job.info, criteria) {
+            matches = false
+        }
+        for _, name := range criteria.Skills {
+            found := false
+            for _, skill := range job.info.Skills {
+                found = found || skill.Name == name
+            }
+            if !found {
+                matches = false
+            }
+        }
+        if !criteria.Include_archived && (job.info.Is_archived || isExpired(job.info.Expires_on)) {
+            matches = false
+        }
+        if matches {
+            row := jobRow(job)
+            if criteria.User_email != "" {
+                row["match_score"] = matchScore(job.info.Skills, proficiencies)
+                row["bookmarked"] = store.findBookmark(criteria.User_email, job.id) != nil
+            }
+            rows = append(rows, row)
+        }
+    }
+    sort_columns := jobSortColumns
+    if criteria.User_email != "" {
+        sort_columns = withMatchSort(sort_columns)
+    }
+    page_rows := pageRows(rows, page, sort_columns, "posted", "desc", "j.id")
+    return store.jobSummaries(page_rows, terms), len(rows), nil
+}
+
+func (store *memoryStore) SearchOfferedJobs(user_email string, page data.Page_request) (summaries []data.Job_summary, total int, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    var rows []memoryRow
+    for _, job := range store.jobs {
+        if job.info.Creator == user_email {
+            rows = append(rows, jobRow(job))
+        }
+    }
+    page_rows := pageRows(rows, page, jobSortColumns, "posted", "desc", "j.id")
+    return store.jobSummaries(page_rows, nil), len(rows), nil
+}
+
+func (store *memoryStore) GetJobDetail(job_id string) (foundjob data.Job_info, err error) {
+    id, _ := strconv.Atoi(job_id)  // we already validated this
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    job, found := store.jobs[id]
+    if !found {
+        return foundjob, data.NotFoundError("No matching job found")
+    }
+    foundjob = job.info
+    foundjob.Skills = append([]data.Job_skill{}, job.info.Skills...)
+    foundjob.Job_id = fmt.Sprintf("%05d", id)
+    foundjob.Date_posted = data.DisplayTime(job.created)
+    foundjob.Expires_on = data.DisplayTime(job.info.Expires_on)
+    foundjob.Org_id = orgIdString(job.org_id)
+    foundjob.Organization = store.orgName(job.org_id)
+    return foundjob, nil
+}
+
+func (store *memoryStore) ModifyJob(changes data.Job_changes) (return_job_id string, err error) {
+    idval, _ := strconv.Atoi(changes.Job_id)
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    job, found := store.jobs[idval]
+    if !found {
+        return "00000", data.NotFoundError("No matching job found")
+    }
+    err = store.checkJobManager(job, changes.Creator)
+    if err != nil {
+        return "00000", err
+    }
+    if job.info.Is_archived {
+        return "00000", data.ConflictError("Job has been archived")
+    }
+    err = checkJobChanges(changes, job.info.Is_open, job.info.Expires_on, job.hired_person)
+    if err != nil {
+        return "00000", err
+    }
+    if changes.Title != nil {
+        job.info.Title = *changes.Title
+    }
+    if changes.Description != nil {
+        job.info.Description = *changes.Description
+    }
+    if changes.Min_education != nil {
+        job.info.Min_education = *changes.Min_education
+    }
+    if changes.Min_experience != nil {
+        job.info.Min_experience = *changes.Min_experience
+    }
+    if changes.Salary != nil {
+        job.info.Salary = *changes.Salary
+    }
+    filled := false
+    if changes.Is_open != nil {
+        if *changes.Is_open != job.info.Is_open {
+            store.flagFilledBookmarks(job.id, !*changes.Is_open)
+        }
+        filled = job.info.Is_open && !*changes.Is_open
+        job.info.Is_open = *changes.Is_open
+    }
+    if changes.Expires_on != nil {
+        job.info.Expires_on = *changes.Expires_on
+    }
+    if changes.Province != nil {
+        job.info.Province = *changes.Province
+    }
+    if changes.City != nil {
+        job.info.City = *changes.City
+    }
+    if changes.Latitude != nil {
+        job.info.Latitude = *changes.Latitude
+    }
+    if changes.Longitude != nil {
+        job.info.Longitude = *changes.Longitude
+    }
+    if changes.Work_arrangement != nil {
+        job.info.Work_arrangement = *changes.Work_arrangement
+    }
+    if changes.Employment_type != nil {
+        job.info.Employment_type = *changes.Employment_type
+    }
+    if changes.Skills != nil {
+        store.setJobSkills(job, *changes.Skills)
+    }
+    store.queueWebhookEvent(data.EventJobModified, job, "", "")
+    if filled {
+        store.queueWebhookEvent(data.EventJobFilled, job, "", "")
+    }
+    return changes.Job_id, nil
+}
+
+func (store *memoryStore) ArchiveJob(creator_email string, job_id string) (err error) {
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    job, found := store.jobs[idval]
+    if !found {
+        return data.NotFoundError("No matching job found")
+    }
+    err = store.checkJobManager(job, creator_email)
+    if err != nil {
+        return err
+    }
+    if job.info.Is_archived {
+        return data.ConflictError("Job has already been archived")
+    }
+    job.info.Is_archived = true
+    job.info.Is_open = false
+    return nil
+}
+
+func (store *memoryStore) DeleteJob(creator_email string, job_id string) (err error) {
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    job, found := store.jobs[idval]
+    if !found {
+        return data.NotFoundError("No matching job found")
+    }
+    err = store.checkJobManager(job, creator_email)
+    if err != nil {
+        return err
+    }
+    for _, application := range store.applications {
+        if application.job_id == idval {
+            return data.ConflictError("Job has applications and cannot be deleted - archive it instead")
+        }
+    }
+    delete(store.jobs, idval)
+    store.dropBookmarks(func(bookmark *memoryBookmark) bool {
+        return bookmark.job_id == idval
+    })
+    return nil
+}
+
+func (store *memoryStore) CloseExpiredJobs() (closed int, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    for _, job := range store.jobs {
+        if job.info.Is_open && isExpired(job.info.Expires_on) {
+            job.info.Is_open = false
+            closed++
+        }
+    }
+    return closed, nil
+}
+
+func (store *memoryStore) SubmitJobApplication(user_email string, job_id string, cover_letter string,
+                                               resume *data.Document) (applied_job_id string, unmet []data.Unmet_requirement, err error) {
+    blob_key := ""
+    if resume != nil {
+        blob_key, err = putResume(resume)
+        if err != nil {
+            return "", nil, err
+        }
+    }
+    applied_job_id, unmet, err = store.submitJobApplication(user_email, job_id, cover_letter, resume, blob_key)
+    if err != nil {
+        deleteBlobs([]string{blob_key})
+    }
+    return applied_job_id, unmet, err
+}
+
+func (store *memoryStore) submitJobApplication(user_email string, job_id string, cover_letter string, resume *data.Document,
+                                               blob_key string) (applied_job_id string, unmet []data.Unmet_requirement, err error) {
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    user, found := store.users[user_email]
+    if !found {
+        return "", nil, data.NotFoundError("Unknown user")
+    }
+    job, found := store.jobs[idval]
+    if !found {
+        return "", nil, data.NotFoundError("No matching job found")
+    }
+    if job.info.Is_archived {
+        return "", nil, data.ConflictError("Job has been archived")
+    }
+    if isExpired(job.info.Expires_on) {
+        return "", nil, data.ConflictError("Job has expired")
+    }
+    if !job.info.Is_open {
+        return "", nil, data.ConflictError("Job has already been filled")
+    }
+    if user_email == job.info.Creator {
+        return "", nil, data.ForbiddenError("Creator cannot submit an application for their own job")
+    }
+    if store.findApplication(idval, user_email) != nil {
+        return "", nil, data.ConflictError("Attempt to create duplicate job application")
+    }
+    store.last_application_id++
+    application := &memoryApplication{id: store.last_application_id, job_id: idval, user_email: user_email,
+                                      apply_time: data.StoredNow(), cover_letter: cover_letter, resume: user.resume}
+    if resume != nil {
+        application.resume = newMemoryResume(user_email, resume, blob_key)
+    }
+    store.applications = append(store.applications, application)
+    store.setStatus(application, data.StatusSubmitted, user_email)
+    return job_id, store.unmetRequirements(user, job), nil
+}
+
+// Return the requirements of a job that a user does not meet. Must hold the mutex
+func (store *memoryStore) unmetRequirements(user *memoryUser, job *memoryJob) []data.Unmet_requirement {
+    return unmetRequirements(user.education, user.experience, job.info.Min_education, job.info.Min_experience,
+                             job.info.Skills, store.proficiencies(user.profile.Email))
+}
+
+func (store *memoryStore) SearchCandidates(creator_email string, job_id string, qualified_only bool,
+                                           page data.Page_request) (candidates []data.Candidate, total int, err error) {
+    idval, _ := strconv.Atoi(job_id)
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    job, found := store.jobs[idval]
+    if !found {
+        return candidates, 0, data.NotFoundError("No matching job found")
+    }
+    err = store.checkJobManager(job, creator_email)
+    if err != nil {
+        return candidates, 0, err
+    }
+    var rows []memoryRow
+    for _, application := range store.applications {
+        user, found := store.users[application.user_email]
+        if application.job_id != idval || !found {
+            continue
+        }
+        qualified := len(store.unmetRequirements(user, job)) == 0
+        if qualified_only && !qualified {
+            continue
+        }
+        rows = append(rows, memoryRow{"a.id": application.id, "a.apply_time": application.apply_time,
+                                      "a.status": application.status, "u.first_name": user.profile.First,
+                                      "u.last_name": user.profile.Last, "application": application, "user": user,
+                                      "qualified": qualified,
+                                      "match_score": matchScore(job.info.Skills, store.proficiencies(application.user_email))})
+    }
+    for _, row := range pageRows(rows, page, candidateSortColumns, "applied", "asc", "a.id") {
+        application := row["application"].(*memoryApplication)
+        user := row["user"].(*memoryUser)
+        candidate := data.Candidate{
+            Email:        application.user_email,
+            Name:         user.profile.First + " " + user.profile.Last,
+            Phone:        user.profile.Phone,
+            Applied_date: data.DisplayTime(application.apply_time),
+            Status:       application.status,
+            Status_date:  data.DisplayTime(application.status_time),
+            Match_score:  scoreValue(row["match_score"].(int)),
+            Education:    user.education,
+            Experience:   user.experience,
+            Qualified:    row["qualified"].(bool),
+            Cover_letter: application.cover_letter,
+        }
+        if application.resume != nil {
+            candidate.Resume = application.resume.document()
+        }
+        candidates = append(candidates, candidate)
+    }
+    return candidates, len(rows), nil
+}
+
+func (store *memoryStore) UpdateApplicationStatus(creator_email string, job_id string, applicant_email string, status string) (err error) {
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    job, found := store.jobs[idval]
+    if !found {
+        return data.NotFoundError("No matching job found")
+    }
+    err = store.checkJobManager(job, creator_email)
+    if err != nil {
+        return err
+    }
+    application := store.findApplication(idval, applicant_email)
+    if application == nil {
+        return data.NotFoundError("No application from this user for this job")
+    }
+    if data.IsFinalStatus(application.status) {
+        return data.ConflictError("Application has already been " + application.status)
+    }
+    if application.status == status {
+        return data.ConflictError("Application status is already " + status)
+    }
+    if status == data.StatusHired {
+        if !job.info.Is_open {
+            return data.ConflictError("Job has already been filled")
+        }
+        job.info.Is_open = false
+        job.hired_person = applicant_email
+        store.flagFilledBookmarks(job.id, true)
+    }
+    store.setStatus(application, status, creator_email)
+    if status == data.StatusHired {
+        store.queueWebhookEvent(data.EventJobFilled, job, "", "")
+    }
+    return nil
+}
+
+func (store *memoryStore) WithdrawApplication(user_email string, job_id string) (withdrawn_job_id string, err error) {
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    application := store.findApplication(idval, user_email)
+    if application == nil {
+        return "", data.NotFoundError("No application from this user for this job")
+    }
+    if data.IsFinalStatus(application.status) {
+        return "", data.ConflictError("Application has already been " + application.status)
+    }
+    store.setStatus(application, data.StatusWithdrawn, user_email)
+    return job_id, nil
+}
+
+func (store *memoryStore) SearchAppliedJobs(user_email string, page data.Page_request) (applications []data.Application_summary, total int, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    history := make(map[int][]data.Status_entry)
+    for _, entry := range store.history {
+        if entry.user_email == user_email {
+            history[entry.job_id] = append(history[entry.job_id],
+                data.Status_entry{Status: entry.status, Changed: data.DisplayTime(entry.change_time)})
+        }
+    }
+    var rows []memoryRow
+    for _, application := range store.applications {
+        job, found := store.jobs[application.job_id]
+        if application.user_email != user_email || !found {
+            continue
+        }
+        rows = append(rows, memoryRow{"j.id": job.id, "j.created": job.created, "j.title": job.info.Title,
+                                      "ja.apply_time": application.apply_time, "ja.status": application.status,
+                                      "application": application, "job": job})
+    }
+    for _, row := range pageRows(rows, page, applicationSortColumns, "applied", "desc", "j.id") {
+        application := row["application"].(*memoryApplication)
+        job := row["job"].(*memoryJob)
+        var summary data.Application_summary
+        summary.Job_id = fmt.Sprintf("%05d", job.id)
+        summary.Title = job.info.Title
+        summary.Is_open = job.info.Is_open
+        summary.Date_posted = data.DisplayTime(job.created)
+        summary.Expires_on = data.DisplayTime(job.info.Expires_on)
+        summary.Is_archived = job.info.Is_archived
+        summary.Org_id = orgIdString(job.org_id)
+        summary.Organization = store.orgName(job.org_id)
+        summary.Province = job.info.Province
+        summary.City = job.info.City
+        summary.Work_arrangement = job.info.Work_arrangement
+        summary.Employment_type = job.info.Employment_type
+        summary.Applied_date = data.DisplayTime(application.apply_time)
+        summary.Status = application.status
+        summary.Status_date = data.DisplayTime(application.status_time)
+        summary.History = history[job.id]
+        applications = append(applications, summary)
+    }
+    return applications, len(rows), nil
+}
+
+func (store *memoryStore) ListSkills(keyword string, page data.Page_request) (skills []data.Skill, total int, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    var rows []memoryRow
+    for name, id := range store.skills {
+        if keyword == "" || containsFold(name, keyword) {
+            rows = append(rows, memoryRow{"s.id": id, "s.name": name})
+        }
+    }
+    for _, row := range pageRows(rows, page, skillSortColumns, "name", "asc", "s.id") {
+        skills = append(skills, data.Skill{Name: row["s.name"].(string)})
+    }
+    return skills, len(rows), nil
+}
+
+func (store *memoryStore) SetUserResume(user_email string, resume *data.Document) (err error) {
+    blob_key := ""
+    if resume != nil {
+        blob_key, err = putResume(resume)
+        if err != nil {
+            return err
+        }
+    }
+    old_key, err := store.setUserResume(user_email, resume, blob_key)
+    if err != nil {
+        deleteBlobs([]string{blob_key})
+        return err
+    }
+    deleteBlobs([]string{old_key})
+    return nil
+}
+
+func (store *memoryStore) setUserResume(user_email string, resume *data.Document, blob_key string) (old_key string, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    user, found := store.users[user_email]
+    if !found {
+        return "", data.NotFoundError("Unknown user")
+    }
+    old := user.resume
+    if resume == nil && old == nil {
+        return "", data.NotFoundError("User has no resume")
+    }
+    user.resume = nil
+    if resume != nil {
+        user.resume = newMemoryResume(user_email, resume, blob_key)
+    }
+    if old != nil && !store.resumeInUse(old) {
+        old_key = old.blob_key
+    }
+    return old_key, nil
+}
+
+func (store *memoryStore) GetUserResume(user_email string) (resume data.Document, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    user, found := store.users[user_email]
+    if !found {
+        return resume, data.NotFoundError("Unknown user")
+    }
+    if user.resume == nil {
+        return resume, data.NotFoundError("User has no resume")
+    }
+    return user.resume.load()
+}
+
+func (store *memoryStore) GetApplicationResume(creator_email string, job_id string, applicant_email string) (resume data.Document, err error) {
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    job, found := store.jobs[idval]
+    if !found {
+        return resume, data.NotFoundError("No matching job found")
+    }
+    err = store.checkJobManager(job, creator_email)
+    if err != nil {
+        return resume, err
+    }
+    application := store.findApplication(idval, applicant_email)
+    if application == nil {
+        return resume, data.NotFoundError("No application from this user for this job")
+    }
+    if application.resume == nil {
+        return resume, data.NotFoundError("Application has no resume")
+    }
+    return application.resume.load()
+}
+
+func (store *memoryStore) CreateSavedSearch(search data.Saved_search) (search_id string, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    count := 0
+    for _, existing := range store.saved_searches {
+        if existing.info.User_email == search.User_email {
+            if existing.info.Name == search.Name {
+                return "", data.ConflictError("You already have a saved search with this name")
+            }
+            count++
+        }
+    }
+    if count >= data.MaxSavedSearches {
+        return "", data.ConflictError(fmt.Sprintf("A user can have at most %d saved searches", data.MaxSavedSearches))
+    }
+    nowstring := data.StoredNow()
+    store.last_search_id++
+    search.Search_id = ""
+    search.New_matches = 0
+    search.Created = nowstring
+    search.Last_viewed = nowstring
+    store.saved_searches[store.last_search_id] = &memorySavedSearch{id: store.last_search_id, info: search,
+                                                                     seen_job_id: store.last_job_id}
+    return searchIdString(store.last_search_id), nil
+}
+
+// The new matches are counted after the mutex is released, since
+// SearchJobs takes it too
+func (store *memoryStore) ListSavedSearches(user_email string, page data.Page_request) (searches []data.Saved_search, total int, err error) {
+    store.mutex.Lock()
+    var rows []memoryRow
+    for _, search := range store.saved_searches {
+        if search.info.User_email == user_email {
+            rows = append(rows, memoryRow{"ss.id": search.id, "ss.name": search.info.Name, "ss.created": search.info.Created, "search": search})
+        }
+    }
+    var seen []int
+    for _, row := range pageRows(rows, page, savedSearchSortColumns, "name", "asc", "ss.id") {
+        search := row["search"].(*memorySavedSearch)
+        found := search.info
+        found.Search_id = searchIdString(search.id)
+        searches = append(searches, found)
+        seen = append(seen, search.seen_job_id)
+    }
+    store.mutex.Unlock()
+    for i := range searches {
+        searches[i].New_matches, err = countNewMatches(store, searches[i], seen[i])
+        if err != nil {
+            return nil, 0, err
+        }
+        searches[i] = displaySavedSearch(searches[i])
+    }
+    return searches, len(rows), nil
+}
+
+func (store *memoryStore) RunSavedSearch(user_email string, search_id string, page data.Page_request) (summaries []data.Job_summary, total int, err error) {
+    idval, _ := strconv.Atoi(search_id)  // already validated the format
+    store.mutex.Lock()
+    search, found := store.saved_searches[idval]
+    if !found || search.info.User_email != user_email {
+        store.mutex.Unlock()
+        return summaries, 0, data.NotFoundError("No matching saved search found")
+    }
+    criteria := search.info.Criteria
+    seen_job_id := store.last_job_id
+    store.mutex.Unlock()
+    criteria.User_email = user_email
+    summaries, total, err = store.SearchJobs(criteria, page)
+    if err != nil {
+        return summaries, 0, err
+    }
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    search.seen_job_id = seen_job_id
+    search.info.Last_viewed = data.StoredNow()
+    return summaries, total, nil
+}
+
+func (store *memoryStore) DeleteSavedSearch(user_email string, search_id string) (err error) {
+    idval, _ := strconv.Atoi(search_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    search, found := store.saved_searches[idval]
+    if !found || search.info.User_email != user_email {
+        return data.NotFoundError("No matching saved search found")
+    }
+    delete(store.saved_searches, idval)
+    return nil
+}
+
+func (store *memoryStore) GetSearchAlerts(user_email string) (alerts data.Search_alerts, err error) {
+    searches, _, err := store.ListSavedSearches(user_email, data.Page_request{})
+    if err != nil {
+        return alerts, err
+    }
+    return searchAlerts(searches), nil
+}
+
+func (store *memoryStore) AddBookmark(user_email string, job_id string) (err error) {
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    if _, found := store.jobs[idval]; !found {
+        return data.NotFoundError("No matching job found")
+    }
+    if store.findBookmark(user_email, idval) != nil {
+        return data.ConflictError("Job is already bookmarked")
+    }
+    store.last_bookmark_id++
+    store.bookmarks = append(store.bookmarks, &memoryBookmark{id: store.last_bookmark_id, user_email: user_email,
+                                                              job_id: idval, created: data.StoredNow()})
+    return nil
+}
+
+func (store *memoryStore) RemoveBookmark(user_email string, job_id string) (err error) {
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    if store.findBookmark(user_email, idval) == nil {
+        return data.NotFoundError("Job is not bookmarked")
+    }
+    store.dropBookmarks(func(bookmark *memoryBookmark) bool {
+        return bookmark.user_email == user_email && bookmark.job_id == idval
+    })
+    return nil
+}
+
+func (store *memoryStore) ListBookmarks(user_email string, page data.Page_request) (bookmarks []data.Bookmark, total int, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    var rows []memoryRow
+    for _, bookmark := range store.bookmarks {
+        job, found := store.jobs[bookmark.job_id]
+        if bookmark.user_email != user_email || !found {
+            continue
+        }
+        row := jobRow(job)
+        row["b.id"] = bookmark.id
+        row["b.created"] = bookmark.created
+        row["bookmark"] = bookmark
+        rows = append(rows, row)
+    }
+    page_rows := pageRows(rows, page, bookmarkSortColumns, "bookmarked", "desc", "b.id")
+    for i, summary := range store.jobSummaries(page_rows, nil) {
+        bookmark := page_rows[i]["bookmark"].(*memoryBookmark)
+        bookmarks = append(bookmarks, data.Bookmark{Job_summary: summary, Bookmarked_on: data.DisplayTime(bookmark.created),
+                                                    Filled: data.DisplayTime(bookmark.filled)})
+    }
+    return bookmarks, len(rows), nil
+}
+
+func (store *memoryStore) CreateWebhook(hook data.Webhook) (created data.Webhook, err error) {
+    org_id := 0
+    if hook.Org_id != "" {
+        org_id, _ = strconv.Atoi(hook.Org_id)  // already validated the format
+    }
+    secret, err := newToken()
+    if err != nil {
+        return created, err
+    }
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    if org_id != 0 {
+        err = store.checkOrgOwner(org_id, hook.Creator)
+        if err != nil {
+            return created, err
+        }
+    }
+    count := 0
+    for _, other := range store.webhooks {
+        if other.info.Creator == hook.Creator {
+            count++
+        }
+    }
+    if count >= data.MaxWebhooks {
+        return created, data.ConflictError(fmt.Sprintf("A user can have at most %d webhooks", data.MaxWebhooks))
+    }
+    store.last_webhook_id++
+    hook.Webhook_id = ""
+    hook.Secret = ""
+    hook.Created = data.StoredNow()
+    store.webhooks[store.last_webhook_id] = &memoryWebhook{id: store.last_webhook_id, info: hook, org_id: org_id, secret: secret}
+    created = hook
+    created.Webhook_id = webhookIdString(store.last_webhook_id)
+    created.Secret = secret
+    created.Created = data.DisplayTime(hook.Created)
+    return created, nil
+}
+
+func (store *memoryStore) ListWebhooks(user_email string, page data.Page_request) (hooks []data.Webhook, total int, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    var rows []memoryRow
+    for _, hook := range store.webhooks {
+        if hook.info.Creator == user_email {
+            rows = append(rows, memoryRow{"w.id": hook.id, "w.created": hook.info.Created, "w.url": hook.info.Url, "hook": hook})
+        }
+    }
+    for _, row := range pageRows(rows, page, webhookSortColumns, "created", "asc", "w.id") {
+        hook := row["hook"].(*memoryWebhook)
+        found := hook.info
+        found.Webhook_id = webhookIdString(hook.id)
+        found.Created = data.DisplayTime(found.Created)
+        hooks = append(hooks, found)
+    }
+    return hooks, len(rows), nil
+}
+
+func (store *memoryStore) DeleteWebhook(user_email string, webhook_id string) (err error) {
+    idval, _ := strconv.Atoi(webhook_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    hook, found := store.webhooks[idval]
+    if !found || hook.info.Creator != user_email {
+        return data.NotFoundError("No matching webhook found")
+    }
+    store.dropWebhook(idval)
+    return nil
+}
+
+func (store *memoryStore) ListWebhookDeliveries(user_email string, webhook_id string, page data.Page_request) (deliveries []data.Webhook_delivery, total int, err error) {
+    idval, _ := strconv.Atoi(webhook_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    hook, found := store.webhooks[idval]
+    if !found || hook.info.Creator != user_email {
+        return deliveries, 0, data.NotFoundError("No matching webhook found")
+    }
+    var rows []memoryRow
+    for _, delivery := range store.deliveries {
+        if delivery.webhook_id == idval {
+            rows = append(rows, memoryRow{"d.id": delivery.id, "d.created": delivery.info.Created, "d.status": delivery.info.Status,
+                                          "delivery": delivery})
+        }
+    }
+    for _, row := range pageRows(rows, page, deliverySortColumns, "created", "desc", "d.id") {
+        delivery := row["delivery"].(*memoryDelivery)
+        found := delivery.info
+        found.Delivery_id = webhookIdString(delivery.id)
+        found.Webhook_id = webhookIdString(idval)
+        if found.Status != data.DeliveryPending {
+            found.Next_attempt = ""
+        }
+        deliveries = append(deliveries, displayDelivery(found))
+    }
+    return deliveries, len(rows), nil
+}
+
+func (store *memoryStore) DueWebhookDeliveries(limit int) (deliveries []data.Webhook_delivery, err error) {
+    nowstring := data.StoredNow()
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    var due []*memoryDelivery
+    for _, delivery := range store.deliveries {
+        if delivery.info.Status == data.DeliveryPending && delivery.info.Next_attempt <= nowstring {
+            due = append(due, delivery)
+        }
+    }
+    sort.SliceStable(due, func(i, j int) bool {
+        return due[i].info.Next_attempt < due[j].info.Next_attempt
+    })
+    for _, delivery := range due {
+        if len(deliveries) == limit {
+            break
+        }
+        hook := store.webhooks[delivery.webhook_id]
+        found := delivery.info
+        found.Delivery_id = webhookIdString(delivery.id)
+        found.Webhook_id = webhookIdString(delivery.webhook_id)
+        found.Url = hook.info.Url
+        found.Secret = hook.secret
+        deliveries = append(deliveries, found)
+    }
+    return deliveries, nil
+}
+
+func (store *memoryStore) RecordWebhookAttempt(delivery_id string, delivered bool, response_code int, message string) (err error) {
+    idval, _ := strconv.Atoi(delivery_id)
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    for _, delivery := range store.deliveries {
+        if delivery.id == idval {
+            delivery.info.Attempts++
+            delivery.info.Status, delivery.info.Next_attempt, delivery.info.Delivered = attemptOutcome(delivery.info.Attempts, delivered)
+            delivery.info.Response_code = response_code
+            delivery.info.Last_error = deliveryError(message)
+            return nil
+        }
+    }
+    return data.NotFoundError("No matching delivery found")
+}
+
+func (store *memoryStore) GetNotificationPreferences(user_email string) (prefs data.Notification_preferences, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    return store.notificationPreferences(user_email), nil
+}
+
+func (store *memoryStore) SetNotificationPreferences(changes data.Notification_changes) (prefs data.Notification_preferences, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    prefs = changePreferences(store.notificationPreferences(changes.User_email), changes)
+    store.preferences[changes.User_email] = prefs
+    return prefs, nil
+}
+
+func (store *memoryStore) DueNotifications(limit int) (notifications []data.Notification, err error) {
+    nowstring := data.StoredNow()
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    var due []*memoryNotification
+    for _, notification := range store.outbox {
+        if notification.status == data.DeliveryPending && notification.next_attempt <= nowstring {
+            due = append(due, notification)
+        }
+    }
+    sort.SliceStable(due, func(i, j int) bool {
+        return due[i].next_attempt < due[j].next_attempt
+    })
+    for _, notification := range due {
+        if len(notifications) == limit {
+            break
+        }
+        found := notification.info
+        found.Notification_id = notificationIdString(notification.id)
+        found.Created = data.DisplayTime(found.Created)
+        notifications = append(notifications, found)
+    }
+    return notifications, nil
+}
+
+func (store *memoryStore) RecordNotificationAttempt(notification_id string, sent bool, message string) (err error) {
+    idval, _ := strconv.Atoi(notification_id)
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    for _, notification := range store.outbox {
+        if notification.id == idval {
+            notification.info.Attempts++
+            notification.status, notification.next_attempt, notification.sent = attemptOutcome(notification.info.Attempts, sent)
+            notification.last_error = deliveryError(message)
+            return nil
+        }
+    }
+    return data.NotFoundError("No matching notification found")
+}
+
+func (store *memoryStore) SendMessage(message data.Message) (sent data.Message, err error) {
+    idval, _ := strconv.Atoi(message.Job_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    job, err := store.checkThread(idval, message.Applicant, message.Sender)
+    if err != nil {
+        return sent, err
+    }
+    if job.info.Creator == "" {
+        return sent, data.ConflictError("The job's creator no longer has an account")
+    }
+    store.last_message_id++
+    message.Message_id = ""
+    message.Job_id = fmt.Sprintf("%05d", idval)
+    message.Sent = data.StoredNow()
+    message.Read = ""
+    store.messages = append(store.messages, &memoryMessage{id: store.last_message_id, job_id: idval, info: message})
+    sent = message
+    sent.Message_id = messageIdString(store.last_message_id)
+    sent.Sent = data.DisplayTime(message.Sent)
+    return sent, nil
+}
+
+func (store *memoryStore) ListMessages(user_email string, job_id string, applicant string, page data.Page_request) (messages []data.Message, total int, err error) {
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    _, err = store.checkThread(idval, applicant, user_email)
+    if err != nil {
+        return messages, 0, err
+    }
+    var rows []memoryRow
+    for _, message := range store.messages {
+        if message.job_id == idval && message.info.Applicant == applicant {
+            rows = append(rows, memoryRow{"m.id": message.id, "m.created": message.info.Sent, "message": message})
+        }
+    }
+    for _, row := range pageRows(rows, page, messageSortColumns, "sent", "asc", "m.id") {
+        message := row["message"].(*memoryMessage)
+        found := message.info
+        found.Message_id = messageIdString(message.id)
+        found.Sent = data.DisplayTime(found.Sent)
+        found.Read = data.DisplayTime(found.Read)
+        messages = append(messages, found)
+    }
+    return messages, len(rows), nil
+}
+
+func (store *memoryStore) MarkMessagesRead(user_email string, job_id string, applicant string) (marked int, err error) {
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    _, err = store.checkThread(idval, applicant, user_email)
+    if err != nil {
+        return 0, err
+    }
+    nowstring := data.StoredNow()
+    for _, message := range store.messages {
+        if message.job_id == idval && message.info.Applicant == applicant && message.info.Sender != user_email && message.info.Read == "" {
+            message.info.Read = nowstring
+            marked++
+        }
+    }
+    return marked, nil
+}
+
+func (store *memoryStore) GetUnreadCounts(user_email string) (counts data.Unread_counts, err error) {
+    store.mutex.Lock()
+    defer store.mutex.Unlock()
+    threads := map[string]*data.Unread_thread{}
+    latest_id := map[*data.Unread_thread]int{}
+    var order []*data.Unread_thread
+    for _, message := range store.messages {
+        job := store.jobs[message.job_id]
+        if message.info.Read != "" || message.info.Sender == user_email ||
+           (message.info.Applicant != user_email && job.info.Creator != user_email) {
+            continue
+        }
+        key := fmt.Sprintf("%d/%s", message.job_id, message.info.Applicant)
+        thread, found := threads[key]
+        if !found {
+            thread = &data.Unread_thread{Job_id: message.info.Job_id, Title: job.info.Title, Applicant: message.info.Applicant}
+            threads[key] = thread
+            order = append(order, thread)
+        }
+        thread.Unread++
+        // messages are in the order they were sent
+        thread.Latest = message.info.Sent
+        latest_id[thread] = message.id
+    }
+    sort.SliceStable(order, func(i, j int) bool {
+        if order[i].Latest != order[j].Latest {
+            return order[i].Latest > order[j].Latest
+        }
+        return latest_id[order[i]] > latest_id[order[j]]
+    })
+    counts.Threads = []data.Unread_thread{}
+    for _, thread := range order {
+        found := *thread
+        found.Latest = data.DisplayTime(found.Latest)
+        counts.Total += found.Unread
+        counts.Threads = append(counts.Threads, found)
+    }
+    return counts, nil
+}
@@ -0,0 +1,27 @@
+package dbaccess
+// Tests for the in-memory store and the demo data
+
+import (
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+func TestLoadDemoData(t *testing.T) {
+    store := NewMemoryStore()
+    if err := LoadDemoData(store); err != nil {
+        t.Fatalf("LoadDemoData failed: %v", err)
+    }
+    if ok, _ := store.CheckPassword("sally@cmkl.ac.th", demoPassword); !ok {
+        t.Errorf("demo users should have the demo password")
+    }
+    _, total, _ := store.SearchJobs(data.Search_criteria{}, data.Page_request{})
+    if total != len(demoJobs) {
+        t.Errorf("expected %d jobs, got %d", len(demoJobs), total)
+    }
+    for _, hire := range demoHires {
+        job, err := store.GetJobDetail(hire.Job_id)
+        if err != nil || job.Is_open {
+            t.Errorf("job %s should be filled, got %+v (%v)", hire.Job_id, job, err)
+        }
+    }
+}
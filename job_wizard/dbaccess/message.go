@@ -0,0 +1,174 @@
+package dbaccess
+// This module holds the database functions for messages between
+// applicants and employers. Each job application has one thread, and
+// only the applicant and the creator of the job can send to it or read it
+
+import (
+    "fmt"
+    "strconv"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+//**************** Private Functions *******************************//
+
+// Turn a message id into a string with leading zeros
+func messageIdString(idval int) string {
+    return fmt.Sprintf("%05d", idval)
+}
+
+// Check that a user may use the thread of an application: they must be
+// the applicant or the creator of the job, and the application must exist
+// Returns the job's creator, which is "" if they have deleted their account
+func checkThread(conn queryer, job_idval int, applicant string, user_email string) (created_by string, err error) {
+    row := conn.QueryRow("SELECT created_by FROM job WHERE id=?", job_idval)
+    err = row.Scan(&created_by)
+    if err != nil {
+        return "", data.NotFoundError("No matching job found")
+    }
+    if user_email != applicant && user_email != created_by {
+        return "", data.ForbiddenError("Only the applicant and the job's creator can use this thread")
+    }
+    var found int
+    row = conn.QueryRow("SELECT id FROM job_application WHERE job_id=? AND user_email=?", job_idval, applicant)
+    err = row.Scan(&found)
+    if err != nil {
+        return "", data.NotFoundError("No application from this user for this job")
+    }
+    return created_by, nil
+}
+
+//******** Exported Functions *****************************//
+
+// Function to add a message to the thread of an application. The sender
+// must be the applicant or the job's creator, and both must still have
+// an account. Returns the message with its ID and time
+func (store *sqlStore) SendMessage(message data.Message) (sent data.Message, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return sent, err
+    }
+    idval, _ := strconv.Atoi(message.Job_id)  // already validated the format
+    tx, err := db.Begin()
+    if err != nil {
+        return sent, err
+    }
+    created_by, err := checkThread(tx, idval, message.Applicant, message.Sender)
+    if err != nil {
+        tx.Rollback()
+        return sent, err
+    }
+    if created_by == "" {
+        tx.Rollback()
+        return sent, data.ConflictError("The job's creator no longer has an account")
+    }
+    nowstring := data.StoredNow()
+    sqlcmd := "INSERT INTO message (job_id, applicant, sender, body, created, read_time) values (?,?,?,?,?,'')"
+    id, err := insertReturningId(tx, sqlcmd, idval, message.Applicant, message.Sender, message.Body, nowstring)
+    if err != nil {
+        tx.Rollback()
+        return sent, err
+    }
+    err = tx.Commit()
+    if err != nil {
+        return sent, err
+    }
+    sent = message
+    sent.Message_id = messageIdString(id)
+    sent.Job_id = fmt.Sprintf("%05d", idval)
+    sent.Sent = data.DisplayTime(nowstring)
+    sent.Read = ""
+    return sent, nil
+}
+
+// Function to list the messages in the thread of an application, by
+// default the oldest first, for the applicant or the job's creator
+// Listing does not mark them read. Returns one page plus the total number
+func (store *sqlStore) ListMessages(user_email string, job_id string, applicant string, page data.Page_request) (messages []data.Message, total int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return messages, 0, err
+    }
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    _, err = checkThread(db, idval, applicant, user_email)
+    if err != nil {
+        return messages, 0, err
+    }
+    err = db.QueryRow("SELECT COUNT(*) FROM message WHERE job_id=? AND applicant=?", idval, applicant).Scan(&total)
+    if err != nil {
+        return messages, 0, err
+    }
+    clause, page_args := pageClause(page, messageSortColumns, "sent", "asc", "m.id")
+    sqlcmd := "SELECT m.id, m.sender, m.body, m.created, m.read_time FROM message m WHERE m.job_id=? AND m.applicant=?" + clause
+    rows, err := db.Query(sqlcmd, append([]interface{}{idval, applicant}, page_args...)...)
+    if err != nil {
+        return messages, 0, err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var message data.Message
+        var message_idval int
+        err = rows.Scan(&message_idval, &message.Sender, &message.Body, &message.Sent, &message.Read)
+        if err != nil {
+            return nil, 0, err
+        }
+        message.Message_id = messageIdString(message_idval)
+        message.Job_id = fmt.Sprintf("%05d", idval)
+        message.Applicant = applicant
+        message.Sent = data.DisplayTime(message.Sent)
+        message.Read = data.DisplayTime(message.Read)
+        messages = append(messages, message)
+    }
+    return messages, total, nil
+}
+
+// Function to mark as read all the messages in a thread sent to the
+// user by the other side. Returns how many were marked
+func (store *sqlStore) MarkMessagesRead(user_email string, job_id string, applicant string) (marked int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return 0, err
+    }
+    idval, _ := strconv.Atoi(job_id)  // already validated the format
+    _, err = checkThread(db, idval, applicant, user_email)
+    if err != nil {
+        return 0, err
+    }
+    sqlcmd := "UPDATE message SET read_time=? WHERE job_id=? AND applicant=? AND sender<>? AND read_time=''"
+    result, err := db.Exec(sqlcmd, data.StoredNow(), idval, applicant, user_email)
+    if err != nil {
+        return 0, err
+    }
+    count, _ := result.RowsAffected()
+    return int(count), nil
+}
+
+// Function to count the messages sent to a user that they have not read,
+// in the threads of their applications and of the jobs they created
+func (store *sqlStore) GetUnreadCounts(user_email string) (counts data.Unread_counts, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return counts, err
+    }
+    sqlcmd := "SELECT m.job_id, j.title, m.applicant, COUNT(*), MAX(m.created) FROM message m JOIN job j ON j.id=m.job_id"
+    sqlcmd += " WHERE m.read_time='' AND m.sender<>? AND (m.applicant=? OR j.created_by=?)"
+    sqlcmd += " GROUP BY m.job_id, j.title, m.applicant ORDER BY MAX(m.created) DESC, MAX(m.id) DESC"
+    rows, err := db.Query(sqlcmd, user_email, user_email, user_email)
+    if err != nil {
+        return counts, err
+    }
+    defer rows.Close()
+    counts.Threads = []data.Unread_thread{}
+    for rows.Next() {
+        var thread data.Unread_thread
+        var job_idval int
+        err = rows.Scan(&job_idval, &thread.Title, &thread.Applicant, &thread.Unread, &thread.Latest)
+        if err != nil {
+            return counts, err
+        }
+        thread.Job_id = fmt.Sprintf("%05d", job_idval)
+        thread.Latest = data.DisplayTime(thread.Latest)
+        counts.Total += thread.Unread
+        counts.Threads = append(counts.Threads, thread)
+    }
+    return counts, nil
+}
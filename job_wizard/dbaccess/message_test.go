@@ -0,0 +1,96 @@
+package dbaccess
+// Tests for the message threads of job applications: who can use them,
+// and how unread messages are counted
+
+import (
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+func TestMessages(t *testing.T) {
+    mustRegister(t, "message.boss@example.com")
+    mustRegister(t, "message.seeker@example.com")
+    mustRegister(t, "message.other@example.com")
+    job_id, _ := testStore.CreateJob(data.Job_info{Creator: "message.boss@example.com", Title: "Harbour Pilot", Description: "Guides ships in", Salary: 45000})
+    second_id, _ := testStore.CreateJob(data.Job_info{Creator: "message.boss@example.com", Title: "Deck Hand", Description: "Ties ropes", Salary: 15000})
+
+    // no thread until there is an application
+    _, err := testStore.SendMessage(data.Message{Job_id: job_id, Applicant: "message.seeker@example.com", Sender: "message.boss@example.com", Body: "Hello"})
+    if data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("a thread without an application should not be found, got %v", err)
+    }
+    testStore.SubmitJobApplication("message.seeker@example.com", job_id, "", nil)
+    testStore.SubmitJobApplication("message.seeker@example.com", second_id, "", nil)
+
+    sent, err := testStore.SendMessage(data.Message{Job_id: job_id, Applicant: "message.seeker@example.com", Sender: "message.boss@example.com", Body: "Can you start on Monday?"})
+    if err != nil || sent.Message_id == "" || sent.Sent == "" || sent.Job_id != job_id {
+        t.Fatalf("SendMessage returned %+v (%v)", sent, err)
+    }
+    testStore.SendMessage(data.Message{Job_id: job_id, Applicant: "message.seeker@example.com", Sender: "message.boss@example.com", Body: "Please bring your licence"})
+    testStore.SendMessage(data.Message{Job_id: job_id, Applicant: "message.seeker@example.com", Sender: "message.seeker@example.com", Body: "Yes, I can"})
+    testStore.SendMessage(data.Message{Job_id: second_id, Applicant: "message.seeker@example.com", Sender: "message.boss@example.com", Body: "Are you still interested?"})
+
+    // only the applicant and the job's creator can use the thread
+    _, err = testStore.SendMessage(data.Message{Job_id: job_id, Applicant: "message.seeker@example.com", Sender: "message.other@example.com", Body: "Hi"})
+    if data.ErrorCode(err) != data.CodeForbidden {
+        t.Errorf("another user should not send to the thread, got %v", err)
+    }
+    if _, _, err = testStore.ListMessages("message.other@example.com", job_id, "message.seeker@example.com", data.Page_request{}); data.ErrorCode(err) != data.CodeForbidden {
+        t.Errorf("another user should not read the thread, got %v", err)
+    }
+    if _, err = testStore.MarkMessagesRead("message.other@example.com", job_id, "message.seeker@example.com"); data.ErrorCode(err) != data.CodeForbidden {
+        t.Errorf("another user should not mark the thread read, got %v", err)
+    }
+
+    messages, total, err := testStore.ListMessages("message.seeker@example.com", job_id, "message.seeker@example.com", data.Page_request{})
+    if err != nil || total != 3 || len(messages) != 3 || messages[0].Body != "Can you start on Monday?" || messages[2].Sender != "message.seeker@example.com" {
+        t.Fatalf("expected the thread oldest first, got %+v, %d (%v)", messages, total, err)
+    }
+    messages, _, _ = testStore.ListMessages("message.boss@example.com", job_id, "message.seeker@example.com", data.Page_request{Sort: "sent", Order: "desc", Limit: 1})
+    if len(messages) != 1 || messages[0].Body != "Yes, I can" || messages[0].Read != "" {
+        t.Errorf("expected the newest message, unread, got %+v", messages)
+    }
+
+    counts, err := testStore.GetUnreadCounts("message.seeker@example.com")
+    if err != nil || counts.Total != 3 || len(counts.Threads) != 2 {
+        t.Fatalf("expected three unread messages in two threads, got %+v (%v)", counts, err)
+    }
+    if counts.Threads[0].Job_id != second_id || counts.Threads[0].Unread != 1 || counts.Threads[1].Unread != 2 || counts.Threads[1].Title != "Harbour Pilot" {
+        t.Errorf("expected the thread with the newest message first, got %+v", counts.Threads)
+    }
+    if counts, _ = testStore.GetUnreadCounts("message.boss@example.com"); counts.Total != 1 || counts.Threads[0].Applicant != "message.seeker@example.com" {
+        t.Errorf("expected one unread message for the employer, got %+v", counts)
+    }
+
+    // marking read only marks what the other side sent
+    marked, err := testStore.MarkMessagesRead("message.seeker@example.com", job_id, "message.seeker@example.com")
+    if err != nil || marked != 2 {
+        t.Fatalf("expected two messages marked read, got %d (%v)", marked, err)
+    }
+    if marked, _ = testStore.MarkMessagesRead("message.seeker@example.com", job_id, "message.seeker@example.com"); marked != 0 {
+        t.Errorf("messages should only be marked read once, got %d", marked)
+    }
+    if counts, _ = testStore.GetUnreadCounts("message.seeker@example.com"); counts.Total != 1 || len(counts.Threads) != 1 {
+        t.Errorf("expected one unread message left, got %+v", counts)
+    }
+    messages, _, _ = testStore.ListMessages("message.boss@example.com", job_id, "message.seeker@example.com", data.Page_request{})
+    if messages[0].Read == "" || messages[2].Read != "" {
+        t.Errorf("only the messages to the applicant should be read, got %+v", messages)
+    }
+
+    // a deleted applicant's threads go with their applications; once the
+    // employer has gone, their messages go too and nobody can send to the thread
+    testStore.DeleteUser("message.boss@example.com")
+    _, err = testStore.SendMessage(data.Message{Job_id: job_id, Applicant: "message.seeker@example.com", Sender: "message.seeker@example.com", Body: "Hello?"})
+    if data.ErrorCode(err) != data.CodeConflict {
+        t.Errorf("sending after the employer left should conflict, got %v", err)
+    }
+    if _, total, _ = testStore.ListMessages("message.seeker@example.com", job_id, "message.seeker@example.com", data.Page_request{}); total != 1 {
+        t.Errorf("the applicant should still read their own messages, got %d", total)
+    }
+    testStore.DeleteUser("message.seeker@example.com")
+    mustRegister(t, "message.seeker@example.com")
+    if counts, _ = testStore.GetUnreadCounts("message.seeker@example.com"); counts.Total != 0 {
+        t.Errorf("a deleted user's messages should be deleted, got %+v", counts)
+    }
+}
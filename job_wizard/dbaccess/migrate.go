@@ -0,0 +1,352 @@
+package dbaccess
+// This module holds the schema migrations for the JobWizard database
+// Each migration is a pair of SQL scripts in the migrations directory,
+// NNNN_name.up.sql and NNNN_name.down.sql, embedded in the executable.
+// PostgreSQL has its own copy of each script in migrations/postgres,
+// with the same numbers and names.
+// The schema_version table records which migrations have been applied.
+// The full-text index is optional, so its scripts are kept apart in
+// migrations/fts5, applied only when SQLite has FTS5, and recorded in
+// the fts_version table instead
+// connectDb brings the database up to the latest version automatically
+// unless JOBWIZARD_AUTO_MIGRATE is set to false, and -task migrate can
+// move it to any version, including 0 (empty)
+
+import (
+    "database/sql"
+    "embed"
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+//go:embed migrations/*.sql migrations/postgres/*.sql migrations/fts5/*.sql
+var migrationFiles embed.FS
+
+// Directories holding the scripts for each database
+const (
+    sqliteMigrations   = "migrations"
+    postgresMigrations = "migrations/postgres"
+    ftsMigrations      = "migrations/fts5"
+)
+
+// One migration step, read from the embedded scripts
+type migration struct {
+    version int
+    name    string
+    up      string
+    down    string
+}
+
+//**************** Private Functions *******************************//
+
+// Return the directory of migration scripts for a connection
+func migrationDir(conn *sql.DB) string {
+    if isPostgres(conn) {
+        return postgresMigrations
+    }
+    return sqliteMigrations
+}
+
+// Read the embedded migration scripts from a directory, sorted by version
+// Every version from 1 up must have both an up and a down script
+func loadMigrations(dir string) (steps []migration, err error) {
+    entries, err := migrationFiles.ReadDir(dir)
+    if err != nil {
+        return nil, err
+    }
+    byVersion := map[int]*migration{}
+    for _, entry := range entries {
+        filename := entry.Name()
+        if entry.IsDir() {
+            continue
+        }
+        var direction string
+        if strings.HasSuffix(filename, ".up.sql") {
+            direction = "up"
+        } else if strings.HasSuffix(filename, ".down.sql") {
+            direction = "down"
+        } else {
+            continue
+        }
+        base := strings.TrimSuffix(filename, "." + direction + ".sql")
+        number, name, found := strings.Cut(base, "_")
+        version, converr := strconv.Atoi(number)
+        if !found || converr != nil || version < 1 {
+            return nil, fmt.Errorf("Badly named migration script %s", filename)
+        }
+        script, err := migrationFiles.ReadFile(dir + "/" + filename)
+        if err != nil {
+            return nil, err
+        }
+        step := byVersion[version]
+        if step == nil {
+            step = &migration{version: version, name: name}
+            byVersion[version] = step
+        }
+        if direction == "up" {
+            step.up = string(script)
+        } else {
+            step.down = string(script)
+        }
+    }
+    for _, step := range byVersion {
+        steps = append(steps, *step)
+    }
+    sort.Slice(steps, func(i, j int) bool { return steps[i].version < steps[j].version })
+    for i, step := range steps {
+        if step.version != i + 1 {
+            return nil, fmt.Errorf("Migration %d is missing", i + 1)
+        }
+        if step.up == "" || step.down == "" {
+            return nil, fmt.Errorf("Migration %d needs both an up and a down script", step.version)
+        }
+    }
+    return steps, nil
+}
+
+// Return true if the named table exists
+func tableExists(conn *sql.DB, table string) bool {
+    sqlcmd := "SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?"
+    if isPostgres(conn) {
+        sqlcmd = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema=current_schema() AND table_name=?"
+    }
+    var count int
+    conn.QueryRow(sqlcmd, table).Scan(&count)
+    return count > 0
+}
+
+// Return true if the table has the named column
+func columnExists(conn *sql.DB, table string, column string) bool {
+    sqlcmd := "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name=?"
+    if isPostgres(conn) {
+        sqlcmd = "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema=current_schema() AND table_name=? AND column_name=?"
+    }
+    var count int
+    conn.QueryRow(sqlcmd, table, column).Scan(&count)
+    return count > 0
+}
+
+// Work out the version of a database created before schema_version
+// existed, from init_tables.sql or an earlier build, by looking for the
+// tables and columns each migration adds
+func legacyVersion(conn *sql.DB) int {
+    if !tableExists(conn, "job") {
+        return 0
+    }
+    version := 1
+    if columnExists(conn, "user", "password_hash") && tableExists(conn, "session") {
+        version = 2
+        if columnExists(conn, "job_application", "status") && tableExists(conn, "application_status") {
+            version = 3
+        }
+    }
+    return version
+}
+
+// Make sure the schema_version table exists and return the current version
+// A database that has tables but no schema_version is adopted at the
+// version it already matches, so its data is kept
+func currentVersion(conn *sql.DB) (version int, err error) {
+    if !tableExists(conn, "schema_version") {
+        legacy := legacyVersion(conn)
+        _, err = conn.Exec(`CREATE TABLE schema_version (
+            version integer PRIMARY KEY,
+            name varchar(64),
+            applied varchar(32))`)
+        if err != nil {
+            return 0, fmt.Errorf("Error creating schema_version table - %v", err)
+        }
+        steps, err := loadMigrations(migrationDir(conn))
+        if err != nil {
+            return 0, err
+        }
+        now := data.StoredNow()
+        for _, step := range steps[:legacy] {
+            _, err = conn.Exec("INSERT INTO schema_version (version,name,applied) VALUES (?,?,?)",
+                               step.version, step.name, now)
+            if err != nil {
+                return 0, err
+            }
+        }
+    }
+    err = conn.QueryRow("SELECT COALESCE(MAX(version),0) FROM schema_version").Scan(&version)
+    return version, err
+}
+
+// Make sure the fts_version table exists and return the version of the
+// full-text index. An index created before fts_version existed is
+// adopted at version 1
+func currentFtsVersion(conn *sql.DB) (version int, err error) {
+    if !tableExists(conn, "fts_version") {
+        _, err = conn.Exec(`CREATE TABLE fts_version (
+            version integer PRIMARY KEY,
+            name varchar(64),
+            applied varchar(32))`)
+        if err != nil {
+            return 0, fmt.Errorf("Error creating fts_version table - %v", err)
+        }
+        if tableExists(conn, "job_fts") {
+            _, err = conn.Exec("INSERT INTO fts_version (version,name,applied) VALUES (1,'job_fts',?)", data.StoredNow())
+            if err != nil {
+                return 0, err
+            }
+        }
+    }
+    err = conn.QueryRow("SELECT COALESCE(MAX(version),0) FROM fts_version").Scan(&version)
+    return version, err
+}
+
+// Move the full-text index to the target version of its own scripts,
+// -1 meaning the latest. Only call this if SQLite has FTS5
+func migrateFts(conn *sql.DB, target int) (err error) {
+    steps, err := loadMigrations(ftsMigrations)
+    if err != nil {
+        return err
+    }
+    if target == -1 {
+        target = len(steps)
+    }
+    from, err := currentFtsVersion(conn)
+    if err != nil {
+        return err
+    }
+    for version := from + 1; version <= target && version <= len(steps); version++ {
+        if err = applyMigration(conn, steps[version-1], true, "fts_version"); err != nil {
+            return err
+        }
+    }
+    for version := from; version > target; version-- {
+        if err = applyMigration(conn, steps[version-1], false, "fts_version"); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Run one migration script and record the change in the version table,
+// schema_version or fts_version, all in a single transaction so a failed
+// script leaves nothing behind
+func applyMigration(conn *sql.DB, step migration, up bool, table string) (err error) {
+    tx, err := conn.Begin()
+    if err != nil {
+        return err
+    }
+    script := step.down
+    if up {
+        script = step.up
+    }
+    _, err = tx.Exec(script)
+    if err == nil {
+        if up {
+            _, err = tx.Exec("INSERT INTO " + table + " (version,name,applied) VALUES (?,?,?)",
+                             step.version, step.name, data.StoredNow())
+        } else {
+            _, err = tx.Exec("DELETE FROM " + table + " WHERE version=?", step.version)
+        }
+    }
+    if err != nil {
+        tx.Rollback()
+        direction := "down"
+        if up {
+            direction = "up"
+        }
+        return fmt.Errorf("Error migrating %s through %04d_%s - %v", direction, step.version, step.name, err)
+    }
+    return tx.Commit()
+}
+
+// Move the database to the target version, applying up or down
+// scripts as needed. A target of -1 means the latest version
+// Returns the version the database started at
+func migrateDb(conn *sql.DB, target int) (from int, err error) {
+    steps, err := loadMigrations(migrationDir(conn))
+    if err != nil {
+        return 0, err
+    }
+    if target == -1 {
+        target = len(steps)
+    }
+    if target < 0 || target > len(steps) {
+        return 0, data.ValidationError("version", fmt.Sprintf("Invalid schema version %d - must be from 0 to %d", target, len(steps)))
+    }
+    from, err = currentVersion(conn)
+    if err != nil {
+        return 0, err
+    }
+    if from > len(steps) {
+        return from, fmt.Errorf("Database schema version %d is newer than this program (%d)", from, len(steps))
+    }
+    for version := from + 1; version <= target; version++ {
+        if err = applyMigration(conn, steps[version-1], true, "schema_version"); err != nil {
+            return from, err
+        }
+    }
+    for version := from; version > target; version-- {
+        if err = applyMigration(conn, steps[version-1], false, "schema_version"); err != nil {
+            return from, err
+        }
+    }
+    // the full-text index needs the job table, so it goes with it
+    if fts5Available(conn) {
+        fts_target := -1
+        if target == 0 {
+            fts_target = 0
+        }
+        if err = migrateFts(conn, fts_target); err != nil {
+            return from, err
+        }
+    }
+    if target != from {
+        // have the full-text index checked again
+        resetFullText()
+    }
+    return from, nil
+}
+
+// Return false if JOBWIZARD_AUTO_MIGRATE is set to false
+func autoMigrate() bool {
+    setting := os.Getenv("JOBWIZARD_AUTO_MIGRATE")
+    if setting == "" {
+        return true
+    }
+    enabled, err := strconv.ParseBool(setting)
+    return err != nil || enabled
+}
+
+//******** Exported Functions *****************************//
+
+// Move the database schema to the target version, 0 to SchemaVersion's
+// latest, or -1 for the latest. Returns the version before and after
+func Migrate(target int) (from int, to int, err error) {
+    err = firstConnect(dbname, false)
+    if err != nil {
+        return 0, 0, err
+    }
+    from, err = migrateDb(db, target)
+    if err != nil {
+        // some steps may have succeeded before the failure
+        to, _ = currentVersion(db)
+        return from, to, err
+    }
+    to, err = currentVersion(db)
+    return from, to, err
+}
+
+// Return the current schema version of the database and the
+// latest version this program knows about
+func SchemaVersion() (version int, latest int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return 0, 0, err
+    }
+    steps, err := loadMigrations(migrationDir(db))
+    if err != nil {
+        return 0, 0, err
+    }
+    version, err = currentVersion(db)
+    return version, len(steps), err
+}
@@ -0,0 +1,184 @@
+package dbaccess
+// Tests for the schema migrations
+// Each test works on its own database file, not the shared test database
+
+import (
+    "database/sql"
+    "path/filepath"
+    "testing"
+)
+
+// open a new empty database in the test's temporary directory
+func openScratchDb(t *testing.T) *sql.DB {
+    t.Helper()
+    conn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "scratch_db"))
+    if err != nil {
+        t.Fatalf("open failed: %v", err)
+    }
+    t.Cleanup(func() { conn.Close() })
+    return conn
+}
+
+// check the version recorded in schema_version
+func expectVersion(t *testing.T, conn *sql.DB, expected int) {
+    t.Helper()
+    version, err := currentVersion(conn)
+    if err != nil {
+        t.Fatalf("currentVersion failed: %v", err)
+    }
+    if version != expected {
+        t.Fatalf("expected schema version %d, got %d", expected, version)
+    }
+}
+
+func TestMigrationsLoad(t *testing.T) {
+    steps, err := loadMigrations(sqliteMigrations)
+    if err != nil {
+        t.Fatalf("loadMigrations failed: %v", err)
+    }
+    if len(steps) == 0 || steps[0].name != "initial" {
+        t.Fatalf("expected the first migration to be initial, got %+v", steps)
+    }
+}
+
+func TestMigrateUpDownUp(t *testing.T) {
+    conn := openScratchDb(t)
+    steps, _ := loadMigrations(sqliteMigrations)
+    latest := len(steps)
+
+    from, err := migrateDb(conn, -1)
+    if err != nil || from != 0 {
+        t.Fatalf("migrate up from empty: from=%d err=%v", from, err)
+    }
+    expectVersion(t, conn, latest)
+    if !columnExists(conn, "job_application", "status") || !tableExists(conn, "session") {
+        t.Fatalf("latest schema is incomplete")
+    }
+    var count int
+    conn.QueryRow("SELECT COUNT(*) FROM lu_education").Scan(&count)
+    if count != 5 {
+        t.Fatalf("expected 5 education levels, got %d", count)
+    }
+
+    // each step down must undo exactly one step up
+    for target := latest - 1; target >= 0; target-- {
+        if _, err = migrateDb(conn, target); err != nil {
+            t.Fatalf("migrate down to %d failed: %v", target, err)
+        }
+        expectVersion(t, conn, target)
+    }
+    if tableExists(conn, "job") || tableExists(conn, "user") {
+        t.Fatalf("version 0 should have no application tables")
+    }
+
+    if _, err = migrateDb(conn, -1); err != nil {
+        t.Fatalf("migrate up again failed: %v", err)
+    }
+    expectVersion(t, conn, latest)
+
+    // running again changes nothing
+    from, err = migrateDb(conn, -1)
+    if err != nil || from != latest {
+        t.Fatalf("repeat migrate: from=%d err=%v", from, err)
+    }
+}
+
+func TestMigrateInvalidVersion(t *testing.T) {
+    conn := openScratchDb(t)
+    steps, _ := loadMigrations(sqliteMigrations)
+    for _, target := range []int{-2, len(steps) + 1} {
+        if _, err := migrateDb(conn, target); err == nil {
+            t.Errorf("expected an error migrating to %d", target)
+        }
+    }
+}
+
+// A database built by hand before schema_version existed keeps its data
+// and is brought up to date from the version it matches
+func TestAdoptLegacyDatabase(t *testing.T) {
+    conn := openScratchDb(t)
+    steps, _ := loadMigrations(sqliteMigrations)
+    if _, err := conn.Exec(steps[0].up); err != nil {
+        t.Fatalf("legacy setup failed: %v", err)
+    }
+    _, err := conn.Exec("INSERT INTO user (user_email,first_name,last_name) VALUES ('old@example.com','Old','User')")
+    if err != nil {
+        t.Fatalf("legacy insert failed: %v", err)
+    }
+    from, err := migrateDb(conn, -1)
+    if err != nil {
+        t.Fatalf("migrate legacy failed: %v", err)
+    }
+    if from != 1 {
+        t.Fatalf("expected legacy database to be adopted at version 1, got %d", from)
+    }
+    expectVersion(t, conn, len(steps))
+    var email string
+    conn.QueryRow("SELECT user_email FROM user").Scan(&email)
+    if email != "old@example.com" {
+        t.Fatalf("legacy data lost, got %q", email)
+    }
+}
+
+// Time stamps in the old local format are converted to UTC and back
+func TestConvertTimestamps(t *testing.T) {
+    conn := openScratchDb(t)
+    if _, err := migrateDb(conn, 3); err != nil {
+        t.Fatalf("migrate to 3 failed: %v", err)
+    }
+    conn.Exec("INSERT INTO job (title, created) VALUES ('Old Job', '2025-06-27 13:41 +700')")
+    conn.Exec("INSERT INTO user (user_email, created) VALUES ('old@example.com', '2025-06-05')")
+    if _, err := migrateDb(conn, 4); err != nil {
+        t.Fatalf("migrate to 4 failed: %v", err)
+    }
+    var job_created, user_created string
+    conn.QueryRow("SELECT created FROM job").Scan(&job_created)
+    conn.QueryRow("SELECT created FROM user").Scan(&user_created)
+    if job_created != "2025-06-27T06:41:00Z" || user_created != "2025-06-04T17:00:00Z" {
+        t.Errorf("expected UTC time stamps, got %q and %q", job_created, user_created)
+    }
+    if _, err := migrateDb(conn, 3); err != nil {
+        t.Fatalf("migrate back to 3 failed: %v", err)
+    }
+    conn.QueryRow("SELECT created FROM job").Scan(&job_created)
+    if job_created != "2025-06-27 13:41 +700" {
+        t.Errorf("expected the old format back, got %q", job_created)
+    }
+}
+
+// With FTS5 the full-text index is created with the schema, removed with
+// it, and rebuilt if jobs were changed by a build that could not update it
+func TestFullTextIndexMigration(t *testing.T) {
+    conn := openScratchDb(t)
+    if !fts5Available(conn) {
+        t.Skip("SQLite was built without FTS5; run with -tags sqlite_fts5")
+    }
+    if _, err := migrateDb(conn, -1); err != nil {
+        t.Fatalf("migrate up failed: %v", err)
+    }
+    if !tableExists(conn, "job_fts") {
+        t.Fatalf("the full-text index should be created")
+    }
+    // one job deleted and another added without the index knowing
+    conn.Exec("INSERT INTO job (id, title, description) VALUES (1, 'Welder', 'Welds pipes')")
+    conn.Exec("INSERT INTO job_fts (rowid, title, description) VALUES (1, 'Welder', 'Welds pipes')")
+    conn.Exec("DELETE FROM job WHERE id=1")
+    conn.Exec("INSERT INTO job (id, title, description) VALUES (2, 'Glazier', 'Fits windows')")
+    if err := syncFullText(conn); err != nil {
+        t.Fatalf("syncFullText failed: %v", err)
+    }
+    var title string
+    conn.QueryRow("SELECT title FROM job_fts WHERE job_fts MATCH 'windows'").Scan(&title)
+    var count int
+    conn.QueryRow("SELECT COUNT(*) FROM job_fts").Scan(&count)
+    if title != "Glazier" || count != 1 {
+        t.Errorf("expected the index to be rebuilt, got %q and %d rows", title, count)
+    }
+
+    if _, err := migrateDb(conn, 0); err != nil {
+        t.Fatalf("migrate down failed: %v", err)
+    }
+    if tableExists(conn, "job_fts") {
+        t.Errorf("the full-text index should be removed with the schema")
+    }
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS job_application;
+DROP TABLE IF EXISTS job;
+DROP TABLE IF EXISTS user;
+DROP TABLE IF EXISTS lu_education;
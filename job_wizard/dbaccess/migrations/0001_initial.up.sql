@@ -1,4 +1,4 @@
--- Script for initializing the JobWizard database
+-- Initial JobWizard schema, formerly database/init_tables.sql
 -- 
 -- Created by Sally Goldin, 18 June 2025
 --
@@ -50,4 +50,3 @@ CREATE TABLE IF NOT EXISTS job_application (
 	apply_time varchar(32),
 	UNIQUE(job_id, user_email)  -- You can only apply once for a job
 );
-
@@ -0,0 +1,11 @@
+-- Passwords and login sessions for the REST API
+
+ALTER TABLE user ADD COLUMN password_hash varchar(64) default '';  -- bcrypt hash, never the password itself
+
+CREATE TABLE IF NOT EXISTS session (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	token varchar(64) UNIQUE,  -- random bearer token given to the client
+	user_email varchar(32),    -- user who logged in
+	created varchar(32),
+	expires integer            -- unix time after which the token is invalid
+);
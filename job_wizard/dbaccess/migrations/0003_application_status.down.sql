@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS application_status;
+ALTER TABLE job_application DROP COLUMN status_time;
+ALTER TABLE job_application DROP COLUMN status;
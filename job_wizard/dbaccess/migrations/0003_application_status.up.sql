@@ -0,0 +1,16 @@
+-- Application lifecycle
+-- Statuses are submitted, reviewed, shortlisted, interviewing, offered,
+-- hired, rejected and withdrawn. The last three are final.
+
+ALTER TABLE job_application ADD COLUMN status varchar(16) default 'submitted';
+ALTER TABLE job_application ADD COLUMN status_time varchar(32);  -- when the status last changed
+
+-- Every status an application has passed through, oldest first
+CREATE TABLE IF NOT EXISTS application_status (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	job_id int,
+	user_email varchar(32),  -- applicant
+	status varchar(16),
+	changed_by varchar(32),  -- email of the user who made the change
+	change_time varchar(32)
+);
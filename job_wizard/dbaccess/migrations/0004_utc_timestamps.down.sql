@@ -0,0 +1,16 @@
+-- Back to local time stamps with a fixed +700 suffix
+
+UPDATE user SET created = strftime('%Y-%m-%d %H:%M +700', created, '+7 hours')
+    WHERE created LIKE '____-__-__T__:__:__Z';
+UPDATE job SET created = strftime('%Y-%m-%d %H:%M +700', created, '+7 hours')
+    WHERE created LIKE '____-__-__T__:__:__Z';
+UPDATE job_application SET apply_time = strftime('%Y-%m-%d %H:%M +700', apply_time, '+7 hours')
+    WHERE apply_time LIKE '____-__-__T__:__:__Z';
+UPDATE job_application SET status_time = strftime('%Y-%m-%d %H:%M +700', status_time, '+7 hours')
+    WHERE status_time LIKE '____-__-__T__:__:__Z';
+UPDATE application_status SET change_time = strftime('%Y-%m-%d %H:%M +700', change_time, '+7 hours')
+    WHERE change_time LIKE '____-__-__T__:__:__Z';
+UPDATE session SET created = strftime('%Y-%m-%d %H:%M +700', created, '+7 hours')
+    WHERE created LIKE '____-__-__T__:__:__Z';
+UPDATE schema_version SET applied = strftime('%Y-%m-%d %H:%M +700', applied, '+7 hours')
+    WHERE applied LIKE '____-__-__T__:__:__Z';
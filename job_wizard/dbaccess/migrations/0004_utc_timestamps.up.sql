@@ -0,0 +1,21 @@
+-- Time stamps in UTC
+-- Time stamps used to be stored in local time with a fixed "+700" on the
+-- end, such as 2025-06-27 13:41 +700, or as a plain date. They are now
+-- ISO-8601 in UTC, such as 2025-06-27T06:41:00Z, which sorts correctly
+-- and can be shown in any time zone. The old values are taken to be
+-- UTC+7, as their suffix says
+
+UPDATE user SET created = strftime('%Y-%m-%dT%H:%M:%SZ', substr(created,1,16), '-7 hours')
+    WHERE created LIKE '____-__-__ __:__ +700' OR created LIKE '____-__-__';
+UPDATE job SET created = strftime('%Y-%m-%dT%H:%M:%SZ', substr(created,1,16), '-7 hours')
+    WHERE created LIKE '____-__-__ __:__ +700' OR created LIKE '____-__-__';
+UPDATE job_application SET apply_time = strftime('%Y-%m-%dT%H:%M:%SZ', substr(apply_time,1,16), '-7 hours')
+    WHERE apply_time LIKE '____-__-__ __:__ +700' OR apply_time LIKE '____-__-__';
+UPDATE job_application SET status_time = strftime('%Y-%m-%dT%H:%M:%SZ', substr(status_time,1,16), '-7 hours')
+    WHERE status_time LIKE '____-__-__ __:__ +700' OR status_time LIKE '____-__-__';
+UPDATE application_status SET change_time = strftime('%Y-%m-%dT%H:%M:%SZ', substr(change_time,1,16), '-7 hours')
+    WHERE change_time LIKE '____-__-__ __:__ +700' OR change_time LIKE '____-__-__';
+UPDATE session SET created = strftime('%Y-%m-%dT%H:%M:%SZ', substr(created,1,16), '-7 hours')
+    WHERE created LIKE '____-__-__ __:__ +700' OR created LIKE '____-__-__';
+UPDATE schema_version SET applied = strftime('%Y-%m-%dT%H:%M:%SZ', substr(applied,1,16), '-7 hours')
+    WHERE applied LIKE '____-__-__ __:__ +700' OR applied LIKE '____-__-__';
@@ -0,0 +1,7 @@
+-- Job expiry and archiving
+-- A job with an expiry time is closed once that time has passed. An
+-- archived job is closed and no longer appears in searches, but is kept
+-- so applicants can still see what they applied for
+
+ALTER TABLE job ADD COLUMN expires_on varchar(32) default '';  -- UTC time stamp, or '' for none
+ALTER TABLE job ADD COLUMN is_archived integer default 0;      -- 1 once archived by the creator
@@ -0,0 +1,3 @@
+ALTER TABLE job DROP COLUMN organization_id;
+DROP TABLE IF EXISTS organization_member;
+DROP TABLE IF EXISTS organization;
@@ -0,0 +1,25 @@
+-- Employer organizations
+-- Users belong to organizations as owners or recruiters. Every member
+-- can post jobs for the organization and manage each other's postings;
+-- only owners can change the organization and its members
+
+CREATE TABLE IF NOT EXISTS organization (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name varchar(64) UNIQUE,
+	description varchar(1024),
+	website varchar(256),
+	logo_url varchar(256),
+	location varchar(64),
+	created varchar(32)
+);
+
+CREATE TABLE IF NOT EXISTS organization_member (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	organization_id int,
+	user_email varchar(32),
+	role varchar(16),        -- owner or recruiter
+	added varchar(32),
+	UNIQUE(organization_id, user_email)
+);
+
+ALTER TABLE job ADD COLUMN organization_id int default 0;  -- 0 if the job belongs to no organization
@@ -0,0 +1,6 @@
+ALTER TABLE job DROP COLUMN employment_type;
+ALTER TABLE job DROP COLUMN work_arrangement;
+ALTER TABLE job DROP COLUMN longitude;
+ALTER TABLE job DROP COLUMN latitude;
+ALTER TABLE job DROP COLUMN city;
+ALTER TABLE job DROP COLUMN province;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_skill;
+DROP TABLE IF EXISTS job_skill;
+DROP TABLE IF EXISTS skill;
@@ -0,0 +1,26 @@
+-- Skills
+-- The skill table lists every skill named by a job or a user, in lower
+-- case. Jobs list required and nice-to-have skills; users list the
+-- skills they have, with a proficiency from 1 (beginner) to 5 (expert)
+
+CREATE TABLE IF NOT EXISTS skill (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name varchar(32) UNIQUE,
+	created varchar(32)
+);
+
+CREATE TABLE IF NOT EXISTS job_skill (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	job_id int,
+	skill_id int,
+	required integer default 0,   -- 1 if required, 0 if nice to have
+	UNIQUE(job_id, skill_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_skill (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_email varchar(32),
+	skill_id int,
+	proficiency int,
+	UNIQUE(user_email, skill_id)
+);
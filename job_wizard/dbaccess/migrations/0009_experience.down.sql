@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS work_history;
+ALTER TABLE user DROP COLUMN years_experience;
@@ -0,0 +1,15 @@
+-- Candidate experience
+-- Users give their years of work experience, which is compared with a
+-- job's min_experience, and a history of the jobs they have held
+-- Dates are YYYY-MM-DD; end_date is '' for a job the user still has
+
+ALTER TABLE user ADD COLUMN years_experience integer default 0;
+
+CREATE TABLE IF NOT EXISTS work_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_email varchar(32),
+	title varchar(64),
+	employer varchar(64),
+	start_date varchar(32),
+	end_date varchar(32) default ''
+);
@@ -0,0 +1,4 @@
+ALTER TABLE job_application DROP COLUMN cover_letter;
+ALTER TABLE job_application DROP COLUMN resume_id;
+ALTER TABLE user DROP COLUMN resume_id;
+DROP TABLE IF EXISTS resume;
@@ -0,0 +1,19 @@
+-- Application attachments
+-- Users can upload a resume with an application, and keep a default
+-- resume on their profile which is used when they apply without one.
+-- The resume table describes each file; the content is kept in the
+-- blob store under blob_key. A resume_id of 0 means no resume
+
+CREATE TABLE IF NOT EXISTS resume (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_email varchar(32),
+	file_name varchar(128),
+	content_type varchar(128),
+	size_bytes integer,
+	blob_key varchar(64),
+	created varchar(32)
+);
+
+ALTER TABLE user ADD COLUMN resume_id integer default 0;
+ALTER TABLE job_application ADD COLUMN resume_id integer default 0;
+ALTER TABLE job_application ADD COLUMN cover_letter text default '';
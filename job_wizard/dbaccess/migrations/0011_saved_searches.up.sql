@@ -0,0 +1,15 @@
+-- Saved searches
+-- Users can keep named job searches to run again. criteria holds the
+-- search criteria as JSON. Jobs with an id above seen_job_id were
+-- posted since the search was last run, and are its new matches
+
+CREATE TABLE IF NOT EXISTS saved_search (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_email varchar(32),
+	name varchar(64),
+	criteria text,
+	seen_job_id integer default 0,
+	created varchar(32),
+	last_viewed varchar(32),
+	UNIQUE(user_email, name)
+);
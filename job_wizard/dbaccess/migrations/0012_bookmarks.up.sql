@@ -0,0 +1,12 @@
+-- Bookmarks
+-- Users can bookmark jobs to come back to without applying. filled
+-- records when a bookmarked job was filled, or is '' while it is open
+
+CREATE TABLE IF NOT EXISTS bookmark (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_email varchar(32),
+	job_id int,
+	created varchar(32),
+	filled varchar(32) default '',
+	UNIQUE(user_email, job_id)
+);
@@ -0,0 +1,31 @@
+-- Webhooks
+-- Employers register URLs to be told about events on the jobs they
+-- created, or owners on the jobs of their organization (organization_id
+-- is 0 otherwise). events is a comma-separated list of event names
+-- Each event is queued as a webhook_delivery in the same transaction as
+-- the change, and sent by the server, which retries failed deliveries
+-- at next_attempt until they succeed or run out of attempts
+
+CREATE TABLE IF NOT EXISTS webhook (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_email varchar(32),
+	organization_id int default 0,
+	url varchar(256),
+	events varchar(256),
+	secret varchar(64),
+	created varchar(32)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	webhook_id int,
+	event varchar(32),
+	payload text,
+	status varchar(16),      -- pending, delivered or failed
+	attempts int default 0,
+	next_attempt varchar(32) default '',
+	response_code int default 0,
+	last_error varchar(256) default '',
+	created varchar(32),
+	delivered varchar(32) default ''
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS notification_outbox;
+DROP TABLE IF EXISTS notification_preference;
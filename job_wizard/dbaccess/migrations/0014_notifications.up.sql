@@ -0,0 +1,34 @@
+-- Email notifications
+-- Job creators are told when someone applies for their job, and
+-- applicants when they are hired or rejected. A user without a
+-- notification_preference row gets every kind, in English
+-- Each email is queued in notification_outbox in the same transaction as
+-- the change, and sent by the server, which retries failed emails at
+-- next_attempt until they are sent or run out of attempts
+
+CREATE TABLE IF NOT EXISTS notification_preference (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_email varchar(32),
+	language varchar(8) default 'en',        -- en or th
+	application_received integer default 1,  -- 1 to be told, 0 not
+	hired integer default 1,
+	rejected integer default 1
+);
+
+CREATE TABLE IF NOT EXISTS notification_outbox (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	recipient varchar(32),
+	name varchar(32),            -- first name of the recipient
+	kind varchar(32),
+	language varchar(8),
+	job_id int,
+	title varchar(64),
+	applicant varchar(32) default '',
+	applicant_name varchar(64) default '',
+	status varchar(16),          -- pending, delivered or failed
+	attempts int default 0,
+	next_attempt varchar(32) default '',
+	last_error varchar(256) default '',
+	created varchar(32),
+	sent varchar(32) default ''
+);
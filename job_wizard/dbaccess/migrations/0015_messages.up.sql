@@ -0,0 +1,13 @@
+-- Messages between an applicant and the creator of the job they applied
+-- for. Each application has one thread, identified by job_id and
+-- applicant. read_time is when the recipient read the message, '' until then
+
+CREATE TABLE IF NOT EXISTS message (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	job_id int,
+	applicant varchar(32),
+	sender varchar(32),
+	body varchar(2000),
+	created varchar(32),
+	read_time varchar(32) default ''
+);
@@ -0,0 +1,5 @@
+-- Full-text index over job titles and descriptions, used by keyword
+-- search. Only applied when SQLite is built with FTS5 (see search.go)
+CREATE VIRTUAL TABLE IF NOT EXISTS job_fts USING fts5(title, description);
+DELETE FROM job_fts;
+INSERT INTO job_fts (rowid, title, description) SELECT id, title, description FROM job;
@@ -0,0 +1,53 @@
+-- Initial JobWizard schema for PostgreSQL
+-- The same as ../0001_initial.up.sql except that ids are SERIAL,
+-- is_open is a boolean, and user must be quoted because it is a
+-- reserved word. Text uses the "C" collation so that it sorts the
+-- same way as in SQLite, which matters for the time stamp strings
+--
+
+-- Look up table provides text for education level 
+CREATE TABLE IF NOT EXISTS lu_education (
+	value int,
+	title varchar(24) COLLATE "C"
+);
+
+-- Populate this lookup table
+INSERT INTO lu_education (value,title) values (0,'Unknown');
+INSERT INTO lu_education (value,title) values (1,'High school diploma');
+INSERT INTO lu_education (value,title) values (2,'Bachelors degree');
+INSERT INTO lu_education (value,title) values (3,'Masters degree');
+INSERT INTO lu_education (value,title) values (4,'Doctoral degree');
+
+-- Registered users
+CREATE TABLE IF NOT EXISTS "user" (
+	id SERIAL PRIMARY KEY,
+	user_email varchar(32) COLLATE "C",  -- user email, which is the identifier for a user	
+	first_name varchar(32) COLLATE "C",  
+	last_name  varchar(32) COLLATE "C",  
+	phone varchar(16) COLLATE "C",
+	max_education integer,
+	created varchar(32) COLLATE "C"
+);
+
+-- Job descriptions
+
+CREATE TABLE IF NOT EXISTS job (
+	id SERIAL PRIMARY KEY,
+	created_by varchar(32) COLLATE "C",  -- email of creating user (employer)	
+	title varchar(64) COLLATE "C",  
+	description varchar(1024) COLLATE "C",  
+	min_education integer,
+	min_years_experience integer,
+	salary integer,
+	hired_person varchar(32) COLLATE "C" default '',    -- email of person hired
+	is_open boolean default true,   -- false once filled
+	created varchar(32) COLLATE "C"
+);
+
+CREATE TABLE IF NOT EXISTS job_application (
+	id SERIAL PRIMARY KEY,
+	job_id int,              -- job ID with leading zeros
+	user_email varchar(32) COLLATE "C",  -- user who has applied
+	apply_time varchar(32) COLLATE "C",
+	UNIQUE(job_id, user_email)  -- You can only apply once for a job
+);
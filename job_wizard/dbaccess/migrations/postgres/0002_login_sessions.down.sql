@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS session;
+ALTER TABLE "user" DROP COLUMN password_hash;
@@ -0,0 +1,11 @@
+-- Passwords and login sessions for the REST API
+
+ALTER TABLE "user" ADD COLUMN password_hash varchar(64) COLLATE "C" default '';  -- bcrypt hash, never the password itself
+
+CREATE TABLE IF NOT EXISTS session (
+	id SERIAL PRIMARY KEY,
+	token varchar(64) COLLATE "C" UNIQUE,  -- random bearer token given to the client
+	user_email varchar(32) COLLATE "C",    -- user who logged in
+	created varchar(32) COLLATE "C",
+	expires bigint             -- unix time after which the token is invalid
+);
@@ -0,0 +1,16 @@
+-- Application lifecycle
+-- Statuses are submitted, reviewed, shortlisted, interviewing, offered,
+-- hired, rejected and withdrawn. The last three are final.
+
+ALTER TABLE job_application ADD COLUMN status varchar(16) COLLATE "C" default 'submitted';
+ALTER TABLE job_application ADD COLUMN status_time varchar(32) COLLATE "C";  -- when the status last changed
+
+-- Every status an application has passed through, oldest first
+CREATE TABLE IF NOT EXISTS application_status (
+	id SERIAL PRIMARY KEY,
+	job_id int,
+	user_email varchar(32) COLLATE "C",  -- applicant
+	status varchar(16) COLLATE "C",
+	changed_by varchar(32) COLLATE "C",  -- email of the user who made the change
+	change_time varchar(32) COLLATE "C"
+);
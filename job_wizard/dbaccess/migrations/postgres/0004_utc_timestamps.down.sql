@@ -0,0 +1,16 @@
+-- Back to local time stamps with a fixed +700 suffix
+
+UPDATE "user" SET created = to_char(substr(created,1,19)::timestamp + interval '7 hours', 'YYYY-MM-DD HH24:MI "+700"')
+    WHERE created LIKE '____-__-__T__:__:__Z';
+UPDATE job SET created = to_char(substr(created,1,19)::timestamp + interval '7 hours', 'YYYY-MM-DD HH24:MI "+700"')
+    WHERE created LIKE '____-__-__T__:__:__Z';
+UPDATE job_application SET apply_time = to_char(substr(apply_time,1,19)::timestamp + interval '7 hours', 'YYYY-MM-DD HH24:MI "+700"')
+    WHERE apply_time LIKE '____-__-__T__:__:__Z';
+UPDATE job_application SET status_time = to_char(substr(status_time,1,19)::timestamp + interval '7 hours', 'YYYY-MM-DD HH24:MI "+700"')
+    WHERE status_time LIKE '____-__-__T__:__:__Z';
+UPDATE application_status SET change_time = to_char(substr(change_time,1,19)::timestamp + interval '7 hours', 'YYYY-MM-DD HH24:MI "+700"')
+    WHERE change_time LIKE '____-__-__T__:__:__Z';
+UPDATE session SET created = to_char(substr(created,1,19)::timestamp + interval '7 hours', 'YYYY-MM-DD HH24:MI "+700"')
+    WHERE created LIKE '____-__-__T__:__:__Z';
+UPDATE schema_version SET applied = to_char(substr(applied,1,19)::timestamp + interval '7 hours', 'YYYY-MM-DD HH24:MI "+700"')
+    WHERE applied LIKE '____-__-__T__:__:__Z';
@@ -0,0 +1,21 @@
+-- Time stamps in UTC
+-- Time stamps used to be stored in local time with a fixed "+700" on the
+-- end, such as 2025-06-27 13:41 +700, or as a plain date. They are now
+-- ISO-8601 in UTC, such as 2025-06-27T06:41:00Z, which sorts correctly
+-- and can be shown in any time zone. The old values are taken to be
+-- UTC+7, as their suffix says
+
+UPDATE "user" SET created = to_char(substr(created,1,16)::timestamp - interval '7 hours', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+    WHERE created LIKE '____-__-__ __:__ +700' OR created LIKE '____-__-__';
+UPDATE job SET created = to_char(substr(created,1,16)::timestamp - interval '7 hours', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+    WHERE created LIKE '____-__-__ __:__ +700' OR created LIKE '____-__-__';
+UPDATE job_application SET apply_time = to_char(substr(apply_time,1,16)::timestamp - interval '7 hours', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+    WHERE apply_time LIKE '____-__-__ __:__ +700' OR apply_time LIKE '____-__-__';
+UPDATE job_application SET status_time = to_char(substr(status_time,1,16)::timestamp - interval '7 hours', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+    WHERE status_time LIKE '____-__-__ __:__ +700' OR status_time LIKE '____-__-__';
+UPDATE application_status SET change_time = to_char(substr(change_time,1,16)::timestamp - interval '7 hours', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+    WHERE change_time LIKE '____-__-__ __:__ +700' OR change_time LIKE '____-__-__';
+UPDATE session SET created = to_char(substr(created,1,16)::timestamp - interval '7 hours', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+    WHERE created LIKE '____-__-__ __:__ +700' OR created LIKE '____-__-__';
+UPDATE schema_version SET applied = to_char(substr(applied,1,16)::timestamp - interval '7 hours', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+    WHERE applied LIKE '____-__-__ __:__ +700' OR applied LIKE '____-__-__';
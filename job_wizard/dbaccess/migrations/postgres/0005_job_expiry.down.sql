@@ -0,0 +1,2 @@
+ALTER TABLE job DROP COLUMN is_archived;
+ALTER TABLE job DROP COLUMN expires_on;
@@ -0,0 +1,25 @@
+-- Employer organizations
+-- Users belong to organizations as owners or recruiters. Every member
+-- can post jobs for the organization and manage each other's postings;
+-- only owners can change the organization and its members
+
+CREATE TABLE IF NOT EXISTS organization (
+	id SERIAL PRIMARY KEY,
+	name varchar(64) COLLATE "C" UNIQUE,
+	description varchar(1024) COLLATE "C",
+	website varchar(256) COLLATE "C",
+	logo_url varchar(256) COLLATE "C",
+	location varchar(64) COLLATE "C",
+	created varchar(32) COLLATE "C"
+);
+
+CREATE TABLE IF NOT EXISTS organization_member (
+	id SERIAL PRIMARY KEY,
+	organization_id int,
+	user_email varchar(32) COLLATE "C",
+	role varchar(16) COLLATE "C",        -- owner or recruiter
+	added varchar(32) COLLATE "C",
+	UNIQUE(organization_id, user_email)
+);
+
+ALTER TABLE job ADD COLUMN organization_id int default 0;  -- 0 if the job belongs to no organization
@@ -0,0 +1,10 @@
+-- Job location, work arrangement and employment type
+-- Latitude and longitude are both 0 when the position is not known;
+-- work_arrangement and employment_type are '' when not stated
+
+ALTER TABLE job ADD COLUMN province varchar(64) COLLATE "C" default '';
+ALTER TABLE job ADD COLUMN city varchar(64) COLLATE "C" default '';
+ALTER TABLE job ADD COLUMN latitude double precision default 0;
+ALTER TABLE job ADD COLUMN longitude double precision default 0;
+ALTER TABLE job ADD COLUMN work_arrangement varchar(16) COLLATE "C" default '';  -- onsite, hybrid or remote
+ALTER TABLE job ADD COLUMN employment_type varchar(16) COLLATE "C" default '';   -- full-time, part-time, contract or internship
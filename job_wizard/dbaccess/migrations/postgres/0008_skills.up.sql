@@ -0,0 +1,26 @@
+-- Skills
+-- The skill table lists every skill named by a job or a user, in lower
+-- case. Jobs list required and nice-to-have skills; users list the
+-- skills they have, with a proficiency from 1 (beginner) to 5 (expert)
+
+CREATE TABLE IF NOT EXISTS skill (
+	id SERIAL PRIMARY KEY,
+	name varchar(32) COLLATE "C" UNIQUE,
+	created varchar(32) COLLATE "C"
+);
+
+CREATE TABLE IF NOT EXISTS job_skill (
+	id SERIAL PRIMARY KEY,
+	job_id int,
+	skill_id int,
+	required boolean default false,
+	UNIQUE(job_id, skill_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_skill (
+	id SERIAL PRIMARY KEY,
+	user_email varchar(32) COLLATE "C",
+	skill_id int,
+	proficiency int,
+	UNIQUE(user_email, skill_id)
+);
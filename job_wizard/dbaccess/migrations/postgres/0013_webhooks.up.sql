@@ -0,0 +1,31 @@
+-- Webhooks
+-- Employers register URLs to be told about events on the jobs they
+-- created, or owners on the jobs of their organization (organization_id
+-- is 0 otherwise). events is a comma-separated list of event names
+-- Each event is queued as a webhook_delivery in the same transaction as
+-- the change, and sent by the server, which retries failed deliveries
+-- at next_attempt until they succeed or run out of attempts
+
+CREATE TABLE IF NOT EXISTS webhook (
+	id SERIAL PRIMARY KEY,
+	user_email varchar(32) COLLATE "C",
+	organization_id int default 0,
+	url varchar(256) COLLATE "C",
+	events varchar(256) COLLATE "C",
+	secret varchar(64) COLLATE "C",
+	created varchar(32) COLLATE "C"
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+	id SERIAL PRIMARY KEY,
+	webhook_id int,
+	event varchar(32) COLLATE "C",
+	payload text COLLATE "C",
+	status varchar(16) COLLATE "C",      -- pending, delivered or failed
+	attempts int default 0,
+	next_attempt varchar(32) COLLATE "C" default '',
+	response_code int default 0,
+	last_error varchar(256) COLLATE "C" default '',
+	created varchar(32) COLLATE "C",
+	delivered varchar(32) COLLATE "C" default ''
+);
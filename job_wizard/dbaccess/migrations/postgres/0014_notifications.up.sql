@@ -0,0 +1,34 @@
+-- Email notifications
+-- Job creators are told when someone applies for their job, and
+-- applicants when they are hired or rejected. A user without a
+-- notification_preference row gets every kind, in English
+-- Each email is queued in notification_outbox in the same transaction as
+-- the change, and sent by the server, which retries failed emails at
+-- next_attempt until they are sent or run out of attempts
+
+CREATE TABLE IF NOT EXISTS notification_preference (
+	id SERIAL PRIMARY KEY,
+	user_email varchar(32) COLLATE "C",
+	language varchar(8) COLLATE "C" default 'en',   -- en or th
+	application_received boolean default true,
+	hired boolean default true,
+	rejected boolean default true
+);
+
+CREATE TABLE IF NOT EXISTS notification_outbox (
+	id SERIAL PRIMARY KEY,
+	recipient varchar(32) COLLATE "C",
+	name varchar(32) COLLATE "C",            -- first name of the recipient
+	kind varchar(32) COLLATE "C",
+	language varchar(8) COLLATE "C",
+	job_id int,
+	title varchar(64) COLLATE "C",
+	applicant varchar(32) COLLATE "C" default '',
+	applicant_name varchar(64) COLLATE "C" default '',
+	status varchar(16) COLLATE "C",          -- pending, delivered or failed
+	attempts int default 0,
+	next_attempt varchar(32) COLLATE "C" default '',
+	last_error varchar(256) COLLATE "C" default '',
+	created varchar(32) COLLATE "C",
+	sent varchar(32) COLLATE "C" default ''
+);
@@ -0,0 +1,13 @@
+-- Messages between an applicant and the creator of the job they applied
+-- for. Each application has one thread, identified by job_id and
+-- applicant. read_time is when the recipient read the message, '' until then
+
+CREATE TABLE IF NOT EXISTS message (
+	id SERIAL PRIMARY KEY,
+	job_id int,
+	applicant varchar(32) COLLATE "C",
+	sender varchar(32) COLLATE "C",
+	body varchar(2000) COLLATE "C",
+	created varchar(32) COLLATE "C",
+	read_time varchar(32) COLLATE "C" default ''
+);
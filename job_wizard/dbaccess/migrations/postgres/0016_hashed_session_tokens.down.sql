@@ -0,0 +1,2 @@
+-- The hashed tokens cannot be turned back into tokens
+DELETE FROM session;
@@ -0,0 +1,5 @@
+-- Session tokens are now stored as the hex SHA-256 of the token given
+-- to the client, so the database alone does not hold usable tokens.
+-- Sessions started before this cannot be converted; their users log in again
+
+DELETE FROM session;
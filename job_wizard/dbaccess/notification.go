@@ -0,0 +1,234 @@
+package dbaccess
+// This module holds the database functions for email notifications:
+// job creators are told when someone applies for one of their jobs, and
+// applicants when they are hired or rejected, unless they have turned
+// that kind off in their preferences
+// Like webhook deliveries, each email is queued in the outbox in the same
+// transaction as the change, and sent later by the notify package, which
+// records the outcome of each attempt here
+
+import (
+    "database/sql"
+    "fmt"
+    "strconv"
+    "strings"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+//**************** Private Functions *******************************//
+
+// Turn a notification id into a string with leading zeros
+func notificationIdString(idval int) string {
+    return fmt.Sprintf("%05d", idval)
+}
+
+// Return the kind of email sent when an application moves to a status,
+// or "" if that status sends none
+func notificationKind(status string) string {
+    switch status {
+        case data.StatusSubmitted:
+            return data.NotifyApplicationReceived
+        case data.StatusHired:
+            return data.NotifyHired
+        case data.StatusRejected:
+            return data.NotifyRejected
+    }
+    return ""
+}
+
+// The preferences of a user who has never changed them
+func defaultPreferences() data.Notification_preferences {
+    return data.Notification_preferences{Language: data.LanguageEnglish, Application_received: true,
+                                         Hired: true, Rejected: true}
+}
+
+// Return true if a user with these preferences wants this kind of email
+func wantsNotification(prefs data.Notification_preferences, kind string) bool {
+    switch kind {
+        case data.NotifyApplicationReceived:
+            return prefs.Application_received
+        case data.NotifyHired:
+            return prefs.Hired
+        case data.NotifyRejected:
+            return prefs.Rejected
+    }
+    return false
+}
+
+// Apply the changes that were given to a user's preferences
+func changePreferences(prefs data.Notification_preferences, changes data.Notification_changes) data.Notification_preferences {
+    if changes.Language != nil {
+        prefs.Language = *changes.Language
+    }
+    if changes.Application_received != nil {
+        prefs.Application_received = *changes.Application_received
+    }
+    if changes.Hired != nil {
+        prefs.Hired = *changes.Hired
+    }
+    if changes.Rejected != nil {
+        prefs.Rejected = *changes.Rejected
+    }
+    return prefs
+}
+
+// Read a user's notification preferences, or the defaults if they
+// have never changed them
+func notificationPreferences(conn queryer, user_email string) (prefs data.Notification_preferences, err error) {
+    sqlcmd := "SELECT language, application_received, hired, rejected FROM notification_preference WHERE user_email=?"
+    err = conn.QueryRow(sqlcmd, user_email).Scan(&prefs.Language, &prefs.Application_received, &prefs.Hired, &prefs.Rejected)
+    if err == sql.ErrNoRows {
+        return defaultPreferences(), nil
+    }
+    return prefs, err
+}
+
+// Queue the email, if any, for an application that has just moved to
+// status: to the job's creator for a new application, or to the
+// applicant when they are hired or rejected. Nothing is queued if the
+// recipient has deleted their account or turned that kind off
+// Called inside the transaction that changes the status
+func queueNotification(conn sqlConn, job_idval int, applicant string, status string) (err error) {
+    kind := notificationKind(status)
+    if kind == "" {
+        return nil
+    }
+    var created_by string
+    var title string
+    err = conn.QueryRow("SELECT created_by, title FROM job WHERE id=?", job_idval).Scan(&created_by, &title)
+    if err != nil {
+        return err
+    }
+    recipient := applicant
+    if kind == data.NotifyApplicationReceived {
+        recipient = created_by
+    }
+    var name string
+    err = conn.QueryRow("SELECT first_name FROM user WHERE user_email=?", recipient).Scan(&name)
+    if err == sql.ErrNoRows {
+        return nil
+    } else if err != nil {
+        return err
+    }
+    prefs, err := notificationPreferences(conn, recipient)
+    if err != nil || !wantsNotification(prefs, kind) {
+        return err
+    }
+    applicant_email := ""
+    applicant_name := ""
+    if kind == data.NotifyApplicationReceived {
+        var first_name string
+        var last_name string
+        err = conn.QueryRow("SELECT first_name, last_name FROM user WHERE user_email=?", applicant).Scan(&first_name, &last_name)
+        if err != nil {
+            return err
+        }
+        applicant_email = applicant
+        applicant_name = strings.TrimSpace(first_name + " " + last_name)
+    }
+    nowstring := data.StoredNow()
+    sqlcmd := "INSERT INTO notification_outbox (recipient, name, kind, language, job_id, title, applicant, applicant_name,"
+    sqlcmd += " status, attempts, next_attempt, created) values (?,?,?,?,?,?,?,?,?,0,?,?)"
+    _, err = conn.Exec(sqlcmd, recipient, name, kind, prefs.Language, job_idval, title, applicant_email, applicant_name,
+                       data.DeliveryPending, nowstring, nowstring)
+    return err
+}
+
+//******** Exported Functions *****************************//
+
+// Function to return a user's notification preferences
+func (store *sqlStore) GetNotificationPreferences(user_email string) (prefs data.Notification_preferences, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return prefs, err
+    }
+    return notificationPreferences(db, user_email)
+}
+
+// Function to change a user's notification preferences. Only the fields
+// given in changes, which have already been validated, are changed
+// Returns the preferences after the change
+func (store *sqlStore) SetNotificationPreferences(changes data.Notification_changes) (prefs data.Notification_preferences, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return prefs, err
+    }
+    tx, err := db.Begin()
+    if err != nil {
+        return prefs, err
+    }
+    prefs, err = notificationPreferences(tx, changes.User_email)
+    if err != nil {
+        tx.Rollback()
+        return prefs, err
+    }
+    prefs = changePreferences(prefs, changes)
+    sqlcmd := "UPDATE notification_preference SET language=?, application_received=?, hired=?, rejected=? WHERE user_email=?"
+    result, err := tx.Exec(sqlcmd, prefs.Language, prefs.Application_received, prefs.Hired, prefs.Rejected, changes.User_email)
+    if err != nil {
+        tx.Rollback()
+        return prefs, err
+    }
+    if count, _ := result.RowsAffected(); count == 0 {
+        sqlcmd = "INSERT INTO notification_preference (user_email, language, application_received, hired, rejected) values (?,?,?,?,?)"
+        _, err = tx.Exec(sqlcmd, changes.User_email, prefs.Language, prefs.Application_received, prefs.Hired, prefs.Rejected)
+        if err != nil {
+            tx.Rollback()
+            return prefs, err
+        }
+    }
+    return prefs, tx.Commit()
+}
+
+// Function to return up to limit pending emails whose next attempt
+// is due, the longest waiting first
+func (store *sqlStore) DueNotifications(limit int) (notifications []data.Notification, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return notifications, err
+    }
+    sqlcmd := "SELECT id, recipient, name, kind, language, job_id, title, applicant, applicant_name, attempts, created"
+    sqlcmd += " FROM notification_outbox WHERE status=? AND next_attempt<=? ORDER BY next_attempt, id LIMIT ?"
+    rows, err := db.Query(sqlcmd, data.DeliveryPending, data.StoredNow(), limit)
+    if err != nil {
+        return notifications, err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var notification data.Notification
+        var idval int
+        var job_idval int
+        err = rows.Scan(&idval, &notification.Recipient, &notification.Name, &notification.Kind, &notification.Language,
+                        &job_idval, &notification.Title, &notification.Applicant, &notification.Applicant_name,
+                        &notification.Attempts, &notification.Created)
+        if err != nil {
+            return nil, err
+        }
+        notification.Notification_id = notificationIdString(idval)
+        notification.Job_id = fmt.Sprintf("%05d", job_idval)
+        notification.Created = data.DisplayTime(notification.Created)
+        notifications = append(notifications, notification)
+    }
+    return notifications, nil
+}
+
+// Function to record the outcome of an attempt to send an email. One
+// that was not sent is retried later, the same way as webhook deliveries,
+// or fails once it has used all its attempts
+func (store *sqlStore) RecordNotificationAttempt(notification_id string, sent bool, message string) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    idval, _ := strconv.Atoi(notification_id)
+    var attempts int
+    err = db.QueryRow("SELECT attempts FROM notification_outbox WHERE id=?", idval).Scan(&attempts)
+    if err != nil {
+        return data.NotFoundError("No matching notification found")
+    }
+    attempts++
+    status, next_attempt, sent_time := attemptOutcome(attempts, sent)
+    sqlcmd := "UPDATE notification_outbox SET status=?, attempts=?, next_attempt=?, last_error=?, sent=? WHERE id=?"
+    _, err = db.Exec(sqlcmd, status, attempts, next_attempt, deliveryError(message), sent_time, idval)
+    return err
+}
@@ -0,0 +1,98 @@
+package dbaccess
+// Tests for email notifications: which are queued, and how preferences
+// and attempts change that
+
+import (
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Return the notifications due now for one recipient
+func notificationsFor(t *testing.T, recipient string) (due []data.Notification) {
+    t.Helper()
+    notifications, err := testStore.DueNotifications(1000)
+    if err != nil {
+        t.Fatalf("DueNotifications failed: %v", err)
+    }
+    for _, notification := range notifications {
+        if notification.Recipient == recipient {
+            due = append(due, notification)
+        }
+    }
+    return due
+}
+
+func TestNotifications(t *testing.T) {
+    mustRegister(t, "notify.boss@example.com")
+    mustRegister(t, "notify.hired@example.com")
+    mustRegister(t, "notify.rejected@example.com")
+    job_id, _ := testStore.CreateJob(data.Job_info{Creator: "notify.boss@example.com", Title: "Bridge Inspector", Description: "Checks bridges", Salary: 30000})
+
+    prefs, err := testStore.GetNotificationPreferences("notify.hired@example.com")
+    if err != nil || prefs.Language != data.LanguageEnglish || !prefs.Application_received || !prefs.Hired || !prefs.Rejected {
+        t.Fatalf("expected every kind in English by default, got %+v (%v)", prefs, err)
+    }
+    thai := data.LanguageThai
+    prefs, err = testStore.SetNotificationPreferences(data.Notification_changes{User_email: "notify.hired@example.com", Language: &thai})
+    if err != nil || prefs.Language != data.LanguageThai || !prefs.Hired {
+        t.Fatalf("SetNotificationPreferences returned %+v (%v)", prefs, err)
+    }
+    off := false
+    testStore.SetNotificationPreferences(data.Notification_changes{User_email: "notify.rejected@example.com", Rejected: &off})
+
+    for _, applicant := range []string{"notify.hired@example.com", "notify.rejected@example.com"} {
+        if _, _, err = testStore.SubmitJobApplication(applicant, job_id, "", nil); err != nil {
+            t.Fatalf("SubmitJobApplication failed: %v", err)
+        }
+    }
+    due := notificationsFor(t, "notify.boss@example.com")
+    if len(due) != 2 || due[0].Kind != data.NotifyApplicationReceived || due[0].Applicant != "notify.hired@example.com" ||
+       due[0].Applicant_name != "Test User" || due[0].Title != "Bridge Inspector" || due[0].Job_id != job_id || due[0].Name != "Test" {
+        t.Fatalf("expected the employer to be told of both applications, got %+v", due)
+    }
+
+    // moving through the other statuses sends nothing until the hire
+    for _, status := range []string{data.StatusReviewed, data.StatusShortlisted, data.StatusInterviewing, data.StatusOffered} {
+        testStore.UpdateApplicationStatus("notify.boss@example.com", job_id, "notify.hired@example.com", status)
+        if found := notificationsFor(t, "notify.hired@example.com"); len(found) != 0 {
+            t.Fatalf("nothing should be sent for %s, got %+v", status, found)
+        }
+    }
+    testStore.UpdateApplicationStatus("notify.boss@example.com", job_id, "notify.hired@example.com", data.StatusHired)
+    due = notificationsFor(t, "notify.hired@example.com")
+    if len(due) != 1 || due[0].Kind != data.NotifyHired || due[0].Language != data.LanguageThai {
+        t.Errorf("expected the hire in Thai, got %+v", due)
+    }
+    testStore.UpdateApplicationStatus("notify.boss@example.com", job_id, "notify.rejected@example.com", data.StatusRejected)
+    if found := notificationsFor(t, "notify.rejected@example.com"); len(found) != 0 {
+        t.Errorf("a user who turned rejections off should not be told, got %+v", found)
+    }
+
+    // a sent email is done; one that failed waits before the next try
+    if err = testStore.RecordNotificationAttempt(due[0].Notification_id, true, ""); err != nil {
+        t.Fatalf("RecordNotificationAttempt failed: %v", err)
+    }
+    boss_due := notificationsFor(t, "notify.boss@example.com")
+    testStore.RecordNotificationAttempt(boss_due[0].Notification_id, false, "550 No such user")
+    if found := notificationsFor(t, "notify.hired@example.com"); len(found) != 0 {
+        t.Errorf("a sent email should not be due, got %+v", found)
+    }
+    if found := notificationsFor(t, "notify.boss@example.com"); len(found) != 1 {
+        t.Errorf("an email that failed should wait before it is retried, got %+v", found)
+    }
+    if err = testStore.RecordNotificationAttempt("99999", true, ""); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("expected a missing notification not to be found, got %v", err)
+    }
+
+    // deleting a user deletes their preferences and their emails
+    testStore.DeleteUser("notify.boss@example.com")
+    mustRegister(t, "notify.boss@example.com")
+    if found := notificationsFor(t, "notify.boss@example.com"); len(found) != 0 {
+        t.Errorf("a deleted user's emails should be deleted, got %+v", found)
+    }
+    testStore.DeleteUser("notify.hired@example.com")
+    mustRegister(t, "notify.hired@example.com")
+    if prefs, _ = testStore.GetNotificationPreferences("notify.hired@example.com"); prefs.Language != data.LanguageEnglish {
+        t.Errorf("a deleted user's preferences should be deleted, got %+v", prefs)
+    }
+}
@@ -0,0 +1,350 @@
+package dbaccess
+// This module holds the database functions for employer organizations:
+// creating them, changing their profiles, and managing their members
+// Every member can post jobs for an organization and manage any of its
+// jobs; only owners can change the profile or the members
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Selects the name of the organization offering job j, or '' if none
+const orgNameColumn = "coalesce((SELECT o.name FROM organization o WHERE o.id = j.organization_id), '')"
+
+//**************** Private Functions *******************************//
+
+// Turn an organization id into a string with leading zeros, or "" for none
+func orgIdString(idval int) string {
+    if idval == 0 {
+        return ""
+    }
+    return fmt.Sprintf("%05d", idval)
+}
+
+// Check that an organization exists and that the user is a member
+// Returns the member's role
+func checkOrgMember(conn queryer, org_idval int, user_email string) (role string, err error) {
+    var name string
+    row := conn.QueryRow("SELECT name FROM organization WHERE id=?", org_idval)
+    err = row.Scan(&name)
+    if err != nil {
+        return "", data.NotFoundError("No matching organization found")
+    }
+    row = conn.QueryRow("SELECT role FROM organization_member WHERE organization_id=? AND user_email=?", org_idval, user_email)
+    err = row.Scan(&role)
+    if err != nil {
+        return "", data.ForbiddenError("Specified user is not a member of this organization")
+    }
+    return role, nil
+}
+
+// Check that an organization exists and that the user is one of its owners
+func checkOrgOwner(conn queryer, org_idval int, user_email string) (err error) {
+    role, err := checkOrgMember(conn, org_idval, user_email)
+    if err != nil {
+        return err
+    }
+    if role != data.RoleOwner {
+        return data.ForbiddenError("Only an owner can change this organization")
+    }
+    return nil
+}
+
+// Check that an organization has more than one owner, before
+// one of them leaves or becomes a recruiter
+func checkOtherOwners(conn queryer, org_idval int) (err error) {
+    var owners int
+    row := conn.QueryRow("SELECT COUNT(*) FROM organization_member WHERE organization_id=? AND role=?", org_idval, data.RoleOwner)
+    err = row.Scan(&owners)
+    if err != nil {
+        return err
+    }
+    if owners <= 1 {
+        return data.ConflictError("An organization must have at least one owner")
+    }
+    return nil
+}
+
+// Check that a user who is leaving for good is not the only owner of
+// any organization, which would leave nobody able to manage it
+func checkNotSoleOwner(conn sqlConn, user_email string) (err error) {
+    rows, err := conn.Query("SELECT organization_id FROM organization_member WHERE user_email=? AND role=?", user_email, data.RoleOwner)
+    if err != nil {
+        return err
+    }
+    var org_ids []int
+    for rows.Next() {
+        var org_idval int
+        err = rows.Scan(&org_idval)
+        if err != nil {
+            rows.Close()
+            return err
+        }
+        org_ids = append(org_ids, org_idval)
+    }
+    rows.Close()
+    for _, org_idval := range org_ids {
+        if checkOtherOwners(conn, org_idval) != nil {
+            return data.ConflictError(fmt.Sprintf("You are the only owner of organization %s - transfer ownership first", orgIdString(org_idval)))
+        }
+    }
+    return nil
+}
+
+//******** Exported Functions *****************************//
+
+// Function to create a new organization. org.Creator becomes its first owner
+// Organization names must be unique
+// Returns the ID (autoincrement) of the organization, transformed into a string with leading zeros
+func (store *sqlStore) CreateOrganization(org data.Organization) (org_id string, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return "", err
+    }
+    tx, err := db.Begin()
+    if err != nil {
+        return "", err
+    }
+    nowstring := data.StoredNow()
+    sqlcmd := "INSERT INTO organization (name, description, website, logo_url, location, created) values (?,?,?,?,?,?)"
+    id, err := insertReturningId(tx, sqlcmd, org.Name, org.Description, org.Website, org.Logo_url, org.Location, nowstring)
+    if err != nil {
+        tx.Rollback()
+        if isUniqueViolation(err) {
+            return "", data.ConflictError("Organization name is not unique; organization not created")
+        }
+        return "", err
+    }
+    sqlcmd = "INSERT INTO organization_member (organization_id, user_email, role, added) values (?,?,?,?)"
+    _, err = tx.Exec(sqlcmd, id, org.Creator, data.RoleOwner, nowstring)
+    if err != nil {
+        tx.Rollback()
+        return "", err
+    }
+    err = tx.Commit()
+    if err != nil {
+        return "", err
+    }
+    return orgIdString(id), nil
+}
+
+// Function to get the profile of an organization and its members,
+// in the order they joined
+func (store *sqlStore) GetOrganization(org_id string) (org data.Organization, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return org, err
+    }
+    idval, _ := strconv.Atoi(org_id)  // already validated the format
+    sqlcmd := "SELECT name, description, website, logo_url, location, created FROM organization WHERE id=?"
+    row := db.QueryRow(sqlcmd, idval)
+    err = row.Scan(&org.Name, &org.Description, &org.Website, &org.Logo_url, &org.Location, &org.Created)
+    if err != nil {
+        return org, data.NotFoundError("No matching organization found")
+    }
+    org.Org_id = orgIdString(idval)
+    org.Created = data.DisplayTime(org.Created)
+    sqlcmd = "SELECT m.user_email, m.role, m.added, u.first_name, u.last_name FROM organization_member m, user u" +
+        " WHERE m.user_email=u.user_email AND m.organization_id=? ORDER BY m.id"
+    rows, err := db.Query(sqlcmd, idval)
+    if err != nil {
+        return org, err
+    }
+    defer rows.Close()
+    var first string
+    var last string
+    for rows.Next() {
+        var member data.Org_member
+        err = rows.Scan(&member.Email, &member.Role, &member.Added, &first, &last)
+        if err != nil {
+            return org, err
+        }
+        member.Name = first + " " + last
+        member.Added = data.DisplayTime(member.Added)
+        org.Members = append(org.Members, member)
+    }
+    return org, nil
+}
+
+// Function for an owner, org.Creator, to change an organization's profile
+// Only the fields that are not empty are changed
+func (store *sqlStore) UpdateOrganization(org data.Organization) (err error) {
+    var columns []string
+    var args []interface{}
+    values := []struct {
+        column string
+        value  string
+    }{
+        {"name", org.Name}, {"description", org.Description}, {"website", org.Website},
+        {"logo_url", org.Logo_url}, {"location", org.Location},
+    }
+    for _, value := range values {
+        if value.value != "" {
+            columns = append(columns, value.column + "=?")
+            args = append(args, value.value)
+        }
+    }
+    if len(columns) == 0 {
+        return data.ValidationError("", "Nothing to change")
+    }
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    idval, _ := strconv.Atoi(org.Org_id)  // already validated the format
+    err = checkOrgOwner(db, idval, org.Creator)
+    if err != nil {
+        return err
+    }
+    sqlcmd := fmt.Sprintf("UPDATE organization SET %s WHERE id=?", strings.Join(columns, ", "))
+    _, err = db.Exec(sqlcmd, append(args, idval)...)
+    if err != nil && isUniqueViolation(err) {
+        return data.ConflictError("Organization name is not unique; organization not changed")
+    }
+    return err
+}
+
+// Function to list organizations, by default in order of name
+// The keyword, if given, must be part of the name; if member_email is
+// given, only the organizations that user belongs to are listed
+// Returns one page of organizations, without their members, plus the total number
+func (store *sqlStore) SearchOrganizations(keyword string, member_email string, page data.Page_request) (orgs []data.Organization, total int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return orgs, 0, err
+    }
+    var clauses []string
+    var args []interface{}
+    if keyword != "" {
+        clauses = append(clauses, "o.name like ? escape '\\'")
+        args = append(args, "%" + escapeLike(keyword) + "%")
+    }
+    if member_email != "" {
+        clauses = append(clauses, "o.id IN (SELECT organization_id FROM organization_member WHERE user_email=?)")
+        args = append(args, member_email)
+    }
+    fromclause := "FROM organization o"
+    if len(clauses) > 0 {
+        fromclause += " where " + strings.Join(clauses, " and ")
+    }
+    row := db.QueryRow("SELECT COUNT(*) " + fromclause, args...)
+    err = row.Scan(&total)
+    if err != nil {
+        return orgs, 0, err
+    }
+    clause, page_args := pageClause(page, organizationSortColumns, "name", "asc", "o.id")
+    sqlcmd := "SELECT o.id, o.name, o.description, o.website, o.logo_url, o.location, o.created " + fromclause + clause
+    rows, err := db.Query(sqlcmd, append(args, page_args...)...)
+    if err != nil {
+        return orgs, 0, err
+    }
+    defer rows.Close()
+    var idval int
+    for rows.Next() {
+        var org data.Organization
+        err = rows.Scan(&idval, &org.Name, &org.Description, &org.Website, &org.Logo_url, &org.Location, &org.Created)
+        if err != nil {
+            return orgs, 0, err
+        }
+        org.Org_id = orgIdString(idval)
+        org.Created = data.DisplayTime(org.Created)
+        orgs = append(orgs, org)
+    }
+    return orgs, total, nil
+}
+
+// Function for an owner, change.Creator, to add a member to an organization
+// or to change a member's role. The last owner cannot become a recruiter
+func (store *sqlStore) SetOrganizationMember(change data.Member_change) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    idval, _ := strconv.Atoi(change.Org_id)  // already validated the format
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    err = checkOrgOwner(tx, idval, change.Creator)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    var current string
+    row := tx.QueryRow("SELECT role FROM organization_member WHERE organization_id=? AND user_email=?", idval, change.Member)
+    err = row.Scan(&current)
+    if err != nil {
+        sqlcmd := "INSERT INTO organization_member (organization_id, user_email, role, added) values (?,?,?,?)"
+        _, err = tx.Exec(sqlcmd, idval, change.Member, change.Role, data.StoredNow())
+        if err != nil {
+            tx.Rollback()
+            return err
+        }
+        return tx.Commit()
+    }
+    if current == change.Role {
+        tx.Rollback()
+        return data.ConflictError("Member is already " + current)
+    }
+    if current == data.RoleOwner {
+        err = checkOtherOwners(tx, idval)
+        if err != nil {
+            tx.Rollback()
+            return err
+        }
+    }
+    sqlcmd := "UPDATE organization_member SET role=? WHERE organization_id=? AND user_email=?"
+    _, err = tx.Exec(sqlcmd, change.Role, idval, change.Member)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    return tx.Commit()
+}
+
+// Function to remove a member from an organization. Owners can remove
+// anybody, and any member can leave, except the last owner
+// Jobs the member posted stay with the organization
+func (store *sqlStore) RemoveOrganizationMember(change data.Member_change) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    idval, _ := strconv.Atoi(change.Org_id)  // already validated the format
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    if change.Member == change.Creator {
+        _, err = checkOrgMember(tx, idval, change.Creator)
+    } else {
+        err = checkOrgOwner(tx, idval, change.Creator)
+    }
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    var current string
+    row := tx.QueryRow("SELECT role FROM organization_member WHERE organization_id=? AND user_email=?", idval, change.Member)
+    err = row.Scan(&current)
+    if err != nil {
+        tx.Rollback()
+        return data.NotFoundError("No such member of this organization")
+    }
+    if current == data.RoleOwner {
+        err = checkOtherOwners(tx, idval)
+        if err != nil {
+            tx.Rollback()
+            return err
+        }
+    }
+    _, err = tx.Exec("DELETE FROM organization_member WHERE organization_id=? AND user_email=?", idval, change.Member)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    return tx.Commit()
+}
@@ -0,0 +1,199 @@
+package dbaccess
+// Tests for organizations, their members and the jobs they offer
+
+import (
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+func TestOrganizationMembers(t *testing.T) {
+    mustRegister(t, "org.owner@example.com")
+    mustRegister(t, "org.recruiter@example.com")
+    mustRegister(t, "org.outsider@example.com")
+    org_id, err := testStore.CreateOrganization(data.Organization{Creator: "org.owner@example.com",
+        Name: "Owner's Widgets", Website: "https://widgets.example.com", Location: "Chiang Mai"})
+    if err != nil {
+        t.Fatalf("CreateOrganization failed: %v", err)
+    }
+    if _, err = testStore.CreateOrganization(data.Organization{Creator: "org.outsider@example.com", Name: "Owner's Widgets"}); data.ErrorCode(err) != data.CodeConflict {
+        t.Errorf("a duplicate name should be a conflict, got %v", err)
+    }
+
+    changes := []struct {
+        change data.Member_change
+        remove bool
+        code   string
+    }{
+        {data.Member_change{Creator: "org.outsider@example.com", Member: "org.outsider@example.com", Role: data.RoleOwner}, false, data.CodeForbidden},
+        {data.Member_change{Creator: "org.owner@example.com", Member: "org.recruiter@example.com", Role: data.RoleRecruiter}, false, ""},
+        {data.Member_change{Creator: "org.owner@example.com", Member: "org.recruiter@example.com", Role: data.RoleRecruiter}, false, data.CodeConflict},
+        {data.Member_change{Creator: "org.recruiter@example.com", Member: "org.outsider@example.com", Role: data.RoleRecruiter}, false, data.CodeForbidden},
+        {data.Member_change{Creator: "org.owner@example.com", Member: "org.owner@example.com", Role: data.RoleRecruiter}, false, data.CodeConflict},
+        {data.Member_change{Creator: "org.owner@example.com", Member: "org.owner@example.com"}, true, data.CodeConflict},
+        {data.Member_change{Creator: "org.recruiter@example.com", Member: "org.owner@example.com"}, true, data.CodeForbidden},
+        {data.Member_change{Creator: "org.owner@example.com", Member: "org.outsider@example.com"}, true, data.CodeNotFound},
+        {data.Member_change{Creator: "org.owner@example.com", Member: "org.outsider@example.com", Role: data.RoleRecruiter}, false, ""},
+        {data.Member_change{Creator: "org.outsider@example.com", Member: "org.outsider@example.com"}, true, ""},
+    }
+    for i, change := range changes {
+        change.change.Org_id = org_id
+        if change.remove {
+            err = testStore.RemoveOrganizationMember(change.change)
+        } else {
+            err = testStore.SetOrganizationMember(change.change)
+        }
+        if (err == nil) != (change.code == "") || (err != nil && data.ErrorCode(err) != change.code) {
+            t.Errorf("change %d: expected %q, got %v", i, change.code, err)
+        }
+    }
+
+    org, err := testStore.GetOrganization(org_id)
+    if err != nil {
+        t.Fatalf("GetOrganization failed: %v", err)
+    }
+    if org.Name != "Owner's Widgets" || len(org.Members) != 2 || org.Members[0].Role != data.RoleOwner ||
+       org.Members[1].Email != "org.recruiter@example.com" {
+        t.Errorf("unexpected organization %+v", org)
+    }
+    if err = testStore.UpdateOrganization(data.Organization{Creator: "org.recruiter@example.com", Org_id: org_id, Location: "Phuket"}); data.ErrorCode(err) != data.CodeForbidden {
+        t.Errorf("a recruiter should not change the organization, got %v", err)
+    }
+    if err = testStore.UpdateOrganization(data.Organization{Creator: "org.owner@example.com", Org_id: org_id, Location: "Phuket"}); err != nil {
+        t.Fatalf("UpdateOrganization failed: %v", err)
+    }
+    org, _ = testStore.GetOrganization(org_id)
+    if org.Location != "Phuket" || org.Website != "https://widgets.example.com" {
+        t.Errorf("only the location should have changed, got %+v", org)
+    }
+    mine, total, _ := testStore.SearchOrganizations("", "org.recruiter@example.com", data.Page_request{})
+    if total != 1 || mine[0].Org_id != org_id {
+        t.Errorf("the recruiter should belong to one organization, got %v", mine)
+    }
+    found, _, _ := testStore.SearchOrganizations("widgets", "", data.Page_request{})
+    if len(found) != 1 || found[0].Name != "Owner's Widgets" {
+        t.Errorf("a keyword should find the organization, got %v", found)
+    }
+}
+
+func TestOrganizationJobs(t *testing.T) {
+    mustRegister(t, "orgjob.owner@example.com")
+    mustRegister(t, "orgjob.recruiter@example.com")
+    mustRegister(t, "orgjob.applicant@example.com")
+    org_id, _ := testStore.CreateOrganization(data.Organization{Creator: "orgjob.owner@example.com", Name: "Gadget_Works 100%"})
+    testStore.SetOrganizationMember(data.Member_change{Creator: "orgjob.owner@example.com", Org_id: org_id,
+                                                       Member: "orgjob.recruiter@example.com", Role: data.RoleRecruiter})
+
+    if _, err := testStore.CreateJob(data.Job_info{Creator: "orgjob.applicant@example.com", Title: "Gatecrasher",
+                                                   Description: "Not a member", Org_id: org_id}); data.ErrorCode(err) != data.CodeForbidden {
+        t.Errorf("only members should post jobs for an organization, got %v", err)
+    }
+    job_id, err := testStore.CreateJob(data.Job_info{Creator: "orgjob.owner@example.com", Title: "Gadget Tester",
+                                                     Description: "Test gadgets", Org_id: org_id})
+    if err != nil {
+        t.Fatalf("CreateJob failed: %v", err)
+    }
+    testStore.SubmitJobApplication("orgjob.applicant@example.com", job_id, "", nil)
+
+    // the recruiter can manage the owner's posting, an outsider cannot
+    salary := 20000
+    if _, err = testStore.ModifyJob(data.Job_changes{Creator: "orgjob.recruiter@example.com", Job_id: job_id, Salary: &salary}); err != nil {
+        t.Errorf("a recruiter should modify the organization's job, got %v", err)
+    }
+    if _, err = testStore.ModifyJob(data.Job_changes{Creator: "orgjob.applicant@example.com", Job_id: job_id, Salary: &salary}); data.ErrorCode(err) != data.CodeForbidden {
+        t.Errorf("an outsider should not modify the job, got %v", err)
+    }
+    candidates, _, err := testStore.SearchCandidates("orgjob.recruiter@example.com", job_id, false, data.Page_request{})
+    if err != nil || len(candidates) != 1 {
+        t.Errorf("a recruiter should see the candidates, got %v (%v)", candidates, err)
+    }
+    if err = testStore.UpdateApplicationStatus("orgjob.recruiter@example.com", job_id, "orgjob.applicant@example.com", data.StatusReviewed); err != nil {
+        t.Errorf("a recruiter should change the application status, got %v", err)
+    }
+
+    found, _ := testStore.GetJobDetail(job_id)
+    if found.Org_id != org_id || found.Organization != "Gadget_Works 100%" {
+        t.Errorf("the job should show its organization, got %+v", found)
+    }
+    summaries, _, _ := testStore.SearchJobs(data.Search_criteria{Org_id: org_id}, data.Page_request{})
+    if len(summaries) != 1 || summaries[0].Job_id != job_id || summaries[0].Organization != "Gadget_Works 100%" {
+        t.Errorf("expected only %s for the organization, got %v", job_id, summaries)
+    }
+    summaries, _, _ = testStore.SearchJobs(data.Search_criteria{Organization: "_works 100%"}, data.Page_request{})
+    if len(summaries) != 1 || summaries[0].Job_id != job_id {
+        t.Errorf("expected only %s for part of the name, got %v", job_id, summaries)
+    }
+    summaries, _, _ = testStore.SearchJobs(data.Search_criteria{Organization: "get%works"}, data.Page_request{})
+    if len(summaries) != 0 {
+        t.Errorf("wildcards in the organization name should match literally, got %v", summaries)
+    }
+
+    // once the recruiter leaves, they can no longer manage the job
+    testStore.RemoveOrganizationMember(data.Member_change{Creator: "orgjob.recruiter@example.com", Org_id: org_id,
+                                                          Member: "orgjob.recruiter@example.com"})
+    if err = testStore.ArchiveJob("orgjob.recruiter@example.com", job_id); data.ErrorCode(err) != data.CodeForbidden {
+        t.Errorf("a former member should not archive the job, got %v", err)
+    }
+}
+
+// The only owner of an organization cannot delete their account until
+// someone else owns it too
+func TestDeleteSoleOwner(t *testing.T) {
+    mustRegister(t, "sole.owner@example.com")
+    mustRegister(t, "sole.recruiter@example.com")
+    org_id, err := testStore.CreateOrganization(data.Organization{Creator: "sole.owner@example.com", Name: "Lonely Ltd"})
+    if err != nil {
+        t.Fatalf("CreateOrganization failed: %v", err)
+    }
+    testStore.SetOrganizationMember(data.Member_change{Creator: "sole.owner@example.com", Org_id: org_id,
+                                                       Member: "sole.recruiter@example.com", Role: data.RoleRecruiter})
+    if err = testStore.DeleteUser("sole.owner@example.com"); data.ErrorCode(err) != data.CodeConflict {
+        t.Fatalf("deleting the only owner should conflict, got %v", err)
+    }
+    if registered, _ := testStore.IsRegisteredUser("sole.owner@example.com"); !registered {
+        t.Fatalf("the owner should not have been deleted")
+    }
+    // a recruiter can leave, and so can an owner once there is another
+    if err = testStore.DeleteUser("sole.recruiter@example.com"); err != nil {
+        t.Errorf("a recruiter should be able to delete their account, got %v", err)
+    }
+    mustRegister(t, "sole.recruiter@example.com")
+    testStore.SetOrganizationMember(data.Member_change{Creator: "sole.owner@example.com", Org_id: org_id,
+                                                       Member: "sole.recruiter@example.com", Role: data.RoleOwner})
+    if err = testStore.DeleteUser("sole.owner@example.com"); err != nil {
+        t.Fatalf("DeleteUser failed once there is another owner: %v", err)
+    }
+    if err = testStore.UpdateOrganization(data.Organization{Creator: "sole.recruiter@example.com", Org_id: org_id, Location: "Krabi"}); err != nil {
+        t.Errorf("the new owner should manage the organization, got %v", err)
+    }
+}
+
+// When a recruiter leaves, the organization's jobs stay open and pass to
+// an owner, while their own jobs are closed, and their messages are removed
+func TestDeleteRecruiterKeepsOrgJobs(t *testing.T) {
+    mustRegister(t, "keep.owner@example.com")
+    mustRegister(t, "keep.recruiter@example.com")
+    mustRegister(t, "keep.applicant@example.com")
+    org_id, _ := testStore.CreateOrganization(data.Organization{Creator: "keep.owner@example.com", Name: "Keepers Co."})
+    testStore.SetOrganizationMember(data.Member_change{Creator: "keep.owner@example.com", Org_id: org_id,
+                                                       Member: "keep.recruiter@example.com", Role: data.RoleRecruiter})
+    org_job, _ := testStore.CreateJob(data.Job_info{Creator: "keep.recruiter@example.com", Title: "Keeper", Description: "Keeps things", Org_id: org_id})
+    own_job, _ := testStore.CreateJob(data.Job_info{Creator: "keep.recruiter@example.com", Title: "Side Gig", Description: "Not the company's"})
+    testStore.SubmitJobApplication("keep.applicant@example.com", org_job, "", nil)
+    testStore.SendMessage(data.Message{Job_id: org_job, Applicant: "keep.applicant@example.com", Sender: "keep.applicant@example.com", Body: "Hello"})
+    testStore.SendMessage(data.Message{Job_id: org_job, Applicant: "keep.applicant@example.com", Sender: "keep.recruiter@example.com", Body: "Hi there"})
+
+    if err := testStore.DeleteUser("keep.recruiter@example.com"); err != nil {
+        t.Fatalf("DeleteUser failed: %v", err)
+    }
+    job, _ := testStore.GetJobDetail(org_job)
+    if !job.Is_open || job.Creator != "keep.owner@example.com" {
+        t.Errorf("the organization's job should stay open and go to the owner, got %+v", job)
+    }
+    if job, _ = testStore.GetJobDetail(own_job); job.Is_open || job.Creator != "" {
+        t.Errorf("the recruiter's own job should be closed, got %+v", job)
+    }
+    messages, _, err := testStore.ListMessages("keep.owner@example.com", org_job, "keep.applicant@example.com", data.Page_request{})
+    if err != nil || len(messages) != 1 || messages[0].Sender != "keep.applicant@example.com" {
+        t.Errorf("the owner should see the thread without the recruiter's messages, got %+v (%v)", messages, err)
+    }
+}
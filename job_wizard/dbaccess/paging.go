@@ -0,0 +1,95 @@
+package dbaccess
+// This module builds the ORDER BY and LIMIT clauses used to return
+// listings one page at a time in a stable order
+
+import (
+    "strings"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Map the sort fields in data.Job_sort_fields etc. to SQL columns
+// Sort fields are never pasted into SQL directly; unknown fields use the default
+var (
+    // relevance is only meaningful for a full-text search, see ftsJobSortColumns
+    jobSortColumns = map[string][]string{
+        "relevance": {"j.created"},
+        "posted": {"j.created"},
+        "title":  {"j.title"},
+        "salary": {"j.salary"},
+        "job_id": {"j.id"},
+    }
+    applicationSortColumns = map[string][]string{
+        "applied": {"ja.apply_time"},
+        "posted":  {"j.created"},
+        "title":   {"j.title"},
+        "status":  {"ja.status"},
+        "job_id":  {"j.id"},
+    }
+    // match is the match_score column; see skill.go
+    candidateSortColumns = map[string][]string{
+        "applied": {"a.apply_time"},
+        "name":    {"u.first_name", "u.last_name"},
+        "status":  {"a.status"},
+        "match":   {"match_score"},
+    }
+    organizationSortColumns = map[string][]string{
+        "name":    {"o.name"},
+        "created": {"o.created"},
+        "org_id":  {"o.id"},
+    }
+    skillSortColumns = map[string][]string{
+        "name": {"s.name"},
+    }
+    bookmarkSortColumns = map[string][]string{
+        "bookmarked": {"b.created"},
+        "posted":     {"j.created"},
+        "title":      {"j.title"},
+        "salary":     {"j.salary"},
+        "job_id":     {"j.id"},
+    }
+    savedSearchSortColumns = map[string][]string{
+        "name":    {"ss.name"},
+        "created": {"ss.created"},
+    }
+    webhookSortColumns = map[string][]string{
+        "created": {"w.created"},
+        "url":     {"w.url"},
+    }
+    deliverySortColumns = map[string][]string{
+        "created": {"d.created"},
+        "status":  {"d.status"},
+    }
+    messageSortColumns = map[string][]string{
+        "sent": {"m.created"},
+    }
+)
+
+// Build the ORDER BY, LIMIT and OFFSET clauses for a page request
+// The tiebreak column (a unique id) is always sorted last so that
+// rows with equal sort values keep the same order from page to page
+// Returns the clause and the values for its '?' placeholders
+func pageClause(page data.Page_request, columns map[string][]string, default_sort string,
+                default_order string, tiebreak string) (clause string, args []interface{}) {
+    sort_columns, found := columns[page.Sort]
+    if !found {
+        sort_columns = columns[default_sort]
+    }
+    order := strings.ToUpper(page.Order)
+    if order != "ASC" && order != "DESC" {
+        order = strings.ToUpper(default_order)
+    }
+    var terms []string
+    for _, column := range sort_columns {
+        terms = append(terms, column + " " + order)
+    }
+    terms = append(terms, tiebreak + " " + order)
+    clause = " ORDER BY " + strings.Join(terms, ", ")
+    if page.Limit > 0 {
+        clause += " LIMIT ? OFFSET ?"
+        args = append(args, page.Limit, page.Offset)
+    } else if page.Offset > 0 {
+        clause += " LIMIT -1 OFFSET ?"
+        args = append(args, page.Offset)
+    }
+    return clause, args
+}
@@ -0,0 +1,52 @@
+package dbaccess
+// Tests for paging and sorting of listings
+
+import (
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+func TestPagingOfferedJobs(t *testing.T) {
+    mustRegister(t, "paging.owner@example.com")
+    titles := []string{"Echo", "Alpha", "Delta", "Charlie", "Bravo"}
+    for _, title := range titles {
+        testStore.CreateJob(data.Job_info{Creator: "paging.owner@example.com", Title: title, Description: "Paging test"})
+    }
+    expected := []string{"Alpha", "Bravo", "Charlie", "Delta", "Echo"}
+    var seen []string
+    page := data.Page_request{Limit: 2, Sort: "title", Order: "asc"}
+    for {
+        summaries, total, err := testStore.SearchOfferedJobs("paging.owner@example.com", page)
+        if err != nil {
+            t.Fatalf("SearchOfferedJobs failed: %v", err)
+        }
+        if total != len(titles) {
+            t.Errorf("expected total %d, got %d", len(titles), total)
+        }
+        for _, summary := range summaries {
+            seen = append(seen, summary.Title)
+        }
+        result := data.New_result_page(summaries, len(summaries), total, page)
+        if result.Next_offset == nil {
+            break
+        }
+        page.Offset = *result.Next_offset
+    }
+    if len(seen) != len(expected) {
+        t.Fatalf("expected %v, got %v", expected, seen)
+    }
+    for i := range expected {
+        if seen[i] != expected[i] {
+            t.Errorf("position %d: expected %s, got %s", i, expected[i], seen[i])
+        }
+    }
+
+    // default order is most recently posted first; all have the same
+    // posted minute so the job id breaks the tie
+    summaries, _, _ := testStore.SearchOfferedJobs("paging.owner@example.com", data.Page_request{})
+    for i := 1; i < len(summaries); i++ {
+        if summaries[i-1].Date_posted == summaries[i].Date_posted && summaries[i-1].Job_id < summaries[i].Job_id {
+            t.Errorf("default order not stable: %v", summaries)
+        }
+    }
+}
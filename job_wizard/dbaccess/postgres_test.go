@@ -0,0 +1,54 @@
+package dbaccess
+// Tests for the PostgreSQL translation and schema scripts
+// These need no server; see TestMain for running every test on PostgreSQL
+
+import (
+    "testing"
+)
+
+func TestPostgresQuery(t *testing.T) {
+    cases := []struct {
+        query    string
+        expected string
+    }{
+        {"SELECT id FROM user WHERE user_email=?",
+         "SELECT id FROM \"user\" WHERE user_email=$1"},
+        {"FROM job_application a, user u where a.user_email=u.user_email AND a.job_id=?",
+         "FROM job_application a, \"user\" u where a.user_email=u.user_email AND a.job_id=$1"},
+        {"(j.title like ? escape '\\' or j.description like ? escape '\\')",
+         "(j.title ILIKE $1 escape '\\' or j.description ILIKE $2 escape '\\')"},
+        {" ORDER BY j.created DESC, j.id DESC LIMIT -1 OFFSET ?",
+         " ORDER BY j.created DESC, j.id DESC LIMIT ALL OFFSET $1"},
+        // quoted text and comments are left alone
+        {"UPDATE job SET created_by='user?' WHERE id=? -- like user?",
+         "UPDATE job SET created_by='user?' WHERE id=$1 -- like user?"},
+        {"DROP TABLE IF EXISTS \"user\";",
+         "DROP TABLE IF EXISTS \"user\";"},
+    }
+    for _, c := range cases {
+        if translated := postgresQuery(c.query); translated != c.expected {
+            t.Errorf("postgresQuery(%q)\n  got      %q\n  expected %q", c.query, translated, c.expected)
+        }
+    }
+}
+
+// Every SQLite migration must have a PostgreSQL version with the same name
+func TestPostgresMigrationsMatch(t *testing.T) {
+    sqlite_steps, err := loadMigrations(sqliteMigrations)
+    if err != nil {
+        t.Fatalf("loadMigrations(sqlite) failed: %v", err)
+    }
+    postgres_steps, err := loadMigrations(postgresMigrations)
+    if err != nil {
+        t.Fatalf("loadMigrations(postgres) failed: %v", err)
+    }
+    if len(sqlite_steps) != len(postgres_steps) {
+        t.Fatalf("%d SQLite migrations but %d for PostgreSQL", len(sqlite_steps), len(postgres_steps))
+    }
+    for i := range sqlite_steps {
+        if sqlite_steps[i].name != postgres_steps[i].name {
+            t.Errorf("migration %d is %s for SQLite but %s for PostgreSQL",
+                     i + 1, sqlite_steps[i].name, postgres_steps[i].name)
+        }
+    }
+}
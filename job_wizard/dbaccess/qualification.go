@@ -0,0 +1,104 @@
+package dbaccess
+// This module holds the functions that compare an applicant with the
+// requirements of a job: the minimum education and experience, and the
+// required skills. Both stores use them, so that they agree on which
+// requirements are unmet and which candidates are qualified
+// It also stores the work history of users
+
+import (
+    "sort"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// True if user u meets every requirement of job j
+// Missing education or experience counts as 0
+const qualifiedCondition = "(coalesce(u.max_education, 0) >= coalesce(j.min_education, 0)" +
+    " AND coalesce(u.years_experience, 0) >= coalesce(j.min_years_experience, 0)" +
+    " AND NOT EXISTS (SELECT 1 FROM job_skill js WHERE js.job_id = j.id AND js.required" +
+    " AND NOT EXISTS (SELECT 1 FROM user_skill us WHERE us.skill_id = js.skill_id AND us.user_email = u.user_email)))"
+
+//**************** Private Functions *******************************//
+
+// Return the requirements of a job that a user does not meet, or nil if
+// the user meets them all. The proficiencies are keyed by skill name
+// This is the check done by qualifiedCondition
+func unmetRequirements(education int, experience int, min_education int, min_experience int,
+                       job_skills []data.Job_skill, proficiencies map[string]int) (unmet []data.Unmet_requirement) {
+    if education < min_education {
+        unmet = append(unmet, data.Unmet_requirement{Requirement: data.RequirementEducation,
+                                                     Required: min_education, Actual: education})
+    }
+    if experience < min_experience {
+        unmet = append(unmet, data.Unmet_requirement{Requirement: data.RequirementExperience,
+                                                     Required: min_experience, Actual: experience})
+    }
+    for _, skill := range job_skills {
+        if skill.Required && proficiencies[skill.Name] == 0 {
+            unmet = append(unmet, data.Unmet_requirement{Requirement: data.RequirementSkill,
+                                                         Required: data.MinProficiency, Skill: skill.Name})
+        }
+    }
+    return unmet
+}
+
+// Sort a work history the way it is returned: most recent start first
+func sortWorkHistory(history []data.Work_entry) {
+    sort.SliceStable(history, func(i, j int) bool {
+        return history[i].Start_date > history[j].Start_date
+    })
+}
+
+// Replace the work history of a user
+func saveWorkHistory(conn sqlConn, user_email string, history []data.Work_entry) (err error) {
+    _, err = conn.Exec("DELETE FROM work_history WHERE user_email=?", user_email)
+    if err != nil {
+        return err
+    }
+    for _, entry := range history {
+        _, err = conn.Exec("INSERT INTO work_history (user_email, title, employer, start_date, end_date) values (?,?,?,?,?)",
+                           user_email, entry.Title, entry.Employer, entry.Start_date, entry.End_date)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Return the work history of a user, most recent start first
+func loadWorkHistory(conn sqlConn, user_email string) (history []data.Work_entry, err error) {
+    sqlcmd := "SELECT title, employer, start_date, end_date FROM work_history WHERE user_email=? ORDER BY start_date DESC, id"
+    rows, err := conn.Query(sqlcmd, user_email)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    history = []data.Work_entry{}
+    for rows.Next() {
+        var entry data.Work_entry
+        err = rows.Scan(&entry.Title, &entry.Employer, &entry.Start_date, &entry.End_date)
+        if err != nil {
+            return nil, err
+        }
+        history = append(history, entry)
+    }
+    return history, nil
+}
+
+// Return the unmet requirements of a job for an applicant, reading them
+// with the transaction used to apply
+func loadUnmetRequirements(conn sqlConn, user_email string, education int, experience int,
+                           job_idval int, min_education int, min_experience int) (unmet []data.Unmet_requirement, err error) {
+    job_skills, err := loadJobSkills(conn, job_idval)
+    if err != nil {
+        return nil, err
+    }
+    user_skills, err := loadUserSkills(conn, user_email)
+    if err != nil {
+        return nil, err
+    }
+    proficiencies := make(map[string]int)
+    for _, skill := range user_skills {
+        proficiencies[skill.Name] = skill.Proficiency
+    }
+    return unmetRequirements(education, experience, min_education, min_experience, job_skills, proficiencies), nil
+}
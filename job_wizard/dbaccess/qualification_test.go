@@ -0,0 +1,108 @@
+package dbaccess
+// Tests for user experience and work history, unmet requirements when
+// applying, and qualified candidates
+
+import (
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+func TestWorkHistory(t *testing.T) {
+    mustRegister(t, "history.user@example.com")
+    profile, _ := testStore.GetUserProfile("history.user@example.com")
+    if profile.Experience == nil || *profile.Experience != 0 || len(profile.Work_history) != 0 {
+        t.Errorf("a new user should have no experience, got %+v", profile)
+    }
+    years := 7
+    history := []data.Work_entry{
+        {Title: "Junior Developer", Employer: "Acme", Start_date: "2018-03-01", End_date: "2020-12-31"},
+        {Title: "Senior Developer", Employer: "Widgets Co.", Start_date: "2021-01-04"},
+    }
+    if err := testStore.UpdateUserProfile("history.user@example.com", "", "", "", nil, "", &years, nil, history); err != nil {
+        t.Fatalf("UpdateUserProfile failed: %v", err)
+    }
+    profile, _ = testStore.GetUserProfile("history.user@example.com")
+    if *profile.Experience != 7 || len(profile.Work_history) != 2 || profile.Work_history[0].Title != "Senior Developer" ||
+       profile.Work_history[0].End_date != "" || profile.Work_history[1].End_date != "2020-12-31" {
+        t.Errorf("expected 7 years and the most recent job first, got %+v", profile)
+    }
+
+    // 0 years is a change, nil is not
+    years = 0
+    testStore.UpdateUserProfile("history.user@example.com", "", "", "", nil, "", &years, nil, nil)
+    profile, _ = testStore.GetUserProfile("history.user@example.com")
+    if *profile.Experience != 0 || len(profile.Work_history) != 2 {
+        t.Errorf("only the experience should have changed, got %+v", profile)
+    }
+
+    // details given at registration are stored with the account
+    years = 3
+    skills := []data.User_skill{{Name: "Go", Proficiency: 2}}
+    if err := testStore.RegisterUser("registered.history@example.com", "Test", "User", "0812345678", 2, "password123",
+                                     &years, skills, history); err != nil {
+        t.Fatalf("RegisterUser failed: %v", err)
+    }
+    profile, _ = testStore.GetUserProfile("registered.history@example.com")
+    if *profile.Experience != 3 || len(profile.Skills) != 1 || profile.Skills[0].Name != "Go" || len(profile.Work_history) != 2 {
+        t.Errorf("expected the registered experience, skills and history, got %+v", profile)
+    }
+
+    // a new account with the same email starts with no history
+    if err := testStore.DeleteUser("history.user@example.com"); err != nil {
+        t.Fatalf("DeleteUser failed: %v", err)
+    }
+    mustRegister(t, "history.user@example.com")
+    profile, _ = testStore.GetUserProfile("history.user@example.com")
+    if len(profile.Work_history) != 0 {
+        t.Errorf("the work history should be deleted with the user, got %v", profile.Work_history)
+    }
+}
+
+func TestUnmetRequirements(t *testing.T) {
+    mustRegister(t, "qualify.boss@example.com")
+    mustRegister(t, "qualify.fit@example.com")
+    mustRegister(t, "qualify.green@example.com")
+    fit_years := 5
+    green_years := 1
+    testStore.UpdateUserProfile("qualify.fit@example.com", "", "", "", nil, "", &fit_years,
+        []data.User_skill{{Name: "qualifytest welding", Proficiency: 1}}, nil)
+    testStore.UpdateUserProfile("qualify.green@example.com", "", "", "", nil, "", &green_years,
+        []data.User_skill{{Name: "qualifytest painting", Proficiency: 5}}, nil)
+    job_id, err := testStore.CreateJob(data.Job_info{Creator: "qualify.boss@example.com", Title: "Qualified Welder", Description: "Welding",
+        Min_education: 2, Min_experience: 3,
+        Skills: []data.Job_skill{{Name: "qualifytest welding", Required: true}, {Name: "qualifytest painting"}}})
+    if err != nil {
+        t.Fatalf("CreateJob failed: %v", err)
+    }
+
+    applied_id, unmet, err := testStore.SubmitJobApplication("qualify.fit@example.com", job_id, "", nil)
+    if err != nil || applied_id != job_id || len(unmet) != 0 {
+        t.Errorf("the fit applicant should meet every requirement, got %v (%v)", unmet, err)
+    }
+    _, unmet, err = testStore.SubmitJobApplication("qualify.green@example.com", job_id, "", nil)
+    if err != nil {
+        t.Fatalf("an applicant who is not qualified should still apply, got %v", err)
+    }
+    expected := []data.Unmet_requirement{
+        {Requirement: data.RequirementExperience, Required: 3, Actual: 1},
+        {Requirement: data.RequirementSkill, Required: data.MinProficiency, Skill: "qualifytest welding"},
+    }
+    if len(unmet) != len(expected) {
+        t.Fatalf("expected %v, got %v", expected, unmet)
+    }
+    for i := range expected {
+        if unmet[i] != expected[i] {
+            t.Errorf("expected %v, got %v", expected, unmet)
+        }
+    }
+
+    candidates, total, err := testStore.SearchCandidates("qualify.boss@example.com", job_id, false, data.Page_request{})
+    if err != nil || total != 2 || !candidates[0].Qualified || candidates[1].Qualified ||
+       candidates[0].Experience != 5 || candidates[1].Education != 2 {
+        t.Errorf("only the fit applicant should be qualified, got %+v (%v)", candidates, err)
+    }
+    candidates, total, _ = testStore.SearchCandidates("qualify.boss@example.com", job_id, true, data.Page_request{})
+    if total != 1 || len(candidates) != 1 || candidates[0].Email != "qualify.fit@example.com" {
+        t.Errorf("expected only the fit applicant, got %+v", candidates)
+    }
+}
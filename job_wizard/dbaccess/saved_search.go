@@ -0,0 +1,242 @@
+package dbaccess
+// This module holds the database functions for saved searches: named
+// job search criteria that users keep so they can run them again
+// Each search remembers the highest job id when it was last run, so
+// the jobs posted since then can be counted as its new matches
+// The matches themselves are always found with SearchJobs
+
+import (
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+//**************** Private Functions *******************************//
+
+// Turn a saved search id into a string with leading zeros
+func searchIdString(idval int) string {
+    return fmt.Sprintf("%05d", idval)
+}
+
+// Return the highest job id so far, or 0 if there are no jobs
+// Jobs with higher ids are posted after this is called
+func maxJobId(conn queryer) (idval int, err error) {
+    err = conn.QueryRow("SELECT coalesce(MAX(id), 0) FROM job").Scan(&idval)
+    return idval, err
+}
+
+// Count the jobs matching a saved search that were posted after
+// the job with id seen_job_id. Works for any store
+func countNewMatches(store Store, search data.Saved_search, seen_job_id int) (count int, err error) {
+    criteria := search.Criteria
+    criteria.User_email = search.User_email
+    criteria.After_job_id = seen_job_id
+    _, count, err = store.SearchJobs(criteria, data.Page_request{Limit: 1})
+    return count, err
+}
+
+// Return a copy of a saved search with its time stamps, and the dates
+// in its criteria, ready to show to the user
+func displaySavedSearch(search data.Saved_search) data.Saved_search {
+    search.Created = data.DisplayTime(search.Created)
+    search.Last_viewed = data.DisplayTime(search.Last_viewed)
+    if search.Criteria.Posted_from != "" {
+        search.Criteria.Posted_from = data.DisplayTime(search.Criteria.Posted_from)
+    }
+    if search.Criteria.Posted_to != "" {
+        search.Criteria.Posted_to = data.DisplayTime(search.Criteria.Posted_to)
+    }
+    return search
+}
+
+// Pick out the saved searches that have new matches, for GetSearchAlerts
+func searchAlerts(searches []data.Saved_search) (alerts data.Search_alerts) {
+    alerts.Alerts = []data.Saved_search{}
+    for _, search := range searches {
+        if search.New_matches > 0 {
+            alerts.Alerts = append(alerts.Alerts, search)
+            alerts.Total_new += search.New_matches
+        }
+    }
+    return alerts
+}
+
+// Load one saved search belonging to a user, with the id of the
+// last job seen when it was run
+func loadSavedSearch(conn queryer, user_email string, idval int) (search data.Saved_search, seen_job_id int, err error) {
+    var criteria string
+    sqlcmd := "SELECT name, criteria, seen_job_id, created, last_viewed FROM saved_search WHERE id=? AND user_email=?"
+    err = conn.QueryRow(sqlcmd, idval, user_email).Scan(&search.Name, &criteria, &seen_job_id, &search.Created, &search.Last_viewed)
+    if err != nil {
+        return search, 0, data.NotFoundError("No matching saved search found")
+    }
+    err = json.Unmarshal([]byte(criteria), &search.Criteria)
+    if err != nil {
+        return search, 0, err
+    }
+    search.Search_id = searchIdString(idval)
+    search.User_email = user_email
+    return search, seen_job_id, nil
+}
+
+//******** Exported Functions *****************************//
+
+// Function to save a named search for search.User_email, whose
+// criteria have already been validated. Names are unique for each user
+// Jobs posted before the search is saved are not new matches
+// Returns the ID of the search, as a string with leading zeros
+func (store *sqlStore) CreateSavedSearch(search data.Saved_search) (search_id string, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return "", err
+    }
+    criteria, err := json.Marshal(search.Criteria)
+    if err != nil {
+        return "", err
+    }
+    tx, err := db.Begin()
+    if err != nil {
+        return "", err
+    }
+    var count int
+    err = tx.QueryRow("SELECT COUNT(*) FROM saved_search WHERE user_email=?", search.User_email).Scan(&count)
+    if err != nil {
+        tx.Rollback()
+        return "", err
+    }
+    if count >= data.MaxSavedSearches {
+        tx.Rollback()
+        return "", data.ConflictError(fmt.Sprintf("A user can have at most %d saved searches", data.MaxSavedSearches))
+    }
+    seen_job_id, err := maxJobId(tx)
+    if err != nil {
+        tx.Rollback()
+        return "", err
+    }
+    nowstring := data.StoredNow()
+    sqlcmd := "INSERT INTO saved_search (user_email, name, criteria, seen_job_id, created, last_viewed) values (?,?,?,?,?,?)"
+    id, err := insertReturningId(tx, sqlcmd, search.User_email, search.Name, string(criteria), seen_job_id, nowstring, nowstring)
+    if err != nil {
+        tx.Rollback()
+        if isUniqueViolation(err) {
+            return "", data.ConflictError("You already have a saved search with this name")
+        }
+        return "", err
+    }
+    err = tx.Commit()
+    if err != nil {
+        return "", err
+    }
+    return searchIdString(id), nil
+}
+
+// Function to list a user's saved searches, by default in order of name,
+// each with the number of new matches since it was last run
+// Returns one page of searches plus the total number
+func (store *sqlStore) ListSavedSearches(user_email string, page data.Page_request) (searches []data.Saved_search, total int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return searches, 0, err
+    }
+    err = db.QueryRow("SELECT COUNT(*) FROM saved_search WHERE user_email=?", user_email).Scan(&total)
+    if err != nil {
+        return searches, 0, err
+    }
+    clause, page_args := pageClause(page, savedSearchSortColumns, "name", "asc", "ss.id")
+    sqlcmd := "SELECT ss.id, ss.name, ss.criteria, ss.seen_job_id, ss.created, ss.last_viewed FROM saved_search ss WHERE ss.user_email=?" + clause
+    rows, err := db.Query(sqlcmd, append([]interface{}{user_email}, page_args...)...)
+    if err != nil {
+        return searches, 0, err
+    }
+    var seen []int
+    for rows.Next() {
+        var search data.Saved_search
+        var idval int
+        var seen_job_id int
+        var criteria string
+        err = rows.Scan(&idval, &search.Name, &criteria, &seen_job_id, &search.Created, &search.Last_viewed)
+        if err == nil {
+            err = json.Unmarshal([]byte(criteria), &search.Criteria)
+        }
+        if err != nil {
+            rows.Close()
+            return nil, 0, err
+        }
+        search.Search_id = searchIdString(idval)
+        search.User_email = user_email
+        searches = append(searches, search)
+        seen = append(seen, seen_job_id)
+    }
+    rows.Close()
+    // count the new matches once the rows are closed, so that
+    // SearchJobs does not need a second connection
+    for i := range searches {
+        searches[i].New_matches, err = countNewMatches(store, searches[i], seen[i])
+        if err != nil {
+            return nil, 0, err
+        }
+        searches[i] = displaySavedSearch(searches[i])
+    }
+    return searches, total, nil
+}
+
+// Function to run a saved search and return one page of the matching
+// jobs. The search is marked as viewed, so the jobs it finds are no
+// longer counted as new matches
+func (store *sqlStore) RunSavedSearch(user_email string, search_id string, page data.Page_request) (summaries []data.Job_summary, total int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return summaries, 0, err
+    }
+    idval, _ := strconv.Atoi(search_id)  // already validated the format
+    search, _, err := loadSavedSearch(db, user_email, idval)
+    if err != nil {
+        return summaries, 0, err
+    }
+    // find the last job before searching, so that a job posted in
+    // between is counted again rather than missed
+    seen_job_id, err := maxJobId(db)
+    if err != nil {
+        return summaries, 0, err
+    }
+    criteria := search.Criteria
+    criteria.User_email = user_email
+    summaries, total, err = store.SearchJobs(criteria, page)
+    if err != nil {
+        return summaries, 0, err
+    }
+    sqlcmd := "UPDATE saved_search SET seen_job_id=?, last_viewed=? WHERE id=?"
+    _, err = db.Exec(sqlcmd, seen_job_id, data.StoredNow(), idval)
+    if err != nil {
+        return summaries, 0, err
+    }
+    return summaries, total, nil
+}
+
+// Function to delete one of a user's saved searches
+func (store *sqlStore) DeleteSavedSearch(user_email string, search_id string) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    idval, _ := strconv.Atoi(search_id)  // already validated the format
+    result, err := db.Exec("DELETE FROM saved_search WHERE id=? AND user_email=?", idval, user_email)
+    if err != nil {
+        return err
+    }
+    if count, _ := result.RowsAffected(); count == 0 {
+        return data.NotFoundError("No matching saved search found")
+    }
+    return nil
+}
+
+// Function to return the saved searches of a user that have new
+// matches, with the total number of new matches
+func (store *sqlStore) GetSearchAlerts(user_email string) (alerts data.Search_alerts, err error) {
+    searches, _, err := store.ListSavedSearches(user_email, data.Page_request{})
+    if err != nil {
+        return alerts, err
+    }
+    return searchAlerts(searches), nil
+}
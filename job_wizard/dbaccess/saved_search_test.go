@@ -0,0 +1,101 @@
+package dbaccess
+// Tests for saved searches and their new matches
+
+import (
+    "fmt"
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+func TestSavedSearches(t *testing.T) {
+    mustRegister(t, "saved.seeker@example.com")
+    mustRegister(t, "saved.other@example.com")
+    mustRegister(t, "saved.boss@example.com")
+    old_id, _ := testStore.CreateJob(data.Job_info{Creator: "saved.boss@example.com", Title: "Beekeeper", Description: "Keeps bees", Salary: 30000})
+
+    search := data.Saved_search{User_email: "saved.seeker@example.com", Name: "Bees",
+                                Criteria: data.Search_criteria{Keyword: "Beekeeper", Salary: 20000}}
+    search_id, err := testStore.CreateSavedSearch(search)
+    if err != nil {
+        t.Fatalf("CreateSavedSearch failed: %v", err)
+    }
+    if _, err = testStore.CreateSavedSearch(search); data.ErrorCode(err) != data.CodeConflict {
+        t.Errorf("a second search with the same name should conflict, got %v", err)
+    }
+    other := search
+    other.User_email = "saved.other@example.com"
+    if _, err = testStore.CreateSavedSearch(other); err != nil {
+        t.Errorf("another user should be able to use the same name, got %v", err)
+    }
+
+    // jobs posted before the search was saved are not new
+    searches, total, err := testStore.ListSavedSearches("saved.seeker@example.com", data.Page_request{})
+    if err != nil || total != 1 || len(searches) != 1 || searches[0].New_matches != 0 || searches[0].Criteria.Salary != 20000 {
+        t.Fatalf("expected one search without new matches, got %+v, %d (%v)", searches, total, err)
+    }
+    new_id, _ := testStore.CreateJob(data.Job_info{Creator: "saved.boss@example.com", Title: "Beekeeper", Description: "Keeps more bees", Salary: 25000})
+    testStore.CreateJob(data.Job_info{Creator: "saved.boss@example.com", Title: "Beekeeper", Description: "Badly paid", Salary: 10000})
+    alerts, err := testStore.GetSearchAlerts("saved.seeker@example.com")
+    if err != nil || alerts.Total_new != 1 || len(alerts.Alerts) != 1 || alerts.Alerts[0].Search_id != search_id {
+        t.Errorf("expected one new match, got %+v (%v)", alerts, err)
+    }
+
+    // running the search finds every match and marks them as seen
+    summaries, total, err := testStore.RunSavedSearch("saved.seeker@example.com", search_id, data.Page_request{})
+    if err != nil || total != 2 {
+        t.Errorf("expected both matching jobs, got %+v (%v)", summaries, err)
+    }
+    found := map[string]bool{}
+    for _, summary := range summaries {
+        found[summary.Job_id] = true
+    }
+    if !found[old_id] || !found[new_id] {
+        t.Errorf("expected jobs %s and %s, got %+v", old_id, new_id, summaries)
+    }
+    alerts, _ = testStore.GetSearchAlerts("saved.seeker@example.com")
+    if alerts.Total_new != 0 || len(alerts.Alerts) != 0 {
+        t.Errorf("running the search should clear its new matches, got %+v", alerts)
+    }
+    alerts, _ = testStore.GetSearchAlerts("saved.other@example.com")
+    if alerts.Total_new != 1 {
+        t.Errorf("the other user's search should still have a new match, got %+v", alerts)
+    }
+
+    if _, _, err = testStore.RunSavedSearch("saved.other@example.com", search_id, data.Page_request{}); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("only the owner should run a search, got %v", err)
+    }
+    if err = testStore.DeleteSavedSearch("saved.other@example.com", search_id); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("only the owner should delete a search, got %v", err)
+    }
+    if err = testStore.DeleteSavedSearch("saved.seeker@example.com", search_id); err != nil {
+        t.Errorf("DeleteSavedSearch failed: %v", err)
+    }
+    if _, total, _ = testStore.ListSavedSearches("saved.seeker@example.com", data.Page_request{}); total != 0 {
+        t.Errorf("the search should be deleted, got %d", total)
+    }
+
+    // deleting a user deletes their searches
+    testStore.DeleteUser("saved.other@example.com")
+    mustRegister(t, "saved.other@example.com")
+    if _, total, _ = testStore.ListSavedSearches("saved.other@example.com", data.Page_request{}); total != 0 {
+        t.Errorf("a deleted user's searches should be deleted, got %d", total)
+    }
+}
+
+func TestSavedSearchLimitAndOrder(t *testing.T) {
+    mustRegister(t, "saved.many@example.com")
+    for i := data.MaxSavedSearches; i > 0; i-- {
+        search := data.Saved_search{User_email: "saved.many@example.com", Name: fmt.Sprintf("Search %02d", i)}
+        if _, err := testStore.CreateSavedSearch(search); err != nil {
+            t.Fatalf("CreateSavedSearch %d failed: %v", i, err)
+        }
+    }
+    search := data.Saved_search{User_email: "saved.many@example.com", Name: "One too many"}
+    if _, err := testStore.CreateSavedSearch(search); data.ErrorCode(err) != data.CodeConflict {
+        t.Errorf("expected the limit on saved searches, got %v", err)
+    }
+    searches, total, err := testStore.ListSavedSearches("saved.many@example.com", data.Page_request{Limit: 2, Offset: 1})
+    if err != nil || total != data.MaxSavedSearches || len(searches) != 2 || searches[0].Name != "Search 02" || searches[1].Name != "Search 03" {
+        t.Errorf("expected the second page in order of name, got %+v, %d (%v)", searches, total, err)
+    }
+}
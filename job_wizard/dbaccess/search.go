@@ -0,0 +1,303 @@
+package dbaccess
+// This module holds the full-text search support for job titles and
+// descriptions. When SQLite is built with FTS5 (go build -tags sqlite_fts5)
+// the job_fts virtual table is used for matching, relevance ranking and
+// highlighted snippets. Otherwise we fall back to LIKE matching and
+// build the highlights ourselves, so the search task works either way
+
+import (
+    "database/sql"
+    "strings"
+    "sync"
+    "unicode"
+)
+
+// Markers placed around matched words in highlighted titles and snippets
+const (
+    highlightStart = "<mark>"
+    highlightEnd   = "</mark>"
+    snippetEllipsis = "..."
+    snippetWords = 16   // approximate number of words in a snippet
+)
+
+// Sort columns for a full-text search: the same as jobSortColumns, but
+// relevance is the bm25 rank, with title matches counting ten times as much
+// as description matches. bm25 is lower for better matches, hence the minus
+var ftsJobSortColumns = map[string][]string{}
+
+func init() {
+    for field, columns := range jobSortColumns {
+        ftsJobSortColumns[field] = columns
+    }
+    ftsJobSortColumns["relevance"] = []string{"-bm25(job_fts, 10.0, 1.0)"}
+}
+
+// Whether the full-text index is used, decided once by fullTextEnabled
+var (
+    ftsOnce    sync.Once
+    ftsEnabled bool
+)
+
+// Anything with an Exec method - either the db or a transaction
+type execer interface {
+    Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// One term from a keyword search: a single word, possibly a prefix
+// such as "dev*", or a phrase given in double quotes. A word with
+// punctuation inside, such as "snake_case", is treated as a phrase
+// The raw text is what the user typed, used when FTS5 is not available
+type searchTerm struct {
+    text   string
+    raw    string
+    prefix bool
+}
+
+//**************** Private Functions *******************************//
+
+// Return true if SQLite was built with FTS5. Never true for PostgreSQL
+func fts5Available(conn *sql.DB) bool {
+    if isPostgres(conn) {
+        return false
+    }
+    var used int
+    err := conn.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used)
+    return err == nil && used != 0
+}
+
+// Rebuild the full-text index if any job is missing from it or differs
+// from it, or it holds a job that no longer exists. That happens when a
+// build without FTS5 has changed the jobs, since it cannot touch the index
+func syncFullText(conn *sql.DB) (err error) {
+    var stale int
+    sqlcmd := "SELECT (SELECT COUNT(*) FROM job j WHERE NOT EXISTS (SELECT 1 FROM job_fts f"
+    sqlcmd += " WHERE f.rowid=j.id AND f.title IS j.title AND f.description IS j.description))"
+    sqlcmd += " + (SELECT COUNT(*) FROM job_fts f WHERE NOT EXISTS (SELECT 1 FROM job j WHERE j.id=f.rowid))"
+    err = conn.QueryRow(sqlcmd).Scan(&stale)
+    if err != nil || stale == 0 {
+        return err
+    }
+    _, err = conn.Exec("DELETE FROM job_fts")
+    if err == nil {
+        _, err = conn.Exec("INSERT INTO job_fts (rowid, title, description) SELECT id, title, description FROM job")
+    }
+    return err
+}
+
+// Return true if the full-text index can be used
+// It never is for PostgreSQL, which uses the ILIKE fallback
+// The index is created by the migrations in migrations/fts5 when SQLite
+// has FTS5. The first call checks that it is there, and rebuilds it if
+// it is out of step with the job table
+func fullTextEnabled() bool {
+    if db == nil {
+        return false
+    }
+    ftsOnce.Do(func() {
+        ftsEnabled = fts5Available(db) && tableExists(db, "job_fts") && syncFullText(db) == nil
+    })
+    return ftsEnabled
+}
+
+// Have the full-text index checked again on its next use, after the
+// schema has changed. Not safe while other goroutines are searching
+func resetFullText() {
+    ftsOnce = sync.Once{}
+    ftsEnabled = false
+}
+
+// Copy the current title and description of a job into the full-text index
+// Does nothing if the index is not in use
+func indexJob(conn execer, job_id int) (err error) {
+    if !fullTextEnabled() {
+        return nil
+    }
+    _, err = conn.Exec("DELETE FROM job_fts WHERE rowid=?", job_id)
+    if err != nil {
+        return err
+    }
+    _, err = conn.Exec("INSERT INTO job_fts (rowid, title, description) SELECT id, title, description FROM job WHERE id=?", job_id)
+    return err
+}
+
+// Remove a deleted job from the full-text index
+// Does nothing if the index is not in use
+func unindexJob(conn execer, job_id int) (err error) {
+    if !fullTextEnabled() {
+        return nil
+    }
+    _, err = conn.Exec("DELETE FROM job_fts WHERE rowid=?", job_id)
+    return err
+}
+
+// Split a keyword string into search terms
+// Text in double quotes is a phrase; a word ending in * is a prefix
+// Punctuation is dropped, so the terms can never break the query syntax
+func parseKeyword(keyword string) (terms []searchTerm) {
+    parts := strings.Split(keyword, "\"")
+    for i, part := range parts {
+        if i % 2 == 1 {
+            // inside quotes
+            phrase := strings.Join(searchWords(part), " ")
+            if phrase != "" {
+                terms = append(terms, searchTerm{text: phrase, raw: strings.TrimSpace(part)})
+            }
+            continue
+        }
+        for _, field := range strings.Fields(part) {
+            prefix := strings.HasSuffix(field, "*")
+            raw := strings.TrimSuffix(field, "*")
+            phrase := strings.Join(searchWords(raw), " ")
+            if phrase != "" {
+                terms = append(terms, searchTerm{text: phrase, raw: raw, prefix: prefix})
+            }
+        }
+    }
+    return terms
+}
+
+// Break text into words made of letters and digits
+func searchWords(text string) []string {
+    return strings.FieldsFunc(text, func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+}
+
+// Build an FTS5 MATCH expression that requires every term
+func ftsQuery(terms []searchTerm) string {
+    var parts []string
+    for _, term := range terms {
+        part := "\"" + term.text + "\""
+        if term.prefix {
+            part += "*"
+        }
+        parts = append(parts, part)
+    }
+    return strings.Join(parts, " ")
+}
+
+// Build LIKE clauses requiring the raw text of each term in either the title
+// or the description. Returns the clauses and the values for their '?' placeholders
+func likeClauses(terms []searchTerm) (clauses []string, args []interface{}) {
+    for _, term := range terms {
+        pattern := "%" + escapeLike(term.raw) + "%"
+        clauses = append(clauses, "(j.title like ? escape '\\' or j.description like ? escape '\\')")
+        args = append(args, pattern, pattern)
+    }
+    return clauses, args
+}
+
+// Return true if a word from the text matches a word from a search term,
+// ignoring case. If prefix is true the term word only has to start the text word
+func wordMatches(candidate string, word string, prefix bool) bool {
+    if prefix {
+        return strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(word))
+    }
+    return strings.EqualFold(candidate, word)
+}
+
+// Find the words in text as [start,end) byte offsets
+func wordOffsets(text string) (offsets [][2]int) {
+    start := -1
+    for i, r := range text {
+        inword := unicode.IsLetter(r) || unicode.IsDigit(r)
+        if inword && start < 0 {
+            start = i
+        } else if !inword && start >= 0 {
+            offsets = append(offsets, [2]int{start, i})
+            start = -1
+        }
+    }
+    if start >= 0 {
+        offsets = append(offsets, [2]int{start, len(text)})
+    }
+    return offsets
+}
+
+// Find which words match the terms. Phrases match a run of words
+func matchedWords(text string, offsets [][2]int, terms []searchTerm) []bool {
+    matched := make([]bool, len(offsets))
+    for _, term := range terms {
+        phrase := strings.Fields(term.text)
+        for i := 0; i + len(phrase) <= len(offsets); i++ {
+            found := true
+            for k, word := range phrase {
+                candidate := text[offsets[i+k][0]:offsets[i+k][1]]
+                // only the last word of a phrase can be a prefix
+                prefix := term.prefix && k == len(phrase) - 1
+                if !wordMatches(candidate, word, prefix) {
+                    found = false
+                    break
+                }
+            }
+            if found {
+                for k := range phrase {
+                    matched[i+k] = true
+                }
+            }
+        }
+    }
+    return matched
+}
+
+// Wrap the words from first to last (inclusive) of text in highlight
+// markers where they match
+func markWords(text string, offsets [][2]int, matched []bool, first int, last int) string {
+    var builder strings.Builder
+    position := offsets[first][0]
+    for i := first; i <= last; i++ {
+        builder.WriteString(text[position:offsets[i][0]])
+        word := text[offsets[i][0]:offsets[i][1]]
+        if matched[i] {
+            builder.WriteString(highlightStart + word + highlightEnd)
+        } else {
+            builder.WriteString(word)
+        }
+        position = offsets[i][1]
+    }
+    return builder.String()
+}
+
+// Highlight every match of the terms in a short text such as a title
+func highlightText(text string, terms []searchTerm) string {
+    offsets := wordOffsets(text)
+    if len(offsets) == 0 {
+        return text
+    }
+    matched := matchedWords(text, offsets, terms)
+    return text[:offsets[0][0]] + markWords(text, offsets, matched, 0, len(offsets)-1) +
+        text[offsets[len(offsets)-1][1]:]
+}
+
+// Return an excerpt of about snippetWords words around the first match
+// of the terms, with matches highlighted, like the FTS5 snippet() function
+func makeSnippet(text string, terms []searchTerm) string {
+    offsets := wordOffsets(text)
+    if len(offsets) == 0 {
+        return ""
+    }
+    matched := matchedWords(text, offsets, terms)
+    first := 0
+    for i := range matched {
+        if matched[i] {
+            first = i
+            break
+        }
+    }
+    first -= snippetWords / 4
+    if first < 0 {
+        first = 0
+    }
+    last := first + snippetWords - 1
+    if last >= len(offsets) {
+        last = len(offsets) - 1
+    }
+    snippet := markWords(text, offsets, matched, first, last)
+    if first > 0 {
+        snippet = snippetEllipsis + snippet
+    }
+    if last < len(offsets) - 1 {
+        snippet += snippetEllipsis
+    }
+    return snippet
+}
@@ -0,0 +1,98 @@
+package dbaccess
+// Tests for keyword search over job titles and descriptions
+// These pass with or without FTS5; run with -tags sqlite_fts5 to
+// exercise the full-text index and ranking
+
+import (
+    "strings"
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// return the ids of the summaries, in order
+func summaryIds(summaries []data.Job_summary) (ids []string) {
+    for _, summary := range summaries {
+        ids = append(ids, summary.Job_id)
+    }
+    return ids
+}
+
+func TestKeywordSearchesDescription(t *testing.T) {
+    mustRegister(t, "fts.owner@example.com")
+    frontend, _ := testStore.CreateJob(data.Job_info{Creator: "fts.owner@example.com", Title: "Front End Developer", Description: "Design and build attractive and highly usable UIs using React and JavaScript"})
+    reactlead, _ := testStore.CreateJob(data.Job_info{Creator: "fts.owner@example.com", Title: "Zebrafish React Lead", Description: "Lead a team of engineers working with zebrafish"})
+    backend, _ := testStore.CreateJob(data.Job_info{Creator: "fts.owner@example.com", Title: "Back End Zebrafish Developer", Description: "Microservices and REST APIs for zebrafish research in Go"})
+
+    summaries, _, err := testStore.SearchJobs(data.Search_criteria{Keyword: "React"}, data.Page_request{})
+    if err != nil {
+        t.Fatalf("SearchJobs failed: %v", err)
+    }
+    ids := summaryIds(summaries)
+    if len(ids) != 2 || !strings.Contains(strings.Join(ids, ","), frontend) {
+        t.Errorf("React should match the Front End job by description, got %v", ids)
+    }
+    if fullTextEnabled() && ids[0] != reactlead {
+        t.Errorf("a title match should rank above a description match, got %v", ids)
+    }
+
+    // every word must match
+    summaries, _, _ = testStore.SearchJobs(data.Search_criteria{Keyword: "zebrafish developer"}, data.Page_request{})
+    if ids = summaryIds(summaries); len(ids) != 1 || ids[0] != backend {
+        t.Errorf("expected only %s for two words, got %v", backend, ids)
+    }
+    // a quoted phrase must match as a phrase
+    summaries, _, _ = testStore.SearchJobs(data.Search_criteria{Keyword: "\"team of engineers\""}, data.Page_request{})
+    if ids = summaryIds(summaries); len(ids) != 1 || ids[0] != reactlead {
+        t.Errorf("expected only %s for the phrase, got %v", reactlead, ids)
+    }
+    summaries, _, _ = testStore.SearchJobs(data.Search_criteria{Keyword: "\"engineers of team\""}, data.Page_request{})
+    if len(summaries) != 0 {
+        t.Errorf("words out of order should not match a phrase, got %v", summaryIds(summaries))
+    }
+    // prefix search
+    summaries, _, _ = testStore.SearchJobs(data.Search_criteria{Keyword: "microserv*"}, data.Page_request{})
+    if ids = summaryIds(summaries); len(ids) != 1 || ids[0] != backend {
+        t.Errorf("expected only %s for the prefix, got %v", backend, ids)
+    }
+    if len(summaries) == 1 && !strings.Contains(summaries[0].Snippet, "<mark>Microservices</mark>") {
+        t.Errorf("snippet should highlight the match, got %q", summaries[0].Snippet)
+    }
+
+    // unmatched syntax must not cause an error
+    for _, keyword := range []string{"\"unbalanced", "AND OR NOT", "title:react", "NEAR(a b)", "*"} {
+        if _, _, err = testStore.SearchJobs(data.Search_criteria{Keyword: keyword}, data.Page_request{}); err != nil {
+            t.Errorf("SearchJobs(%q) returned error: %v", keyword, err)
+        }
+    }
+
+    // the index follows changes to the job
+    kotlin := "Now using Kotlin instead"
+    testStore.ModifyJob(data.Job_changes{Creator: "fts.owner@example.com", Job_id: backend, Description: &kotlin})
+    summaries, _, _ = testStore.SearchJobs(data.Search_criteria{Keyword: "kotlin"}, data.Page_request{})
+    if ids = summaryIds(summaries); len(ids) != 1 || ids[0] != backend {
+        t.Errorf("modified description should be searchable, got %v", ids)
+    }
+    summaries, _, _ = testStore.SearchJobs(data.Search_criteria{Keyword: "microservices"}, data.Page_request{})
+    if len(summaries) != 0 {
+        t.Errorf("old description should no longer match, got %v", summaryIds(summaries))
+    }
+}
+
+func TestHighlighting(t *testing.T) {
+    terms := parseKeyword("react \"user interfaces\" dev*")
+    if len(terms) != 3 || terms[1].text != "user interfaces" || !terms[2].prefix {
+        t.Fatalf("unexpected terms %+v", terms)
+    }
+    title := highlightText("React Developer, user interfaces", terms)
+    expected := "<mark>React</mark> <mark>Developer</mark>, <mark>user</mark> <mark>interfaces</mark>"
+    if title != expected {
+        t.Errorf("expected %q, got %q", expected, title)
+    }
+    long := "one two three four five six seven eight nine ten eleven twelve thirteen react fifteen " +
+        "sixteen seventeen eighteen nineteen twenty twentyone twentytwo twentythree twentyfour twentyfive thirty"
+    snippet := makeSnippet(long, terms)
+    if !strings.HasPrefix(snippet, snippetEllipsis) || !strings.HasSuffix(snippet, snippetEllipsis) ||
+        !strings.Contains(snippet, "<mark>react</mark>") {
+        t.Errorf("unexpected snippet %q", snippet)
+    }
+}
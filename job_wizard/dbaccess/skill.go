@@ -0,0 +1,217 @@
+package dbaccess
+// This module holds the database functions for skills: the list of
+// known skills, the skills jobs ask for and users have, and the match
+// score of a user for a job, which both stores calculate the same way
+// Skill names are stored in lower case, as helper normalizes them
+
+import (
+    "database/sql"
+    "sort"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Either the db or a transaction, for functions that read and write
+type sqlConn interface {
+    queryer
+    execer
+    Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Selects the match score of a user for job j, as described for
+// data.Job_skill, or -1 if the job lists no skills
+// The user is given by userclause, an SQL value such as "?" or a column
+// Integer division rounds down in both SQLite and PostgreSQL
+const matchScoreColumn = "coalesce((SELECT 100 * SUM((CASE WHEN js.required THEN 2 ELSE 1 END) * coalesce(us.proficiency, 0))" +
+    " / (%d * SUM(CASE WHEN js.required THEN 2 ELSE 1 END))" +
+    " FROM job_skill js LEFT JOIN user_skill us ON us.skill_id = js.skill_id AND us.user_email = %s" +
+    " WHERE js.job_id = j.id), -1) AS match_score"
+
+//**************** Private Functions *******************************//
+
+// Return the weight of a job skill in the match score
+func skillWeight(skill data.Job_skill) int {
+    if skill.Required {
+        return 2
+    }
+    return 1
+}
+
+// Return the match score of a user with the given proficiencies, keyed
+// by skill name, for a job with the given skills, or -1 if there are none
+// This is the calculation done by matchScoreColumn
+func matchScore(job_skills []data.Job_skill, proficiencies map[string]int) int {
+    if len(job_skills) == 0 {
+        return -1
+    }
+    total := 0
+    matched := 0
+    for _, skill := range job_skills {
+        total += skillWeight(skill)
+        matched += skillWeight(skill) * proficiencies[skill.Name]
+    }
+    return 100 * matched / (data.MaxProficiency * total)
+}
+
+// Turn a score from matchScore or matchScoreColumn into the value
+// returned to users, which is nil if the job lists no skills
+func scoreValue(score int) *int {
+    if score < 0 {
+        return nil
+    }
+    return &score
+}
+
+// Return a copy of a sort column map that can also sort by match score
+func withMatchSort(columns map[string][]string) map[string][]string {
+    match_columns := map[string][]string{"match": {"match_score"}}
+    for field, sort_columns := range columns {
+        match_columns[field] = sort_columns
+    }
+    return match_columns
+}
+
+// Sort a job's skills the way they are returned: required first, then by name
+func sortJobSkills(skills []data.Job_skill) {
+    sort.SliceStable(skills, func(i, j int) bool {
+        if skills[i].Required != skills[j].Required {
+            return skills[i].Required
+        }
+        return skills[i].Name < skills[j].Name
+    })
+}
+
+// Return the id of a skill, adding it to the skill table if it is new
+func skillId(conn sqlConn, name string) (id int, err error) {
+    row := conn.QueryRow("SELECT id FROM skill WHERE name=?", name)
+    err = row.Scan(&id)
+    if err == nil {
+        return id, nil
+    }
+    if err != sql.ErrNoRows {
+        return 0, err
+    }
+    _, err = conn.Exec("INSERT INTO skill (name, created) values (?,?)", name, data.StoredNow())
+    if err != nil {
+        return 0, err
+    }
+    row = conn.QueryRow("SELECT id FROM skill WHERE name=?", name)
+    err = row.Scan(&id)
+    return id, err
+}
+
+// Replace the skills of a job
+func saveJobSkills(conn sqlConn, job_idval int, skills []data.Job_skill) (err error) {
+    _, err = conn.Exec("DELETE FROM job_skill WHERE job_id=?", job_idval)
+    if err != nil {
+        return err
+    }
+    for _, skill := range skills {
+        id, err := skillId(conn, skill.Name)
+        if err != nil {
+            return err
+        }
+        _, err = conn.Exec("INSERT INTO job_skill (job_id, skill_id, required) values (?,?,?)", job_idval, id, skill.Required)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Return the skills of a job, required first and then by name
+func loadJobSkills(conn sqlConn, job_idval int) (skills []data.Job_skill, err error) {
+    sqlcmd := "SELECT s.name, js.required FROM job_skill js, skill s WHERE js.skill_id = s.id AND js.job_id=?" +
+        " ORDER BY js.required DESC, s.name"
+    rows, err := conn.Query(sqlcmd, job_idval)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    skills = []data.Job_skill{}
+    for rows.Next() {
+        var skill data.Job_skill
+        err = rows.Scan(&skill.Name, &skill.Required)
+        if err != nil {
+            return nil, err
+        }
+        skills = append(skills, skill)
+    }
+    return skills, nil
+}
+
+// Replace the skills of a user
+func saveUserSkills(conn sqlConn, user_email string, skills []data.User_skill) (err error) {
+    _, err = conn.Exec("DELETE FROM user_skill WHERE user_email=?", user_email)
+    if err != nil {
+        return err
+    }
+    for _, skill := range skills {
+        id, err := skillId(conn, skill.Name)
+        if err != nil {
+            return err
+        }
+        _, err = conn.Exec("INSERT INTO user_skill (user_email, skill_id, proficiency) values (?,?,?)", user_email, id, skill.Proficiency)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Return the skills of a user, by name
+func loadUserSkills(conn sqlConn, user_email string) (skills []data.User_skill, err error) {
+    sqlcmd := "SELECT s.name, us.proficiency FROM user_skill us, skill s WHERE us.skill_id = s.id AND us.user_email=? ORDER BY s.name"
+    rows, err := conn.Query(sqlcmd, user_email)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    skills = []data.User_skill{}
+    for rows.Next() {
+        var skill data.User_skill
+        err = rows.Scan(&skill.Name, &skill.Proficiency)
+        if err != nil {
+            return nil, err
+        }
+        skills = append(skills, skill)
+    }
+    return skills, nil
+}
+
+//******** Exported Functions *****************************//
+
+// Function to list the known skills in order of name. The keyword,
+// if given, must be part of the name
+// Returns one page of skills plus the total number
+func (store *sqlStore) ListSkills(keyword string, page data.Page_request) (skills []data.Skill, total int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return skills, 0, err
+    }
+    fromclause := "FROM skill s"
+    var args []interface{}
+    if keyword != "" {
+        fromclause += " where s.name like ? escape '\\'"
+        args = append(args, "%" + escapeLike(keyword) + "%")
+    }
+    row := db.QueryRow("SELECT COUNT(*) " + fromclause, args...)
+    err = row.Scan(&total)
+    if err != nil {
+        return skills, 0, err
+    }
+    clause, page_args := pageClause(page, skillSortColumns, "name", "asc", "s.id")
+    rows, err := db.Query("SELECT s.name " + fromclause + clause, append(args, page_args...)...)
+    if err != nil {
+        return skills, 0, err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var skill data.Skill
+        err = rows.Scan(&skill.Name)
+        if err != nil {
+            return skills, 0, err
+        }
+        skills = append(skills, skill)
+    }
+    return skills, total, nil
+}
@@ -0,0 +1,121 @@
+package dbaccess
+// Tests for job and user skills, skill searches and match scores
+
+import (
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+func TestMatchScore(t *testing.T) {
+    job_skills := []data.Job_skill{{Name: "go", Required: true}, {Name: "sql", Required: true}, {Name: "docker"}}
+    scores := []struct {
+        proficiencies map[string]int
+        expected      int
+    }{
+        {map[string]int{}, 0},
+        {map[string]int{"go": 5, "sql": 5, "docker": 5}, 100},
+        // (2*5 + 2*3 + 1*0) / (5 * 5) = 64%
+        {map[string]int{"go": 5, "sql": 3, "cobol": 5}, 64},
+        // 1*4 / 25 = 16%
+        {map[string]int{"docker": 4}, 16},
+    }
+    for i, score := range scores {
+        if got := matchScore(job_skills, score.proficiencies); got != score.expected {
+            t.Errorf("score %d: expected %d, got %d", i, score.expected, got)
+        }
+    }
+    if got := matchScore(nil, map[string]int{"go": 5}); got != -1 {
+        t.Errorf("a job without skills should have no score, got %d", got)
+    }
+}
+
+func TestJobSkills(t *testing.T) {
+    mustRegister(t, "skill.boss@example.com")
+    mustRegister(t, "skill.expert@example.com")
+    mustRegister(t, "skill.novice@example.com")
+    testStore.UpdateUserProfile("skill.expert@example.com", "", "", "", nil, "", nil,
+        []data.User_skill{{Name: "skilltest go", Proficiency: 5}, {Name: "skilltest sql", Proficiency: 4}}, nil)
+    testStore.UpdateUserProfile("skill.novice@example.com", "", "", "", nil, "", nil,
+        []data.User_skill{{Name: "skilltest docker", Proficiency: 2}}, nil)
+
+    backend_id, err := testStore.CreateJob(data.Job_info{Creator: "skill.boss@example.com", Title: "Skilled Backend", Description: "Go and SQL",
+        Skills: []data.Job_skill{{Name: "skilltest docker"}, {Name: "skilltest sql", Required: true}, {Name: "skilltest go", Required: true}}})
+    if err != nil {
+        t.Fatalf("CreateJob failed: %v", err)
+    }
+    ops_id, _ := testStore.CreateJob(data.Job_info{Creator: "skill.boss@example.com", Title: "Skilled Operations", Description: "Containers",
+        Skills: []data.Job_skill{{Name: "skilltest docker", Required: true}}})
+    plain_id, _ := testStore.CreateJob(data.Job_info{Creator: "skill.boss@example.com", Title: "Skilled Nothing", Description: "No skills listed"})
+
+    found, err := testStore.GetJobDetail(backend_id)
+    if err != nil {
+        t.Fatalf("GetJobDetail failed: %v", err)
+    }
+    if len(found.Skills) != 3 || found.Skills[0].Name != "skilltest go" || !found.Skills[1].Required || found.Skills[2].Name != "skilltest docker" {
+        t.Errorf("the skills should be required first, then by name, got %v", found.Skills)
+    }
+    profile, _ := testStore.GetUserProfile("skill.expert@example.com")
+    if len(profile.Skills) != 2 || profile.Skills[0].Name != "skilltest go" || profile.Skills[0].Proficiency != 5 {
+        t.Errorf("the profile should have the user's skills, got %v", profile.Skills)
+    }
+
+    // both stores must calculate the same scores
+    summaries, _, err := testStore.SearchJobs(data.Search_criteria{User_email: "skill.expert@example.com", Keyword: "Skilled"},
+        data.Page_request{Sort: "match", Order: "desc"})
+    if err != nil {
+        t.Fatalf("SearchJobs failed: %v", err)
+    }
+    if len(summaries) != 3 || summaries[0].Job_id != backend_id || summaries[0].Match_score == nil || *summaries[0].Match_score != 72 {
+        t.Fatalf("the backend job should match best with 72, got %v", summaries)
+    }
+    if summaries[1].Job_id != ops_id || *summaries[1].Match_score != 0 || summaries[2].Job_id != plain_id || summaries[2].Match_score != nil {
+        t.Errorf("expected operations with 0 and then no score, got %v", summaries)
+    }
+
+    ids := searchIds(t, data.Search_criteria{Skills: []string{"skilltest docker"}})
+    if len(ids) != 2 || !ids[backend_id] || !ids[ops_id] {
+        t.Errorf("expected both jobs with docker, got %v", ids)
+    }
+    ids = searchIds(t, data.Search_criteria{Skills: []string{"skilltest docker", "skilltest go"}})
+    if len(ids) != 1 || !ids[backend_id] {
+        t.Errorf("every skill given should be needed, got %v", ids)
+    }
+
+    // candidates sorted by how well they match
+    testStore.SubmitJobApplication("skill.novice@example.com", backend_id, "", nil)
+    testStore.SubmitJobApplication("skill.expert@example.com", backend_id, "", nil)
+    candidates, _, err := testStore.SearchCandidates("skill.boss@example.com", backend_id, false, data.Page_request{Sort: "match", Order: "desc"})
+    if err != nil {
+        t.Fatalf("SearchCandidates failed: %v", err)
+    }
+    if len(candidates) != 2 || candidates[0].Email != "skill.expert@example.com" || *candidates[0].Match_score != 72 ||
+       *candidates[1].Match_score != 8 {
+        t.Errorf("expected the expert first, got %v", candidates)
+    }
+
+    // changing only the skills replaces them all
+    skills := []data.Job_skill{{Name: "skilltest kubernetes", Required: true}}
+    if _, err = testStore.ModifyJob(data.Job_changes{Creator: "skill.boss@example.com", Job_id: ops_id, Skills: &skills}); err != nil {
+        t.Fatalf("ModifyJob failed: %v", err)
+    }
+    found, _ = testStore.GetJobDetail(ops_id)
+    if len(found.Skills) != 1 || found.Skills[0].Name != "skilltest kubernetes" || found.Title != "Skilled Operations" {
+        t.Errorf("only the skills should have changed, got %+v", found)
+    }
+    known, total, err := testStore.ListSkills("skilltest", data.Page_request{})
+    if err != nil || total != 4 || known[0].Name != "skilltest docker" || known[3].Name != "skilltest sql" {
+        t.Errorf("expected the four test skills by name, got %v (%v)", known, err)
+    }
+
+    // an empty list removes a user's skills, nil leaves them alone
+    testStore.UpdateUserProfile("skill.novice@example.com", "Nova", "", "", nil, "", nil, nil, nil)
+    profile, _ = testStore.GetUserProfile("skill.novice@example.com")
+    if len(profile.Skills) != 1 {
+        t.Errorf("the skills should not change, got %v", profile.Skills)
+    }
+    testStore.UpdateUserProfile("skill.novice@example.com", "", "", "", nil, "", nil, []data.User_skill{}, nil)
+    profile, _ = testStore.GetUserProfile("skill.novice@example.com")
+    if len(profile.Skills) != 0 {
+        t.Errorf("the skills should be removed, got %v", profile.Skills)
+    }
+}
@@ -0,0 +1,126 @@
+package dbaccess
+// This module defines the Store interface, which covers everything the
+// rest of JobWizard needs to keep: users and their sessions, employer
+// organizations, jobs, and applications. There are two implementations:
+// sqlStore, the SQLite or PostgreSQL database used normally, and
+// memoryStore, which keeps everything in memory for tests and demo mode.
+// The helper, api and main packages reach the data only through GetStore()
+
+import (
+    "time"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Operations on users, skills, organizations, jobs, applications, bookmarks and saved searches
+// Job and organization IDs are strings with leading zeros, as shown to users. Time stamps
+// are kept in data.StoredTimeFormat and returned by data.DisplayTime.
+// Listings return one page of results plus the total number matching.
+// Errors a user can cause are data.App_errors, so every implementation
+// reports them the same way
+type Store interface {
+    CheckConnection() bool
+
+    // Users and sessions
+    IsRegisteredUser(user_email string) (bRegistered bool, err error)
+    RegisterUser(user_email string, first_name string, last_name string, phone string, education int, password string,
+                 experience *int, skills []data.User_skill, work_history []data.Work_entry) (err error)
+    GetUserProfile(user_email string) (profile data.User_info, err error)
+    UpdateUserProfile(user_email string, first_name string, last_name string, phone string, education *int,
+                      password string, experience *int, skills []data.User_skill, work_history []data.Work_entry) (err error)
+    DeleteUser(user_email string) (err error)
+    CheckPassword(user_email string, password string) (bOk bool, err error)
+    CreateSession(user_email string) (token string, expires time.Time, err error)
+    GetSessionUser(token string) (user_email string, err error)
+    DeleteSession(token string) (err error)
+
+    // Resumes; the content of each is kept in the blob store (see blob.go)
+    SetUserResume(user_email string, resume *data.Document) (err error)
+    GetUserResume(user_email string) (resume data.Document, err error)
+    GetApplicationResume(creator_email string, job_id string, applicant_email string) (resume data.Document, err error)
+
+    // Skills; the skills of jobs and users are kept with them
+    ListSkills(keyword string, page data.Page_request) (skills []data.Skill, total int, err error)
+
+    // Organizations and their members
+    CreateOrganization(org data.Organization) (org_id string, err error)
+    GetOrganization(org_id string) (org data.Organization, err error)
+    UpdateOrganization(org data.Organization) (err error)
+    SearchOrganizations(keyword string, member_email string, page data.Page_request) (orgs []data.Organization, total int, err error)
+    SetOrganizationMember(change data.Member_change) (err error)
+    RemoveOrganizationMember(change data.Member_change) (err error)
+
+    // Jobs
+    CreateJob(job data.Job_info) (job_id string, err error)
+    SearchJobs(criteria data.Search_criteria, page data.Page_request) (summaries []data.Job_summary, total int, err error)
+    SearchOfferedJobs(user_email string, page data.Page_request) (summaries []data.Job_summary, total int, err error)
+    GetJobDetail(job_id string) (foundjob data.Job_info, err error)
+    ModifyJob(changes data.Job_changes) (return_job_id string, err error)
+    ArchiveJob(creator_email string, job_id string) (err error)
+    DeleteJob(creator_email string, job_id string) (err error)
+    CloseExpiredJobs() (closed int, err error)
+
+    // Applications
+    SubmitJobApplication(user_email string, job_id string, cover_letter string,
+                         resume *data.Document) (applied_job_id string, unmet []data.Unmet_requirement, err error)
+    SearchCandidates(creator_email string, job_id string, qualified_only bool, page data.Page_request) (candidates []data.Candidate, total int, err error)
+    UpdateApplicationStatus(creator_email string, job_id string, applicant_email string, status string) (err error)
+    WithdrawApplication(user_email string, job_id string) (withdrawn_job_id string, err error)
+    SearchAppliedJobs(user_email string, page data.Page_request) (applications []data.Application_summary, total int, err error)
+
+    // Bookmarks
+    AddBookmark(user_email string, job_id string) (err error)
+    RemoveBookmark(user_email string, job_id string) (err error)
+    ListBookmarks(user_email string, page data.Page_request) (bookmarks []data.Bookmark, total int, err error)
+
+    // Saved searches; their matches are found with SearchJobs (see saved_search.go)
+    CreateSavedSearch(search data.Saved_search) (search_id string, err error)
+    ListSavedSearches(user_email string, page data.Page_request) (searches []data.Saved_search, total int, err error)
+    RunSavedSearch(user_email string, search_id string, page data.Page_request) (summaries []data.Job_summary, total int, err error)
+    DeleteSavedSearch(user_email string, search_id string) (err error)
+    GetSearchAlerts(user_email string) (alerts data.Search_alerts, err error)
+
+    // Webhooks; events are queued when jobs and applications change (see webhook.go)
+    CreateWebhook(hook data.Webhook) (created data.Webhook, err error)
+    ListWebhooks(user_email string, page data.Page_request) (hooks []data.Webhook, total int, err error)
+    DeleteWebhook(user_email string, webhook_id string) (err error)
+    ListWebhookDeliveries(user_email string, webhook_id string, page data.Page_request) (deliveries []data.Webhook_delivery, total int, err error)
+    DueWebhookDeliveries(limit int) (deliveries []data.Webhook_delivery, err error)
+    RecordWebhookAttempt(delivery_id string, delivered bool, response_code int, message string) (err error)
+
+    // Email notifications; queued when applications change (see notification.go)
+    GetNotificationPreferences(user_email string) (prefs data.Notification_preferences, err error)
+    SetNotificationPreferences(changes data.Notification_changes) (prefs data.Notification_preferences, err error)
+    DueNotifications(limit int) (notifications []data.Notification, err error)
+    RecordNotificationAttempt(notification_id string, sent bool, message string) (err error)
+
+    // Messages in the thread of a job application (see message.go)
+    SendMessage(message data.Message) (sent data.Message, err error)
+    ListMessages(user_email string, job_id string, applicant string, page data.Page_request) (messages []data.Message, total int, err error)
+    MarkMessagesRead(user_email string, job_id string, applicant string) (marked int, err error)
+    GetUnreadCounts(user_email string) (counts data.Unread_counts, err error)
+}
+
+// The SQL implementation. The connection is the module global db,
+// opened on first use from JOBWIZARD_DB_DRIVER and JOBWIZARD_DB_NAME
+type sqlStore struct{}
+
+// The store used by GetStore, the database unless SetStore has been called
+var activeStore Store = NewSqlStore()
+
+//******** Exported Functions *****************************//
+
+// Return a store backed by the database named by JOBWIZARD_DB_NAME
+func NewSqlStore() Store {
+    return &sqlStore{}
+}
+
+// Replace the store used by the whole program, for instance
+// with NewMemoryStore() for demo mode or tests
+func SetStore(store Store) {
+    activeStore = store
+}
+
+// Return the store in use
+func GetStore() Store {
+    return activeStore
+}
@@ -0,0 +1,227 @@
+package dbaccess
+// This module holds the database functions for viewing, changing
+// and deleting a user's own account
+
+import (
+    "fmt"
+    "strings"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+//******** Exported Functions *****************************//
+
+// Function to get the profile of a registered user, with their skills,
+// work history and the description of their default resume, if any
+// The password hash is never returned
+func (store *sqlStore) GetUserProfile(user_email string) (profile data.User_info, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return profile, err
+    }
+    sqlcmd := "SELECT user_email, first_name, last_name, phone, max_education, coalesce(years_experience, 0), coalesce(resume_id, 0)"
+    sqlcmd += " FROM user WHERE user_email=?"
+    row := db.QueryRow(sqlcmd, user_email)
+    var education, experience int
+    var resume_id int
+    err = row.Scan(&profile.Email, &profile.First, &profile.Last, &profile.Phone, &education, &experience, &resume_id)
+    if err != nil {
+        return profile, data.NotFoundError("Unknown user")
+    }
+    profile.Education = &education
+    profile.Experience = &experience
+    if resume_id != 0 {
+        resume, err := loadResume(db, resume_id, false)
+        if err != nil {
+            return profile, err
+        }
+        profile.Resume = &resume
+    }
+    profile.Skills, err = loadUserSkills(db, user_email)
+    if err != nil {
+        return profile, err
+    }
+    profile.Work_history, err = loadWorkHistory(db, user_email)
+    return profile, err
+}
+
+// Function to change a user's profile. Only changes values with non-null values:
+// "" for strings and nil for education, experience, skills and work history
+// mean no change. Skills or work history that are given replace the old ones
+// A new password is stored as a bcrypt hash, and logs the user out everywhere
+func (store *sqlStore) UpdateUserProfile(user_email string, first_name string, last_name string, phone string, education *int,
+                                         password string, experience *int, skills []data.User_skill,
+                                         work_history []data.Work_entry) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    var columns []string
+    var args []interface{}
+    if first_name != "" {
+        columns = append(columns, "first_name=?")
+        args = append(args, first_name)
+    }
+    if last_name != "" {
+        columns = append(columns, "last_name=?")
+        args = append(args, last_name)
+    }
+    if phone != "" {
+        columns = append(columns, "phone=?")
+        args = append(args, phone)
+    }
+    if education != nil {
+        columns = append(columns, "max_education=?")
+        args = append(args, *education)
+    }
+    if experience != nil {
+        columns = append(columns, "years_experience=?")
+        args = append(args, *experience)
+    }
+    if password != "" {
+        password_hash, err := hashPassword(password)
+        if err != nil {
+            return err
+        }
+        columns = append(columns, "password_hash=?")
+        args = append(args, password_hash)
+    }
+    if len(columns) == 0 && skills == nil && work_history == nil {
+        return data.ValidationError("", "Nothing to change")
+    }
+    bRegistered, err := store.IsRegisteredUser(user_email)
+    if err != nil {
+        return err
+    }
+    if !bRegistered {
+        return data.NotFoundError("Unknown user")
+    }
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    if len(columns) > 0 {
+        sqlcmd := fmt.Sprintf("UPDATE user SET %s WHERE user_email=?", strings.Join(columns, ", "))
+        args = append(args, user_email)
+        _, err = tx.Exec(sqlcmd, args...)
+        if err != nil {
+            tx.Rollback()
+            return err
+        }
+    }
+    if skills != nil {
+        err = saveUserSkills(tx, user_email, skills)
+        if err != nil {
+            tx.Rollback()
+            return err
+        }
+    }
+    if work_history != nil {
+        err = saveWorkHistory(tx, user_email, work_history)
+        if err != nil {
+            tx.Rollback()
+            return err
+        }
+    }
+    if password != "" {
+        _, err = tx.Exec("DELETE FROM session WHERE user_email=?", user_email)
+        if err != nil {
+            tx.Rollback()
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// Function to delete a user's account
+// The user, their sessions, their organization memberships and their own
+// applications (with the status history, resumes and messages) are removed,
+// as are the messages they sent. Jobs they created for an organization go
+// to one of its owners. Their other jobs are kept, so that applicants can
+// still see what they applied for, but are closed and no longer belong to
+// anyone, so they cannot be claimed by a new account with the same email
+// The only owner of an organization cannot leave until there is another
+func (store *sqlStore) DeleteUser(user_email string) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    result, err := tx.Exec("DELETE FROM user WHERE user_email=?", user_email)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    if count, _ := result.RowsAffected(); count == 0 {
+        tx.Rollback()
+        return data.NotFoundError("Unknown user")
+    }
+    err = checkNotSoleOwner(tx, user_email)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    var blob_keys []string
+    rows, err := tx.Query("SELECT blob_key FROM resume WHERE user_email=?", user_email)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    for rows.Next() {
+        var blob_key string
+        err = rows.Scan(&blob_key)
+        if err != nil {
+            rows.Close()
+            tx.Rollback()
+            return err
+        }
+        blob_keys = append(blob_keys, blob_key)
+    }
+    rows.Close()
+    cleanup := []string{
+        "DELETE FROM resume WHERE user_email=?",
+        "DELETE FROM session WHERE user_email=?",
+        "DELETE FROM application_status WHERE user_email=?",
+        "DELETE FROM job_application WHERE user_email=?",
+        "DELETE FROM organization_member WHERE user_email=?",
+        "DELETE FROM user_skill WHERE user_email=?",
+        "DELETE FROM work_history WHERE user_email=?",
+        "DELETE FROM saved_search WHERE user_email=?",
+        "DELETE FROM bookmark WHERE user_email=?",
+        "DELETE FROM webhook_delivery WHERE webhook_id IN (SELECT id FROM webhook WHERE user_email=?)",
+        "DELETE FROM webhook WHERE user_email=?",
+        "DELETE FROM notification_preference WHERE user_email=?",
+        "DELETE FROM notification_outbox WHERE recipient=?",
+        "DELETE FROM message WHERE applicant=?",
+        "DELETE FROM message WHERE sender=?",
+    }
+    for _, sqlcmd := range cleanup {
+        _, err = tx.Exec(sqlcmd, user_email)
+        if err != nil {
+            tx.Rollback()
+            return err
+        }
+    }
+    // organization jobs go to the owner who joined first; the rest are closed
+    owner := "SELECT m.user_email FROM organization_member m WHERE m.organization_id=job.organization_id AND m.role=?"
+    sqlcmd := "UPDATE job SET created_by=(" + owner + " ORDER BY m.added, m.user_email LIMIT 1)"
+    sqlcmd += " WHERE created_by=? AND organization_id<>0 AND EXISTS (" + owner + ")"
+    _, err = tx.Exec(sqlcmd, data.RoleOwner, user_email, data.RoleOwner)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    _, err = tx.Exec("UPDATE job SET is_open=?, created_by='' WHERE created_by=?", false, user_email)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    err = tx.Commit()
+    if err != nil {
+        return err
+    }
+    deleteBlobs(blob_keys)
+    return nil
+}
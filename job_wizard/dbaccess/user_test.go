@@ -0,0 +1,133 @@
+package dbaccess
+// Tests for viewing, changing and deleting a user's own account
+
+import (
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+func TestUpdateUserProfile(t *testing.T) {
+    mustRegister(t, "profile.user@example.com")
+    doctoral := 4
+    err := testStore.UpdateUserProfile("profile.user@example.com", "", "O'Hara", "", &doctoral, "", nil, nil, nil)
+    if err != nil {
+        t.Fatalf("UpdateUserProfile failed: %v", err)
+    }
+    profile, err := testStore.GetUserProfile("profile.user@example.com")
+    if err != nil {
+        t.Fatalf("GetUserProfile failed: %v", err)
+    }
+    // values not given are unchanged
+    if profile.First != "Test" || profile.Last != "O'Hara" || profile.Phone != "0812345678" ||
+       *profile.Education != 4 || profile.Password != "" {
+        t.Errorf("unexpected profile after update %+v", profile)
+    }
+    // education can go back to 0
+    none := 0
+    testStore.UpdateUserProfile("profile.user@example.com", "", "", "", &none, "", nil, nil, nil)
+    if profile, _ = testStore.GetUserProfile("profile.user@example.com"); *profile.Education != 0 {
+        t.Errorf("expected education 0, got %d", *profile.Education)
+    }
+    if err = testStore.UpdateUserProfile("profile.user@example.com", "", "", "", nil, "", nil, nil, nil); data.ErrorCode(err) != data.CodeValidation {
+        t.Errorf("an update with nothing to change should fail validation, got %v", err)
+    }
+    if err = testStore.UpdateUserProfile("nobody@example.com", "Nobody", "", "", nil, "", nil, nil, nil); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("updating an unknown user should be not found, got %v", err)
+    }
+    if _, err = testStore.GetUserProfile("nobody@example.com"); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("profile of an unknown user should be not found, got %v", err)
+    }
+}
+
+func TestPasswordChangeEndsSessions(t *testing.T) {
+    mustRegister(t, "password.user@example.com")
+    token, _, _ := testStore.CreateSession("password.user@example.com")
+    if err := testStore.UpdateUserProfile("password.user@example.com", "", "", "", nil, "a new password", nil, nil, nil); err != nil {
+        t.Fatalf("UpdateUserProfile failed: %v", err)
+    }
+    if _, err := testStore.GetSessionUser(token); err == nil {
+        t.Errorf("old session should end when the password changes")
+    }
+    if ok, _ := testStore.CheckPassword("password.user@example.com", "a new password"); !ok {
+        t.Errorf("new password should work")
+    }
+    if ok, _ := testStore.CheckPassword("password.user@example.com", "password123"); ok {
+        t.Errorf("old password should no longer work")
+    }
+}
+
+func TestSessionTokenHashed(t *testing.T) {
+    mustRegister(t, "session.user@example.com")
+    token, _, err := testStore.CreateSession("session.user@example.com")
+    if err != nil {
+        t.Fatalf("CreateSession failed: %v", err)
+    }
+    if user_email, err := testStore.GetSessionUser(token); err != nil || user_email != "session.user@example.com" {
+        t.Errorf("the token should identify the user, got %s (%v)", user_email, err)
+    }
+    if _, err = testStore.GetSessionUser(hashToken(token)); data.ErrorCode(err) != data.CodeUnauthorized {
+        t.Errorf("the stored hash should not work as a token, got %v", err)
+    }
+    if ok, err := testStore.CheckPassword("nobody@example.com", "password123"); ok || err != nil {
+        t.Errorf("an unknown user should just fail to log in, got %v (%v)", ok, err)
+    }
+    if _, is_sql := testStore.(*sqlStore); !is_sql {
+        return
+    }
+    var count int
+    db.QueryRow("SELECT COUNT(*) FROM session WHERE token=?", token).Scan(&count)
+    if count != 0 {
+        t.Errorf("the token itself should not be stored")
+    }
+    // a database failure is an error, not a wrong password
+    db.Close()
+    defer closeDb()
+    if _, err = testStore.CheckPassword("session.user@example.com", "password123"); err == nil {
+        t.Errorf("CheckPassword should report a database failure")
+    }
+}
+
+func TestDeleteUser(t *testing.T) {
+    mustRegister(t, "leaving.boss@example.com")
+    mustRegister(t, "leaving.applicant@example.com")
+    mustRegister(t, "staying.applicant@example.com")
+    boss_job, _ := testStore.CreateJob(data.Job_info{Creator: "leaving.boss@example.com", Title: "Orphaned Job", Description: "Creator is leaving"})
+    testStore.SubmitJobApplication("staying.applicant@example.com", boss_job, "", nil)
+    mustRegister(t, "other.boss@example.com")
+    other_job, _ := testStore.CreateJob(data.Job_info{Creator: "other.boss@example.com", Title: "Other Job", Description: "Still here"})
+    testStore.SubmitJobApplication("leaving.applicant@example.com", other_job, "", nil)
+    token, _, _ := testStore.CreateSession("leaving.applicant@example.com")
+
+    if err := testStore.DeleteUser("leaving.applicant@example.com"); err != nil {
+        t.Fatalf("DeleteUser failed: %v", err)
+    }
+    if registered, _ := testStore.IsRegisteredUser("leaving.applicant@example.com"); registered {
+        t.Errorf("deleted user is still registered")
+    }
+    if _, err := testStore.GetSessionUser(token); err == nil {
+        t.Errorf("deleted user's session should end")
+    }
+    candidates, total, _ := testStore.SearchCandidates("other.boss@example.com", other_job, false, data.Page_request{})
+    if total != 0 || len(candidates) != 0 {
+        t.Errorf("deleted user's application should be gone, got %v", candidates)
+    }
+
+    if err := testStore.DeleteUser("leaving.boss@example.com"); err != nil {
+        t.Fatalf("DeleteUser failed: %v", err)
+    }
+    // the job stays visible to its applicant, but is closed and has no owner
+    applied, _, _ := testStore.SearchAppliedJobs("staying.applicant@example.com", data.Page_request{})
+    if len(applied) != 1 || applied[0].Job_id != boss_job || applied[0].Is_open {
+        t.Errorf("applicant should still see the closed job, got %+v", applied)
+    }
+    // a new account with the same email does not get the old jobs
+    mustRegister(t, "leaving.boss@example.com")
+    reclaimed := "Reclaimed"
+    _, err := testStore.ModifyJob(data.Job_changes{Creator: "leaving.boss@example.com", Job_id: boss_job, Title: &reclaimed})
+    if data.ErrorCode(err) != data.CodeForbidden {
+        t.Errorf("re-registered user must not own the old job, got %v", err)
+    }
+    if err = testStore.DeleteUser("nobody@example.com"); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("deleting an unknown user should be not found, got %v", err)
+    }
+}
@@ -0,0 +1,347 @@
+package dbaccess
+// This module holds the database functions for webhooks: URLs that
+// employers register to be told about events on their jobs
+// Events are queued as deliveries in the same transaction as the change
+// that caused them, so none are lost if the server stops. The webhook
+// package sends them and records the outcome of each attempt here
+
+import (
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Longest error message kept for a failed attempt
+const maxDeliveryError = 256
+
+//**************** Private Functions *******************************//
+
+// Turn a webhook or delivery id into a string with leading zeros
+func webhookIdString(idval int) string {
+    return fmt.Sprintf("%05d", idval)
+}
+
+// Return true if a webhook with these events wants to be told about event
+func hasEvent(events []string, event string) bool {
+    for _, name := range events {
+        if name == event {
+            return true
+        }
+    }
+    return false
+}
+
+// Build the JSON body posted for an event on a job
+func webhookPayload(event string, job_idval int, title string, org_idval int, applicant string, status string) (string, error) {
+    payload := data.Webhook_event{Event: event, Occurred: data.DisplayTime(data.StoredNow()),
+                                  Job_id: fmt.Sprintf("%05d", job_idval), Title: title,
+                                  Applicant: applicant, Status: status}
+    if org_idval != 0 {
+        payload.Org_id = orgIdString(org_idval)
+    }
+    body, err := json.Marshal(payload)
+    return string(body), err
+}
+
+// Work out the state of a delivery after an attempt, given the number
+// of attempts so far including this one. Each retry waits twice as long
+// as the one before, starting at one minute
+func attemptOutcome(attempts int, delivered bool) (status string, next_attempt string, delivered_time string) {
+    if delivered {
+        return data.DeliveryDelivered, "", data.StoredNow()
+    }
+    if attempts >= data.MaxDeliveryAttempts {
+        return data.DeliveryFailed, "", ""
+    }
+    delay := time.Minute << (attempts - 1)
+    return data.DeliveryPending, data.StoredTime(time.Now().Add(delay)), ""
+}
+
+// Shorten an error message to fit the last_error column
+func deliveryError(message string) string {
+    runes := []rune(message)
+    if len(runes) > maxDeliveryError {
+        return string(runes[:maxDeliveryError])
+    }
+    return message
+}
+
+// Return a copy of a delivery with its time stamps ready to show to the user
+func displayDelivery(delivery data.Webhook_delivery) data.Webhook_delivery {
+    delivery.Created = data.DisplayTime(delivery.Created)
+    delivery.Next_attempt = data.DisplayTime(delivery.Next_attempt)
+    delivery.Delivered = data.DisplayTime(delivery.Delivered)
+    return delivery
+}
+
+// Queue an event on a job for every webhook that wants it: those of the
+// job's creator, and those of the owners of its organization
+// Called inside the transaction that makes the change. applicant and
+// status are "" except for application events
+func queueWebhookEvent(conn sqlConn, event string, job_idval int, applicant string, status string) (err error) {
+    var created_by string
+    var title string
+    var org_idval int
+    row := conn.QueryRow("SELECT created_by, title, organization_id FROM job WHERE id=?", job_idval)
+    err = row.Scan(&created_by, &title, &org_idval)
+    if err != nil {
+        return err
+    }
+    sqlcmd := "SELECT w.id, w.events FROM webhook w WHERE (w.organization_id=0 AND w.user_email=?)"
+    sqlcmd += " OR (w.organization_id<>0 AND w.organization_id=? AND EXISTS(SELECT 1 FROM organization_member m"
+    sqlcmd += " WHERE m.organization_id=w.organization_id AND m.user_email=w.user_email AND m.role=?))"
+    rows, err := conn.Query(sqlcmd, created_by, org_idval, data.RoleOwner)
+    if err != nil {
+        return err
+    }
+    var webhook_ids []int
+    for rows.Next() {
+        var idval int
+        var events string
+        err = rows.Scan(&idval, &events)
+        if err != nil {
+            rows.Close()
+            return err
+        }
+        if hasEvent(strings.Split(events, ","), event) {
+            webhook_ids = append(webhook_ids, idval)
+        }
+    }
+    rows.Close()
+    if len(webhook_ids) == 0 {
+        return nil
+    }
+    payload, err := webhookPayload(event, job_idval, title, org_idval, applicant, status)
+    if err != nil {
+        return err
+    }
+    nowstring := data.StoredNow()
+    sqlcmd = "INSERT INTO webhook_delivery (webhook_id, event, payload, status, attempts, next_attempt, created) values (?,?,?,?,0,?,?)"
+    for _, idval := range webhook_ids {
+        _, err = conn.Exec(sqlcmd, idval, event, payload, data.DeliveryPending, nowstring, nowstring)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+//******** Exported Functions *****************************//
+
+// Function to register a webhook for hook.Creator, whose URL and events
+// have already been validated. If hook.Org_id is given the creator must
+// own that organization. Returns the webhook with its ID and the secret
+// used to sign its deliveries, which is not shown again
+func (store *sqlStore) CreateWebhook(hook data.Webhook) (created data.Webhook, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return created, err
+    }
+    org_idval := 0
+    if hook.Org_id != "" {
+        org_idval, _ = strconv.Atoi(hook.Org_id)  // already validated the format
+        err = checkOrgOwner(db, org_idval, hook.Creator)
+        if err != nil {
+            return created, err
+        }
+    }
+    secret, err := newToken()
+    if err != nil {
+        return created, err
+    }
+    tx, err := db.Begin()
+    if err != nil {
+        return created, err
+    }
+    var count int
+    err = tx.QueryRow("SELECT COUNT(*) FROM webhook WHERE user_email=?", hook.Creator).Scan(&count)
+    if err != nil {
+        tx.Rollback()
+        return created, err
+    }
+    if count >= data.MaxWebhooks {
+        tx.Rollback()
+        return created, data.ConflictError(fmt.Sprintf("A user can have at most %d webhooks", data.MaxWebhooks))
+    }
+    nowstring := data.StoredNow()
+    sqlcmd := "INSERT INTO webhook (user_email, organization_id, url, events, secret, created) values (?,?,?,?,?,?)"
+    id, err := insertReturningId(tx, sqlcmd, hook.Creator, org_idval, hook.Url, strings.Join(hook.Events, ","), secret, nowstring)
+    if err != nil {
+        tx.Rollback()
+        return created, err
+    }
+    err = tx.Commit()
+    if err != nil {
+        return created, err
+    }
+    created = hook
+    created.Webhook_id = webhookIdString(id)
+    created.Secret = secret
+    created.Created = data.DisplayTime(nowstring)
+    return created, nil
+}
+
+// Function to list the webhooks a user has registered, by default the
+// oldest first, without their secrets. Returns one page plus the total number
+func (store *sqlStore) ListWebhooks(user_email string, page data.Page_request) (hooks []data.Webhook, total int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return hooks, 0, err
+    }
+    err = db.QueryRow("SELECT COUNT(*) FROM webhook WHERE user_email=?", user_email).Scan(&total)
+    if err != nil {
+        return hooks, 0, err
+    }
+    clause, page_args := pageClause(page, webhookSortColumns, "created", "asc", "w.id")
+    sqlcmd := "SELECT w.id, w.organization_id, w.url, w.events, w.created FROM webhook w WHERE w.user_email=?" + clause
+    rows, err := db.Query(sqlcmd, append([]interface{}{user_email}, page_args...)...)
+    if err != nil {
+        return hooks, 0, err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var hook data.Webhook
+        var idval int
+        var org_idval int
+        var events string
+        err = rows.Scan(&idval, &org_idval, &hook.Url, &events, &hook.Created)
+        if err != nil {
+            return nil, 0, err
+        }
+        hook.Webhook_id = webhookIdString(idval)
+        if org_idval != 0 {
+            hook.Org_id = orgIdString(org_idval)
+        }
+        hook.Events = strings.Split(events, ",")
+        hook.Created = data.DisplayTime(hook.Created)
+        hooks = append(hooks, hook)
+    }
+    return hooks, total, nil
+}
+
+// Function to delete one of a user's webhooks, with its deliveries
+func (store *sqlStore) DeleteWebhook(user_email string, webhook_id string) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    idval, _ := strconv.Atoi(webhook_id)  // already validated the format
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    result, err := tx.Exec("DELETE FROM webhook WHERE id=? AND user_email=?", idval, user_email)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    if count, _ := result.RowsAffected(); count == 0 {
+        tx.Rollback()
+        return data.NotFoundError("No matching webhook found")
+    }
+    _, err = tx.Exec("DELETE FROM webhook_delivery WHERE webhook_id=?", idval)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    return tx.Commit()
+}
+
+// Function to list the deliveries of one of a user's webhooks, by
+// default the most recent first. Returns one page plus the total number
+func (store *sqlStore) ListWebhookDeliveries(user_email string, webhook_id string, page data.Page_request) (deliveries []data.Webhook_delivery, total int, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return deliveries, 0, err
+    }
+    idval, _ := strconv.Atoi(webhook_id)  // already validated the format
+    var found int
+    err = db.QueryRow("SELECT id FROM webhook WHERE id=? AND user_email=?", idval, user_email).Scan(&found)
+    if err != nil {
+        return deliveries, 0, data.NotFoundError("No matching webhook found")
+    }
+    err = db.QueryRow("SELECT COUNT(*) FROM webhook_delivery WHERE webhook_id=?", idval).Scan(&total)
+    if err != nil {
+        return deliveries, 0, err
+    }
+    clause, page_args := pageClause(page, deliverySortColumns, "created", "desc", "d.id")
+    sqlcmd := "SELECT d.id, d.event, d.payload, d.status, d.attempts, d.next_attempt, d.response_code, d.last_error, d.created, d.delivered"
+    sqlcmd += " FROM webhook_delivery d WHERE d.webhook_id=?" + clause
+    rows, err := db.Query(sqlcmd, append([]interface{}{idval}, page_args...)...)
+    if err != nil {
+        return deliveries, 0, err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var delivery data.Webhook_delivery
+        var delivery_idval int
+        err = rows.Scan(&delivery_idval, &delivery.Event, &delivery.Payload, &delivery.Status, &delivery.Attempts,
+                        &delivery.Next_attempt, &delivery.Response_code, &delivery.Last_error, &delivery.Created, &delivery.Delivered)
+        if err != nil {
+            return nil, 0, err
+        }
+        delivery.Delivery_id = webhookIdString(delivery_idval)
+        delivery.Webhook_id = webhookIdString(idval)
+        if delivery.Status != data.DeliveryPending {
+            delivery.Next_attempt = ""
+        }
+        deliveries = append(deliveries, displayDelivery(delivery))
+    }
+    return deliveries, total, nil
+}
+
+// Function to return up to limit pending deliveries whose next attempt
+// is due, the longest waiting first, with the URL and secret to send them
+func (store *sqlStore) DueWebhookDeliveries(limit int) (deliveries []data.Webhook_delivery, err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return deliveries, err
+    }
+    sqlcmd := "SELECT d.id, d.webhook_id, d.event, d.payload, d.attempts, d.created, w.url, w.secret"
+    sqlcmd += " FROM webhook_delivery d JOIN webhook w ON w.id=d.webhook_id"
+    sqlcmd += " WHERE d.status=? AND d.next_attempt<=? ORDER BY d.next_attempt, d.id LIMIT ?"
+    rows, err := db.Query(sqlcmd, data.DeliveryPending, data.StoredNow(), limit)
+    if err != nil {
+        return deliveries, err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var delivery data.Webhook_delivery
+        var idval int
+        var webhook_idval int
+        err = rows.Scan(&idval, &webhook_idval, &delivery.Event, &delivery.Payload, &delivery.Attempts,
+                        &delivery.Created, &delivery.Url, &delivery.Secret)
+        if err != nil {
+            return nil, err
+        }
+        delivery.Delivery_id = webhookIdString(idval)
+        delivery.Webhook_id = webhookIdString(webhook_idval)
+        delivery.Status = data.DeliveryPending
+        deliveries = append(deliveries, delivery)
+    }
+    return deliveries, nil
+}
+
+// Function to record the outcome of an attempt to send a delivery
+// response_code is 0 if no response was received. A delivery that was not
+// delivered is retried later, or fails once it has used all its attempts
+func (store *sqlStore) RecordWebhookAttempt(delivery_id string, delivered bool, response_code int, message string) (err error) {
+    err = connectDb(dbname)
+    if err != nil {
+        return err
+    }
+    idval, _ := strconv.Atoi(delivery_id)
+    var attempts int
+    err = db.QueryRow("SELECT attempts FROM webhook_delivery WHERE id=?", idval).Scan(&attempts)
+    if err != nil {
+        return data.NotFoundError("No matching delivery found")
+    }
+    attempts++
+    status, next_attempt, delivered_time := attemptOutcome(attempts, delivered)
+    sqlcmd := "UPDATE webhook_delivery SET status=?, attempts=?, next_attempt=?, response_code=?, last_error=?, delivered=? WHERE id=?"
+    _, err = db.Exec(sqlcmd, status, attempts, next_attempt, response_code, deliveryError(message), delivered_time, idval)
+    return err
+}
@@ -0,0 +1,182 @@
+package dbaccess
+// Tests for webhooks: which events are queued, and how attempts are recorded
+
+import (
+    "encoding/json"
+    "fmt"
+    "testing"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+// Return the pending deliveries of one webhook that are due now
+func dueFor(t *testing.T, webhook_id string) (due []data.Webhook_delivery) {
+    t.Helper()
+    deliveries, err := testStore.DueWebhookDeliveries(1000)
+    if err != nil {
+        t.Fatalf("DueWebhookDeliveries failed: %v", err)
+    }
+    for _, delivery := range deliveries {
+        if delivery.Webhook_id == webhook_id {
+            due = append(due, delivery)
+        }
+    }
+    return due
+}
+
+func TestWebhookEvents(t *testing.T) {
+    mustRegister(t, "hook.boss@example.com")
+    mustRegister(t, "hook.seeker@example.com")
+    hook, err := testStore.CreateWebhook(data.Webhook{Creator: "hook.boss@example.com", Url: "http://localhost:9999/hook",
+        Events: []string{data.EventJobCreated, data.EventJobFilled, data.EventApplicationSubmitted, data.EventApplicationStatus}})
+    if err != nil || hook.Webhook_id == "" || len(hook.Secret) != 64 {
+        t.Fatalf("CreateWebhook returned %+v (%v)", hook, err)
+    }
+
+    job_id, _ := testStore.CreateJob(data.Job_info{Creator: "hook.boss@example.com", Title: "Tugboat Captain", Description: "Pushes ships", Salary: 40000})
+    newtitle := "Senior Tugboat Captain"
+    testStore.ModifyJob(data.Job_changes{Creator: "hook.boss@example.com", Job_id: job_id, Title: &newtitle})
+    testStore.SubmitJobApplication("hook.seeker@example.com", job_id, "", nil)
+    for _, status := range []string{data.StatusReviewed, data.StatusShortlisted, data.StatusInterviewing, data.StatusOffered, data.StatusHired} {
+        if err = testStore.UpdateApplicationStatus("hook.boss@example.com", job_id, "hook.seeker@example.com", status); err != nil {
+            t.Fatalf("move to %s failed: %v", status, err)
+        }
+    }
+
+    // job.modified was not asked for
+    due := dueFor(t, hook.Webhook_id)
+    var events []string
+    for _, delivery := range due {
+        events = append(events, delivery.Event)
+        if delivery.Url != hook.Url || delivery.Secret != hook.Secret {
+            t.Errorf("a due delivery should have the URL and secret, got %+v", delivery)
+        }
+    }
+    expected := fmt.Sprint([]string{data.EventJobCreated, data.EventApplicationSubmitted, data.EventApplicationStatus,
+        data.EventApplicationStatus, data.EventApplicationStatus, data.EventApplicationStatus, data.EventApplicationStatus,
+        data.EventJobFilled})
+    if fmt.Sprint(events) != expected {
+        t.Fatalf("expected events %s, got %v", expected, events)
+    }
+    var payload data.Webhook_event
+    json.Unmarshal([]byte(due[6].Payload), &payload)
+    if payload.Job_id != job_id || payload.Title != newtitle || payload.Applicant != "hook.seeker@example.com" || payload.Status != data.StatusHired {
+        t.Errorf("unexpected payload %s", due[6].Payload)
+    }
+
+    // a delivered attempt is done; a failed one waits before the next try
+    if err = testStore.RecordWebhookAttempt(due[0].Delivery_id, true, 200, ""); err != nil {
+        t.Fatalf("RecordWebhookAttempt failed: %v", err)
+    }
+    testStore.RecordWebhookAttempt(due[1].Delivery_id, false, 500, "Response status 500 Internal Server Error")
+    if remaining := dueFor(t, hook.Webhook_id); len(remaining) != len(due) - 2 {
+        t.Errorf("expected %d deliveries still due, got %d", len(due) - 2, len(remaining))
+    }
+    for i := 1; i < data.MaxDeliveryAttempts; i++ {
+        testStore.RecordWebhookAttempt(due[2].Delivery_id, false, 0, "connection refused")
+    }
+    deliveries, total, err := testStore.ListWebhookDeliveries("hook.boss@example.com", hook.Webhook_id, data.Page_request{Sort: "created", Order: "asc"})
+    if err != nil || total != len(due) {
+        t.Fatalf("expected %d deliveries, got %d (%v)", len(due), total, err)
+    }
+    status := map[string]data.Webhook_delivery{}
+    for _, delivery := range deliveries {
+        status[delivery.Delivery_id] = delivery
+    }
+    if found := status[due[0].Delivery_id]; found.Status != data.DeliveryDelivered || found.Delivered == "" || found.Attempts != 1 {
+        t.Errorf("expected a delivered delivery, got %+v", found)
+    }
+    if found := status[due[1].Delivery_id]; found.Status != data.DeliveryPending || found.Next_attempt <= found.Created || found.Response_code != 500 {
+        t.Errorf("expected a delivery waiting to retry, got %+v", found)
+    }
+    if found := status[due[2].Delivery_id]; found.Status != data.DeliveryPending || found.Attempts != data.MaxDeliveryAttempts - 1 {
+        t.Errorf("expected a delivery with one attempt left, got %+v", found)
+    }
+    testStore.RecordWebhookAttempt(due[2].Delivery_id, false, 0, "connection refused")
+    deliveries, _, _ = testStore.ListWebhookDeliveries("hook.boss@example.com", hook.Webhook_id, data.Page_request{Sort: "status"})
+    failed := 0
+    for _, delivery := range deliveries {
+        if delivery.Status == data.DeliveryFailed {
+            failed++
+            if delivery.Last_error != "connection refused" || delivery.Next_attempt != "" {
+                t.Errorf("unexpected failed delivery %+v", delivery)
+            }
+        }
+    }
+    if failed != 1 {
+        t.Errorf("expected one failed delivery, got %d", failed)
+    }
+
+    if _, _, err = testStore.ListWebhookDeliveries("hook.seeker@example.com", hook.Webhook_id, data.Page_request{}); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("only the owner should see the deliveries, got %v", err)
+    }
+    if err = testStore.DeleteWebhook("hook.seeker@example.com", hook.Webhook_id); data.ErrorCode(err) != data.CodeNotFound {
+        t.Errorf("only the owner should delete a webhook, got %v", err)
+    }
+    if err = testStore.DeleteWebhook("hook.boss@example.com", hook.Webhook_id); err != nil {
+        t.Errorf("DeleteWebhook failed: %v", err)
+    }
+    if remaining := dueFor(t, hook.Webhook_id); len(remaining) != 0 {
+        t.Errorf("deleting the webhook should delete its deliveries, got %d", len(remaining))
+    }
+}
+
+func TestOrganizationWebhooks(t *testing.T) {
+    mustRegister(t, "hook.org.owner@example.com")
+    mustRegister(t, "hook.org.recruiter@example.com")
+    org_id, err := testStore.CreateOrganization(data.Organization{Creator: "hook.org.owner@example.com", Name: "Harbour Works"})
+    if err != nil {
+        t.Fatalf("CreateOrganization failed: %v", err)
+    }
+    testStore.SetOrganizationMember(data.Member_change{Creator: "hook.org.owner@example.com", Org_id: org_id,
+                                                       Member: "hook.org.recruiter@example.com", Role: data.RoleRecruiter})
+    _, err = testStore.CreateWebhook(data.Webhook{Creator: "hook.org.recruiter@example.com", Org_id: org_id,
+                                                  Url: "http://localhost:9999/org", Events: []string{data.EventJobCreated}})
+    if data.ErrorCode(err) != data.CodeForbidden {
+        t.Errorf("only an owner should register an organization webhook, got %v", err)
+    }
+    hook, err := testStore.CreateWebhook(data.Webhook{Creator: "hook.org.owner@example.com", Org_id: org_id,
+                                                      Url: "http://localhost:9999/org", Events: []string{data.EventJobCreated}})
+    if err != nil {
+        t.Fatalf("CreateWebhook failed: %v", err)
+    }
+
+    // the recruiter's jobs for the organization are sent; their own are not
+    testStore.CreateJob(data.Job_info{Creator: "hook.org.recruiter@example.com", Org_id: org_id, Title: "Crane Operator", Description: "Lifts containers"})
+    testStore.CreateJob(data.Job_info{Creator: "hook.org.recruiter@example.com", Title: "Dog Walker", Description: "Walks dogs"})
+    due := dueFor(t, hook.Webhook_id)
+    if len(due) != 1 || !json.Valid([]byte(due[0].Payload)) {
+        t.Fatalf("expected one delivery for the organization's job, got %+v", due)
+    }
+    var payload data.Webhook_event
+    json.Unmarshal([]byte(due[0].Payload), &payload)
+    if payload.Org_id != org_id || payload.Title != "Crane Operator" {
+        t.Errorf("unexpected payload %s", due[0].Payload)
+    }
+
+    hooks, total, err := testStore.ListWebhooks("hook.org.owner@example.com", data.Page_request{})
+    if err != nil || total != 1 || hooks[0].Secret != "" || hooks[0].Org_id != org_id || hooks[0].Events[0] != data.EventJobCreated {
+        t.Errorf("expected the webhook without its secret, got %+v (%v)", hooks, err)
+    }
+    for i := 1; i < data.MaxWebhooks; i++ {
+        if _, err = testStore.CreateWebhook(data.Webhook{Creator: "hook.org.owner@example.com", Url: "http://localhost:9999/more",
+                                                         Events: []string{data.EventJobFilled}}); err != nil {
+            t.Fatalf("CreateWebhook %d failed: %v", i, err)
+        }
+    }
+    _, err = testStore.CreateWebhook(data.Webhook{Creator: "hook.org.owner@example.com", Url: "http://localhost:9999/more", Events: []string{data.EventJobFilled}})
+    if data.ErrorCode(err) != data.CodeConflict {
+        t.Errorf("expected the limit on webhooks, got %v", err)
+    }
+
+    // deleting a user deletes their webhooks; they must hand the
+    // organization over first
+    testStore.SetOrganizationMember(data.Member_change{Creator: "hook.org.owner@example.com", Org_id: org_id,
+                                                       Member: "hook.org.recruiter@example.com", Role: data.RoleOwner})
+    if err = testStore.DeleteUser("hook.org.owner@example.com"); err != nil {
+        t.Fatalf("DeleteUser failed: %v", err)
+    }
+    mustRegister(t, "hook.org.owner@example.com")
+    if _, total, _ = testStore.ListWebhooks("hook.org.owner@example.com", data.Page_request{}); total != 0 {
+        t.Errorf("a deleted user's webhooks should be deleted, got %d", total)
+    }
+}
@@ -3,22 +3,54 @@ package helper
 // validation functions for command line arguments
 // Created by Sally Goldin 2025-06-23
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"path"
 	"regexp"
     "strings"
     "time"
     "strconv"
+    "unicode/utf8"
     "github.com/segoldin/JobWizard/job_wizard/data"
     "github.com/segoldin/JobWizard/job_wizard/dbaccess"      
 )
 
-var tasklist = [...]string{"register","create","search","detail","offered","applied","modify","submit","candidates"} 
+var tasklist = [...]string{"register","create","search","detail","offered","applied","modify","submit","candidates",
+                           "status","hire","withdraw","migrate",
+                           "profile","update_profile","delete_account","archive_job","delete_job",
+                           "create_org","org","update_org","orgs","add_member","remove_member","skills",
+                           "set_resume","save_search","saved_searches","run_search","delete_search","search_alerts",
+                           "bookmark","unbookmark","bookmarks",
+                           "add_webhook","webhooks","delete_webhook","webhook_deliveries","deliver_webhooks",
+                           "notifications","set_notifications","deliver_notifications",
+                           "send_message","messages","read_messages","unread_messages"} 
 
 const (
-	timeFormatString = "2006-01-02 15:04 +700"
-	dateOnlyString = "2006-01-02"
+	defaultPageLimit = 50   // listings return this many results unless a limit is given
+	maxPageLimit = 500
+	maxRadiusKm = 1000  // distances are approximate; see dbaccess/location.go
+	maxSkills = 20      // for one job, user or search
+	defaultProficiency = 3  // for a skill given on the command line without one
+	maxWorkEntries = 20     // in a user's work history
+	maxFileName = 128       // for uploaded files
 )
 
+// The first bytes of the files accepted as resumes, by extension
+// A DOCX file is a zip archive
+var resumeFormats = map[string]struct {
+	content_type string
+	magic        []byte
+}{
+	".pdf":  {data.ContentTypePdf, []byte("%PDF-")},
+	".docx": {data.ContentTypeDocx, []byte("PK\x03\x04")},
+}
+
+// Skill names: lower case letters and digits, with a few punctuation
+// characters for names such as "c++", "c#", "node.js" and "ci/cd"
+var skillPattern = regexp.MustCompile("^[a-z0-9][a-z0-9 +#./-]*$")
+
 // Find the specified task in the task list. Return its index (0...) or -1 if not found
 func FindTask(task string) (index int) {
 	index = -1
@@ -31,239 +63,1128 @@ func FindTask(task string) (index int) {
 	return index
 }
 
+// Return the task's name as it is in the task list, or "" if not found
+// Tasks are chosen by name, so that adding one does not move the others
+func TaskName(task string) (name string) {
+	index := FindTask(task)
+	if index < 0 {
+		return ""
+	}
+	return tasklist[index]
+}
+
 // Pass all structs used for arguments 
 // Note that some fields are used by multiple tasks
+// If the arguments are not valid, err is a data.App_error saying which one
 // We pass pointers so that any changes or copying gets preserved in the caller
-func ValidateTaskArgs(task string, user *data.User_info, job *data.Job_info, filter *data.Search_criteria, submission *data.Submission) (bOk bool, msg string) {
+func ValidateTaskArgs(task string, user *data.User_info, job *data.Job_info, filter *data.Search_criteria, submission *data.Submission,
+                      change *data.Status_change, job_changes *data.Job_changes, org *data.Organization,
+                      member *data.Member_change, saved *data.Saved_search, hook *data.Webhook,
+                      prefs *data.Notification_changes, message *data.Message,
+                      page *data.Page_request) (bOk bool, err error) {
 	bOk = true
-	taskIndex := FindTask(task)
-	if taskIndex < 0 {
-		return false, "Invalid task specified"
+	name := TaskName(task)
+	if name == "" {
+		return false, data.BadRequestError("Invalid task specified")
 	}
 	// set all email addresses to lower case
 	user.Email = strings.ToLower(user.Email)
 	job.Creator = strings.ToLower(job.Creator)
-	switch taskIndex {
-		case 0:
-			bOk, msg = ValidateUserInfo(user)
+	switch name {
+		case "register":
+			bOk, err = ValidateUserInfo(user, true)
 			break
-		case 1:
-			bOk, msg = ValidateJobInfo(job, true) 
+		case "create":
+			job.Org_id = org.Org_id
+			bOk, err = ValidateJobInfo(job) 
 			break
-		case 2:
+		case "search", "save_search": // search, or save a search
 			// can only define a command line arg once, so we copy from other structs
 			filter.User_email = user.Email
 			filter.Experience = job.Min_experience
 			filter.Education = job.Min_education
 			filter.Salary = job.Salary
-			bOk, msg = ValidateSearchCriteria(filter)
+			filter.Org_id = org.Org_id
+			filter.Location = org.Location
+			filter.Work_arrangement = job.Work_arrangement
+			filter.Employment_type = job.Employment_type
+			filter.Latitude = job.Latitude
+			filter.Longitude = job.Longitude
+			if name == "save_search" {
+				saved.User_email = user.Email
+				saved.Name = org.Name
+				saved.Criteria = *filter
+				bOk, err = ValidateSavedSearch(saved)
+				break
+			}
+			bOk, err = ValidateSearchCriteria(filter)
+			if bOk {
+				bOk, err = ValidatePageRequest(page, data.Job_sort_fields)
+			}
 			break
-		case 3: 
+		case "detail": 
 			// detail
 			job.Creator = user.Email
-			bOk, msg = ValidateDetailRequest(job)
+			bOk, err = ValidateDetailRequest(job)
 			break
-		case 4: 
+		case "offered": 
 			// jobs offered search
 			// will use job.Creator
-			bOk, msg = ValidateOfferedAppliedRequest(job)
+			bOk, err = ValidateOfferedAppliedRequest(job)
+			if bOk {
+				bOk, err = ValidatePageRequest(page, data.Job_sort_fields)
+			}
 			break
-		case 5: 
+		case "applied": 
 			// jobs applied search
 			job.Creator = user.Email
-			bOk, msg = ValidateOfferedAppliedRequest(job)
+			bOk, err = ValidateOfferedAppliedRequest(job)
+			if bOk {
+				bOk, err = ValidatePageRequest(page, data.Application_sort_fields)
+			}
 			break 
-		case 6:
-			bOk, msg = ValidateJobInfo(job, false) 
+		case "modify":
+			// the fields to change have already been set from the flags given
+			job_changes.Creator = job.Creator
+			job_changes.Job_id = job.Job_id
+			bOk, err = ValidateJobChanges(job_changes)
 			break
-		case 7: // submit a job application
+		case "submit": // submit a job application
 			submission.Email = user.Email
 			submission.Job_id = job.Job_id
-			bOk, msg = ValidateJobSubmission(submission) 
+			bOk, err = ValidateJobSubmission(submission) 
 			break
-		case 8: // candidates
+		case "candidates":
 			// same arguments as detail request
 			// will use job.Creator
-			bOk, msg = ValidateDetailRequest(job)
-			break											 				 			
+			bOk, err = ValidateDetailRequest(job)
+			if bOk {
+				bOk, err = ValidatePageRequest(page, data.Candidate_sort_fields)
+			}
+			break
+		case "status", "hire": // status change or hire
+			// email identifies the applicant, creator the job owner
+			change.Creator = job.Creator
+			change.Job_id = job.Job_id
+			change.Applicant = user.Email
+			if name == "hire" {
+				change.Status = data.StatusHired
+			}
+			bOk, err = ValidateStatusChange(change)
+			break
+		case "withdraw": // withdraw an application
+			// same arguments as submit
+			submission.Email = user.Email
+			submission.Job_id = job.Job_id
+			bOk, err = ValidateJobSubmission(submission)
+			break
+		case "migrate":
+			// the version is checked against the known migrations by dbaccess
+			break
+		case "profile", "delete_account": // view profile or delete account
+			bOk, err = validateRegistered(user.Email, "email")
+			break
+		case "update_profile": // update profile
+			bOk, err = ValidateUserInfo(user, false)
+			break
+		case "archive_job", "delete_job": // archive or delete a job
+			bOk, err = ValidateJobOwnerRequest(job)
+			break
+		case "create_org", "update_org": // create or update an organization
+			// creator is the user making the change
+			org.Creator = job.Creator
+			org.Description = job.Description
+			bOk, err = ValidateOrganization(org, name == "create_org")
+			break
+		case "org": // organization profile
+			org.Creator = user.Email
+			bOk, err = ValidateOrgRequest(org)
+			break
+		case "orgs": // list organizations
+			bOk, err = validateRegistered(user.Email, "email")
+			if bOk {
+				bOk, err = ValidatePageRequest(page, data.Organization_sort_fields)
+			}
+			break
+		case "add_member", "remove_member": // add, change or remove a member
+			// email identifies the member, creator the user making the change
+			member.Creator = job.Creator
+			member.Org_id = org.Org_id
+			member.Member = user.Email
+			bOk, err = ValidateMemberChange(member, name == "remove_member")
+			break
+		case "skills": // list skills
+			bOk, err = validateRegistered(user.Email, "email")
+			if bOk {
+				bOk, err = ValidatePageRequest(page, data.Skill_sort_fields)
+			}
+			break
+		case "set_resume": // set or remove the default resume
+			// the resume is read from the file named by -resume, or nil to remove it
+			bOk, err = validateRegistered(user.Email, "email")
+			if bOk && submission.Resume != nil {
+				bOk, err = ValidateResume(submission.Resume)
+			}
+			break
+		case "saved_searches": // list saved searches
+			bOk, err = validateRegistered(user.Email, "email")
+			if bOk {
+				bOk, err = ValidatePageRequest(page, data.Saved_search_sort_fields)
+			}
+			break
+		case "run_search": // run a saved search
+			saved.User_email = user.Email
+			bOk, err = ValidateSavedSearchRequest(saved)
+			if bOk {
+				bOk, err = ValidatePageRequest(page, data.Job_sort_fields)
+			}
+			break
+		case "delete_search": // delete a saved search
+			saved.User_email = user.Email
+			bOk, err = ValidateSavedSearchRequest(saved)
+			break
+		case "search_alerts": // new matches of saved searches
+			bOk, err = validateRegistered(user.Email, "email")
+			break
+		case "bookmark", "unbookmark": // bookmark a job or remove the bookmark
+			// same arguments as detail
+			job.Creator = user.Email
+			bOk, err = ValidateDetailRequest(job)
+			break
+		case "bookmarks": // list bookmarks
+			bOk, err = validateRegistered(user.Email, "email")
+			if bOk {
+				bOk, err = ValidatePageRequest(page, data.Bookmark_sort_fields)
+			}
+			break
+		case "add_webhook": // register a webhook
+			hook.Creator = user.Email
+			hook.Org_id = org.Org_id
+			bOk, err = ValidateWebhook(hook)
+			break
+		case "webhooks": // list webhooks
+			bOk, err = validateRegistered(user.Email, "email")
+			if bOk {
+				bOk, err = ValidatePageRequest(page, data.Webhook_sort_fields)
+			}
+			break
+		case "delete_webhook": // delete a webhook
+			hook.Creator = user.Email
+			bOk, err = ValidateWebhookRequest(hook)
+			break
+		case "webhook_deliveries": // list the deliveries of a webhook
+			hook.Creator = user.Email
+			bOk, err = ValidateWebhookRequest(hook)
+			if bOk {
+				bOk, err = ValidatePageRequest(page, data.Delivery_sort_fields)
+			}
+			break
+		case "deliver_webhooks": // send the deliveries that are due
+			// no arguments
+			break
+		case "notifications": // see notification preferences
+			bOk, err = validateRegistered(user.Email, "email")
+			break
+		case "set_notifications": // change notification preferences
+			prefs.User_email = user.Email
+			bOk, err = ValidateNotificationChanges(prefs)
+			break
+		case "deliver_notifications": // send the notifications that are due
+			// no arguments
+			break
+		case "send_message": // send a message
+			message.Sender = user.Email
+			message.Job_id = job.Job_id
+			bOk, err = ValidateMessage(message)
+			break
+		case "messages": // list the messages in a thread
+			message.Sender = user.Email
+			message.Job_id = job.Job_id
+			bOk, err = ValidateThreadRequest(message)
+			if bOk {
+				bOk, err = ValidatePageRequest(page, data.Message_sort_fields)
+			}
+			break
+		case "read_messages": // mark the messages in a thread read
+			message.Sender = user.Email
+			message.Job_id = job.Job_id
+			bOk, err = ValidateThreadRequest(message)
+			break
+		case "unread_messages": // count unread messages
+			bOk, err = validateRegistered(user.Email, "email")
+			break
 	} 
-	return bOk,msg 
+	return bOk,err 
 }
 
 // Check that all information needed to create a user is specified,
 // and that the individual field values have valid format
-func ValidateUserInfo(user *data.User_info) (bOk bool, msg string) {
-	bOk, msg = validateEmail(user.Email)
-	if bOk {
+// If "is_create" then we are registering a new user and all fields are required
+// except the experience, skills and work history. Otherwise the user must already exist, and only the
+// values to be changed need to be given
+func ValidateUserInfo(user *data.User_info, is_create bool) (bOk bool, err error) {
+	if !is_create {
+		bOk, err = validateRegistered(user.Email, "email")
+		if !bOk {
+			return bOk, err
+		}
+	}
+	field := "email"
+	bOk, msg := validateEmail(user.Email)
+	if bOk && (is_create || user.First != "") {
+		field = "first"
 		bOk, msg = validateFirstLastName(user.First, "first")
 	}	
-	if bOk {
+	if bOk && (is_create || user.Last != "") {
+		field = "last"
 		bOk, msg = validateFirstLastName(user.Last, "last")
 	}	
-	if bOk {
+	if bOk && (is_create || user.Phone != "") {
+		field = "phone"
 		bOk, msg = validatePhone(user.Phone)
 	}	
-	if bOk {
-		bOk, msg = validateEducation(user.Education)
+	if bOk && is_create && user.Education == nil {
+		no_education := 0
+		user.Education = &no_education
 	}
-	return bOk, msg
+	if bOk && (user.Education != nil) {
+		field = "education"
+		bOk, msg = validateEducation(*user.Education)
+	}
+	if bOk && (is_create || user.Password != "") {
+		field = "password"
+		bOk, msg = validatePassword(user.Password)
+	}
+	if bOk && (user.Experience != nil) {
+		field = "experience"
+		bOk, msg = validateExperience(*user.Experience)
+	}
+	if bOk && (user.Skills != nil) {
+		field = "skills"
+		bOk, msg = validateUserSkills(user.Skills)
+	}
+	if bOk && (user.Work_history != nil) {
+		field = "work_history"
+		bOk, msg = validateWorkHistory(user.Work_history)
+	}
+	return bOk, fieldError(bOk, field, msg)
 }
 
-// Check that all information needed to create a job is specified,
-// and that the individual field values have valid format
-// If "is_create" then we are creating a new job and all fields are required
-// Otherwise, the user can specify only the values that are to be changed
-func ValidateJobInfo(job *data.Job_info, is_create bool) (bOk bool, msg string) {
-	bOk, msg = validateEmail(job.Creator)
-	if bOk {
-		bRegistered, _ := dbaccess.IsRegisteredUser(job.Creator)
-		if !bRegistered {
-			bOk = false
-			msg = "Unknown user email"
-		}
-	}
-	if bOk && !is_create {
-		idstring := job.Job_id
-		idval, err := strconv.Atoi(idstring)
-		if (err != nil) || (idval <= 0) {
-			bOk = false 
-			msg = "Invalid job ID specified"
-		} else {
-			bOk = true
-			msg = ""
-		}
+// Check that an email and password were both supplied for login
+// We do not check the password rules here, that was done at registration
+func ValidateCredentials(credentials *data.Credentials) (bOk bool, err error) {
+	credentials.Email = strings.ToLower(credentials.Email)
+	bOk, msg := validateEmail(credentials.Email)
+	if !bOk {
+		return false, data.ValidationError("email", msg)
 	}
-	if bOk && is_create {
-		bOk, msg = ValidateNonEmpty(job.Title,"title")
+	if credentials.Password == "" {
+		return false, data.ValidationError("password", "Missing password")
 	}
-	if bOk {
-		bOk, msg = validateLength(job.Title,64,"title")
-	}		
-	if bOk && is_create {
-		bOk, msg = ValidateNonEmpty(job.Description, "description")
+	return true, nil
+}
+
+// Check that all information needed to create a job is specified,
+// and that the individual field values have valid format
+// All fields are required except the expiry date, which is turned
+// into a stored time stamp, and the location, work arrangement,
+// employment type and skills
+func ValidateJobInfo(job *data.Job_info) (bOk bool, err error) {
+	bOk, err = validateRegistered(job.Creator, "creator")
+	if !bOk {
+		return bOk, err
 	}
+	field := "title"
+	bOk, msg := validateTitle(job.Title)
 	if bOk {
-		bOk, msg = validateLength(job.Description,1024,"description")
+		field = "description"
+		bOk, msg = validateDescription(job.Description)
 	}			
-	if bOk && (is_create || job.Min_education != 0) {
+	if bOk {
+		field = "min_education"
 		bOk, msg = validateEducation(job.Min_education)
 	}
-	if bOk && (is_create || job.Min_experience != 0) {
+	if bOk {
+		field = "min_experience"
 		bOk, msg = validateExperience(job.Min_experience)
 	}
-	if bOk && (is_create || job.Salary != 0) {
+	if bOk {
+		field = "salary"
 		bOk, msg = validateSalary(job.Salary)
 	}		
-	return bOk, msg
+	if bOk && (job.Expires_on != "") {
+		field = "expires_on"
+		job.Expires_on, bOk, msg = validateExpiry(job.Expires_on)
+	}
+	if bOk {
+		field, bOk, msg = validateJobLocation(&job.Province, &job.City, job.Latitude, job.Longitude)
+	}
+	if bOk && (job.Work_arrangement != "") {
+		field = "work_arrangement"
+		job.Work_arrangement = strings.ToLower(strings.TrimSpace(job.Work_arrangement))
+		bOk, msg = validateArrangement(job.Work_arrangement)
+	}
+	if bOk && (job.Employment_type != "") {
+		field = "employment_type"
+		job.Employment_type = strings.ToLower(strings.TrimSpace(job.Employment_type))
+		bOk, msg = validateEmploymentType(job.Employment_type)
+	}
+	if bOk {
+		field = "skills"
+		bOk, msg = validateJobSkills(job.Skills)
+	}
+	if !bOk {
+		return bOk, fieldError(bOk, field, msg)
+	}
+	if job.Org_id != "" {
+		return validateOrgId(job.Org_id)
+	}
+	return true, nil
+}
+
+// Check the changes to a job. Only the fields given are checked,
+// but each of them must have a valid value
+// An expiry date is turned into a stored time stamp; "" removes the expiry
+func ValidateJobChanges(changes *data.Job_changes) (bOk bool, err error) {
+	bOk, err = validateRegistered(changes.Creator, "creator")
+	if !bOk {
+		return bOk, err
+	}
+	bOk, err = validateJobId(changes.Job_id)
+	if !bOk {
+		return bOk, err
+	}
+	field := ""
+	msg := ""
+	if changes.Title != nil {
+		field = "title"
+		bOk, msg = validateTitle(*changes.Title)
+	}
+	if bOk && (changes.Description != nil) {
+		field = "description"
+		bOk, msg = validateDescription(*changes.Description)
+	}
+	if bOk && (changes.Min_education != nil) {
+		field = "min_education"
+		bOk, msg = validateEducation(*changes.Min_education)
+	}
+	if bOk && (changes.Min_experience != nil) {
+		field = "min_experience"
+		bOk, msg = validateExperience(*changes.Min_experience)
+	}
+	if bOk && (changes.Salary != nil) {
+		field = "salary"
+		bOk, msg = validateSalary(*changes.Salary)
+	}
+	if bOk && (changes.Expires_on != nil) && (*changes.Expires_on != "") {
+		field = "expires_on"
+		var expires string
+		expires, bOk, msg = validateExpiry(*changes.Expires_on)
+		changes.Expires_on = &expires
+	}
+	if bOk && (changes.Province != nil) {
+		field = "province"
+		*changes.Province = strings.TrimSpace(*changes.Province)
+		bOk, msg = validateLength(*changes.Province, 64, "province")
+	}
+	if bOk && (changes.City != nil) {
+		field = "city"
+		*changes.City = strings.TrimSpace(*changes.City)
+		bOk, msg = validateLength(*changes.City, 64, "city")
+	}
+	if bOk && ((changes.Latitude != nil) || (changes.Longitude != nil)) {
+		field = "latitude"
+		bOk = (changes.Latitude != nil) && (changes.Longitude != nil)
+		msg = "latitude and longitude must be changed together"
+		if bOk {
+			field, bOk, msg = validatePosition(*changes.Latitude, *changes.Longitude)
+		}
+	}
+	if bOk && (changes.Work_arrangement != nil) && (*changes.Work_arrangement != "") {
+		field = "work_arrangement"
+		*changes.Work_arrangement = strings.ToLower(strings.TrimSpace(*changes.Work_arrangement))
+		bOk, msg = validateArrangement(*changes.Work_arrangement)
+	}
+	if bOk && (changes.Employment_type != nil) && (*changes.Employment_type != "") {
+		field = "employment_type"
+		*changes.Employment_type = strings.ToLower(strings.TrimSpace(*changes.Employment_type))
+		bOk, msg = validateEmploymentType(*changes.Employment_type)
+	}
+	if bOk && (changes.Skills != nil) {
+		field = "skills"
+		bOk, msg = validateJobSkills(*changes.Skills)
+	}
+	return bOk, fieldError(bOk, field, msg)
 }
 
 // Check the specified search criteria
 // All are optional except for the user, but numeric values have limits
 // If nothing is specified, the search will return all jobs
-func ValidateSearchCriteria(filter *data.Search_criteria) (bOk bool, msg string) {
-	bOk, msg = validateEmail(filter.User_email)
-	if bOk {
-		bRegistered, _ := dbaccess.IsRegisteredUser(filter.User_email)
-		if !bRegistered {
+func ValidateSearchCriteria(filter *data.Search_criteria) (bOk bool, err error) {
+	bOk, err = validateRegistered(filter.User_email, "email")
+	if !bOk {
+		return bOk, err
+	}
+	field := ""
+	msg := ""
+	// turn the dates into stored time stamps for the search
+	// Posted_to becomes exclusive, so that it includes the whole day
+	if filter.Posted_from != "" {
+		field = "posted_from"
+		filter.Posted_from, bOk, msg = validateDate(filter.Posted_from, false)
+	}
+	if bOk && (filter.Posted_to != "") {
+		field = "posted_to"
+		filter.Posted_to, bOk, msg = validateDate(filter.Posted_to, true)
+		if bOk && (filter.Posted_from != "") && (filter.Posted_to <= filter.Posted_from) {
 			bOk = false
-			msg = "Unknown user email"
-		}
-	}	
-	if bOk && filter.Posted != ""{
-		bOk, msg = validateDate(filter.Posted)
-		if bOk {
-			// add a time for DB search
-			filter.Posted += " 00:01 +700"
+			msg = "posted_to must not be before posted_from"
 		}
 	}
 	if bOk && (filter.Experience != 0) {
+		field = "experience"
 		bOk, msg = validateExperience(filter.Experience)
 	}		
 	if bOk && (filter.Education != 0) {
+		field = "education"
 		bOk, msg = validateEducation(filter.Education)
 	}
 	if bOk && (filter.Salary != 0) {
+		field = "salary"
 		bOk, msg = validateSalary(filter.Salary)
 	}		
-	// no constraints on keyword criterion
-	return bOk, msg
+	if bOk && (filter.Work_arrangement != "") {
+		field = "work_arrangement"
+		filter.Work_arrangement = strings.ToLower(strings.TrimSpace(filter.Work_arrangement))
+		bOk, msg = validateArrangement(filter.Work_arrangement)
+	}
+	if bOk && (filter.Employment_type != "") {
+		field = "employment_type"
+		filter.Employment_type = strings.ToLower(strings.TrimSpace(filter.Employment_type))
+		bOk, msg = validateEmploymentType(filter.Employment_type)
+	}
+	if bOk && ((filter.Radius_km != 0) || (filter.Latitude != 0) || (filter.Longitude != 0)) {
+		field, bOk, msg = validateRadius(filter.Latitude, filter.Longitude, filter.Radius_km)
+	}
+	if bOk && (len(filter.Skills) > 0) {
+		field = "skills"
+		bOk, msg = validateSkillNames(filter.Skills)
+	}
+	// no constraints on keyword, location or organization name criteria
+	if bOk && (filter.Org_id != "") {
+		return validateOrgId(filter.Org_id)
+	}
+	return bOk, fieldError(bOk, field, msg)
+}
+
+// Check the paging and sorting arguments for a listing
+// The sort field must be one of sort_fields; an empty sort or order
+// means the listing's default order. A zero limit is replaced by the default
+func ValidatePageRequest(page *data.Page_request, sort_fields []string) (bOk bool, err error) {
+	if page.Limit == 0 {
+		page.Limit = defaultPageLimit
+	}
+	if (page.Limit < 0) || (page.Limit > maxPageLimit) {
+		return false, data.ValidationError("limit", fmt.Sprintf("Invalid limit - must be from 1 to %d", maxPageLimit))
+	}
+	if page.Offset < 0 {
+		return false, data.ValidationError("offset", "Invalid offset - must not be negative")
+	}
+	page.Sort = strings.ToLower(strings.TrimSpace(page.Sort))
+	if page.Sort != "" {
+		bOk = false
+		for _, field := range sort_fields {
+			if page.Sort == field {
+				bOk = true
+				break
+			}
+		}
+		if !bOk {
+			return false, data.ValidationError("sort", "Invalid sort field - must be one of " + strings.Join(sort_fields, ", "))
+		}
+	}
+	page.Order = strings.ToLower(strings.TrimSpace(page.Order))
+	if page.Order != "" && page.Order != "asc" && page.Order != "desc" {
+		return false, data.ValidationError("order", "Invalid order - must be asc or desc")
+	}
+	return true, nil
 }
 
 // check to see that the ID is set and is a positive integer
-func ValidateDetailRequest(job *data.Job_info) (bOk bool, msg string) {	
-	bOk, msg = validateEmail(job.Creator) // not really the creator... just use this field
+func ValidateDetailRequest(job *data.Job_info) (bOk bool, err error) {	
+	bOk, err = validateRegistered(job.Creator, "email") // not really the creator... just use this field
 	if bOk {
-		bRegistered, _ := dbaccess.IsRegisteredUser(job.Creator)
-		if !bRegistered {
-			bOk = false
-			msg = "Unknown user email"
+		bGiven, msg := ValidateNonEmpty(job.Job_id, "Job ID")
+		if !bGiven {
+			return false, data.ValidationError("job_id", msg)
 		}
+		bOk, err = validateJobId(job.Job_id)
 	}
-	idstring := job.Job_id	
+	return bOk, err
+}
+
+// check to see that the ID is set and is a positive integer
+func ValidateJobSubmission(submission *data.Submission) (bOk bool, err error) {	
+	bOk, err = validateRegistered(submission.Email, "email")
 	if bOk {
-		bOk, msg = ValidateNonEmpty(idstring," Job ID")
+		bOk, err = validateJobId(submission.Job_id)
+	}
+	if !bOk {
+		return bOk, err
+	}
+	submission.Cover_letter = strings.TrimSpace(submission.Cover_letter)
+	if utf8.RuneCountInString(submission.Cover_letter) > data.MaxCoverLetter {
+		msg := fmt.Sprintf("Cover letter must be %d characters or less", data.MaxCoverLetter)
+		return false, data.ValidationError("cover_letter", msg)
 	}
+	if submission.Resume != nil {
+		bOk, err = ValidateResume(submission.Resume)
+	}
+	return bOk, err
+}
+
+// Check an uploaded resume: a PDF or DOCX file no bigger than
+// data.MaxResumeBytes. The type is decided from the file name and
+// checked against the start of the content, so the content type
+// the client sent is replaced. Any directory is removed from the name
+func ValidateResume(resume *data.Document) (bOk bool, err error) {
+	resume.File_name = path.Base(strings.ReplaceAll(strings.TrimSpace(resume.File_name), "\\", "/"))
+	if resume.File_name == "." || resume.File_name == "/" {
+		resume.File_name = ""
+	}
+	bOk, msg := ValidateNonEmpty(resume.File_name, "Resume file name")
 	if bOk {
-		idval, err := strconv.Atoi(idstring)
-		if (err != nil) || (idval <= 0) {
-			bOk = false 
-			msg = "Invalid job ID specified"
-		} else {
-			bOk = true
-			msg = ""
+		bOk, msg = validateLength(resume.File_name, maxFileName, "Resume file name")
+	}
+	if !bOk {
+		return false, data.ValidationError("resume", msg)
+	}
+	format, found := resumeFormats[strings.ToLower(path.Ext(resume.File_name))]
+	if !found {
+		return false, data.ValidationError("resume", "Resume must be a PDF or DOCX file")
+	}
+	if len(resume.Content) == 0 {
+		return false, data.ValidationError("resume", "Resume is empty")
+	}
+	if len(resume.Content) > data.MaxResumeBytes {
+		msg = fmt.Sprintf("Resume must be %d MB or less", data.MaxResumeBytes / (1024 * 1024))
+		return false, data.ValidationError("resume", msg)
+	}
+	if !bytes.HasPrefix(resume.Content, format.magic) {
+		return false, data.ValidationError("resume", "Resume content does not match its file type")
+	}
+	resume.Content_type = format.content_type
+	resume.Size = len(resume.Content)
+	return true, nil
+}
+
+// Check a request by a job creator about one application, such as
+// getting its resume. The applicant is identified by email and the
+// status is ignored
+func ValidateApplicationRequest(change *data.Status_change) (bOk bool, err error) {
+	change.Creator = strings.ToLower(change.Creator)
+	change.Applicant = strings.ToLower(change.Applicant)
+	bOk, err = validateRegistered(change.Creator, "creator")
+	if !bOk {
+		return bOk, err
+	}
+	bOk, msg := validateEmail(change.Applicant)
+	if !bOk {
+		return false, data.ValidationError("email", msg)
+	}
+	return validateJobId(change.Job_id)
+}
+
+// Check a request by a job creator to change an application status
+// The applicant is identified by email. Only the creator-side statuses
+// are allowed; submitted and withdrawn are set by the applicant
+func ValidateStatusChange(change *data.Status_change) (bOk bool, err error) {
+	change.Status = strings.ToLower(strings.TrimSpace(change.Status))
+	bOk, err = ValidateApplicationRequest(change)
+	if !bOk {
+		return bOk, err
+	}
+	bOk, msg := validateStatus(change.Status)
+	if bOk && (change.Status == data.StatusSubmitted || change.Status == data.StatusWithdrawn) {
+		bOk = false
+		msg = "Job creator cannot set status " + change.Status
+	}
+	return bOk, fieldError(bOk, "status", msg)
+}
+
+// Check a request by a job creator to archive or delete one of their jobs
+func ValidateJobOwnerRequest(job *data.Job_info) (bOk bool, err error) {
+	bOk, err = validateRegistered(job.Creator, "creator")
+	if bOk {
+		bOk, err = validateJobId(job.Job_id)
+	}
+	return bOk, err
+}
+
+// Check the information for a new organization, or the changes to one
+// The creator is the user making the request. If "is_create" the name is
+// required; otherwise only the values to be changed need to be given
+func ValidateOrganization(org *data.Organization, is_create bool) (bOk bool, err error) {
+	org.Creator = strings.ToLower(org.Creator)
+	org.Name = strings.TrimSpace(org.Name)
+	bOk, err = validateRegistered(org.Creator, "creator")
+	if !bOk {
+		return bOk, err
+	}
+	if !is_create {
+		bOk, err = validateOrgId(org.Org_id)
+		if !bOk {
+			return bOk, err
 		}
 	}
-	return bOk, msg
+	field := "name"
+	bOk = true
+	msg := ""
+	if is_create || org.Name != "" {
+		bOk, msg = ValidateNonEmpty(org.Name, "name")
+		if bOk {
+			bOk, msg = validateLength(org.Name, 64, "name")
+		}
+	}
+	if bOk {
+		field = "description"
+		bOk, msg = validateLength(org.Description, 1024, "description")
+	}
+	if bOk && (org.Website != "") {
+		field = "website"
+		bOk, msg = validateUrl(org.Website, "website")
+	}
+	if bOk && (org.Logo_url != "") {
+		field = "logo_url"
+		bOk, msg = validateUrl(org.Logo_url, "logo_url")
+	}
+	if bOk {
+		field = "location"
+		bOk, msg = validateLength(org.Location, 64, "location")
+	}
+	return bOk, fieldError(bOk, field, msg)
 }
 
-// check to see that the ID is set and is a positive integer
-func ValidateJobSubmission(submission *data.Submission) (bOk bool, msg string) {	
-	bOk, msg = validateEmail(submission.Email) 
+// Check a request to see an organization
+// Creator is the user asking, who can be anybody registered
+func ValidateOrgRequest(org *data.Organization) (bOk bool, err error) {
+	org.Creator = strings.ToLower(org.Creator)
+	bOk, err = validateRegistered(org.Creator, "email")
 	if bOk {
-		bRegistered, _ := dbaccess.IsRegisteredUser(submission.Email)
-		if !bRegistered {
-			bOk = false
-			msg = "Unknown user email"
+		bOk, err = validateOrgId(org.Org_id)
+	}
+	return bOk, err
+}
+
+// Check a request to add, change or remove a member of an organization
+// A new member must be registered. The role is not needed to remove a member
+func ValidateMemberChange(change *data.Member_change, is_remove bool) (bOk bool, err error) {
+	change.Creator = strings.ToLower(change.Creator)
+	change.Member = strings.ToLower(change.Member)
+	change.Role = strings.ToLower(strings.TrimSpace(change.Role))
+	bOk, err = validateRegistered(change.Creator, "creator")
+	if !bOk {
+		return bOk, err
+	}
+	bOk, err = validateOrgId(change.Org_id)
+	if !bOk {
+		return bOk, err
+	}
+	if is_remove {
+		bOk, msg := validateEmail(change.Member)
+		return bOk, fieldError(bOk, "email", msg)
+	}
+	bOk, err = validateRegistered(change.Member, "email")
+	if !bOk {
+		return bOk, err
+	}
+	bOk, msg := validateRole(change.Role)
+	return bOk, fieldError(bOk, "role", msg)
+}
+
+// Check a search to be saved: it needs a name, and its criteria are
+// checked, and their dates converted, the same way as for a search
+func ValidateSavedSearch(search *data.Saved_search) (bOk bool, err error) {
+	search.User_email = strings.ToLower(search.User_email)
+	search.Name = strings.TrimSpace(search.Name)
+	search.Criteria.User_email = search.User_email
+	bOk, err = ValidateSearchCriteria(&search.Criteria)
+	if !bOk {
+		return bOk, err
+	}
+	bOk, msg := ValidateNonEmpty(search.Name, "name")
+	if bOk {
+		bOk, msg = validateLength(search.Name, data.MaxSearchName, "name")
+	}
+	return bOk, fieldError(bOk, "name", msg)
+}
+
+// Check a request to run or delete a saved search
+func ValidateSavedSearchRequest(search *data.Saved_search) (bOk bool, err error) {
+	search.User_email = strings.ToLower(search.User_email)
+	bOk, err = validateRegistered(search.User_email, "email")
+	if bOk {
+		bOk, err = validateSearchId(search.Search_id)
+	}
+	return bOk, err
+}
+
+// Check that the information needed to register a webhook is given and
+// valid. The events are trimmed, and each may be given only once
+func ValidateWebhook(hook *data.Webhook) (bOk bool, err error) {
+	hook.Creator = strings.ToLower(hook.Creator)
+	bOk, err = validateRegistered(hook.Creator, "email")
+	if !bOk {
+		return bOk, err
+	}
+	if hook.Org_id != "" {
+		bOk, err = validateOrgId(hook.Org_id)
+		if !bOk {
+			return bOk, err
 		}
-	}	
+	}
+	hook.Url = strings.TrimSpace(hook.Url)
+	bOk, msg := ValidateNonEmpty(hook.Url, "url")
 	if bOk {
-		idstring := submission.Job_id
-		idval, err := strconv.Atoi(idstring)
-		if (err != nil) || (idval <= 0) {
-			bOk = false 
-			msg = "Invalid job ID specified"
-		} else {
-			bOk = true
-			msg = ""
+		bOk, msg = validateUrl(hook.Url, "url")
+	}
+	if !bOk {
+		return false, data.ValidationError("url", msg)
+	}
+	var events []string
+	for _, event := range hook.Events {
+		event = strings.TrimSpace(event)
+		if !validateEvent(event) {
+			return false, data.ValidationError("events", "Unknown event " + event + " - must be one of " + joinEvents())
 		}
+		for _, other := range events {
+			if other == event {
+				return false, data.ValidationError("events", "Event " + event + " is given twice")
+			}
+		}
+		events = append(events, event)
 	}
-	return bOk, msg
+	if len(events) == 0 {
+		return false, data.ValidationError("events", "At least one event is required - one of " + joinEvents())
+	}
+	hook.Events = events
+	return true, nil
+}
+
+// Check a request to delete a webhook or list its deliveries
+func ValidateWebhookRequest(hook *data.Webhook) (bOk bool, err error) {
+	hook.Creator = strings.ToLower(hook.Creator)
+	bOk, err = validateRegistered(hook.Creator, "email")
+	if bOk {
+		bOk, err = validateWebhookId(hook.Webhook_id)
+	}
+	return bOk, err
+}
+
+// Check changes to a user's notification preferences. At least one
+// change must be given, and the language must be one we have templates for
+func ValidateNotificationChanges(prefs *data.Notification_changes) (bOk bool, err error) {
+	prefs.User_email = strings.ToLower(prefs.User_email)
+	bOk, err = validateRegistered(prefs.User_email, "email")
+	if !bOk {
+		return bOk, err
+	}
+	if prefs.Language == nil && prefs.Application_received == nil && prefs.Hired == nil && prefs.Rejected == nil {
+		return false, data.ValidationError("language", "Nothing to change - give a language or the notifications to turn on or off")
+	}
+	if prefs.Language != nil {
+		language := strings.ToLower(strings.TrimSpace(*prefs.Language))
+		if !validateLanguage(language) {
+			return false, data.ValidationError("language", "Unknown language " + language + " - must be one of " +
+			                                   strings.Join(data.Languages[:], ", "))
+		}
+		prefs.Language = &language
+	}
+	return true, nil
+}
+
+// Check a request for the thread of an application: the job and the
+// applicant, who is the sender if not given. The sender is the user
+// making the request
+func ValidateThreadRequest(message *data.Message) (bOk bool, err error) {
+	message.Sender = strings.ToLower(message.Sender)
+	bOk, err = validateRegistered(message.Sender, "email")
+	if bOk {
+		bOk, err = validateJobId(message.Job_id)
+	}
+	if !bOk {
+		return bOk, err
+	}
+	message.Applicant = strings.ToLower(strings.TrimSpace(message.Applicant))
+	if message.Applicant == "" {
+		message.Applicant = message.Sender
+	}
+	bOk, msg := validateEmail(message.Applicant)
+	if !bOk {
+		return false, data.ValidationError("applicant", msg)
+	}
+	return true, nil
+}
+
+// Check a message to send to the thread of an application
+func ValidateMessage(message *data.Message) (bOk bool, err error) {
+	bOk, err = ValidateThreadRequest(message)
+	if !bOk {
+		return bOk, err
+	}
+	message.Body = strings.TrimSpace(message.Body)
+	if message.Body == "" {
+		return false, data.ValidationError("body", "Message must not be blank")
+	}
+	if utf8.RuneCountInString(message.Body) > data.MaxMessageLength {
+		msg := fmt.Sprintf("Message must be %d characters or less", data.MaxMessageLength)
+		return false, data.ValidationError("body", msg)
+	}
+	return true, nil
+}
+
+// Split a comma-separated list of skills, as given on the command line
+// or in a query, leaving out empty entries. The names are checked later
+func SplitSkills(list string) (names []string) {
+	for _, name := range strings.Split(list, ",") {
+		if strings.TrimSpace(name) != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Turn the command line lists of required and nice-to-have skills into job skills
+func ParseJobSkills(required string, nice_to_have string) (skills []data.Job_skill) {
+	skills = []data.Job_skill{}
+	for _, name := range SplitSkills(required) {
+		skills = append(skills, data.Job_skill{Name: name, Required: true})
+	}
+	for _, name := range SplitSkills(nice_to_have) {
+		skills = append(skills, data.Job_skill{Name: name})
+	}
+	return skills
+}
+
+// Turn a command line list of skills such as "go:4,sql:2" into user skills
+// A skill without a proficiency gets proficiency 3
+func ParseUserSkills(list string) (skills []data.User_skill, err error) {
+	skills = []data.User_skill{}
+	for _, entry := range SplitSkills(list) {
+		skill := data.User_skill{Name: entry, Proficiency: defaultProficiency}
+		if name, level, found := strings.Cut(entry, ":"); found {
+			skill.Name = name
+			skill.Proficiency, err = strconv.Atoi(strings.TrimSpace(level))
+			if err != nil {
+				return nil, data.ValidationError("skills", "Invalid proficiency for " + name + " - must be an integer")
+			}
+		}
+		skills = append(skills, skill)
+	}
+	return skills, nil
+}
+
+// Turn a command line work history, a JSON list such as
+// [{"title":"Developer","employer":"Acme","start_date":"2020-01-06"}],
+// into work history entries. An empty string means an empty history
+func ParseWorkHistory(text string) (history []data.Work_entry, err error) {
+	history = []data.Work_entry{}
+	if strings.TrimSpace(text) == "" {
+		return history, nil
+	}
+	err = json.Unmarshal([]byte(text), &history)
+	if err != nil {
+		return nil, data.ValidationError("work_history", "Invalid work_history - must be a JSON list of entries with title, employer, start_date and end_date")
+	}
+	return history, nil
 }
 
 // Specialized searches
 // The only required argument is the email, which is interpreted differently
 // depending on the task
-func ValidateOfferedAppliedRequest(job *data.Job_info) (bOk bool, msg string) {	
-	bOk, msg = validateEmail(job.Creator) // not really the creator... just use this field
+func ValidateOfferedAppliedRequest(job *data.Job_info) (bOk bool, err error) {	
+	return validateRegistered(job.Creator, "email") // not really the creator... just use this field
+}
+
+// Turn the result of one of the private checks into a validation error
+// for the named field, or nil if the check passed
+func fieldError(bOk bool, field string, msg string) error {
 	if bOk {
-		bRegistered, _ := dbaccess.IsRegisteredUser(job.Creator)
-		if !bRegistered {
-			bOk = false
-			msg = "Unknown user email"
+		return nil
+	}
+	return data.ValidationError(field, msg)
+}
+
+// Check that an email is valid and belongs to a registered user
+// The field is the name of the argument holding the email
+func validateRegistered(email_addr string, field string) (bOk bool, err error) {
+	bOk, msg := validateEmail(email_addr)
+	if !bOk {
+		return false, data.ValidationError(field, msg)
+	}
+	bRegistered, _ := dbaccess.GetStore().IsRegisteredUser(email_addr)
+	if !bRegistered {
+		return false, data.NotFoundError("Unknown user email")
+	}
+	return true, nil
+}
+
+// Check that a job ID is a positive integer
+func validateJobId(idstring string) (bOk bool, err error) {
+	idval, converr := strconv.Atoi(idstring)
+	if (converr != nil) || (idval <= 0) {
+		return false, data.ValidationError("job_id", "Invalid job ID specified")
+	}
+	return true, nil
+}
+
+// Check that an organization ID is a positive integer
+func validateOrgId(idstring string) (bOk bool, err error) {
+	idval, converr := strconv.Atoi(idstring)
+	if (converr != nil) || (idval <= 0) {
+		return false, data.ValidationError("org_id", "Invalid organization ID specified")
+	}
+	return true, nil
+}
+
+// Check that a webhook ID is a positive integer
+func validateWebhookId(idstring string) (bOk bool, err error) {
+	idval, converr := strconv.Atoi(idstring)
+	if (converr != nil) || (idval <= 0) {
+		return false, data.ValidationError("webhook_id", "Invalid webhook ID specified")
+	}
+	return true, nil
+}
+
+// Return true if a webhook can be told about this event
+func validateEvent(event string) bool {
+	for _, name := range data.Webhook_events {
+		if name == event {
+			return true
 		}
-	}	
-	return bOk, msg
+	}
+	return false
 }
 
-// check to see if the passed date assumed to be in form YYYY-MM-DD is valid
-func validateDate(datestring string) (bOk bool, msg string) {
-	_, err := time.Parse(dateOnlyString,datestring)
+// List the webhook events for an error message
+func joinEvents() string {
+	return strings.Join(data.Webhook_events[:], ", ")
+}
+
+// Return true if notifications can be written in this language
+func validateLanguage(language string) bool {
+	for _, name := range data.Languages {
+		if name == language {
+			return true
+		}
+	}
+	return false
+}
+
+// Check that a saved search ID is a positive integer
+func validateSearchId(idstring string) (bOk bool, err error) {
+	idval, converr := strconv.Atoi(idstring)
+	if (converr != nil) || (idval <= 0) {
+		return false, data.ValidationError("search_id", "Invalid saved search ID specified")
+	}
+	return true, nil
+}
+
+// check to see if the passed date, either YYYY-MM-DD in the display time zone
+// or an RFC3339 time, is valid, and convert it to a stored time stamp
+// If is_end is true the result is the first time after the date or time,
+// so a date includes the whole day
+func validateDate(datestring string, is_end bool) (stored string, bOk bool, msg string) {
+	t, err := time.ParseInLocation(data.DateFormat, datestring, data.DisplayZone())
+	if err == nil {
+		if is_end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return data.StoredTime(t), true, ""
+	}
+	t, err = time.Parse(time.RFC3339, datestring)
 	if err != nil {
-		bOk = false 
-		msg = "Invalid date"
-	} else {
-		bOk = true
-		msg = ""
+		return "", false, "Invalid date - must be YYYY-MM-DD or an RFC3339 time"
+	}
+	if is_end {
+		t = t.Truncate(time.Second).Add(time.Second)
+	}
+	return data.StoredTime(t), true, ""
+}
+
+// Check a job expiry date, which must be in the future, and turn it into
+// a stored time stamp. The job closes at the end of the day given
+func validateExpiry(datestring string) (stored string, bOk bool, msg string) {
+	stored, bOk, msg = validateDate(datestring, true)
+	if bOk && (stored <= data.StoredNow()) {
+		return "", false, "expires_on must be in the future"
+	}
+	return stored, bOk, msg
+}
+
+// Validate the work history of a user. Each entry needs a title, an
+// employer and a start date that is not in the future; the end date,
+// if given, cannot be before the start. Dates are YYYY-MM-DD
+func validateWorkHistory(history []data.Work_entry) (bOk bool, msg string) {
+	if len(history) > maxWorkEntries {
+		return false, fmt.Sprintf("At most %d work history entries can be given", maxWorkEntries)
+	}
+	today := time.Now().In(data.DisplayZone()).Format(data.DateFormat)
+	for i := range history {
+		entry := &history[i]
+		entry.Title = strings.TrimSpace(entry.Title)
+		entry.Employer = strings.TrimSpace(entry.Employer)
+		entry.Start_date = strings.TrimSpace(entry.Start_date)
+		entry.End_date = strings.TrimSpace(entry.End_date)
+		bOk, msg = validateTitle(entry.Title)
+		if bOk {
+			bOk, msg = ValidateNonEmpty(entry.Employer, "employer")
+		}
+		if bOk {
+			bOk, msg = validateLength(entry.Employer, 64, "employer")
+		}
+		if bOk {
+			bOk, msg = validatePlainDate(entry.Start_date, "start_date")
+		}
+		if bOk && (entry.Start_date > today) {
+			bOk, msg = false, "start_date cannot be in the future"
+		}
+		if bOk && (entry.End_date != "") {
+			bOk, msg = validatePlainDate(entry.End_date, "end_date")
+			if bOk && (entry.End_date < entry.Start_date) {
+				bOk, msg = false, "end_date cannot be before start_date"
+			}
+		}
+		if !bOk {
+			return bOk, fmt.Sprintf("Entry %d: %s", i + 1, msg)
+		}
+	}
+	return true, ""
+}
+
+// Check that a date is in the form YYYY-MM-DD
+func validatePlainDate(datestring string, name string) (bOk bool, msg string) {
+	_, err := time.Parse(data.DateFormat, datestring)
+	if err != nil {
+		return false, "Invalid " + name + " - must be YYYY-MM-DD"
+	}
+	return true, ""
+}
+
+// Validate a job title. Must not be blank, and at most 64 characters
+func validateTitle(title string) (bOk bool, msg string) {
+	bOk, msg = ValidateNonEmpty(title, "title")
+	if bOk {
+		bOk, msg = validateLength(title, 64, "title")
+	}
+	return bOk, msg
+}
+
+// Validate a job description. Must not be blank, and at most 1024 characters
+func validateDescription(desc string) (bOk bool, msg string) {
+	bOk, msg = ValidateNonEmpty(desc, "description")
+	if bOk {
+		bOk, msg = validateLength(desc, 1024, "description")
 	}
 	return bOk, msg
 }
@@ -304,7 +1225,7 @@ func validateEmail(email_addr string) (bOk bool, msg string) {
 		if err == nil {
 			msg = "Invalid email address"
 		} else {
-			fmt.Sprintf(msg, "Error: %v", err)
+			msg = fmt.Sprintf("Error: %v", err)
 		}
 	}
 	return bOk, msg
@@ -327,7 +1248,7 @@ func validateFirstLastName(name string, which string) (bOk bool, msg string) {
 		if err == nil {
 			msg = "Invalid " + which + " name"
 		} else {
-			fmt.Sprintf(msg,"Error: %v",err)
+			msg = fmt.Sprintf("Error: %v", err)
 		}
 	}
 	return bOk, msg	
@@ -344,12 +1265,198 @@ func validatePhone(phone_num_string string) (bOk bool, msg string) {
 		if err == nil {
 			msg = "Invalid phone number"
 		} else {
-			fmt.Sprintf(msg,"Error: %v",err)
+			msg = fmt.Sprintf("Error: %v", err)
 		}
 	}
 	return bOk, msg
 }
 
+// Validate password. Must be between 8 and 64 characters
+// (bcrypt ignores anything beyond 72 bytes)
+func validatePassword(password string) (bOk bool, msg string) {
+	if password == "" {
+		return false, "Missing password"
+	}
+	if len(password) < 8 {
+		return false, "Password must be at least 8 characters"
+	}
+	return validateLength(password, 64, "Password")
+}
+
+// Validate application status. Must be one of data.Application_statuses
+func validateStatus(status string) (bOk bool, msg string) {
+	if status == "" {
+		return false, "Missing application status"
+	}
+	for _, s := range data.Application_statuses {
+		if status == s {
+			return true, ""
+		}
+	}
+	return false, "Invalid application status"
+}
+
+// Validate an organization role. Must be one of data.Org_roles
+func validateRole(role string) (bOk bool, msg string) {
+	if role == "" {
+		return false, "Missing role"
+	}
+	for _, r := range data.Org_roles {
+		if role == r {
+			return true, ""
+		}
+	}
+	return false, "Invalid role - must be owner or recruiter"
+}
+
+// Validate a web address, such as a website or logo. Must be an absolute
+// http or https URL of at most 256 characters
+func validateUrl(address string, label string) (bOk bool, msg string) {
+	bOk, msg = validateLength(address, 256, label)
+	if !bOk {
+		return bOk, msg
+	}
+	parsed, err := url.Parse(address)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return false, "Invalid " + label + " - must be an http or https URL"
+	}
+	return true, ""
+}
+
+// Validate the location of a job. The province and city are trimmed and
+// may be blank; a latitude and longitude of 0,0 means no position
+// Returns the field that is wrong, if any
+func validateJobLocation(province *string, city *string, latitude float64, longitude float64) (field string, bOk bool, msg string) {
+	*province = strings.TrimSpace(*province)
+	*city = strings.TrimSpace(*city)
+	bOk, msg = validateLength(*province, 64, "province")
+	if !bOk {
+		return "province", bOk, msg
+	}
+	bOk, msg = validateLength(*city, 64, "city")
+	if !bOk {
+		return "city", bOk, msg
+	}
+	return validatePosition(latitude, longitude)
+}
+
+// Validate a position. Latitude must be from -90 to 90 and longitude
+// from -180 to 180. Returns the field that is wrong, if any
+func validatePosition(latitude float64, longitude float64) (field string, bOk bool, msg string) {
+	if !((latitude >= -90) && (latitude <= 90)) {  // also catches NaN
+		return "latitude", false, "Invalid latitude - must be from -90 to 90"
+	}
+	if !((longitude >= -180) && (longitude <= 180)) {
+		return "longitude", false, "Invalid longitude - must be from -180 to 180"
+	}
+	return "", true, ""
+}
+
+// Validate a radius search. The radius and the position of its centre
+// must be given together, and the radius can be at most maxRadiusKm
+// Returns the field that is wrong, if any
+func validateRadius(latitude float64, longitude float64, radius_km float64) (field string, bOk bool, msg string) {
+	if !((radius_km > 0) && (radius_km <= maxRadiusKm)) {
+		return "radius_km", false, fmt.Sprintf("Invalid radius_km - must be more than 0 and at most %d", maxRadiusKm)
+	}
+	if (latitude == 0) && (longitude == 0) {
+		return "latitude", false, "latitude and longitude are required with radius_km"
+	}
+	return validatePosition(latitude, longitude)
+}
+
+// Validate a work arrangement. Must be one of data.Work_arrangements
+func validateArrangement(arrangement string) (bOk bool, msg string) {
+	for _, a := range data.Work_arrangements {
+		if arrangement == a {
+			return true, ""
+		}
+	}
+	return false, "Invalid work arrangement - must be onsite, hybrid or remote"
+}
+
+// Validate an employment type. Must be one of data.Employment_types
+func validateEmploymentType(employment string) (bOk bool, msg string) {
+	for _, e := range data.Employment_types {
+		if employment == e {
+			return true, ""
+		}
+	}
+	return false, "Invalid employment type - must be full-time, part-time, contract or internship"
+}
+
+// Validate a skill name and put it in the normal form, lower case with
+// single spaces. Must be at most 32 characters
+func validateSkillName(name *string) (bOk bool, msg string) {
+	*name = strings.ToLower(strings.Join(strings.Fields(*name), " "))
+	if *name == "" {
+		return false, "Skill name must not be blank"
+	}
+	bOk, msg = validateLength(*name, 32, "Skill name")
+	if bOk && !skillPattern.MatchString(*name) {
+		return false, "Invalid skill name " + *name
+	}
+	return bOk, msg
+}
+
+// Validate a list of skill names for a search, putting each in normal form
+func validateSkillNames(names []string) (bOk bool, msg string) {
+	for i := range names {
+		bOk, msg = validateSkillName(&names[i])
+		if !bOk {
+			return bOk, msg
+		}
+	}
+	return validateUniqueSkills(names)
+}
+
+// Validate the skills of a job, putting each name in normal form
+// No skill can be listed twice
+func validateJobSkills(skills []data.Job_skill) (bOk bool, msg string) {
+	names := make([]string, len(skills))
+	for i := range skills {
+		bOk, msg = validateSkillName(&skills[i].Name)
+		if !bOk {
+			return bOk, msg
+		}
+		names[i] = skills[i].Name
+	}
+	return validateUniqueSkills(names)
+}
+
+// Validate the skills of a user, putting each name in normal form
+// Proficiencies must be from data.MinProficiency to data.MaxProficiency
+func validateUserSkills(skills []data.User_skill) (bOk bool, msg string) {
+	names := make([]string, len(skills))
+	for i := range skills {
+		bOk, msg = validateSkillName(&skills[i].Name)
+		if !bOk {
+			return bOk, msg
+		}
+		if (skills[i].Proficiency < data.MinProficiency) || (skills[i].Proficiency > data.MaxProficiency) {
+			return false, fmt.Sprintf("Invalid proficiency for %s - must be from %d to %d",
+				skills[i].Name, data.MinProficiency, data.MaxProficiency)
+		}
+		names[i] = skills[i].Name
+	}
+	return validateUniqueSkills(names)
+}
+
+// Check that a list of skill names has no duplicates and is not too long
+func validateUniqueSkills(names []string) (bOk bool, msg string) {
+	if len(names) > maxSkills {
+		return false, fmt.Sprintf("At most %d skills can be given", maxSkills)
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			return false, "Skill " + name + " is listed more than once"
+		}
+		seen[name] = true
+	}
+	return true, ""
+}
+
 // Validate education level. If missing we will assume 0
 // Allowed values are 0 through 4
 func validateEducation(ed_level int) (bOk bool, msg string) {
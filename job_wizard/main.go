@@ -9,20 +9,38 @@ import (
     //"log"
     "os"
     "path/filepath" 
+    "strconv"
+    "strings"
+    "time"
     "github.com/segoldin/JobWizard/job_wizard/data"    
     "github.com/segoldin/JobWizard/job_wizard/dbaccess"
     "github.com/segoldin/JobWizard/job_wizard/helper"    
     "github.com/segoldin/JobWizard/job_wizard/api"
     "github.com/segoldin/JobWizard/job_wizard/api/middlewares"        
+    "github.com/segoldin/JobWizard/job_wizard/webhook"
+    "github.com/segoldin/JobWizard/job_wizard/notify"
     "github.com/joho/godotenv"
     "github.com/labstack/echo/v4"
     "github.com/labstack/echo/v4/middleware"    
 )
 
+// Expired jobs are closed this often in server mode, unless
+// JOBWIZARD_SWEEP_MINUTES says otherwise
+const defaultSweepMinutes = 15
+
+// Webhook deliveries that are due are sent this often in server mode,
+// unless JOBWIZARD_WEBHOOK_SECONDS says otherwise
+const defaultWebhookSeconds = 30
+
+// Notification emails that are due are sent this often in server mode,
+// unless JOBWIZARD_MAIL_SECONDS says otherwise
+const defaultMailSeconds = 60
+
 // Job search criteria
 
 var (
     server         bool
+    demo           bool
     task           string
     help           bool
     taskhelp       bool
@@ -30,12 +48,36 @@ var (
     job            data.Job_info
     filter         data.Search_criteria
     submission     data.Submission
+    change         data.Status_change
+    job_changes    data.Job_changes
+    org            data.Organization
+    member         data.Member_change
+    saved          data.Saved_search
+    hook           data.Webhook
+    notification_changes data.Notification_changes
+    message        data.Message
+    mine           bool
+    qualified      bool
+    education      int
+    experience     int
+    work_history   string
+    skills         string
+    nice_to_have   string
+    resume_file    string
+    events         string
+    language       string
+    notify_received bool
+    notify_hired   bool
+    notify_rejected bool
+    page           data.Page_request
+    schema_version int
 )
 
 
 func main() {
     flag.BoolVar(&server, "server", false, "Specify as true to expose REST API")
     flag.BoolVar(&help, "help", false, "Specify as true to see general help")
+    flag.BoolVar(&demo, "demo", false, "Specify as true to use sample data in memory instead of the database")
     flag.StringVar(&task, "task", "", "Task to perform")
     // see validate.go for a list of defined tasks
     // arguments for register
@@ -43,7 +85,12 @@ func main() {
     flag.StringVar(&user.First,"first","","First name of user registering")
     flag.StringVar(&user.Last,"last","","Last name of user registering")
     flag.StringVar(&user.Phone,"phone","","10 digit phone number of user registering")   
-    flag.IntVar(&user.Education,"education",0,"Education of user registering - 0 to 4 (doctoral)") 
+    flag.IntVar(&education,"education",0,"Education of user - 0 to 4 (doctoral)") 
+    flag.StringVar(&user.Password,"password","","Password for REST API login - 8 to 64 chars")
+    flag.IntVar(&experience,"experience",0,"Years of work experience of user")
+    flag.StringVar(&work_history,"work_history","","Work history of user - JSON list of entries with title, employer, start_date and end_date")
+    flag.StringVar(&skills,"skills","","Comma-separated skills - with :proficiency (1 to 5) for a user, required skills for a job")
+    flag.StringVar(&nice_to_have,"nice_to_have","","Comma-separated skills that are nice to have for a job")
     // arguments for create (job) and modify job
     flag.StringVar(&job.Creator,"creator","","Email of user creating the job")
     flag.StringVar(&job.Title,"title","","Job title, in quotes - 64 chars max")
@@ -52,14 +99,68 @@ func main() {
     flag.IntVar(&job.Min_experience,"min_experience",0,"Minimum years of experience desired - integer")
     flag.IntVar(&job.Salary,"salary",0,"Monthly salary offered - integer, max 1 million")
     flag.BoolVar(&job.Is_open,"is_open",true,"Is the job still open?")    
+    flag.StringVar(&job.Expires_on,"expires_on","","Date after which the job closes, in format YYYY-MM-DD, or RFC3339 time")
+    flag.StringVar(&job.Province,"province","","Province where the job is, in quotes - 64 chars max")
+    flag.StringVar(&job.City,"city","","City or district where the job is, in quotes - 64 chars max")
+    flag.Float64Var(&job.Latitude,"latitude",0,"Latitude of the job, or of the centre of a radius search")
+    flag.Float64Var(&job.Longitude,"longitude",0,"Longitude of the job, or of the centre of a radius search")
+    flag.StringVar(&job.Work_arrangement,"work_arrangement","","Where the work is done - onsite, hybrid or remote")
+    flag.StringVar(&job.Employment_type,"employment_type","","Type of employment - full-time, part-time, contract or internship")
     // arguments for search jobs
     //   uses "email" ==> user.Email
-    flag.StringVar(&filter.Posted,"posted","","Posted date in format YYYY-MM-DD") 
+    flag.StringVar(&filter.Posted_from,"posted_from","","Earliest posted date in format YYYY-MM-DD, or RFC3339 time")
+    flag.StringVar(&filter.Posted_from,"posted","","Same as posted_from")
+    flag.StringVar(&filter.Posted_to,"posted_to","","Latest posted date in format YYYY-MM-DD, or RFC3339 time")
     //   uses "min_education" ==> job.Min_education
     //   uses "salary"==> job.Salary
-    flag.StringVar(&filter.Keyword,"keyword","","Keyword for title search")  
+    flag.StringVar(&filter.Keyword,"keyword","","Keywords for title and description search")  
+    flag.BoolVar(&filter.Include_archived,"include_archived",false,"Specify as true to include archived and expired jobs")
+    flag.StringVar(&filter.Organization,"organization","","Part of the name of the organization offering the job")
+    flag.Float64Var(&filter.Radius_km,"radius_km",0,"Only jobs within this many km of -latitude and -longitude")
+    //   uses "location" for part of the city or province, and the job location flags
+    // arguments for organizations
+    //   uses "creator" for the user making a change, "description" and "keyword"
+    flag.StringVar(&org.Org_id,"org_id","","Id of organization, also to post a job for it or search its jobs")
+    flag.StringVar(&org.Name,"name","","Organization or saved search name, in quotes - 64 chars max")
+    flag.StringVar(&org.Website,"website","","Organization website - http or https URL")
+    flag.StringVar(&org.Logo_url,"logo_url","","URL of the organization's logo - http or https URL")
+    flag.StringVar(&org.Location,"location","","Organization location, in quotes - 64 chars max, or part of a job's city or province")
+    flag.BoolVar(&mine,"mine",false,"Specify as true to list only organizations I belong to")
+    //   uses "email" for the member
+    flag.StringVar(&member.Role,"role","","Role of organization member - owner or recruiter")
+    // arguments for saved searches
+    //   uses "name" and the search arguments to save a search
+    flag.StringVar(&saved.Search_id,"search_id","","Id of a saved search to run or delete")
+    // arguments for webhooks
+    //   uses "email" for the user and "org_id" for an organization they own
+    flag.StringVar(&hook.Url,"url","","URL to send webhook events to - http or https")
+    flag.StringVar(&events,"events","","Comma-separated webhook events, such as job.created,application.submitted")
+    flag.StringVar(&hook.Webhook_id,"webhook_id","","Id of a webhook to delete or see the deliveries of")
+    // arguments for notification preferences
+    flag.StringVar(&language,"language","","Language for notification emails - en or th")
+    flag.BoolVar(&notify_received,"notify_received",true,"Email me when someone applies for my job?")
+    flag.BoolVar(&notify_hired,"notify_hired",true,"Email me when I am hired?")
+    flag.BoolVar(&notify_rejected,"notify_rejected",true,"Email me when my application is rejected?")
+    // arguments for messages
+    //   uses "email" for the user and "job_id" for the job applied for
+    flag.StringVar(&message.Applicant,"applicant","","Email of the applicant whose thread to use - leave out for my own applications")
+    flag.StringVar(&message.Body,"body","","Text of a message, in quotes - 2000 chars max")
     // arguments for detail task
     flag.StringVar(&job.Job_id,"job_id","","Id of job to be displayed")
+    // paging and sorting arguments for search, offered, applied and candidates
+    flag.IntVar(&page.Limit,"limit",0,"Maximum number of results to return (default 50)")
+    flag.IntVar(&page.Offset,"offset",0,"Number of results to skip")
+    flag.StringVar(&page.Sort,"sort","","Field to sort results by")
+    flag.StringVar(&page.Order,"order","","Sort order - asc or desc")
+    // arguments for status change
+    //   uses "creator", "job_id" and "email" (the applicant)
+    flag.StringVar(&change.Status,"status","","New application status")
+    // arguments for submit and set_resume
+    flag.StringVar(&resume_file,"resume","","Path of a resume to send - PDF or DOCX, at most 5 MB")
+    flag.StringVar(&submission.Cover_letter,"cover_letter","","Cover letter for an application, in quotes - 4000 chars max")
+    flag.BoolVar(&qualified,"qualified",false,"Specify as true to list only candidates who meet all the job's requirements")
+    // arguments for migrate task
+    flag.IntVar(&schema_version,"version",-1,"Schema version to migrate to (default latest)")
     flag.Usage = customUsage
     flag.Parse()
     if help {
@@ -69,6 +170,15 @@ func main() {
             customUsage()
         }
     } 
+    godotenv.Load(".env_jobwizard")
+    err := data.SetDisplayZone(os.Getenv("JOBWIZARD_TIMEZONE"))
+    if err != nil {
+        jsonErrorOutput(err)
+        os.Exit(1)
+    }
+    if demo {
+        startDemo()
+    }
     if server {
         setupAPI()
     } else {
@@ -80,7 +190,7 @@ func main() {
 // or for a single task as specified by task_name
 func customUsage() {
     fmt.Println("\nGeneral usage: ./job_wizard -task <taskname> [arguments...]")
-    fmt.Println("\tWrites results to standard output in JSON format\n")
+    fmt.Print("\tWrites results to standard output in JSON format\n\n")
     fmt.Println("Available tasks: ")
     fmt.Println("\tregister\tCreate a new user in the database")
     fmt.Println("\tcreate\t\tCreate a new job posting")
@@ -90,9 +200,48 @@ func customUsage() {
     fmt.Println("\tapplied\t\tSearch for jobs that I have applied for")
     fmt.Println("\tmodify\t\tModify a job created by me")
     fmt.Println("\tsubmit\t\tSubmit an application for a job")
-    fmt.Println("\tcandidates\tGet applicants for a specific job\n")    
-    fmt.Println("For task-specific arguments, type ./job_wizard -help=true -task <task_name>\n")
+    fmt.Println("\tcandidates\tGet applicants for a specific job")
+    fmt.Println("\tstatus\t\tMove an application for my job to a new status")
+    fmt.Println("\thire\t\tHire an applicant for my job, which closes the job")
+    fmt.Println("\twithdraw\tWithdraw my application for a job")
+    fmt.Println("\tprofile\t\tSee my name, phone and education")
+    fmt.Println("\tupdate_profile\tChange my name, phone, education or password")
+    fmt.Println("\tdelete_account\tDelete my account and my applications")
+    fmt.Println("\tarchive_job\tClose a job created by me and hide it from searches")
+    fmt.Println("\tdelete_job\tDelete a job created by me that has no applications")
+    fmt.Println("\tcreate_org\tCreate an employer organization, with me as owner")
+    fmt.Println("\torg\t\tSee an organization's profile and members")
+    fmt.Println("\tupdate_org\tChange the profile of an organization I own")
+    fmt.Println("\torgs\t\tList organizations")
+    fmt.Println("\tadd_member\tAdd a member to an organization I own, or change their role")
+    fmt.Println("\tremove_member\tRemove a member from an organization, or leave it")
+    fmt.Println("\tskills\t\tList the skills that jobs and users have given")
+    fmt.Println("\tset_resume\tSet or remove my default resume for applications")
+    fmt.Println("\tsave_search\tSave search criteria under a name, to run again")
+    fmt.Println("\tsaved_searches\tList my saved searches, with their new matches")
+    fmt.Println("\trun_search\tRun one of my saved searches")
+    fmt.Println("\tdelete_search\tDelete one of my saved searches")
+    fmt.Println("\tsearch_alerts\tList my saved searches that have new matches")
+    fmt.Println("\tbookmark\tBookmark a job to come back to later")
+    fmt.Println("\tunbookmark\tRemove one of my bookmarks")
+    fmt.Println("\tbookmarks\tList the jobs I have bookmarked")
+    fmt.Println("\tadd_webhook\tRegister a URL to be sent events on my jobs")
+    fmt.Println("\twebhooks\tList my webhooks")
+    fmt.Println("\tdelete_webhook\tDelete one of my webhooks")
+    fmt.Println("\twebhook_deliveries\tList the deliveries of one of my webhooks")
+    fmt.Println("\tdeliver_webhooks\tSend the webhook deliveries that are due")
+    fmt.Println("\tnotifications\tSee which emails I am sent, and in what language")
+    fmt.Println("\tset_notifications\tChange which emails I am sent, or their language")
+    fmt.Println("\tdeliver_notifications\tSend the notification emails that are due")
+    fmt.Println("\tsend_message\tSend a message about an application")
+    fmt.Println("\tmessages\tList the messages about an application")
+    fmt.Println("\tread_messages\tMark the messages about an application as read")
+    fmt.Println("\tunread_messages\tCount my unread messages")
+    fmt.Print("\tmigrate\t\tUpgrade or downgrade the database schema\n\n")    
+    fmt.Print("For task-specific arguments, type ./job_wizard -help=true -task <task_name>\n\n")
     fmt.Println("To run as a backend service, type ./job_wizard -server=true")
+    fmt.Println("Add -demo=true to use sample data kept in memory, without a database;")
+    fmt.Println("all the sample users have the password jobwizard")
     os.Exit(0)                      
 }
 
@@ -100,14 +249,14 @@ func customUsage() {
 // display information about the arguments for a single task as specified by task_name
 func customUsageTask(task_name string) {
     fmt.Println("\nGeneral usage: ./job_wizard -task <taskname> [arguments...]")
-    fmt.Println("\tWrites results to standard output in JSON format\n")
-    task_index := helper.FindTask(task_name)
-    if (task_index < 0) {
+    fmt.Print("\tWrites results to standard output in JSON format\n\n")
+    name := helper.TaskName(task_name)
+    if (name == "") {
         fmt.Printf("Unknown task '%s'\n",task_name)
         os.Exit(0)
     }
-    switch(task_index) {
-        case 0: // register
+    switch(name) {
+        case "register":
             fmt.Println("Register a new email as a JobWizard user")
             fmt.Println("Arguments for register task:")
             fmt.Println("\t-email <NEW email address>")
@@ -115,10 +264,15 @@ func customUsageTask(task_name string) {
             fmt.Println("\t-last <last name>")
             fmt.Println("\t-phone <10 digit Thai phone>")
             fmt.Println("\t-education <integer 0 to 4>")
-            fmt.Println("All arguments are required\n")
-            fmt.Println("Example: ./job_wizard -task register -email sally@gmail.com -first Sally -last Goldin -phone 0987651122 -education 4\n")
+            fmt.Println("\t-password <password for REST API login, 8 to 64 chars>")
+            fmt.Println("\t-experience <years of work experience, 0 to 75>")
+            fmt.Println("\t-skills <comma-separated skills, each with an optional :proficiency from 1 to 5 (default 3)>")
+            fmt.Println("\t-work_history <JSON list of jobs held, each with title, employer, start_date and end_date>")
+            fmt.Println("\t\tDates are YYYY-MM-DD; leave out end_date for a current job")
+            fmt.Print("All arguments except experience, skills and work_history are required\n\n")
+            fmt.Print("Example: ./job_wizard -task register -email sally@gmail.com -first Sally -last Goldin -phone 0987651122 -education 4 -password \"correct horse\"\n\n")
             break;               
-        case 1: // create
+        case "create":
             fmt.Println("Create a new job posting in the JobWizard database")
             fmt.Println("Arguments for create task:")
             fmt.Println("\t-creator <email of registered user>")
@@ -127,43 +281,79 @@ func customUsageTask(task_name string) {
             fmt.Println("\t-min_education <integer 0 to 4>")
             fmt.Println("\t-min_experience <integer 0 to 75>")
             fmt.Println("\t-salary <monthly salary in baht, 0 means unspecified>")
-            fmt.Println("Creator, title and description are required\n")
-            fmt.Println("Example: ./job_wizard -task create -creator sally@gmail.com -title \"Front End Developer\" -description \"Build user interfaces for enterprise web applications\" -min_education 2 -salary 35000\n")         
+            fmt.Println("\t-expires_on <date: YYYY-MM-DD, or RFC3339 time - the job closes after this>")
+            fmt.Println("\t-org_id <organization offering the job - the creator must be a member>")
+            fmt.Println("\t-province <province in quotes>")
+            fmt.Println("\t-city <city or district in quotes>")
+            fmt.Println("\t-latitude <decimal degrees> -longitude <decimal degrees>")
+            fmt.Println("\t-work_arrangement <onsite, hybrid or remote>")
+            fmt.Println("\t-employment_type <full-time, part-time, contract or internship>")
+            fmt.Println("\t-skills <comma-separated required skills>")
+            fmt.Println("\t-nice_to_have <comma-separated skills that are nice to have>")
+            fmt.Print("Creator, title and description are required\n\n")
+            fmt.Print("Example: ./job_wizard -task create -creator sally@gmail.com -title \"Front End Developer\" -description \"Build user interfaces for enterprise web applications\" -min_education 2 -salary 35000\n\n")         
             break
-        case 2: // search
+        case "search":
             fmt.Println("Search for jobs based on criteria, and print summaries")
             fmt.Println("Arguments for search task:")
             fmt.Println("\t-email <email of registered user>")          
             fmt.Println("\t-min_education <integer 1 to 4>")
             fmt.Println("\t-min_experience <integer >")
             fmt.Println("\t-salary <monthly salary in baht>")          
-            fmt.Println("\t-posted <date: YYYY-MM-DD>")
-            fmt.Println("\t-keyword <keyword to search for in title>")          
-            fmt.Println("Only email is required\n")
-            fmt.Println("Example: ./job_wizard -task search -email sally@gmail.com -salary 30000 -keyword Developer\n")
+            fmt.Println("\t-posted_from <date: YYYY-MM-DD, or RFC3339 time> (or -posted)")
+            fmt.Println("\t-posted_to <date: YYYY-MM-DD, or RFC3339 time - the whole day is included>")
+            fmt.Println("\t-keyword <words to search for in title and description>")          
+            fmt.Println("\t\tAll words must match. Use \"...\" for a phrase and a trailing * for a prefix")
+            fmt.Println("\t-include_archived=true <also return archived and expired jobs>")
+            fmt.Println("\t-org_id <only jobs offered by this organization>")
+            fmt.Println("\t-organization <only jobs offered by organizations whose name contains this>")
+            fmt.Println("\t-location <only jobs whose city or province contains this>")
+            fmt.Println("\t-work_arrangement <onsite, hybrid or remote>")
+            fmt.Println("\t-employment_type <full-time, part-time, contract or internship>")
+            fmt.Println("\t-latitude <decimal degrees> -longitude <decimal degrees> -radius_km <at most 1000>")
+            fmt.Println("\t\tOnly jobs with a position within radius_km of the point given")
+            fmt.Println("\t-skills <comma-separated skills - only jobs that list all of them>")
+            fmt.Println("\t-limit <maximum results to return, default 50, at most 500>")
+            fmt.Println("\t-offset <number of results to skip>")
+            fmt.Println("\t-sort <relevance (default with keyword), posted (default), title, salary, match or job_id>")
+            fmt.Println("\t-order <asc or desc>")
+            fmt.Println("Only email is required")
+            fmt.Println("With a keyword, each result has a title_highlight and snippet with matches in <mark></mark>")
+            fmt.Println("Jobs that list skills have a match_score from 0 to 100, for how well the user's skills fit")
+            fmt.Print("Results are wrapped with the total count and the offset of the next page\n\n")
+            fmt.Print("Example: ./job_wizard -task search -email sally@gmail.com -salary 30000 -keyword Developer\n\n")
             break
-        case 3: // detail
+        case "detail":
             fmt.Println("Return all detailed information for a specific job")
             fmt.Println("Arguments for detail task:")
             fmt.Println("\t-email <email of registered user>")
-            fmt.Println("\t-job_id <show detail for what job>\n")
-            fmt.Println("All arguments are required\n")    
-            fmt.Println("Example: ./job_wizard -task detail -email sally@gmail.com -job_id 00003\n")
+            fmt.Print("\t-job_id <show detail for what job>\n\n")
+            fmt.Print("All arguments are required\n\n")    
+            fmt.Print("Example: ./job_wizard -task detail -email sally@gmail.com -job_id 00003\n\n")
             break
-        case 4: // offered
+        case "offered":
             fmt.Println("Return summaries for all jobs created/posted by a user")
             fmt.Println("Arguments for offered task:")
             fmt.Println("\t-creator <email of registered job creator>")
-            fmt.Println("All arguments are required\n")    
-            fmt.Println("Example: ./job_wizard -task offered -creator sally@gmail.com\n")
+            fmt.Println("\t-limit <maximum results to return, default 50, at most 500>")
+            fmt.Println("\t-offset <number of results to skip>")
+            fmt.Println("\t-sort <posted (default), title, salary or job_id>")
+            fmt.Println("\t-order <asc or desc>")
+            fmt.Print("Only creator is required\n\n")    
+            fmt.Print("Example: ./job_wizard -task offered -creator sally@gmail.com\n\n")
             break
-        case 5: // applied
-            fmt.Println("Return summaries for all jobs a user has applied for")
+        case "applied":
+            fmt.Println("Return summaries for all jobs a user has applied for,")
+            fmt.Println("with the apply date, current status and status history of each application")
             fmt.Println("Arguments for applied task:")
             fmt.Println("\t-email <email of registered user>")
-            fmt.Println("All arguments are required\n")    
-            fmt.Println("Example: ./job_wizard -task applied -email sally@gmail.com\n")
-        case 6: // modify job
+            fmt.Println("\t-limit <maximum results to return, default 50, at most 500>")
+            fmt.Println("\t-offset <number of results to skip>")
+            fmt.Println("\t-sort <applied (default), posted, title, status or job_id>")
+            fmt.Println("\t-order <asc or desc>")
+            fmt.Print("Only email is required\n\n")    
+            fmt.Print("Example: ./job_wizard -task applied -email sally@gmail.com\n\n")
+        case "modify": // modify job
             fmt.Println("Modify some attributes of a specific job")
             fmt.Println("Arguments for modify task:")
             fmt.Println("\t-creator <email of registered user>")
@@ -173,28 +363,436 @@ func customUsageTask(task_name string) {
             fmt.Println("\t-min_education <integer 0 to 4>")
             fmt.Println("\t-min_experience <integer 0 to 75>")
             fmt.Println("\t-salary <monthly salary in baht, 0 means unspecified>")
-            fmt.Println("\t-is_open=false")
-            fmt.Println("Creator and job_id are required, changes any other attributes specified\n")
-            fmt.Println("Example: ./job_wizard -task modify -creator sally@gmail.com -job_id 00002 -title \"User Experience Developer\" -salary 38000\n")         
+            fmt.Println("\t-is_open=false to mark the job filled, or -is_open=true to reopen it")
+            fmt.Println("\t-expires_on <date: YYYY-MM-DD, or RFC3339 time, or \"\" for no expiry>")
+            fmt.Println("\t-province <province in quotes> -city <city or district in quotes>")
+            fmt.Println("\t-latitude <decimal degrees> -longitude <decimal degrees> - give both, or 0 and 0 to remove the position")
+            fmt.Println("\t-work_arrangement <onsite, hybrid or remote>")
+            fmt.Println("\t-employment_type <full-time, part-time, contract or internship>")
+            fmt.Println("\t-skills <comma-separated required skills> -nice_to_have <comma-separated skills>")
+            fmt.Println("\t\tGiving either replaces all the job's skills")
+            fmt.Println("Creator and job_id are required, changes any other attributes specified")
+            fmt.Println("The creator can be any member of the organization offering the job")
+            fmt.Print("Any value can be given, including 0 (for instance -salary 0 makes the salary unspecified)\n\n")
+            fmt.Print("Example: ./job_wizard -task modify -creator sally@gmail.com -job_id 00002 -title \"User Experience Developer\" -salary 38000\n\n")         
             break
-        case 7: // submit application for job
+        case "submit": // submit application for job
             fmt.Println("Apply for a particular job (submit application)")
             fmt.Println("Arguments for submit task:")
             fmt.Println("\t-email <email of registered user>")
-            fmt.Println("\t-job_id <apply for what job>\n")                  
-            fmt.Println("All arguments are required\n")    
-            fmt.Println("Example: ./job_wizard -task submit -email sally@gmail.com -job_id 00014\n")
+            fmt.Println("\t-job_id <apply for what job>")
+            fmt.Println("\t-resume <path of a PDF or DOCX file, at most 5 MB>")
+            fmt.Print("\t-cover_letter <cover letter in quotes, 4000 chars max>\n\n")
+            fmt.Println("Email and job_id are required")
+            fmt.Println("Without -resume, the user's default resume is sent, if they have one (see set_resume)")
+            fmt.Println("If the user does not meet the job's minimum education or experience, or lacks a")
+            fmt.Println("required skill, the application is still made, and the result has a warning and")
+            fmt.Print("the list of unmet_requirements\n\n")
+            fmt.Print("Example: ./job_wizard -task submit -email sally@gmail.com -job_id 00014 -resume cv.pdf\n\n")
             break
-        case 8: // view candidates
+        case "candidates": // view candidates
             fmt.Println("Return candidates for a specific job")
             fmt.Println("Arguments for candidates task:")
             fmt.Println("\t-creator <email of job creator>")
-            fmt.Println("\t-job_id <show candidates for what job>\n")
-            fmt.Println("All arguments are required\n")    
-            fmt.Println("Example: ./job_wizard -task candidates -creator sally@gmail.com -job_id 00003\n")
-            break                       
+            fmt.Println("\t-job_id <show candidates for what job>")
+            fmt.Println("\t-limit <maximum results to return, default 50, at most 500>")
+            fmt.Println("\t-offset <number of results to skip>")
+            fmt.Println("\t-qualified=true <only candidates who meet all the job's requirements>")
+            fmt.Println("\t-sort <applied (default), name, status or match>")
+            fmt.Println("\t-order <asc or desc>")
+            fmt.Println("Creator and job_id are required")
+            fmt.Println("Each candidate shows whether they are qualified: their education and experience")
+            fmt.Println("are at least the job's minimum and they have every required skill")
+            fmt.Println("If the job lists skills, each candidate has a match_score from 0 to 100")
+            fmt.Print("The creator can be any member of the organization offering the job\n\n")
+            fmt.Print("Example: ./job_wizard -task candidates -creator sally@gmail.com -job_id 00003\n\n")
+            break
+        case "status": // change application status
+            fmt.Println("Move an application for one of my jobs to a new status")
+            fmt.Println("Arguments for status task:")
+            fmt.Println("\t-creator <email of job creator>")
+            fmt.Println("\t-job_id <job that was applied for>")
+            fmt.Println("\t-email <email of the applicant>")
+            fmt.Println("\t-status <reviewed, shortlisted, interviewing, offered, hired or rejected>")
+            fmt.Println("All arguments are required")
+            fmt.Print("Hired, rejected and withdrawn applications cannot be changed\n\n")
+            fmt.Print("Example: ./job_wizard -task status -creator sally@gmail.com -job_id 00003 -email jim@gmail.com -status shortlisted\n\n")
+            break
+        case "hire":
+            fmt.Println("Hire an applicant for one of my jobs. The job is marked as filled")
+            fmt.Println("Arguments for hire task:")
+            fmt.Println("\t-creator <email of job creator>")
+            fmt.Println("\t-job_id <job that was applied for>")
+            fmt.Println("\t-email <email of the applicant>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task hire -creator sally@gmail.com -job_id 00003 -email jim@gmail.com\n\n")
+            break
+        case "withdraw":
+            fmt.Println("Withdraw my application for a job")
+            fmt.Println("Arguments for withdraw task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-job_id <job that I applied for>")
+            fmt.Println("All arguments are required")
+            fmt.Print("Hired or rejected applications cannot be withdrawn\n\n")
+            fmt.Print("Example: ./job_wizard -task withdraw -email sally@gmail.com -job_id 00014\n\n")
+            break
+        case "migrate":
+            fmt.Println("Move the database schema to a particular version")
+            fmt.Println("The schema is normally brought up to date automatically,")
+            fmt.Println("unless JOBWIZARD_AUTO_MIGRATE is set to false")
+            fmt.Println("Arguments for migrate task:")
+            fmt.Println("\t-version <schema version> - 0 removes all tables (optional, default latest)")
+            fmt.Print("Example: ./job_wizard -task migrate -version 2\n\n")
+            break
+        case "profile":
+            fmt.Println("See the profile of a registered user")
+            fmt.Println("Arguments for profile task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task profile -email sally@gmail.com\n\n")
+            break
+        case "update_profile":
+            fmt.Println("Change the profile of a registered user")
+            fmt.Println("Arguments for update_profile task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-first <new first name>")
+            fmt.Println("\t-last <new last name>")
+            fmt.Println("\t-phone <new 10 digit Thai phone>")
+            fmt.Println("\t-education <new integer 0 to 4>")
+            fmt.Println("\t-password <new password, 8 to 64 chars>")
+            fmt.Println("\t-experience <new years of work experience, 0 to 75>")
+            fmt.Println("\t-skills <comma-separated skills, each with an optional :proficiency from 1 to 5 (default 3)>")
+            fmt.Println("\t\tReplaces all the user's skills; -skills \"\" removes them")
+            fmt.Println("\t-work_history <JSON list of jobs held, each with title, employer, start_date and end_date>")
+            fmt.Println("\t\tReplaces the user's work history; -work_history \"\" removes it")
+            fmt.Println("Email is required. Give only the values you want to change")
+            fmt.Print("A new password ends all REST API sessions for the user\n\n")
+            fmt.Print("Example: ./job_wizard -task update_profile -email sally@gmail.com -phone 0987650000\n\n")
+            break
+        case "delete_account":
+            fmt.Println("Delete a user's account")
+            fmt.Println("The user's applications and messages are deleted too. Jobs the user")
+            fmt.Println("created for an organization pass to one of its owners; other jobs")
+            fmt.Println("are closed, but kept so applicants can still see them")
+            fmt.Println("The only owner of an organization must make another member an owner first")
+            fmt.Println("Arguments for delete_account task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task delete_account -email sally@gmail.com\n\n")
+            break
+        case "archive_job":
+            fmt.Println("Archive one of my jobs. The job is closed and no longer appears in")
+            fmt.Println("searches, but applicants can still see it")
+            fmt.Println("Arguments for archive_job task:")
+            fmt.Println("\t-creator <email of job creator>")
+            fmt.Println("\t-job_id <job to archive>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task archive_job -creator sally@gmail.com -job_id 00003\n\n")
+            break
+        case "delete_job":
+            fmt.Println("Delete one of my jobs completely")
+            fmt.Println("Only jobs that nobody has applied for can be deleted; archive the others")
+            fmt.Println("Arguments for delete_job task:")
+            fmt.Println("\t-creator <email of job creator>")
+            fmt.Println("\t-job_id <job to delete>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task delete_job -creator sally@gmail.com -job_id 00003\n\n")
+            break
+        case "create_org":
+            fmt.Println("Create an employer organization. The creator becomes its owner")
+            fmt.Println("Arguments for create_org task:")
+            fmt.Println("\t-creator <email of registered user>")
+            fmt.Println("\t-name <organization name in quotes, must be unique>")
+            fmt.Println("\t-description <description in quotes, up to 1024 chars>")
+            fmt.Println("\t-website <http or https URL>")
+            fmt.Println("\t-logo_url <http or https URL of a logo image>")
+            fmt.Println("\t-location <location in quotes>")
+            fmt.Println("Creator and name are required")
+            fmt.Print("Every member can post jobs for the organization and manage all its jobs\n\n")
+            fmt.Print("Example: ./job_wizard -task create_org -creator sally@gmail.com -name \"CMKL University\" -location Bangkok\n\n")
+            break
+        case "org":
+            fmt.Println("Show the profile and members of an organization")
+            fmt.Println("Arguments for org task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-org_id <show what organization>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task org -email sally@gmail.com -org_id 00001\n\n")
+            break
+        case "update_org":
+            fmt.Println("Change the profile of an organization I own")
+            fmt.Println("Arguments for update_org task:")
+            fmt.Println("\t-creator <email of an owner>")
+            fmt.Println("\t-org_id <change what organization>")
+            fmt.Println("\t-name <new name in quotes>")
+            fmt.Println("\t-description <new description in quotes>")
+            fmt.Println("\t-website <new http or https URL>")
+            fmt.Println("\t-logo_url <new http or https URL of a logo image>")
+            fmt.Println("\t-location <new location in quotes>")
+            fmt.Print("Creator and org_id are required. Give only the values you want to change\n\n")
+            fmt.Print("Example: ./job_wizard -task update_org -creator sally@gmail.com -org_id 00001 -website https://www.cmkl.ac.th\n\n")
+            break
+        case "orgs":
+            fmt.Println("List organizations, in order of name")
+            fmt.Println("Arguments for orgs task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-keyword <part of the organization name>")
+            fmt.Println("\t-mine=true <only organizations I belong to>")
+            fmt.Println("\t-limit <maximum results to return, default 50, at most 500>")
+            fmt.Println("\t-offset <number of results to skip>")
+            fmt.Println("\t-sort <name (default), created or org_id>")
+            fmt.Println("\t-order <asc or desc>")
+            fmt.Print("Only email is required\n\n")
+            fmt.Print("Example: ./job_wizard -task orgs -email sally@gmail.com -mine=true\n\n")
+            break
+        case "add_member":
+            fmt.Println("Add a registered user to an organization I own, or change a member's role")
+            fmt.Println("Arguments for add_member task:")
+            fmt.Println("\t-creator <email of an owner>")
+            fmt.Println("\t-org_id <organization>")
+            fmt.Println("\t-email <email of the member>")
+            fmt.Println("\t-role <owner or recruiter>")
+            fmt.Println("All arguments are required")
+            fmt.Print("The last owner cannot become a recruiter\n\n")
+            fmt.Print("Example: ./job_wizard -task add_member -creator sally@gmail.com -org_id 00001 -email jim@gmail.com -role recruiter\n\n")
+            break
+        case "remove_member":
+            fmt.Println("Remove a member from an organization. Owners can remove anybody,")
+            fmt.Println("and any member can leave by giving their own email as both creator and email")
+            fmt.Println("Arguments for remove_member task:")
+            fmt.Println("\t-creator <email of the user making the change>")
+            fmt.Println("\t-org_id <organization>")
+            fmt.Println("\t-email <email of the member>")
+            fmt.Println("All arguments are required")
+            fmt.Print("The last owner cannot be removed. Jobs the member posted stay with the organization\n\n")
+            fmt.Print("Example: ./job_wizard -task remove_member -creator sally@gmail.com -org_id 00001 -email jim@gmail.com\n\n")
+            break
+        case "skills":
+            fmt.Println("List the skills that jobs and users have given, in order of name")
+            fmt.Println("Arguments for skills task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-keyword <part of the skill name>")
+            fmt.Println("\t-limit <maximum results to return, default 50, at most 500>")
+            fmt.Println("\t-offset <number of results to skip>")
+            fmt.Println("\t-order <asc or desc>")
+            fmt.Print("Only email is required\n\n")
+            fmt.Print("Example: ./job_wizard -task skills -email sally@gmail.com -keyword java\n\n")
+            break
+        case "set_resume":
+            fmt.Println("Set the default resume sent with applications that are made without one")
+            fmt.Println("Arguments for set_resume task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Print("\t-resume <path of a PDF or DOCX file, at most 5 MB, or \"\" to remove the default>\n\n")
+            fmt.Println("Both arguments are required. Applications already sent keep the resume they were sent with")
+            fmt.Print("The profile task shows the name, type and size of the default resume\n\n")
+            fmt.Print("Example: ./job_wizard -task set_resume -email sally@gmail.com -resume cv.pdf\n\n")
+            break
+        case "save_search":
+            fmt.Println("Save search criteria under a name, so the search can be run again")
+            fmt.Println("Arguments for save_search task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-name <name of the search in quotes, 64 chars max>")
+            fmt.Println("\tAny of the criteria of the search task, such as -keyword, -salary,")
+            fmt.Println("\t-min_education, -min_experience, -posted_from or -skills")
+            fmt.Println("Email and name are required. Each user can save 20 searches, with different names")
+            fmt.Print("Jobs posted after the search is saved are counted as its new matches\n\n")
+            fmt.Print("Example: ./job_wizard -task save_search -email sally@gmail.com -name \"Go jobs\" -keyword golang -salary 30000\n\n")
+            break
+        case "saved_searches":
+            fmt.Println("List my saved searches, in order of name, with their criteria and the")
+            fmt.Println("number of new matches: jobs posted since each search was last run")
+            fmt.Println("Arguments for saved_searches task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-limit <maximum results to return, default 50, at most 500>")
+            fmt.Println("\t-offset <number of results to skip>")
+            fmt.Println("\t-sort <name (default) or created>")
+            fmt.Println("\t-order <asc or desc>")
+            fmt.Print("Only email is required\n\n")
+            fmt.Print("Example: ./job_wizard -task saved_searches -email sally@gmail.com\n\n")
+            break
+        case "run_search":
+            fmt.Println("Run one of my saved searches and print summaries, as the search task does")
+            fmt.Println("The search is marked as viewed, so it has no new matches until more jobs are posted")
+            fmt.Println("Arguments for run_search task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-search_id <run what saved search>")
+            fmt.Println("\t-limit <maximum results to return, default 50, at most 500>")
+            fmt.Println("\t-offset <number of results to skip>")
+            fmt.Println("\t-sort <relevance (default with keyword), posted (default), title, salary, match or job_id>")
+            fmt.Println("\t-order <asc or desc>")
+            fmt.Print("Email and search_id are required\n\n")
+            fmt.Print("Example: ./job_wizard -task run_search -email sally@gmail.com -search_id 00001\n\n")
+            break
+        case "delete_search":
+            fmt.Println("Delete one of my saved searches")
+            fmt.Println("Arguments for delete_search task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-search_id <delete what saved search>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task delete_search -email sally@gmail.com -search_id 00001\n\n")
+            break
+        case "search_alerts":
+            fmt.Println("List my saved searches that have new matches, with the total number of new matches")
+            fmt.Println("Arguments for search_alerts task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task search_alerts -email sally@gmail.com\n\n")
+            break
+        case "bookmark":
+            fmt.Println("Bookmark a job, to find it again without searching")
+            fmt.Println("Searches made for me say which jobs I have bookmarked")
+            fmt.Println("Arguments for bookmark task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-job_id <bookmark what job>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task bookmark -email sally@gmail.com -job_id 00003\n\n")
+            break
+        case "unbookmark":
+            fmt.Println("Remove one of my bookmarks")
+            fmt.Println("Arguments for unbookmark task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-job_id <remove the bookmark of what job>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task unbookmark -email sally@gmail.com -job_id 00003\n\n")
+            break
+        case "bookmarks":
+            fmt.Println("List the jobs I have bookmarked, most recently bookmarked first")
+            fmt.Println("Jobs that have been filled since are still listed, with the time they were filled")
+            fmt.Println("Arguments for bookmarks task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-limit <maximum results to return, default 50, at most 500>")
+            fmt.Println("\t-offset <number of results to skip>")
+            fmt.Println("\t-sort <bookmarked (default), posted, title, salary or job_id>")
+            fmt.Println("\t-order <asc or desc>")
+            fmt.Print("Only email is required\n\n")
+            fmt.Print("Example: ./job_wizard -task bookmarks -email sally@gmail.com\n\n")
+            break
+        case "add_webhook":
+            fmt.Println("Register a URL to be sent events on the jobs I created, or on the")
+            fmt.Println("jobs of an organization I own. Each event is POSTed as JSON, signed")
+            fmt.Println("with the secret that is returned, which is not shown again")
+            fmt.Println("Arguments for add_webhook task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-url <http or https URL to send events to>")
+            fmt.Println("\t-events <comma-separated events: job.created, job.modified, job.filled,")
+            fmt.Println("\t         application.submitted and application.status_changed>")
+            fmt.Println("\t-org_id <organization I own, for the events on its jobs>")
+            fmt.Println("Email, url and events are required. Each user can have 10 webhooks")
+            fmt.Print("Deliveries are sent by the server, or by the deliver_webhooks task\n\n")
+            fmt.Print("Example: ./job_wizard -task add_webhook -email sally@gmail.com -url https://example.com/hook -events job.created,application.submitted\n\n")
+            break
+        case "webhooks":
+            fmt.Println("List my webhooks, oldest first, without their secrets")
+            fmt.Println("Arguments for webhooks task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-limit <maximum results to return, default 50, at most 500>")
+            fmt.Println("\t-offset <number of results to skip>")
+            fmt.Println("\t-sort <created (default) or url>")
+            fmt.Println("\t-order <asc or desc>")
+            fmt.Print("Only email is required\n\n")
+            fmt.Print("Example: ./job_wizard -task webhooks -email sally@gmail.com\n\n")
+            break
+        case "delete_webhook":
+            fmt.Println("Delete one of my webhooks, and its deliveries")
+            fmt.Println("Arguments for delete_webhook task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-webhook_id <delete what webhook>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task delete_webhook -email sally@gmail.com -webhook_id 00001\n\n")
+            break
+        case "webhook_deliveries":
+            fmt.Println("List the deliveries of one of my webhooks, most recent first, with")
+            fmt.Println("their status (pending, delivered or failed), attempts and last error")
+            fmt.Println("Arguments for webhook_deliveries task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-webhook_id <list the deliveries of what webhook>")
+            fmt.Println("\t-limit <maximum results to return, default 50, at most 500>")
+            fmt.Println("\t-offset <number of results to skip>")
+            fmt.Println("\t-sort <created (default) or status>")
+            fmt.Println("\t-order <asc or desc>")
+            fmt.Print("Email and webhook_id are required\n\n")
+            fmt.Print("Example: ./job_wizard -task webhook_deliveries -email sally@gmail.com -webhook_id 00001\n\n")
+            break
+        case "deliver_webhooks":
+            fmt.Println("Send the webhook deliveries that are due now, as the server does")
+            fmt.Println("every 30 seconds. A failed delivery is retried after 1 minute, then")
+            fmt.Println("2, 4 and so on, and gives up after 8 attempts")
+            fmt.Print("There are no arguments\n\n")
+            fmt.Print("Example: ./job_wizard -task deliver_webhooks\n\n")
+            break
+        case "notifications":
+            fmt.Println("See my notification preferences: the language of my emails, and whether")
+            fmt.Println("I am sent an email when someone applies for my job, when I am hired and")
+            fmt.Println("when my application is rejected")
+            fmt.Println("Arguments for notifications task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task notifications -email sally@gmail.com\n\n")
+            break
+        case "set_notifications":
+            fmt.Println("Change my notification preferences. Until they are changed, every")
+            fmt.Println("email is sent, in English")
+            fmt.Println("Arguments for set_notifications task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Println("\t-language <en or th>")
+            fmt.Println("\t-notify_received=<true or false, for applications for my jobs>")
+            fmt.Println("\t-notify_hired=<true or false, for being hired>")
+            fmt.Println("\t-notify_rejected=<true or false, for my applications being rejected>")
+            fmt.Print("Email and at least one change are required\n\n")
+            fmt.Print("Example: ./job_wizard -task set_notifications -email sally@gmail.com -language th -notify_rejected=false\n\n")
+            break
+        case "deliver_notifications":
+            fmt.Println("Send the notification emails that are due now, as the server does every")
+            fmt.Println("60 seconds. A failed email is retried the same way as a webhook delivery")
+            fmt.Println("Emails go through the SMTP server set by JOBWIZARD_MAIL_SENDER=smtp and")
+            fmt.Println("JOBWIZARD_SMTP_HOST, or to JOBWIZARD_MAIL_FILE or the log for development")
+            fmt.Print("There are no arguments\n\n")
+            fmt.Print("Example: ./job_wizard -task deliver_notifications\n\n")
+            break
+        case "send_message":
+            fmt.Println("Send a message in the thread of a job application. Only the applicant")
+            fmt.Println("and the creator of the job can send to the thread or read it")
+            fmt.Println("Arguments for send_message task:")
+            fmt.Println("\t-email <email of the applicant or the job's creator>")
+            fmt.Println("\t-job_id <the job applied for>")
+            fmt.Println("\t-applicant <email of the applicant, if I created the job>")
+            fmt.Println("\t-body <text of the message, in quotes - 2000 chars max>")
+            fmt.Print("Email, job_id and body are required\n\n")
+            fmt.Print("Example: ./job_wizard -task send_message -email sally@gmail.com -job_id 00003 -applicant john@gmail.com -body \"Can you come in on Monday?\"\n\n")
+            break
+        case "messages":
+            fmt.Println("List the messages in the thread of a job application, oldest first")
+            fmt.Println("Listing them does not mark them read")
+            fmt.Println("Arguments for messages task:")
+            fmt.Println("\t-email <email of the applicant or the job's creator>")
+            fmt.Println("\t-job_id <the job applied for>")
+            fmt.Println("\t-applicant <email of the applicant, if I created the job>")
+            fmt.Println("\t-limit <maximum results to return, default 50, at most 500>")
+            fmt.Println("\t-offset <number of results to skip>")
+            fmt.Println("\t-sort <sent (default)>")
+            fmt.Println("\t-order <asc or desc>")
+            fmt.Print("Email and job_id are required\n\n")
+            fmt.Print("Example: ./job_wizard -task messages -email john@gmail.com -job_id 00003\n\n")
+            break
+        case "read_messages":
+            fmt.Println("Mark the messages sent to me in the thread of a job application as read")
+            fmt.Println("Arguments for read_messages task:")
+            fmt.Println("\t-email <email of the applicant or the job's creator>")
+            fmt.Println("\t-job_id <the job applied for>")
+            fmt.Println("\t-applicant <email of the applicant, if I created the job>")
+            fmt.Print("Email and job_id are required\n\n")
+            fmt.Print("Example: ./job_wizard -task read_messages -email john@gmail.com -job_id 00003\n\n")
+            break
+        case "unread_messages":
+            fmt.Println("Count the messages sent to me that I have not read, in total and for")
+            fmt.Println("each thread, the thread with the newest message first")
+            fmt.Println("Arguments for unread_messages task:")
+            fmt.Println("\t-email <email of registered user>")
+            fmt.Print("All arguments are required\n\n")
+            fmt.Print("Example: ./job_wizard -task unread_messages -email john@gmail.com\n\n")
+            break
         default:
-            fmt.Println("Invalid task specified\n")                     
+            fmt.Print("Invalid task specified\n\n")                     
     }
     os.Exit(0)
 }
@@ -209,150 +807,649 @@ func setupAPI() {
     }
     godotenv.Load(".env_jobwizard")
     e := echo.New()
+    e.HTTPErrorHandler = api.HTTPErrorHandler
     //e.Use(middleware.Logger())
     e.Use(middleware.Recover())    
+    // leave room for a resume and the other form fields
+    e.Use(middleware.BodyLimit("8M"))
 
     e.Use(middleware.CORS())
     middlewares.InitCorsMiddleware(e)
     _privateAPI := e.Group("/api")
     _ = _privateAPI
     api.ApplicationPrivateRoute(_privateAPI)
+    // open the database and bring its schema up to date before anything
+    // else can use it
+    if !dbaccess.GetStore().CheckConnection() {
+        fmt.Println("Connection to DB failed")
+        os.Exit(1)
+    }
+    startExpirySweeper()
+    startWebhookSender()
+    startNotificationSender()
     e.Logger.Fatal(e.Start(":" + os.Getenv("JOBWIZARD_API_PORT")))
 }
 
+// Close expired jobs now, and then every JOBWIZARD_SWEEP_MINUTES
+// minutes for as long as the server runs
+func startExpirySweeper() {
+    minutes, err := strconv.Atoi(os.Getenv("JOBWIZARD_SWEEP_MINUTES"))
+    if err != nil || minutes <= 0 {
+        minutes = defaultSweepMinutes
+    }
+    go func() {
+        for {
+            closed, err := dbaccess.GetStore().CloseExpiredJobs()
+            if err != nil {
+                fmt.Printf("Error closing expired jobs: %v\n", err)
+            } else if closed > 0 {
+                fmt.Printf("Closed %d expired jobs\n", closed)
+            }
+            time.Sleep(time.Duration(minutes) * time.Minute)
+        }
+    }()
+}
+
+// Send the webhook deliveries that are due every JOBWIZARD_WEBHOOK_SECONDS
+// seconds for as long as the server runs
+func startWebhookSender() {
+    seconds, err := strconv.Atoi(os.Getenv("JOBWIZARD_WEBHOOK_SECONDS"))
+    if err != nil || seconds <= 0 {
+        seconds = defaultWebhookSeconds
+    }
+    go func() {
+        for {
+            _, failed, err := webhook.DeliverDue(dbaccess.GetStore())
+            if err != nil {
+                fmt.Printf("Error sending webhook deliveries: %v\n", err)
+            } else if failed > 0 {
+                fmt.Printf("%d webhook deliveries could not be sent\n", failed)
+            }
+            time.Sleep(time.Duration(seconds) * time.Second)
+        }
+    }()
+}
+
+// Send the notification emails that are due every JOBWIZARD_MAIL_SECONDS
+// seconds for as long as the server runs
+func startNotificationSender() {
+    seconds, err := strconv.Atoi(os.Getenv("JOBWIZARD_MAIL_SECONDS"))
+    if err != nil || seconds <= 0 {
+        seconds = defaultMailSeconds
+    }
+    sender := notify.SenderFromEnv()
+    go func() {
+        for {
+            _, failed, err := notify.DeliverDue(dbaccess.GetStore(), sender)
+            if err != nil {
+                fmt.Printf("Error sending notifications: %v\n", err)
+            } else if failed > 0 {
+                fmt.Printf("%d notifications could not be sent\n", failed)
+            }
+            time.Sleep(time.Duration(seconds) * time.Second)
+        }
+    }()
+}
+
+// Switch to an in-memory store holding the sample data
+// Nothing done in demo mode is saved
+func startDemo() {
+    store := dbaccess.NewMemoryStore()
+    err := dbaccess.LoadDemoData(store)
+    if err != nil {
+        jsonErrorOutput(err)
+        os.Exit(1)
+    }
+    dbaccess.SetStore(store)
+    dbaccess.SetBlobStore(dbaccess.NewMemoryBlobStore())
+}
+
 func commandLineFunction() {
-    dbOk := dbaccess.CheckConnection()
+    dbOk := dbaccess.GetStore().CheckConnection()
 
     if !dbOk {
-        fmt.Println("Connection to DB failed")
+        jsonErrorOutput(fmt.Errorf("Connection to DB failed"))
         os.Exit(1)
     }
-    valid, msg := helper.ValidateTaskArgs(task,&user,&job,&filter,&submission)
+    setJobChanges()
+    setNotificationChanges()
+    task_name := helper.TaskName(task)
+    err := setSkills(task_name)
+    if err == nil {
+        err = setUserChanges(task_name)
+    }
+    if err == nil {
+        err = setResume(task_name)
+    }
+    if events != "" {
+        hook.Events = strings.Split(events, ",")
+    }
+    if err != nil {
+        jsonErrorOutput(err)
+        os.Exit(1)
+    }
+    valid, err := helper.ValidateTaskArgs(task,&user,&job,&filter,&submission,&change,&job_changes,&org,&member,&saved,&hook,&notification_changes,&message,&page)
     if !valid {
-        jsonErrorOutput(msg)
+        jsonErrorOutput(err)
         os.Exit(1)
     }
 
-    task_index := helper.FindTask(task)  // we have already validated the task above
-    jsonResponse := dispatch(task_index)
+    jsonResponse := dispatch(task_name)
     fmt.Println(jsonResponse)
 }
+// Fill in job_changes for the modify task from the job flags that were
+// given on the command line, so that a flag given as 0, "" or false is a
+// change rather than being ignored
+func setJobChanges() {
+    flag.Visit(func(f *flag.Flag) {
+        switch f.Name {
+            case "title":
+                job_changes.Title = &job.Title
+            case "description":
+                job_changes.Description = &job.Description
+            case "min_education":
+                job_changes.Min_education = &job.Min_education
+            case "min_experience":
+                job_changes.Min_experience = &job.Min_experience
+            case "salary":
+                job_changes.Salary = &job.Salary
+            case "is_open":
+                job_changes.Is_open = &job.Is_open
+            case "expires_on":
+                job_changes.Expires_on = &job.Expires_on
+            case "province":
+                job_changes.Province = &job.Province
+            case "city":
+                job_changes.City = &job.City
+            case "latitude":
+                job_changes.Latitude = &job.Latitude
+            case "longitude":
+                job_changes.Longitude = &job.Longitude
+            case "work_arrangement":
+                job_changes.Work_arrangement = &job.Work_arrangement
+            case "employment_type":
+                job_changes.Employment_type = &job.Employment_type
+        }
+    })
+}
+
+// Fill in notification_changes for the set_notifications task from the
+// flags that were given, as for setJobChanges
+func setNotificationChanges() {
+    flag.Visit(func(f *flag.Flag) {
+        switch f.Name {
+            case "language":
+                notification_changes.Language = &language
+            case "notify_received":
+                notification_changes.Application_received = &notify_received
+            case "notify_hired":
+                notification_changes.Hired = &notify_hired
+            case "notify_rejected":
+                notification_changes.Rejected = &notify_rejected
+        }
+    })
+}
+
+// Fill in the skills for the task from the -skills and -nice_to_have
+// flags, if they were given. For users each skill can have a
+// proficiency, as in "go:4,sql"
+func setSkills(task_name string) (err error) {
+    given := false
+    flag.Visit(func(f *flag.Flag) {
+        if f.Name == "skills" || f.Name == "nice_to_have" {
+            given = true
+        }
+    })
+    if !given {
+        return nil
+    }
+    switch(task_name) {
+        case "register", "update_profile": // register, update profile
+            user.Skills, err = helper.ParseUserSkills(skills)
+        case "create":
+            job.Skills = helper.ParseJobSkills(skills, nice_to_have)
+        case "search", "save_search": // search, save a search
+            filter.Skills = helper.SplitSkills(skills)
+        case "modify":
+            job_skills := helper.ParseJobSkills(skills, nice_to_have)
+            job_changes.Skills = &job_skills
+    }
+    return err
+}
+
+// Fill in the education, experience and work history for the register
+// and update_profile tasks, if they were given on the command line, so
+// that -education 0 or -experience 0 is a change
+func setUserChanges(task_name string) (err error) {
+    if task_name != "register" && task_name != "update_profile" {
+        return nil
+    }
+    flag.Visit(func(f *flag.Flag) {
+        switch f.Name {
+            case "education":
+                user.Education = &education
+            case "experience":
+                user.Experience = &experience
+            case "work_history":
+                user.Work_history, err = helper.ParseWorkHistory(work_history)
+        }
+    })
+    return err
+}
+
+// Read the file named by -resume for the submit and set_resume tasks
+// For set_resume, -resume "" removes the default resume, so the flag
+// must be given
+func setResume(task_name string) (err error) {
+    if task_name != "submit" && task_name != "set_resume" {
+        return nil
+    }
+    given := false
+    flag.Visit(func(f *flag.Flag) {
+        if f.Name == "resume" {
+            given = true
+        }
+    })
+    if task_name == "set_resume" && !given {
+        return data.ValidationError("resume", "Give -resume <file>, or -resume \"\" to remove the default resume")
+    }
+    if resume_file == "" {
+        return nil
+    }
+    content, err := os.ReadFile(resume_file)
+    if err != nil {
+        return data.ValidationError("resume", "Cannot read resume file " + resume_file)
+    }
+    submission.Resume = &data.Document{File_name: resume_file, Content: content}
+    return nil
+}
+
 // Figure out what db service/function to call to handle the task
 // We assume that dispatch() knows which structure holds the appropriate arguments
 // for the relevant task
 // This is used only in the command line version
-func dispatch(task_index int) (jsonResponse string) {
+func dispatch(task_name string) (jsonResponse string) {
     var err error
-    switch(task_index) {
-        case 0:
-            err = dbaccess.RegisterUser(user.Email,user.First,user.Last,user.Phone,user.Education)
+    store := dbaccess.GetStore()
+    switch(task_name) {
+        case "register":
+            err = store.RegisterUser(user.Email,user.First,user.Last,user.Phone,*user.Education,user.Password,
+                                     user.Experience,user.Skills,user.Work_history)
             if err != nil {
-                jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
+                jsonResponse = jsonError(err)
             } else {
                 jsonResponse = fmt.Sprintf("{ \"success\" : \"Registered user %s\"}\n",user.Email)  
             }
             break
-        case 1:
-            job_id, err := dbaccess.CreateJob(job.Creator,job.Title,job.Description,job.Min_education,
-                             job.Min_experience,job.Salary)
+        case "create":
+            job_id, err := store.CreateJob(job)
             if err != nil {
-                jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
+                jsonResponse = jsonError(err)
             } else {
                 jsonResponse = fmt.Sprintf("{ \"job_id\" : \"%s\" }\n",job_id)  
             }
-        case 2:
-            summaries, err := dbaccess.SearchJobs(filter.Posted, filter.Experience, filter.Education, filter.Salary, filter.Keyword) 
+        case "search":
+            summaries, total, err := store.SearchJobs(filter, page) 
             if err != nil {
-                jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
-            } else if len(summaries) == 0 {
-                jsonResponse = "{ \"warning\" : \"No matching jobs found\"}"
+                jsonResponse = jsonError(err)
             } else {
-                resp, err := json.Marshal(summaries)
-                if err != nil {
-                    jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
-                } else {
-                    jsonResponse = string(resp)
-                } 
+                jsonResponse = pageResponse(summaries, len(summaries), total, "No matching jobs found")
             }
-        case 3:
-            return_job, err := dbaccess.GetJobDetail(job.Job_id)
+        case "detail":
+            return_job, err := store.GetJobDetail(job.Job_id)
             if err != nil {
-                jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
+                jsonResponse = jsonError(err)
             } else {
                 resp, err := json.Marshal(return_job)
                 if err != nil {
-                    jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
+                    jsonResponse = jsonError(err)
                 } else {
                     jsonResponse = string(resp)
                 } 
             }
-        case 4:
-            summaries, err := dbaccess.SearchOfferedJobs(job.Creator) 
+        case "offered":
+            summaries, total, err := store.SearchOfferedJobs(job.Creator, page) 
             if err != nil {
-                jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
-            } else if len(summaries) == 0 {
-                jsonResponse = "{ \"warning\" : \"No matching jobs found\"}"
+                jsonResponse = jsonError(err)
             } else {
-                resp, err := json.Marshal(summaries)
-                if err != nil {
-                    jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
-                } else {
-                    jsonResponse = string(resp)
-                } 
+                jsonResponse = pageResponse(summaries, len(summaries), total, "No matching jobs found")
             }
-       case 5:
-            summaries, err := dbaccess.SearchAppliedJobs(job.Creator) 
+       case "applied":
+            applications, total, err := store.SearchAppliedJobs(job.Creator, page) 
             if err != nil {
-                jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
-            } else if len(summaries) == 0 {
-                jsonResponse = "{ \"warning\" : \"No matching jobs found\"}"
+                jsonResponse = jsonError(err)
             } else {
-                resp, err := json.Marshal(summaries)
-                if err != nil {
-                    jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
-                } else {
-                    jsonResponse = string(resp)
-                } 
+                jsonResponse = pageResponse(applications, len(applications), total, "No matching jobs found")
             } 
-        case 6: // modify job
-            job_id, err := dbaccess.ModifyJob(job.Creator,job.Job_id,job.Title,job.Description,job.Min_education,
-                             job.Min_experience,job.Salary,job.Is_open)
+        case "modify": // modify job
+            job_id, err := store.ModifyJob(job_changes)
             if err != nil {
-                jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
+                jsonResponse = jsonError(err)
             } else {
                 jsonResponse = fmt.Sprintf("{ \"modified_job_id\" : \"%s\" }\n",job_id)
             }
-        case 7: // submit application for job
-            job_id, err := dbaccess.SubmitJobApplication(submission.Email,submission.Job_id)                       
-            if err != nil && job_id == "" {
-                jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
-            } else if err != nil {
-                jsonResponse = fmt.Sprintf("{ \"warning\" : \"%v\" }\n",err)
+        case "submit": // submit application for job
+            job_id, unmet, err := store.SubmitJobApplication(submission.Email,submission.Job_id,submission.Cover_letter,submission.Resume)                       
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else if len(unmet) > 0 {
+                resp, _ := json.Marshal(map[string]interface{}{"applied_job_id": job_id, "warning": data.UnmetWarning,
+                                                               "unmet_requirements": unmet})
+                jsonResponse = string(resp)
             } else {
                 jsonResponse = fmt.Sprintf("{ \"applied_job_id\" : \"%s\" }\n",job_id)
             }       
-        case 8: // candidates
-            candidates, err := dbaccess.SearchCandidates(job.Creator,job.Job_id) 
+        case "candidates":
+            candidates, total, err := store.SearchCandidates(job.Creator,job.Job_id,qualified,page) 
             if err != nil {
-                jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
-            } else if len(candidates) == 0 {
-                jsonResponse = "{ \"warning\" : \"No candidates found\"}"
+                jsonResponse = jsonError(err)
             } else {
-                resp, err := json.Marshal(candidates)
-                if err != nil {
-                    jsonResponse = fmt.Sprintf("{ \"error\" : \"%v\" }\n",err)
-                } else {
-                    jsonResponse = string(resp)
-                } 
-            }                  
+                jsonResponse = pageResponse(candidates, len(candidates), total, "No candidates found")
+            }
+        case "status", "hire": // status change or hire
+            err = store.UpdateApplicationStatus(change.Creator,change.Job_id,change.Applicant,change.Status)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"job_id\" : \"%s\", \"email\" : \"%s\", \"status\" : \"%s\" }\n",
+                                           change.Job_id,change.Applicant,change.Status)
+            }
+        case "withdraw": // withdraw application
+            job_id, err := store.WithdrawApplication(submission.Email,submission.Job_id)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"withdrawn_job_id\" : \"%s\" }\n",job_id)
+            }
+        case "migrate":
+            if demo {
+                jsonResponse = jsonError(data.BadRequestError("There is no database to migrate in demo mode"))
+                break
+            }
+            from, to, err := dbaccess.Migrate(schema_version)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"previous_version\" : %d, \"schema_version\" : %d }\n",from,to)
+            }
+        case "profile":
+            profile, err := store.GetUserProfile(user.Email)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                resp, _ := json.Marshal(profile)
+                jsonResponse = string(resp)
+            }
+        case "update_profile": // update profile
+            err = store.UpdateUserProfile(user.Email,user.First,user.Last,user.Phone,user.Education,user.Password,
+                                          user.Experience,user.Skills,user.Work_history)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"updated_user\" : \"%s\" }\n",user.Email)
+            }
+        case "delete_account": // delete account
+            err = store.DeleteUser(user.Email)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"deleted_user\" : \"%s\" }\n",user.Email)
+            }
+        case "archive_job": // archive job
+            err = store.ArchiveJob(job.Creator,job.Job_id)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"archived_job_id\" : \"%s\" }\n",job.Job_id)
+            }
+        case "delete_job": // delete job
+            err = store.DeleteJob(job.Creator,job.Job_id)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"deleted_job_id\" : \"%s\" }\n",job.Job_id)
+            }
+        case "create_org": // create organization
+            org_id, err := store.CreateOrganization(org)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"org_id\" : \"%s\" }\n",org_id)
+            }
+        case "org": // organization profile
+            found, err := store.GetOrganization(org.Org_id)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                resp, _ := json.Marshal(found)
+                jsonResponse = string(resp)
+            }
+        case "update_org": // update organization
+            err = store.UpdateOrganization(org)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"updated_org_id\" : \"%s\" }\n",org.Org_id)
+            }
+        case "orgs": // list organizations
+            member_email := ""
+            if mine {
+                member_email = user.Email
+            }
+            orgs, total, err := store.SearchOrganizations(filter.Keyword, member_email, page)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = pageResponse(orgs, len(orgs), total, "No matching organizations found")
+            }
+        case "add_member": // add member or change role
+            err = store.SetOrganizationMember(member)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"org_id\" : \"%s\", \"email\" : \"%s\", \"role\" : \"%s\" }\n",
+                                           member.Org_id,member.Member,member.Role)
+            }
+        case "remove_member": // remove member
+            err = store.RemoveOrganizationMember(member)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"org_id\" : \"%s\", \"removed\" : \"%s\" }\n",member.Org_id,member.Member)
+            }
+        case "skills": // list skills
+            found, total, err := store.ListSkills(filter.Keyword, page)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = pageResponse(found, len(found), total, "No matching skills found")
+            }
+        case "set_resume": // set or remove the default resume
+            err = store.SetUserResume(user.Email, submission.Resume)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else if submission.Resume == nil {
+                jsonResponse = fmt.Sprintf("{ \"removed_resume\" : \"%s\" }\n",user.Email)
+            } else {
+                profile, _ := store.GetUserProfile(user.Email)
+                resp, _ := json.Marshal(map[string]interface{}{"updated": user.Email, "resume": profile.Resume})
+                jsonResponse = string(resp)
+            }
+        case "save_search": // save a search
+            search_id, err := store.CreateSavedSearch(saved)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"search_id\" : \"%s\" }\n",search_id)
+            }
+        case "saved_searches": // list saved searches
+            searches, total, err := store.ListSavedSearches(user.Email, page)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = pageResponse(searches, len(searches), total, "No saved searches found")
+            }
+        case "run_search": // run a saved search
+            summaries, total, err := store.RunSavedSearch(saved.User_email, saved.Search_id, page)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = pageResponse(summaries, len(summaries), total, "No matching jobs found")
+            }
+        case "delete_search": // delete a saved search
+            err = store.DeleteSavedSearch(saved.User_email, saved.Search_id)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"deleted_search_id\" : \"%s\" }\n",saved.Search_id)
+            }
+        case "search_alerts": // new matches of saved searches
+            alerts, err := store.GetSearchAlerts(user.Email)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                resp, _ := json.Marshal(alerts)
+                jsonResponse = string(resp)
+            }
+        case "bookmark": // bookmark a job
+            err = store.AddBookmark(user.Email, job.Job_id)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"bookmarked_job_id\" : \"%s\" }\n",job.Job_id)
+            }
+        case "unbookmark": // remove a bookmark
+            err = store.RemoveBookmark(user.Email, job.Job_id)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"unbookmarked_job_id\" : \"%s\" }\n",job.Job_id)
+            }
+        case "bookmarks": // list bookmarks
+            bookmarks, total, err := store.ListBookmarks(user.Email, page)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = pageResponse(bookmarks, len(bookmarks), total, "No bookmarked jobs")
+            }
+        case "add_webhook": // register a webhook
+            created, err := store.CreateWebhook(hook)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                resp, _ := json.Marshal(created)
+                jsonResponse = string(resp)
+            }
+        case "webhooks": // list webhooks
+            hooks, total, err := store.ListWebhooks(user.Email, page)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = pageResponse(hooks, len(hooks), total, "No webhooks found")
+            }
+        case "delete_webhook": // delete a webhook
+            err = store.DeleteWebhook(hook.Creator, hook.Webhook_id)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"deleted_webhook_id\" : \"%s\" }\n",hook.Webhook_id)
+            }
+        case "webhook_deliveries": // list the deliveries of a webhook
+            deliveries, total, err := store.ListWebhookDeliveries(hook.Creator, hook.Webhook_id, page)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = pageResponse(deliveries, len(deliveries), total, "No deliveries found")
+            }
+        case "deliver_webhooks": // send the deliveries that are due
+            delivered, failed, err := webhook.DeliverDue(store)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"delivered\" : %d, \"not_delivered\" : %d }\n",delivered,failed)
+            }
+        case "notifications": // see notification preferences
+            prefs, err := store.GetNotificationPreferences(user.Email)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                resp, _ := json.Marshal(prefs)
+                jsonResponse = string(resp)
+            }
+        case "set_notifications": // change notification preferences
+            prefs, err := store.SetNotificationPreferences(notification_changes)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                resp, _ := json.Marshal(prefs)
+                jsonResponse = string(resp)
+            }
+        case "deliver_notifications": // send the notifications that are due
+            sent, failed, err := notify.DeliverDue(store, notify.SenderFromEnv())
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"sent\" : %d, \"not_sent\" : %d }\n",sent,failed)
+            }
+        case "send_message": // send a message
+            sent, err := store.SendMessage(message)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                resp, _ := json.Marshal(sent)
+                jsonResponse = string(resp)
+            }
+        case "messages": // list the messages in a thread
+            messages, total, err := store.ListMessages(message.Sender, message.Job_id, message.Applicant, page)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = pageResponse(messages, len(messages), total, "No messages found")
+            }
+        case "read_messages": // mark the messages in a thread read
+            marked, err := store.MarkMessagesRead(message.Sender, message.Job_id, message.Applicant)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                jsonResponse = fmt.Sprintf("{ \"marked_read\" : %d }\n",marked)
+            }
+        case "unread_messages": // count unread messages
+            counts, err := store.GetUnreadCounts(user.Email)
+            if err != nil {
+                jsonResponse = jsonError(err)
+            } else {
+                resp, _ := json.Marshal(counts)
+                jsonResponse = string(resp)
+            }
     }
     return jsonResponse
 }
 
+// Wrap one page of a listing with the total count and next page offset
+// and return it as JSON. The warning is included if nothing matched
+func pageResponse(results interface{}, returned int, total int, warning string) string {
+    result := data.New_result_page(results, returned, total, page)
+    if total == 0 {
+        result.Warning = warning
+    }
+    resp, err := json.Marshal(result)
+    if err != nil {
+        return jsonError(err)
+    }
+    return string(resp)
+}
+
 // Output an error message to the terminal
 // in JSON format
-func jsonErrorOutput(msg string ) {
-    fmt.Printf("{ \"error\" : \"%s\" }\n", msg)
+func jsonErrorOutput(err error) {
+    fmt.Println(jsonError(err))
+}
+
+// Return the JSON error response for an error, the same
+// shape that the REST API uses, including the HTTP status
+func jsonError(err error) string {
+    resp, _ := json.Marshal(data.New_error_response(err))
+    return string(resp)
 }
 
 // Get the current process ID and write to a file in
@@ -0,0 +1,388 @@
+package main
+// Regression tests for the command line dispatch path
+// Values containing quotes, LIKE wildcards and SQL injection payloads
+// must be stored and searched as plain data
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+    "github.com/segoldin/JobWizard/job_wizard/data"
+    "github.com/segoldin/JobWizard/job_wizard/helper"
+)
+
+// Create a fresh database by running every migration and point dbaccess at it
+// through the environment, which takes precedence over .env_jobwizard
+func TestMain(m *testing.M) {
+    dir, err := os.MkdirTemp("", "jobwizard_main_test")
+    if err != nil {
+        panic(err)
+    }
+    testdb := filepath.Join(dir, "jobwizard_test_db")
+    os.Setenv("JOBWIZARD_DB_NAME", testdb)
+    code := m.Run()
+    os.RemoveAll(dir)
+    os.Exit(code)
+}
+
+// Validate and dispatch a task the same way commandLineFunction does
+// The caller fills in the argument structs before calling
+func runTask(t *testing.T, task_name string) string {
+    t.Helper()
+    valid, err := helper.ValidateTaskArgs(task_name, &user, &job, &filter, &submission, &change, &job_changes, &org, &member, &saved, &hook, &notification_changes, &message, &page)
+    if !valid {
+        t.Fatalf("task %s failed validation: %v", task_name, err)
+    }
+    return dispatch(helper.TaskName(task_name))
+}
+
+// clear all command line argument structs between tasks
+func resetArgs() {
+    user = data.User_info{}
+    job = data.Job_info{Is_open: true}
+    filter = data.Search_criteria{}
+    submission = data.Submission{}
+    change = data.Status_change{}
+    job_changes = data.Job_changes{}
+    org = data.Organization{}
+    member = data.Member_change{}
+    saved = data.Saved_search{}
+    hook = data.Webhook{}
+    notification_changes = data.Notification_changes{}
+    message = data.Message{}
+    mine = false
+    page = data.Page_request{}
+}
+
+func TestDispatchWithQuotesAndPayloads(t *testing.T) {
+    resetArgs()
+    bachelors := 2
+    user = data.User_info{Email: "cli.owner@example.com", First: "Cli", Last: "Owner", Phone: "0812345678", Education: &bachelors, Password: "it's a secret"}
+    if resp := runTask(t, "register"); strings.Contains(resp, "error") {
+        t.Fatalf("register failed: %s", resp)
+    }
+
+    resetArgs()
+    job.Creator = "cli.owner@example.com"
+    job.Title = "Director's Assistant"
+    job.Description = "Handles 100% of the director's \"urgent\" mail"
+    resp := runTask(t, "create")
+    var created map[string]string
+    if err := json.Unmarshal([]byte(resp), &created); err != nil || created["job_id"] == "" {
+        t.Fatalf("create returned %s", resp)
+    }
+    job_id := created["job_id"]
+
+    resetArgs()
+    job.Creator = "cli.owner@example.com"
+    job.Title = "snake_case Wrangler"
+    job.Description = "Decoy for wildcard searches"
+    runTask(t, "create")
+
+    resetArgs()
+    user.Email = "cli.owner@example.com"
+    job.Job_id = job_id
+    resp = runTask(t, "detail")
+    var detail data.Job_info
+    if err := json.Unmarshal([]byte(resp), &detail); err != nil {
+        t.Fatalf("detail returned %s", resp)
+    }
+    if detail.Title != "Director's Assistant" {
+        t.Errorf("expected title with apostrophe, got %q", detail.Title)
+    }
+
+    searches := map[string]int{
+        "Director's":             1,
+        "snake_case":             1,
+        "_":                      1,
+        "%":                      0,
+        "' OR '1'='1":            0,
+        "x'; DROP TABLE job; --": 0,
+    }
+    for keyword, expected := range searches {
+        resetArgs()
+        user.Email = "cli.owner@example.com"
+        filter.Keyword = keyword
+        resp = runTask(t, "search")
+        var result struct{ Total int; Results []data.Job_summary }
+        json.Unmarshal([]byte(resp), &result)
+        if len(result.Results) != expected || result.Total != expected {
+            t.Errorf("search for %q: expected %d results, got %s", keyword, expected, resp)
+        }
+    }
+
+    resetArgs()
+    job.Creator = "cli.owner@example.com"
+    job.Job_id = job_id
+    job.Title = "O'Brien's Deputy"
+    job_changes.Title = &job.Title
+    resp = runTask(t, "modify")
+    if !strings.Contains(resp, "modified_job_id") {
+        t.Fatalf("modify returned %s", resp)
+    }
+    resetArgs()
+    job.Creator = "cli.owner@example.com"
+    resp = runTask(t, "offered")
+    if !strings.Contains(resp, "O'Brien's Deputy") {
+        t.Errorf("offered list should include modified title, got %s", resp)
+    }
+}
+
+// Errors from dispatch have the same shape as REST API errors,
+// and messages containing quotes are still valid JSON
+func TestDispatchErrorShape(t *testing.T) {
+    resetArgs()
+    user = data.User_info{Email: "cli.errors@example.com", First: "Cli", Last: "Errors", Phone: "0812345678", Password: "password123"}
+    runTask(t, "register")
+    resp := runTask(t, "register")
+    var response data.Error_response
+    if err := json.Unmarshal([]byte(resp), &response); err != nil {
+        t.Fatalf("error response is not JSON: %s", resp)
+    }
+    if response.Code != data.CodeConflict || response.Status != 409 {
+        t.Errorf("duplicate register should be a conflict, got %s", resp)
+    }
+
+    resetArgs()
+    user.Email = "cli.errors@example.com"
+    job.Job_id = "99999"
+    resp = runTask(t, "detail")
+    json.Unmarshal([]byte(resp), &response)
+    if response.Code != data.CodeNotFound || response.Status != 404 {
+        t.Errorf("unknown job should be not found, got %s", resp)
+    }
+
+    resetArgs()
+    job.Creator = "cli.errors@example.com"
+    job.Title = "Untitled \"draft\""
+    valid, err := helper.ValidateTaskArgs("create", &user, &job, &filter, &submission, &change, &job_changes, &org, &member, &saved, &hook, &notification_changes, &message, &page)
+    if valid {
+        t.Fatalf("create without a description should not validate")
+    }
+    resp = jsonError(err)
+    response = data.Error_response{}
+    if json.Unmarshal([]byte(resp), &response) != nil || response.Code != data.CodeValidation ||
+       response.Field != "description" || response.Status != 422 {
+        t.Errorf("missing description should be a validation error, got %s", resp)
+    }
+}
+
+// A date range includes whole days in the display time zone,
+// wherever the server is
+func TestPostedDateRange(t *testing.T) {
+    resetArgs()
+    user = data.User_info{Email: "cli.dates@example.com", First: "Cli", Last: "Dates", Phone: "0812345678", Password: "password123"}
+    runTask(t, "register")
+    resetArgs()
+    job.Creator = "cli.dates@example.com"
+    job.Title = "Timekeeper"
+    job.Description = "Keeps the clocks right in every time zone"
+    runTask(t, "create")
+
+    for _, zone := range []string{"Asia/Bangkok", "America/New_York", "Pacific/Kiritimati"} {
+        if err := data.SetDisplayZone(zone); err != nil {
+            t.Fatalf("SetDisplayZone(%s) failed: %v", zone, err)
+        }
+        today := time.Now().In(data.DisplayZone())
+        yesterday := today.AddDate(0, 0, -1).Format(data.DateFormat)
+        tomorrow := today.AddDate(0, 0, 1).Format(data.DateFormat)
+        ranges := []struct {
+            from     string
+            to       string
+            expected bool
+        }{
+            {today.Format(data.DateFormat), today.Format(data.DateFormat), true},
+            {yesterday, yesterday, false},
+            {tomorrow, "", false},
+            {"", yesterday, false},
+            {today.Add(-time.Minute).Format(time.RFC3339), "", true},
+        }
+        for _, r := range ranges {
+            resetArgs()
+            user.Email = "cli.dates@example.com"
+            filter = data.Search_criteria{Keyword: "Timekeeper", Posted_from: r.from, Posted_to: r.to}
+            var result data.Result_page
+            resp := runTask(t, "search")
+            json.Unmarshal([]byte(resp), &result)
+            if (result.Total > 0) != r.expected {
+                t.Errorf("%s: posted from %q to %q found %d jobs: %s", zone, r.from, r.to, result.Total, resp)
+            }
+        }
+    }
+    data.SetDisplayZone("")
+}
+
+func TestSavedSearchTasks(t *testing.T) {
+    resetArgs()
+    user = data.User_info{Email: "cli.saver@example.com", First: "Cli", Last: "Saver", Phone: "0812345678", Password: "password123"}
+    runTask(t, "register")
+    resetArgs()
+    user.Email = "cli.saver@example.com"
+    org.Name = "Gardening jobs"
+    filter.Keyword = "Gardener"
+    job.Salary = 20000
+    resp := runTask(t, "save_search")
+    var created map[string]string
+    if err := json.Unmarshal([]byte(resp), &created); err != nil || created["search_id"] == "" {
+        t.Fatalf("save_search returned %s", resp)
+    }
+
+    resetArgs()
+    job = data.Job_info{Creator: "cli.saver@example.com", Title: "Gardener", Description: "Waters the plants", Salary: 25000}
+    runTask(t, "create")
+    resetArgs()
+    user.Email = "cli.saver@example.com"
+    var alerts data.Search_alerts
+    resp = runTask(t, "search_alerts")
+    json.Unmarshal([]byte(resp), &alerts)
+    if alerts.Total_new != 1 || len(alerts.Alerts) != 1 || alerts.Alerts[0].Criteria.Salary != 20000 {
+        t.Errorf("expected one new match for the saved search, got %s", resp)
+    }
+
+    resetArgs()
+    user.Email = "cli.saver@example.com"
+    saved.Search_id = created["search_id"]
+    var result data.Result_page
+    resp = runTask(t, "run_search")
+    json.Unmarshal([]byte(resp), &result)
+    if result.Total != 1 {
+        t.Errorf("expected the saved search to find the job, got %s", resp)
+    }
+    resetArgs()
+    user.Email = "cli.saver@example.com"
+    resp = runTask(t, "search_alerts")
+    if !strings.Contains(resp, "\"total_new\":0") {
+        t.Errorf("running the search should clear its new matches, got %s", resp)
+    }
+}
+
+func TestBookmarkTasks(t *testing.T) {
+    resetArgs()
+    user = data.User_info{Email: "cli.bookmarker@example.com", First: "Cli", Last: "Bookmarker", Phone: "0812345678", Password: "password123"}
+    runTask(t, "register")
+    resetArgs()
+    job = data.Job_info{Creator: "cli.bookmarker@example.com", Title: "Bookbinder", Description: "Binds books", Salary: 21000}
+    resp := runTask(t, "create")
+    var created map[string]string
+    json.Unmarshal([]byte(resp), &created)
+
+    resetArgs()
+    user.Email = "cli.bookmarker@example.com"
+    job.Job_id = created["job_id"]
+    resp = runTask(t, "bookmark")
+    if !strings.Contains(resp, "bookmarked_job_id") {
+        t.Fatalf("bookmark returned %s", resp)
+    }
+    resetArgs()
+    user.Email = "cli.bookmarker@example.com"
+    var result data.Result_page
+    resp = runTask(t, "bookmarks")
+    json.Unmarshal([]byte(resp), &result)
+    if result.Total != 1 || !strings.Contains(resp, "Bookbinder") {
+        t.Errorf("expected the bookmarked job, got %s", resp)
+    }
+    resetArgs()
+    user.Email = "cli.bookmarker@example.com"
+    job.Job_id = created["job_id"]
+    runTask(t, "unbookmark")
+    resetArgs()
+    user.Email = "cli.bookmarker@example.com"
+    resp = runTask(t, "bookmarks")
+    if !strings.Contains(resp, "No bookmarked jobs") {
+        t.Errorf("expected no bookmarks left, got %s", resp)
+    }
+}
+
+func TestNotificationTasks(t *testing.T) {
+    resetArgs()
+    user = data.User_info{Email: "cli.notified@example.com", First: "Cli", Last: "Notified", Phone: "0812345678", Password: "password123"}
+    runTask(t, "register")
+
+    // nothing to change
+    resetArgs()
+    user.Email = "cli.notified@example.com"
+    valid, err := helper.ValidateTaskArgs("set_notifications", &user, &job, &filter, &submission, &change, &job_changes, &org, &member, &saved, &hook, &notification_changes, &message, &page)
+    if valid || data.ErrorCode(err) != data.CodeValidation {
+        t.Errorf("set_notifications without changes should fail validation, got %v", err)
+    }
+
+    resetArgs()
+    user.Email = "cli.notified@example.com"
+    thai := "TH"
+    off := false
+    notification_changes = data.Notification_changes{Language: &thai, Rejected: &off}
+    resp := runTask(t, "set_notifications")
+    var prefs data.Notification_preferences
+    json.Unmarshal([]byte(resp), &prefs)
+    if prefs.Language != data.LanguageThai || prefs.Rejected || !prefs.Hired {
+        t.Fatalf("set_notifications returned %s", resp)
+    }
+    resetArgs()
+    user.Email = "cli.notified@example.com"
+    resp = runTask(t, "notifications")
+    if !strings.Contains(resp, `"language":"th"`) || !strings.Contains(resp, `"rejected":false`) {
+        t.Errorf("expected the changed preferences, got %s", resp)
+    }
+}
+
+func TestMessageTasks(t *testing.T) {
+    resetArgs()
+    user = data.User_info{Email: "cli.employer@example.com", First: "Cli", Last: "Employer", Phone: "0812345678", Password: "password123"}
+    runTask(t, "register")
+    resetArgs()
+    user = data.User_info{Email: "cli.candidate@example.com", First: "Cli", Last: "Candidate", Phone: "0812345678", Password: "password123"}
+    runTask(t, "register")
+    resetArgs()
+    job = data.Job_info{Creator: "cli.employer@example.com", Title: "Glassblower", Description: "Blows glass", Salary: 23000}
+    resp := runTask(t, "create")
+    var created map[string]string
+    json.Unmarshal([]byte(resp), &created)
+    resetArgs()
+    user.Email = "cli.candidate@example.com"
+    job.Job_id = created["job_id"]
+    runTask(t, "submit")
+
+    resetArgs()
+    user.Email = "cli.employer@example.com"
+    job.Job_id = created["job_id"]
+    message = data.Message{Applicant: "CLI.Candidate@example.com", Body: "  Can you come in on \"Monday\"?  "}
+    resp = runTask(t, "send_message")
+    var sent data.Message
+    json.Unmarshal([]byte(resp), &sent)
+    if sent.Message_id == "" || sent.Applicant != "cli.candidate@example.com" || sent.Body != `Can you come in on "Monday"?` {
+        t.Fatalf("send_message returned %s", resp)
+    }
+    resetArgs()
+    user.Email = "cli.candidate@example.com"
+    resp = runTask(t, "unread_messages")
+    if !strings.Contains(resp, `"total":1`) || !strings.Contains(resp, "Glassblower") {
+        t.Errorf("expected one unread message, got %s", resp)
+    }
+    resetArgs()
+    user.Email = "cli.candidate@example.com"
+    job.Job_id = created["job_id"]
+    resp = runTask(t, "messages")
+    if !strings.Contains(resp, `"total":1`) || !strings.Contains(resp, "Monday") {
+        t.Errorf("expected the message in the thread, got %s", resp)
+    }
+    resetArgs()
+    user.Email = "cli.candidate@example.com"
+    job.Job_id = created["job_id"]
+    resp = runTask(t, "read_messages")
+    if !strings.Contains(resp, `"marked_read" : 1`) {
+        t.Errorf("expected one message marked read, got %s", resp)
+    }
+
+    // a blank message fails validation
+    resetArgs()
+    user.Email = "cli.candidate@example.com"
+    job.Job_id = created["job_id"]
+    message.Body = "   "
+    valid, err := helper.ValidateTaskArgs("send_message", &user, &job, &filter, &submission, &change, &job_changes, &org, &member, &saved, &hook, &notification_changes, &message, &page)
+    if valid || data.ErrorCode(err) != data.CodeValidation {
+        t.Errorf("a blank message should fail validation, got %v", err)
+    }
+}
@@ -0,0 +1,201 @@
+package notify
+// This module sends the notification emails queued by dbaccess, through
+// a Sender chosen by the environment: an SMTP server for real mail, or,
+// for development, a file or the log
+// An email that cannot be sent is retried later (see dbaccess/notification.go)
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"os"
+	"sync"
+	"time"
+	"github.com/segoldin/JobWizard/job_wizard/dbaccess"
+)
+
+// Sender address used if JOBWIZARD_MAIL_FROM is not set
+const defaultFrom = "jobwizard@localhost"
+
+// Number of due notifications fetched from the store at a time
+const batchSize = 50
+
+// Longest wait for an SMTP server, for the whole conversation
+const smtpTimeout = 30 * time.Second
+
+// One plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Anything that can send an email
+type Sender interface {
+	Send(message Message) error
+}
+
+// Sends mail through an SMTP server, using STARTTLS if the server
+// offers it. Username is "" for a server that needs no login
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Instead of sending mail, appends each message to the file Path, or
+// writes it to the log if Path is "". For development and testing
+type FileSender struct {
+	Path  string
+	From  string
+	mutex sync.Mutex
+}
+
+// Return the message as it is sent over SMTP, with the headers, the
+// subject encoded for non-ASCII text and the body quoted-printable
+func (message Message) Format(from string) []byte {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "From: %s\r\n", from)
+	fmt.Fprintf(&buffer, "To: %s\r\n", message.To)
+	fmt.Fprintf(&buffer, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buffer, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+	buffer.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buffer.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	writer := quotedprintable.NewWriter(&buffer)
+	writer.Write(bytes.ReplaceAll([]byte(message.Body), []byte("\n"), []byte("\r\n")))
+	writer.Close()
+	return buffer.Bytes()
+}
+
+func (sender *SMTPSender) Send(message Message) (err error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(sender.Host, sender.Port), smtpTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	client, err := smtp.NewClient(conn, sender.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: sender.Host})
+		if err != nil {
+			return err
+		}
+	}
+	if sender.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", sender.Username, sender.Password, sender.Host))
+		if err != nil {
+			return err
+		}
+	}
+	err = client.Mail(sender.From)
+	if err == nil {
+		err = client.Rcpt(message.To)
+	}
+	if err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(message.Format(sender.From))
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (sender *FileSender) Send(message Message) (err error) {
+	text := fmt.Sprintf("From: %s\nTo: %s\nSubject: %s\nDate: %s\n\n%s\n", sender.From, message.To, message.Subject,
+		time.Now().Format(time.RFC1123Z), message.Body)
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+	if sender.Path == "" {
+		log.Print("Email not sent\n" + text)
+		return nil
+	}
+	file, err := os.OpenFile(sender.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(text + "--------\n\n")
+	if closeerr := file.Close(); err == nil {
+		err = closeerr
+	}
+	return err
+}
+
+// Return the sender named by JOBWIZARD_MAIL_SENDER: "smtp" sends through
+// JOBWIZARD_SMTP_HOST and JOBWIZARD_SMTP_PORT (default 25), logging in as
+// JOBWIZARD_SMTP_USER with JOBWIZARD_SMTP_PASSWORD if a user is given;
+// "file" appends to JOBWIZARD_MAIL_FILE; anything else, or "file" without
+// a file name, writes to the log
+// Mail comes from JOBWIZARD_MAIL_FROM
+func SenderFromEnv() Sender {
+	from := os.Getenv("JOBWIZARD_MAIL_FROM")
+	if from == "" {
+		from = defaultFrom
+	}
+	switch os.Getenv("JOBWIZARD_MAIL_SENDER") {
+		case "smtp":
+			port := os.Getenv("JOBWIZARD_SMTP_PORT")
+			if port == "" {
+				port = "25"
+			}
+			return &SMTPSender{Host: os.Getenv("JOBWIZARD_SMTP_HOST"), Port: port, Username: os.Getenv("JOBWIZARD_SMTP_USER"),
+			                   Password: os.Getenv("JOBWIZARD_SMTP_PASSWORD"), From: from}
+		case "file":
+			return &FileSender{Path: os.Getenv("JOBWIZARD_MAIL_FILE"), From: from}
+	}
+	return &FileSender{From: from}
+}
+
+// Send every notification that is due and record the outcome of each
+// attempt. Returns the number sent and the number that will be retried
+// or have now failed for good
+func DeliverDue(store dbaccess.Store, sender Sender) (sent int, failed int, err error) {
+	for {
+		notifications, err := store.DueNotifications(batchSize)
+		if err != nil {
+			return sent, failed, err
+		}
+		for _, notification := range notifications {
+			message, senderr := Compose(notification)
+			if senderr == nil {
+				senderr = sender.Send(message)
+			}
+			errmsg := ""
+			if senderr != nil {
+				errmsg = senderr.Error()
+			}
+			err = store.RecordNotificationAttempt(notification.Notification_id, senderr == nil, errmsg)
+			if err != nil {
+				return sent, failed, err
+			}
+			if senderr == nil {
+				sent++
+			} else {
+				failed++
+			}
+		}
+		// attempts that fail are put off, so a full batch means there may be more
+		if len(notifications) < batchSize {
+			return sent, failed, nil
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package notify
+// Tests for writing and sending notification emails, against a local
+// fake SMTP server
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"github.com/segoldin/JobWizard/job_wizard/data"
+	"github.com/segoldin/JobWizard/job_wizard/dbaccess"
+)
+
+// One message received by the fake server
+type receivedMail struct {
+	from string
+	to   string
+	data string
+}
+
+// An SMTP server that accepts every message, or turns away every
+// recipient if reject is set
+type fakeServer struct {
+	listener net.Listener
+	reject   bool
+	mutex    sync.Mutex
+	messages []receivedMail
+}
+
+func newFakeServer(t *testing.T, reject bool) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	server := &fakeServer{listener: listener, reject: reject}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+// Hold one SMTP conversation
+func (server *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line + "\r\n") }
+	reply("220 localhost fake ESMTP")
+	var message receivedMail
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				message.from = strings.Trim(line[10:], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				if server.reject {
+					reply("550 No such user")
+				} else {
+					message.to = strings.Trim(line[8:], "<>")
+					reply("250 OK")
+				}
+			case command == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var lines []string
+				for {
+					dataline, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if dataline == ".\r\n" {
+						break
+					}
+					lines = append(lines, strings.TrimPrefix(dataline, "."))
+				}
+				message.data = strings.Join(lines, "")
+				server.mutex.Lock()
+				server.messages = append(server.messages, message)
+				server.mutex.Unlock()
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+		}
+	}
+}
+
+// Return the messages received so far
+func (server *fakeServer) received() []receivedMail {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]receivedMail{}, server.messages...)
+}
+
+func (server *fakeServer) sender() *SMTPSender {
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	return &SMTPSender{Host: host, Port: port, From: "jobs@example.com"}
+}
+
+// Return the decoded subject and body of a message received by the server
+func readMessage(t *testing.T, text string) (subject string, body string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("cannot parse message: %v", err)
+	}
+	subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("cannot decode subject: %v", err)
+	}
+	content, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatalf("cannot decode body: %v", err)
+	}
+	return subject, string(content)
+}
+
+// A store with an employer, who has a job, and an applicant who reads
+// their email in Thai
+func newTestStore(t *testing.T) (store dbaccess.Store, job_id string) {
+	t.Helper()
+	store = dbaccess.NewMemoryStore()
+	store.RegisterUser("boss@example.com", "Somchai", "Boss", "0812345678", 2, "password123", nil, nil, nil)
+	store.RegisterUser("seeker@example.com", "Malee", "Seeker", "0812345679", 2, "password123", nil, nil, nil)
+	thai := data.LanguageThai
+	store.SetNotificationPreferences(data.Notification_changes{User_email: "seeker@example.com", Language: &thai})
+	job_id, err := store.CreateJob(data.Job_info{Creator: "boss@example.com", Title: "Locksmith", Description: "Opens locks"})
+	if err != nil {
+		t.Fatalf("CreateJob failed: %v", err)
+	}
+	return store, job_id
+}
+
+func TestDeliverDue(t *testing.T) {
+	server := newFakeServer(t, false)
+	store, job_id := newTestStore(t)
+	if _, _, err := store.SubmitJobApplication("seeker@example.com", job_id, "", nil); err != nil {
+		t.Fatalf("SubmitJobApplication failed: %v", err)
+	}
+	store.UpdateApplicationStatus("boss@example.com", job_id, "seeker@example.com", data.StatusRejected)
+
+	sent, failed, err := DeliverDue(store, server.sender())
+	messages := server.received()
+	if err != nil || sent != 2 || failed != 0 || len(messages) != 2 {
+		t.Fatalf("expected two emails, got %d, %d (%v)", sent, failed, err)
+	}
+	first := messages[0]
+	subject, body := readMessage(t, first.data)
+	if first.from != "jobs@example.com" || first.to != "boss@example.com" || subject != "New application for Locksmith" {
+		t.Errorf("unexpected email to the employer: %+v", first)
+	}
+	if !strings.Contains(body, "Hello Somchai") || !strings.Contains(body, "Malee Seeker (seeker@example.com)") ||
+		!strings.Contains(body, job_id) {
+		t.Errorf("unexpected body %q", body)
+	}
+	second := messages[1]
+	subject, body = readMessage(t, second.data)
+	if second.to != "seeker@example.com" || subject != "ผลการสมัครงานตำแหน่ง Locksmith" || !strings.Contains(body, "สวัสดีคุณMalee") {
+		t.Errorf("expected the rejection in Thai, got %q, %q", subject, body)
+	}
+
+	// nothing is sent twice
+	if sent, _, _ = DeliverDue(store, server.sender()); sent != 0 || len(server.received()) != 2 {
+		t.Errorf("a sent email should not be sent again")
+	}
+}
+
+func TestSendRetried(t *testing.T) {
+	server := newFakeServer(t, true)
+	store, job_id := newTestStore(t)
+	store.SubmitJobApplication("seeker@example.com", job_id, "", nil)
+
+	sent, failed, err := DeliverDue(store, server.sender())
+	if err != nil || sent != 0 || failed != 1 {
+		t.Fatalf("expected one failed attempt, got %d, %d (%v)", sent, failed, err)
+	}
+	// the retry is not due yet
+	if _, failed, _ = DeliverDue(store, server.sender()); failed != 0 {
+		t.Errorf("the email should wait before it is tried again")
+	}
+	notifications, _ := store.DueNotifications(10)
+	if len(notifications) != 0 {
+		t.Errorf("expected nothing due, got %+v", notifications)
+	}
+}
+
+func TestFileSender(t *testing.T) {
+	store, job_id := newTestStore(t)
+	store.SubmitJobApplication("seeker@example.com", job_id, "", nil)
+	for _, status := range []string{data.StatusReviewed, data.StatusShortlisted, data.StatusInterviewing, data.StatusOffered, data.StatusHired} {
+		store.UpdateApplicationStatus("boss@example.com", job_id, "seeker@example.com", status)
+	}
+	path := filepath.Join(t.TempDir(), "mail.txt")
+	sent, _, err := DeliverDue(store, &FileSender{Path: path, From: "jobs@example.com"})
+	if err != nil || sent != 2 {
+		t.Fatalf("expected two emails, got %d (%v)", sent, err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read the file: %v", err)
+	}
+	text := string(content)
+	if !strings.Contains(text, "To: boss@example.com") || !strings.Contains(text, "Subject: คุณได้รับการคัดเลือกสำหรับตำแหน่ง Locksmith") {
+		t.Errorf("expected both emails in the file, got %s", text)
+	}
+}
+
+func TestCompose(t *testing.T) {
+	notification := data.Notification{Recipient: "seeker@example.com", Name: "Malee", Kind: data.NotifyHired,
+		Language: "fr", Job_id: "00001", Title: "Locksmith"}
+	message, err := Compose(notification)
+	if err != nil || message.To != "seeker@example.com" || message.Subject != "You have been hired for Locksmith" {
+		t.Errorf("a language without templates should use English, got %+v (%v)", message, err)
+	}
+	notification.Kind = "promoted"
+	if _, err = Compose(notification); err == nil {
+		t.Errorf("an unknown kind should not be written")
+	}
+}
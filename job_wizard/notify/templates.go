@@ -0,0 +1,79 @@
+package notify
+// Templates for the notification emails, in English and Thai
+// Each is executed with the data.Notification being sent, so it can use
+// .Name (the recipient's first name), .Title, .Job_id, .Applicant and
+// .Applicant_name
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"github.com/segoldin/JobWizard/job_wizard/data"
+)
+
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func newTemplate(subject string, body string) messageTemplate {
+	return messageTemplate{subject: template.Must(template.New("subject").Parse(subject)),
+	                       body: template.Must(template.New("body").Parse(body))}
+}
+
+// Templates by language, then by kind of notification
+var templates = map[string]map[string]messageTemplate{
+	data.LanguageEnglish: {
+		data.NotifyApplicationReceived: newTemplate("New application for {{.Title}}",
+			"Hello {{.Name}},\n\n{{.Applicant_name}} ({{.Applicant}}) has applied for your job {{.Title}} (job {{.Job_id}}).\n\n" +
+			"You can review the application with the other candidates for the job in JobWizard.\n"),
+		data.NotifyHired: newTemplate("You have been hired for {{.Title}}",
+			"Hello {{.Name}},\n\nCongratulations! You have been hired for the job {{.Title}} (job {{.Job_id}}).\n\n" +
+			"The employer will contact you about the next steps.\n"),
+		data.NotifyRejected: newTemplate("Your application for {{.Title}}",
+			"Hello {{.Name}},\n\nThank you for applying for the job {{.Title}} (job {{.Job_id}}).\n\n" +
+			"Unfortunately the employer has decided not to go ahead with your application. " +
+			"We wish you the best of luck with your search.\n"),
+	},
+	data.LanguageThai: {
+		data.NotifyApplicationReceived: newTemplate("มีผู้สมัครงานตำแหน่ง {{.Title}}",
+			"สวัสดีคุณ{{.Name}}\n\n{{.Applicant_name}} ({{.Applicant}}) ได้สมัครงานตำแหน่ง {{.Title}} (รหัสงาน {{.Job_id}}) ของคุณ\n\n" +
+			"คุณสามารถดูใบสมัครนี้พร้อมกับผู้สมัครคนอื่นของงานนี้ได้ใน JobWizard\n"),
+		data.NotifyHired: newTemplate("คุณได้รับการคัดเลือกสำหรับตำแหน่ง {{.Title}}",
+			"สวัสดีคุณ{{.Name}}\n\nขอแสดงความยินดี คุณได้รับการคัดเลือกให้ทำงานในตำแหน่ง {{.Title}} (รหัสงาน {{.Job_id}})\n\n" +
+			"นายจ้างจะติดต่อคุณเกี่ยวกับขั้นตอนต่อไป\n"),
+		data.NotifyRejected: newTemplate("ผลการสมัครงานตำแหน่ง {{.Title}}",
+			"สวัสดีคุณ{{.Name}}\n\nขอขอบคุณที่สมัครงานตำแหน่ง {{.Title}} (รหัสงาน {{.Job_id}})\n\n" +
+			"ขออภัย นายจ้างได้ตัดสินใจไม่รับใบสมัครของคุณในครั้งนี้ ขอให้คุณโชคดีในการหางาน\n"),
+	},
+}
+
+// Added to the end of every message
+var footers = map[string]string{
+	data.LanguageEnglish: "\n--\nTo stop these emails, change your notification preferences in JobWizard.\n",
+	data.LanguageThai:    "\n--\nหากไม่ต้องการรับอีเมลเหล่านี้ สามารถเปลี่ยนการตั้งค่าการแจ้งเตือนได้ใน JobWizard\n",
+}
+
+// Write the email for a notification, in the recipient's language
+// A language with no templates falls back to English
+func Compose(notification data.Notification) (message Message, err error) {
+	language := notification.Language
+	if _, found := templates[language]; !found {
+		language = data.LanguageEnglish
+	}
+	texts, found := templates[language][notification.Kind]
+	if !found {
+		return message, fmt.Errorf("No template for notification kind %s", notification.Kind)
+	}
+	var subject bytes.Buffer
+	var body bytes.Buffer
+	err = texts.subject.Execute(&subject, notification)
+	if err == nil {
+		err = texts.body.Execute(&body, notification)
+	}
+	if err != nil {
+		return message, err
+	}
+	body.WriteString(footers[language])
+	return Message{To: notification.Recipient, Subject: subject.String(), Body: body.String()}, nil
+}
@@ -0,0 +1,180 @@
+package webhook
+// This module sends the webhook deliveries queued by dbaccess. Each one
+// is POSTed as JSON with a signature, the HMAC-SHA256 of the body using
+// the webhook's secret, so receivers can check that it came from us
+// A 2xx response means the delivery arrived; anything else, including a
+// redirect or no response, is retried later (see dbaccess/webhook.go)
+// Deliveries are only sent to public addresses, so a webhook cannot be
+// used to reach the server's own network
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+	"github.com/segoldin/JobWizard/job_wizard/data"
+	"github.com/segoldin/JobWizard/job_wizard/dbaccess"
+)
+
+// Headers sent with each delivery
+const (
+	SignatureHeader = "X-JobWizard-Signature"   // sha256= and the hex HMAC of the body
+	EventHeader     = "X-JobWizard-Event"
+	DeliveryHeader  = "X-JobWizard-Delivery"
+)
+
+// Number of due deliveries fetched from the store at a time
+const batchSize = 50
+
+// Set by tests, whose receivers listen on loopback
+var allowPrivate = false
+
+// Special-purpose ranges that are not covered by the net.IP methods but
+// are often routed to internal hosts
+var reservedNets = parseNets(
+	"0.0.0.0/8",        // "this network"
+	"100.64.0.0/10",    // shared address space, carrier-grade NAT
+	"192.0.0.0/24",     // IETF protocol assignments
+	"192.0.2.0/24",     // documentation
+	"198.18.0.0/15",    // benchmarking
+	"198.51.100.0/24",  // documentation
+	"203.0.113.0/24",   // documentation
+	"240.0.0.0/4",      // reserved, and the broadcast address
+	"64:ff9b::/96",     // NAT64, which reaches any IPv4 address
+	"64:ff9b:1::/48",   // local-use NAT64
+	"100::/64",         // discard
+	"2001:db8::/32",    // documentation
+)
+
+// Parse a list of CIDR prefixes, which must be valid
+func parseNets(prefixes ...string) (nets []*net.IPNet) {
+	for _, prefix := range prefixes {
+		_, ipnet, err := net.ParseCIDR(prefix)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets
+}
+
+// Refuse to connect to an address that is not public. This runs after
+// the host is resolved, for each connection, so a name that resolves to
+// a private address is refused too
+func checkAddress(network string, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("Webhook address %s is not an IP address", host)
+	}
+	if !allowPrivate && !isPublic(ip) {
+		return fmt.Errorf("Webhook address %s is not public", host)
+	}
+	return nil
+}
+
+// Return true unless ip is loopback, private, link-local, multicast,
+// unspecified or in one of reservedNets. IPv4 addresses written as IPv6
+// (::ffff:10.0.0.1) are checked as IPv4
+func isPublic(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, ipnet := range reservedNets {
+		if ipnet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Receivers must answer quickly; redirects are not followed. No proxy is
+// used, since checkAddress would only see the proxy's address
+var client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: checkAddress,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Return the signature header value for a body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post one delivery. Returns the response code, 0 if there was no
+// response, and an error message, which is "" if it was delivered
+func send(delivery data.Webhook_delivery) (code int, message string) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "JobWizard-Webhook")
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, body))
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.Delivery_id)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, "Response status " + resp.Status
+	}
+	return resp.StatusCode, ""
+}
+
+// Send every delivery that is due and record the outcome of each attempt
+// Returns the number delivered and the number that will be retried or
+// have now failed for good
+func DeliverDue(store dbaccess.Store) (delivered int, failed int, err error) {
+	for {
+		deliveries, err := store.DueWebhookDeliveries(batchSize)
+		if err != nil {
+			return delivered, failed, err
+		}
+		for _, delivery := range deliveries {
+			code, message := send(delivery)
+			err = store.RecordWebhookAttempt(delivery.Delivery_id, message == "", code, message)
+			if err != nil {
+				return delivered, failed, err
+			}
+			if message == "" {
+				delivered++
+			} else {
+				failed++
+			}
+		}
+		// attempts that fail are put off, so a full batch means there may be more
+		if len(deliveries) < batchSize {
+			return delivered, failed, nil
+		}
+	}
+}
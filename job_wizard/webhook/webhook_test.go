@@ -0,0 +1,173 @@
+package webhook
+// Tests for sending deliveries, against a local HTTP server standing in
+// for the receiver
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"github.com/segoldin/JobWizard/job_wizard/data"
+	"github.com/segoldin/JobWizard/job_wizard/dbaccess"
+)
+
+// Let deliveries go to the local test receiver until the test ends
+func allowLoopback(t *testing.T) {
+	allowPrivate = true
+	t.Cleanup(func() { allowPrivate = false })
+}
+
+// A store with one user, who has a webhook for new jobs at url
+func newTestStore(t *testing.T, url string) (store dbaccess.Store, hook data.Webhook) {
+	t.Helper()
+	store = dbaccess.NewMemoryStore()
+	if err := store.RegisterUser("sender@example.com", "Test", "User", "0812345678", 2, "password123", nil, nil, nil); err != nil {
+		t.Fatalf("RegisterUser failed: %v", err)
+	}
+	hook, err := store.CreateWebhook(data.Webhook{Creator: "sender@example.com", Url: url, Events: []string{data.EventJobCreated}})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	return store, hook
+}
+
+func TestDeliverDue(t *testing.T) {
+	var received []*http.Request
+	var bodies [][]byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	allowLoopback(t)
+	store, hook := newTestStore(t, receiver.URL+"/hook")
+	job_id, _ := store.CreateJob(data.Job_info{Creator: "sender@example.com", Title: "Locksmith", Description: "Opens locks"})
+
+	delivered, failed, err := DeliverDue(store)
+	if err != nil || delivered != 1 || failed != 0 || len(received) != 1 {
+		t.Fatalf("expected one delivery, got %d, %d (%v)", delivered, failed, err)
+	}
+	req := received[0]
+	if req.Method != http.MethodPost || req.URL.Path != "/hook" || req.Header.Get(EventHeader) != data.EventJobCreated {
+		t.Errorf("unexpected request %s %s %v", req.Method, req.URL.Path, req.Header)
+	}
+	if req.Header.Get(SignatureHeader) != Sign(hook.Secret, bodies[0]) || req.Header.Get(DeliveryHeader) == "" {
+		t.Errorf("the signature should be the HMAC of the body, got %v", req.Header)
+	}
+	if Sign("another secret", bodies[0]) == Sign(hook.Secret, bodies[0]) {
+		t.Errorf("the signature should depend on the secret")
+	}
+	deliveries, _, _ := store.ListWebhookDeliveries("sender@example.com", hook.Webhook_id, data.Page_request{})
+	if len(deliveries) != 1 || deliveries[0].Status != data.DeliveryDelivered || deliveries[0].Response_code != http.StatusNoContent {
+		t.Errorf("expected a delivered delivery, got %+v", deliveries)
+	}
+	if string(bodies[0]) != deliveries[0].Payload || job_id == "" {
+		t.Errorf("the body should be the payload, got %s", bodies[0])
+	}
+
+	// nothing is sent twice
+	if delivered, _, _ = DeliverDue(store); delivered != 0 || len(received) != 1 {
+		t.Errorf("a delivered event should not be sent again")
+	}
+}
+
+func TestDeliveryRetried(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+	defer receiver.Close()
+	allowLoopback(t)
+	store, hook := newTestStore(t, receiver.URL)
+	store.CreateJob(data.Job_info{Creator: "sender@example.com", Title: "Locksmith", Description: "Opens locks"})
+
+	delivered, failed, err := DeliverDue(store)
+	if err != nil || delivered != 0 || failed != 1 {
+		t.Fatalf("expected one failed attempt, got %d, %d (%v)", delivered, failed, err)
+	}
+	deliveries, _, _ := store.ListWebhookDeliveries("sender@example.com", hook.Webhook_id, data.Page_request{})
+	if len(deliveries) != 1 || deliveries[0].Status != data.DeliveryPending || deliveries[0].Attempts != 1 ||
+		deliveries[0].Response_code != http.StatusFound || deliveries[0].Next_attempt == "" {
+		t.Errorf("a redirect should be retried later, got %+v", deliveries)
+	}
+	// the retry is not due yet
+	if _, failed, _ = DeliverDue(store); failed != 0 {
+		t.Errorf("the delivery should wait before it is tried again")
+	}
+
+	receiver.Close()
+	store.CreateJob(data.Job_info{Creator: "sender@example.com", Title: "Plumber", Description: "Fixes pipes"})
+	if _, failed, _ = DeliverDue(store); failed != 1 {
+		t.Errorf("a receiver that is down should be retried later, got %d", failed)
+	}
+}
+
+func TestPrivateAddressRefused(t *testing.T) {
+	var received int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	store, hook := newTestStore(t, receiver.URL)
+	store.CreateJob(data.Job_info{Creator: "sender@example.com", Title: "Locksmith", Description: "Opens locks"})
+
+	delivered, failed, err := DeliverDue(store)
+	if err != nil || delivered != 0 || failed != 1 || received != 0 {
+		t.Fatalf("a loopback receiver should not be sent anything, got %d, %d, %d (%v)", delivered, failed, received, err)
+	}
+	deliveries, _, _ := store.ListWebhookDeliveries("sender@example.com", hook.Webhook_id, data.Page_request{})
+	if len(deliveries) != 1 || deliveries[0].Response_code != 0 || deliveries[0].Last_error == "" {
+		t.Errorf("expected no response and an error, got %+v", deliveries)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	addresses := []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"100.128.0.1", true},
+		{"2606:2800:220:1::1", true},
+		{"127.0.0.1", false},
+		{"127.1.2.3", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"192.0.0.8", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"203.0.113.5", false},
+		{"240.0.0.1", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+		{"::", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"ff02::1", false},
+		{"64:ff9b::a00:1", false},
+		{"2001:db8::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"::ffff:100.64.0.1", false},
+		{"::ffff:198.18.0.1", false},
+		{"::ffff:0.0.0.1", false},
+		{"::ffff:93.184.216.34", true},
+	}
+	for _, test := range addresses {
+		if public := isPublic(net.ParseIP(test.address)); public != test.public {
+			t.Errorf("isPublic(%s) = %v, expected %v", test.address, public, test.public)
+		}
+	}
+}